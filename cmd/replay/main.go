@@ -0,0 +1,88 @@
+// Command replay sends captured legacy traffic back into a server instance
+//
+// Usage:
+//
+//	replay -file captures/legacy.jsonl -addr 127.0.0.1:80 [-client ID] [-conn N] [-timing] [-v]
+//
+// Each captured connection is replayed on a new TCP connection, chunk by chunk, and the
+// status line of the response is compared with the one recorded during the capture
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/capture"
+)
+
+func main() {
+	file := flag.String("file", "", "capture file to replay (required)")
+	addr := flag.String("addr", "127.0.0.1:80", "address of the server to replay into")
+	client := flag.String("client", "", "only replay connections of this client ID")
+	connID := flag.Uint64("conn", 0, "only replay the connection with this ID")
+	timing := flag.Bool("timing", false, "preserve the delays between chunks sent by the box")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout for each replayed connection")
+	verbose := flag.Bool("v", false, "print the full requests and responses")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	connections, err := capture.ReadFile(*file)
+	if err != nil {
+		log.Fatalf("Failed to read capture: %v", err)
+	}
+
+	replayed, mismatches, failures := 0, 0, 0
+	for _, conn := range connections {
+		if *client != "" && conn.ClientID != *client {
+			continue
+		}
+		if *connID != 0 && conn.ID != *connID {
+			continue
+		}
+		replayed++
+
+		response, err := capture.Replay(*addr, conn, capture.ReplayOptions{
+			Timeout:        *timeout,
+			PreserveTiming: *timing,
+		})
+		if err != nil {
+			failures++
+			fmt.Printf("conn %d (%s, client %q): replay failed: %v\n", conn.ID, conn.Remote, conn.ClientID, err)
+			continue
+		}
+
+		got, want := statusLine(response), statusLine(conn.Outbound())
+		result := "ok"
+		if got != want {
+			mismatches++
+			result = "MISMATCH"
+		}
+		fmt.Printf("conn %d (%s, client %q): sent %d bytes, got %q, recorded %q: %s\n",
+			conn.ID, conn.Remote, conn.ClientID, len(conn.Inbound()), got, want, result)
+
+		if *verbose {
+			fmt.Printf("--- request\n%s\n--- response\n%s\n--- recorded response\n%s\n", conn.Inbound(), response, conn.Outbound())
+		}
+	}
+
+	fmt.Printf("%d connections replayed, %d mismatches, %d failures\n", replayed, mismatches, failures)
+	if mismatches > 0 || failures > 0 {
+		os.Exit(1)
+	}
+}
+
+// statusLine returns the first line of a raw HTTP response
+func statusLine(response []byte) string {
+	if i := bytes.IndexByte(response, '\n'); i >= 0 {
+		response = response[:i]
+	}
+	return string(bytes.TrimRight(response, "\r"))
+}
@@ -1,18 +1,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/essensys-hub/essensys-server-backend/internal/api"
+	"github.com/essensys-hub/essensys-server-backend/internal/capture"
 	"github.com/essensys-hub/essensys-server-backend/internal/config"
 	"github.com/essensys-hub/essensys-server-backend/internal/core"
 	"github.com/essensys-hub/essensys-server-backend/internal/data"
+	"github.com/essensys-hub/essensys-server-backend/internal/logging"
+	"github.com/essensys-hub/essensys-server-backend/internal/metrics"
 	"github.com/essensys-hub/essensys-server-backend/internal/server"
 )
 
@@ -23,36 +27,80 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	// Apply logging level and format before anything else is logged
+	if err := logging.Configure(cfg.Logging.Level, cfg.Logging.Format); err != nil {
+		log.Fatalf("Failed to configure logging: %v", err)
+	}
+
 	// Log configuration
 	cfg.LogConfig()
 
 	// Initialize store
 	store := data.NewMemoryStore()
-	log.Println("Initialized in-memory data store")
+	logging.Infof("Initialized in-memory data store")
+
+	// Register tenants and their boxes
+	for _, tenant := range cfg.Tenants {
+		err := store.AddTenant(data.Tenant{
+			ID:      tenant.ID,
+			Name:    tenant.Name,
+			Clients: tenant.Clients,
+			Quotas: data.TenantQuotas{
+				MaxClients:        tenant.Quotas.MaxClients,
+				MaxPendingActions: tenant.Quotas.MaxPendingActions,
+			},
+		})
+		if err != nil {
+			log.Fatalf("Failed to register tenant %s: %v", tenant.ID, err)
+		}
+	}
+
+	// Expose per-client gauges computed from the store
+	data.RegisterMetrics(metrics.Default, store)
 
 	// Initialize services
 	actionService := core.NewActionService(store)
 	statusService := core.NewStatusService(store)
-	log.Println("Initialized action and status services")
+	logging.Infof("Initialized action and status services")
+
+	// Start presence monitor (marks silent boxes offline)
+	presenceMonitor := core.NewPresenceMonitor(store, cfg.Presence.OfflineTimeout, cfg.Presence.CheckInterval)
+	presenceMonitor.Start()
+	logging.Infof("Started presence monitor (offline after %v of silence)", cfg.Presence.OfflineTimeout)
+
+	// Start automation: tenant schedules send their scenes, presence events reach the tenant webhooks
+	automationRunner := core.NewAutomationRunner(store, actionService, core.DefaultScheduleInterval)
+	presenceMonitor.Subscribe(automationRunner.HandlePresence)
+	automationRunner.Start()
+	logging.Infof("Started automation (schedules checked every %v)", core.DefaultScheduleInterval)
 
 	// Initialize handler
 	handler := api.NewHandler(actionService, statusService, store)
 
 	// Setup router with middleware chain
-	router := api.NewRouter(handler, cfg.Auth.Clients, cfg.Auth.Enabled)
+	// With a separate API listener, port 80 only serves the firmware routes
+	var router http.Handler
+	if cfg.API.Enabled {
+		router = api.NewLegacyRouter(handler, cfg.Auth.Clients, cfg.Auth.Enabled)
+	} else {
+		router = api.NewRouterWithAdminTokens(handler, cfg.Auth.Clients, cfg.Auth.Enabled, cfg.AdminTokens())
+	}
 	if cfg.Auth.Enabled {
-		log.Println("Configured HTTP router with middleware chain (Recovery → Logging → BasicAuth)")
+		logging.Infof("Configured HTTP router with middleware chain (Recovery → Logging → BasicAuth)")
 	} else {
-		log.Println("Configured HTTP router with middleware chain (Recovery → Logging) - Authentication DISABLED")
+		logging.Infof("Configured HTTP router with middleware chain (Recovery → Logging) - Authentication DISABLED")
+	}
+	if len(cfg.Tenants) > 0 {
+		logging.Infof("Admin routes require a tenant admin token (%d tenants configured)", len(cfg.Tenants))
 	}
 
 	// Configure server address
 	addr := fmt.Sprintf(":%d", cfg.Server.Port)
 
 	// Log server startup
-	log.Printf("Server starting on %s", addr)
-	log.Printf("Health check available at: http://localhost%s/health", addr)
-	log.Printf("===========================================")
+	logging.Infof("Server starting on %s", addr)
+	logging.Infof("Health check available at: http://localhost%s/health", addr)
+	logging.Infof("===========================================")
 
 	// Channel to listen for errors from the server
 	serverErrors := make(chan error, 1)
@@ -63,22 +111,117 @@ func main() {
 		log.Fatalf("Failed to create listener: %v", err)
 	}
 
+	// Record the raw traffic of selected boxes when capture is enabled
+	var acceptListener net.Listener = listener
+	var captureRecorder *capture.Recorder
+	if cfg.Capture.Enabled {
+		captureRecorder, err = capture.NewRecorder(cfg.Capture.Path, cfg.Capture.MaxFileBytes, cfg.Capture.MaxFiles)
+		if err != nil {
+			log.Fatalf("Failed to open capture file: %v", err)
+		}
+		acceptListener = capture.NewListener(listener, captureRecorder, capture.NewFilter(cfg.Capture.Clients, cfg.Capture.IPs))
+		logging.Infof("Capturing raw traffic to %s", cfg.Capture.Path)
+	}
+
 	// Wrap with logging listener to see all TCP connections
-	loggingListener := server.NewLoggingListener(listener)
+	loggingListener := server.NewLoggingListener(acceptListener)
 
 	// Create legacy HTTP server that tolerates non-standard HTTP from BP_MQX_ETH clients
 	legacyServer := server.NewLegacyHTTPServer(router)
+	legacyServer.ReadTimeout = cfg.Server.ReadTimeout
+	legacyServer.WriteTimeout = cfg.Server.WriteTimeout
+	legacyServer.Limits = cfg.Server.Limits.ServerLimits()
+	legacyServer.Conformance, err = server.ParseConformanceMode(cfg.Server.Conformance)
+	if err != nil {
+		log.Fatalf("Invalid conformance mode: %v", err)
+	}
 
 	// Start server in a goroutine
 	go func() {
-		log.Println("HTTP server listening (legacy-compatible mode)...")
-		log.Printf("Waiting for connections on %s...", addr)
-		log.Println("NOTE: Server configured to accept non-standard HTTP from BP_MQX_ETH clients")
-		log.Println("  - Tolerates trailing spaces in request line")
-		log.Println("  - Accepts HTTP/1.0 and HTTP/1.1")
-		serverErrors <- legacyServer.Serve(loggingListener)
+		logging.Infof("HTTP server listening (legacy-compatible mode)...")
+		logging.Infof("Waiting for connections on %s...", addr)
+		logging.Infof("NOTE: Server configured to accept non-standard HTTP from BP_MQX_ETH clients")
+		logging.Infof("  - Tolerates trailing spaces in request line")
+		logging.Infof("  - Accepts HTTP/1.0 and HTTP/1.1")
+		if err := legacyServer.Serve(loggingListener); err != server.ErrServerClosed {
+			serverErrors <- err
+		}
 	}()
 
+	// Start the metrics listener on its own address (never on the legacy port)
+	var metricsServer *http.Server
+	if cfg.Metrics.Enabled {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metrics.Default.Handler())
+		metricsServer = &http.Server{
+			Addr:         cfg.Metrics.Address,
+			Handler:      metricsMux,
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+			IdleTimeout:  cfg.Server.IdleTimeout,
+		}
+		go func() {
+			logging.Infof("Metrics available at: http://%s/metrics", cfg.Metrics.Address)
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				serverErrors <- err
+			}
+		}()
+	}
+
+	// Start the standard API listener: admin routes, with keep-alive, HTTP/2 and optional TLS
+	var apiServer *http.Server
+	if cfg.API.Enabled {
+		apiHandler := api.NewAPIRouter(handler, cfg.Auth.Clients, cfg.Auth.Enabled, cfg.AdminTokens())
+		if cfg.API.ServeMetrics {
+			apiMux := http.NewServeMux()
+			apiMux.Handle("/metrics", metrics.Default.Handler())
+			apiMux.Handle("/", apiHandler)
+			apiHandler = apiMux
+		}
+		apiServer = &http.Server{
+			Addr:         cfg.API.Address,
+			Handler:      apiHandler,
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+			IdleTimeout:  cfg.Server.IdleTimeout,
+		}
+		go func() {
+			var err error
+			if cfg.API.TLSEnabled() {
+				logging.Infof("API available at: https://%s (admin routes, firmware routes blocked)", cfg.API.Address)
+				err = apiServer.ListenAndServeTLS(cfg.API.TLSCertFile, cfg.API.TLSKeyFile)
+			} else {
+				logging.Infof("API available at: http://%s (admin routes, firmware routes blocked)", cfg.API.Address)
+				err = apiServer.ListenAndServe()
+			}
+			if err != nil && err != http.ErrServerClosed {
+				serverErrors <- err
+			}
+		}()
+	}
+
+	// The API and metrics listeners stop accepting with the legacy one and drain alongside it
+	if apiServer != nil {
+		legacyServer.RegisterDrain("api server", apiServer.Shutdown)
+	}
+	if metricsServer != nil {
+		legacyServer.RegisterDrain("metrics server", metricsServer.Shutdown)
+	}
+
+	// Shutdown hooks run once the active connections are drained, in this order
+	legacyServer.RegisterOnShutdown("presence monitor", func(ctx context.Context) error {
+		presenceMonitor.Stop()
+		return nil
+	})
+	// After the presence monitor, so no webhook starts once deliveries are being waited for
+	legacyServer.RegisterOnShutdown("automation", automationRunner.Shutdown)
+	if captureRecorder != nil {
+		// Connections abandoned at the deadline were closed before this runs, so they are captured too
+		legacyServer.RegisterOnShutdown("capture file", func(ctx context.Context) error {
+			return captureRecorder.Close()
+		})
+	}
+
 	// Channel to listen for interrupt signals
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
@@ -89,17 +232,16 @@ func main() {
 		log.Fatalf("Server error: %v", err)
 
 	case sig := <-shutdown:
-		log.Printf("Received shutdown signal: %v", sig)
-		log.Println("Starting graceful shutdown...")
-
-		// Close the listener to stop accepting new connections
-		if err := listener.Close(); err != nil {
-			log.Printf("Error closing listener: %v", err)
+		logging.Infof("Received shutdown signal: %v", sig)
+		logging.Infof("Starting graceful shutdown (waiting up to %v for active connections)...", cfg.Server.ShutdownTimeout)
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+		if err := legacyServer.Shutdown(ctx); err != nil {
+			logging.Errorf("Graceful shutdown incomplete: %v", err)
+			return
 		}
 
-		// Give existing connections time to finish
-		time.Sleep(2 * time.Second)
-
-		log.Println("Server stopped gracefully")
+		logging.Infof("Server stopped gracefully")
 	}
 }
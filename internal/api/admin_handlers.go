@@ -0,0 +1,351 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/data"
+	"github.com/essensys-hub/essensys-server-backend/internal/middleware"
+	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
+)
+
+// adminStore returns the store an admin request may use
+// Requests authenticated with a tenant admin token only see that tenant's boxes
+func (h *Handler) adminStore(r *http.Request) data.Store {
+	if tenantID, ok := middleware.GetTenantID(r); ok {
+		return h.store.ForTenant(tenantID)
+	}
+	return h.store
+}
+
+// sessionResponse is a closed presence session in admin responses
+type sessionResponse struct {
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationSeconds float64   `json:"duration_seconds"`
+}
+
+// presenceResponse is the presence summary of a single box
+type presenceResponse struct {
+	ClientID       string            `json:"client_id"`
+	Online         bool              `json:"online"`
+	LastSeen       time.Time         `json:"last_seen"`
+	FirstSeen      *time.Time        `json:"first_seen"`
+	ConnectedSince *time.Time        `json:"connected_since"`
+	UptimeSeconds  float64           `json:"uptime_seconds"`
+	Availability   float64           `json:"availability"`
+	Sessions       []sessionResponse `json:"sessions"`
+}
+
+// newPresenceResponse builds the presence summary of a client at the given time
+func newPresenceResponse(client data.ClientInfo, now time.Time) presenceResponse {
+	presence := client.Presence
+	response := presenceResponse{
+		ClientID:      client.ID,
+		Online:        client.IsConnected,
+		LastSeen:      client.LastSeen,
+		UptimeSeconds: presence.Uptime(now).Seconds(),
+		Availability:  presence.Availability(now),
+		Sessions:      make([]sessionResponse, 0, len(presence.Sessions)),
+	}
+	if !presence.FirstSeen.IsZero() {
+		firstSeen := presence.FirstSeen
+		response.FirstSeen = &firstSeen
+	}
+	if !presence.ConnectedSince.IsZero() {
+		connectedSince := presence.ConnectedSince
+		response.ConnectedSince = &connectedSince
+	}
+	for _, session := range presence.Sessions {
+		response.Sessions = append(response.Sessions, sessionResponse{
+			Start:           session.Start,
+			End:             session.End,
+			DurationSeconds: session.Duration().Seconds(),
+		})
+	}
+	return response
+}
+
+// GetAdminPresence handles GET /api/admin/presence
+// It returns uptime, availability and session history per box, optionally filtered by ?client=
+func (h *Handler) GetAdminPresence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	clientFilter := r.URL.Query().Get("client")
+	now := time.Now()
+
+	response := make([]presenceResponse, 0)
+	for _, client := range h.adminStore(r).ListClients() {
+		if clientFilter != "" && client.ID != clientFilter {
+			continue
+		}
+		response = append(response, newPresenceResponse(client, now))
+	}
+
+	w.Header().Set("Content-Type", "application/json ;charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// clientResponse is the inventory record of a single box
+type clientResponse struct {
+	ClientID        string     `json:"client_id"`
+	Name            string     `json:"name"`
+	Site            string     `json:"site"`
+	Tags            []string   `json:"tags"`
+	FirmwareVersion string     `json:"firmware_version"`
+	RemoteIP        string     `json:"remote_ip"`
+	CredentialID    string     `json:"credential_id"`
+	Online          bool       `json:"online"`
+	FirstSeen       *time.Time `json:"first_seen"`
+	LastSeen        time.Time  `json:"last_seen"`
+	PendingActions  int        `json:"pending_actions"`
+}
+
+// newClientResponse builds the inventory record of a client
+func newClientResponse(client data.ClientInfo) clientResponse {
+	response := clientResponse{
+		ClientID:        client.ID,
+		Name:            client.Metadata.Name,
+		Site:            client.Metadata.Site,
+		Tags:            client.Metadata.Tags,
+		FirmwareVersion: client.Metadata.FirmwareVersion,
+		RemoteIP:        client.Metadata.RemoteIP,
+		CredentialID:    client.Metadata.CredentialID,
+		Online:          client.IsConnected,
+		LastSeen:        client.LastSeen,
+		PendingActions:  client.PendingActions,
+	}
+	if response.Tags == nil {
+		response.Tags = []string{}
+	}
+	if !client.Presence.FirstSeen.IsZero() {
+		firstSeen := client.Presence.FirstSeen
+		response.FirstSeen = &firstSeen
+	}
+	return response
+}
+
+// clientFilter holds the inventory query parameters
+type clientFilter struct {
+	firmware       string // exact firmware version
+	firmwareBefore string // firmware strictly older than this version
+	site           string
+	tag            string
+	name           string // case-insensitive substring of the friendly name
+	online         *bool
+}
+
+// parseClientFilter reads the inventory filter from the query string
+func parseClientFilter(r *http.Request) (clientFilter, error) {
+	query := r.URL.Query()
+	filter := clientFilter{
+		firmware:       query.Get("firmware"),
+		firmwareBefore: query.Get("firmware_before"),
+		site:           query.Get("site"),
+		tag:            query.Get("tag"),
+		name:           strings.ToLower(query.Get("name")),
+	}
+	if onlineStr := query.Get("online"); onlineStr != "" {
+		online, err := strconv.ParseBool(onlineStr)
+		if err != nil {
+			return filter, err
+		}
+		filter.online = &online
+	}
+	return filter, nil
+}
+
+// matches reports whether a client passes every filter
+func (f clientFilter) matches(client data.ClientInfo) bool {
+	metadata := client.Metadata
+	if f.firmware != "" && metadata.FirmwareVersion != f.firmware {
+		return false
+	}
+	if f.firmwareBefore != "" {
+		if metadata.FirmwareVersion == "" || compareFirmwareVersions(metadata.FirmwareVersion, f.firmwareBefore) >= 0 {
+			return false
+		}
+	}
+	if f.site != "" && metadata.Site != f.site {
+		return false
+	}
+	if f.tag != "" && !metadata.HasTag(f.tag) {
+		return false
+	}
+	if f.name != "" && !strings.Contains(strings.ToLower(metadata.Name), f.name) {
+		return false
+	}
+	if f.online != nil && client.IsConnected != *f.online {
+		return false
+	}
+	return true
+}
+
+// compareFirmwareVersions compares firmware versions like "V125" by their numeric part
+// Versions without a number fall back to a plain string comparison
+func compareFirmwareVersions(a, b string) int {
+	numA, errA := strconv.Atoi(strings.TrimLeft(a, "vV"))
+	numB, errB := strconv.Atoi(strings.TrimLeft(b, "vV"))
+	if errA == nil && errB == nil {
+		switch {
+		case numA < numB:
+			return -1
+		case numA > numB:
+			return 1
+		default:
+			return 0
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// GetAdminClients handles GET /api/admin/clients
+// Supported filters: firmware, firmware_before, site, tag, name and online
+func (h *Handler) GetAdminClients(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseClientFilter(r)
+	if err != nil {
+		http.Error(w, "Invalid filter", http.StatusBadRequest)
+		return
+	}
+
+	response := make([]clientResponse, 0)
+	for _, client := range h.adminStore(r).ListClients() {
+		if filter.matches(client) {
+			response = append(response, newClientResponse(client))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json ;charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// metadataUpdateRequest is the body of PUT /api/admin/clients/{id}
+// Omitted fields are left unchanged
+type metadataUpdateRequest struct {
+	Name *string   `json:"name"`
+	Site *string   `json:"site"`
+	Tags *[]string `json:"tags"`
+}
+
+// AdminClient handles GET and PUT /api/admin/clients/{id}
+func (h *Handler) AdminClient(w http.ResponseWriter, r *http.Request) {
+	clientID := strings.TrimPrefix(r.URL.Path, "/api/admin/clients/")
+	if clientID == "" || strings.Contains(clientID, "/") {
+		http.Error(w, "Client ID is required", http.StatusBadRequest)
+		return
+	}
+
+	store := h.adminStore(r)
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPatch:
+		var update metadataUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		store.UpdateClientMetadata(clientID, data.MetadataUpdate{
+			Name: update.Name,
+			Site: update.Site,
+			Tags: update.Tags,
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	for _, client := range store.ListClients() {
+		if client.ID == clientID {
+			w.Header().Set("Content-Type", "application/json ;charset=UTF-8")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(newClientResponse(client))
+			return
+		}
+	}
+	http.Error(w, "Client not found", http.StatusNotFound)
+}
+
+// valuesResponse is the exchange table of a box as stored by the server
+type valuesResponse struct {
+	ClientID string                `json:"client_id"`
+	Values   []protocol.ExchangeKV `json:"values"`
+}
+
+// GetAdminValues handles GET /api/admin/values?indices=349,350
+// Like /api/admin/inject it reads the caller's box unless ?client= names another one
+// Indices the box never reported are left out
+func (h *Handler) GetAdminValues(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	clientID, ok := middleware.GetClientID(r)
+	if !ok {
+		clientID = "default"
+	}
+	if target := r.URL.Query().Get("client"); target != "" {
+		clientID = target
+	} else if _, isTenantAdmin := middleware.GetTenantID(r); isTenantAdmin {
+		http.Error(w, "client parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	indices, err := parseIndices(r.URL.Query().Get("indices"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Reading must not register an unknown box, so it has to be listed already
+	store := h.adminStore(r)
+	known := false
+	for _, client := range store.ListClients() {
+		if client.ID == clientID {
+			known = true
+			break
+		}
+	}
+	if !known {
+		http.Error(w, "Client not found", http.StatusNotFound)
+		return
+	}
+
+	response := valuesResponse{ClientID: clientID, Values: store.GetAllValues(clientID, indices)}
+	w.Header().Set("Content-Type", "application/json ;charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// parseIndices reads a comma-separated list of exchange table indices
+func parseIndices(list string) ([]int, error) {
+	if list == "" {
+		return nil, errors.New("indices parameter is required")
+	}
+	parts := strings.Split(list, ",")
+	indices := make([]int, 0, len(parts))
+	for _, part := range parts {
+		index, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || index < 0 || index > protocol.MaxExchangeIndex {
+			return nil, fmt.Errorf("invalid index %q", part)
+		}
+		indices = append(indices, index)
+	}
+	return indices, nil
+}
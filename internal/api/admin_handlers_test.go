@@ -0,0 +1,203 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/core"
+	"github.com/essensys-hub/essensys-server-backend/internal/data"
+)
+
+func TestGetAdminPresence(t *testing.T) {
+	store := data.NewMemoryStore()
+	actionService := core.NewActionService(store)
+	statusService := core.NewStatusService(store)
+	handler := NewHandler(actionService, statusService, store)
+
+	store.SetClientConnected("box-1", true)
+	store.SetClientConnected("box-2", true)
+	store.SetClientConnected("box-2", false)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/presence", nil)
+	w := httptest.NewRecorder()
+	handler.GetAdminPresence(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response []presenceResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response) != 2 {
+		t.Fatalf("Expected 2 boxes, got %d", len(response))
+	}
+	if !response[0].Online || response[0].ConnectedSince == nil {
+		t.Errorf("Expected box-1 online with an open session, got %+v", response[0])
+	}
+	if response[1].Online || len(response[1].Sessions) != 1 {
+		t.Errorf("Expected box-2 offline with one session, got %+v", response[1])
+	}
+}
+
+func TestGetAdminPresence_FilterByClient(t *testing.T) {
+	store := data.NewMemoryStore()
+	handler := NewHandler(core.NewActionService(store), core.NewStatusService(store), store)
+
+	store.SetClientConnected("box-1", true)
+	store.SetClientConnected("box-2", true)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/presence?client=box-2", nil)
+	w := httptest.NewRecorder()
+	handler.GetAdminPresence(w, req)
+
+	var response []presenceResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response) != 1 || response[0].ClientID != "box-2" {
+		t.Errorf("Expected only box-2, got %+v", response)
+	}
+}
+
+func TestGetAdminClients_FilterByFirmware(t *testing.T) {
+	store := data.NewMemoryStore()
+	handler := NewHandler(core.NewActionService(store), core.NewStatusService(store), store)
+
+	store.RecordClientReport("box-old", data.ClientReport{FirmwareVersion: "V98"})
+	store.RecordClientReport("box-mid", data.ClientReport{FirmwareVersion: "V120"})
+	store.RecordClientReport("box-new", data.ClientReport{FirmwareVersion: "V125"})
+
+	testCases := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{"box-mid", "box-new", "box-old"}},
+		{"?firmware=V125", []string{"box-new"}},
+		{"?firmware_before=V125", []string{"box-mid", "box-old"}},
+		{"?firmware_before=V100", []string{"box-old"}},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/clients"+tc.query, nil)
+		w := httptest.NewRecorder()
+		handler.GetAdminClients(w, req)
+
+		var response []clientResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response for %q: %v", tc.query, err)
+		}
+		if len(response) != len(tc.expected) {
+			t.Errorf("For %q expected %d clients, got %d", tc.query, len(tc.expected), len(response))
+			continue
+		}
+		for i, id := range tc.expected {
+			if response[i].ClientID != id {
+				t.Errorf("For %q expected client %s at position %d, got %s", tc.query, id, i, response[i].ClientID)
+			}
+		}
+	}
+}
+
+func TestAdminClient_UpdateMetadata(t *testing.T) {
+	store := data.NewMemoryStore()
+	handler := NewHandler(core.NewActionService(store), core.NewStatusService(store), store)
+	router := NewRouter(handler, map[string]string{}, false)
+
+	body := `{"name":"Maison Dupont","site":"Lyon","tags":["pilot"]}`
+	req := httptest.NewRequest(http.MethodPut, "/api/admin/clients/box-1", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	// Filter by site and tag through the router
+	req = httptest.NewRequest(http.MethodGet, "/api/admin/clients?site=Lyon&tag=pilot", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response []clientResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response) != 1 || response[0].Name != "Maison Dupont" {
+		t.Errorf("Expected box-1 with its name, got %+v", response)
+	}
+}
+
+func TestAdminClient_NotFound(t *testing.T) {
+	store := data.NewMemoryStore()
+	handler := NewHandler(core.NewActionService(store), core.NewStatusService(store), store)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/clients/unknown", nil)
+	w := httptest.NewRecorder()
+	handler.AdminClient(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestCompareFirmwareVersions(t *testing.T) {
+	if compareFirmwareVersions("V98", "V125") >= 0 {
+		t.Error("Expected V98 < V125")
+	}
+	if compareFirmwareVersions("V125", "v125") != 0 {
+		t.Error("Expected V125 == v125")
+	}
+	if compareFirmwareVersions("V130", "V125") <= 0 {
+		t.Error("Expected V130 > V125")
+	}
+}
+
+func TestGetAdminValues(t *testing.T) {
+	store := data.NewMemoryStore()
+	handler := NewHandler(core.NewActionService(store), core.NewStatusService(store), store)
+
+	store.SetValue("box-1", 349, "22")
+	store.SetValue("box-1", 350, "1")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/values?client=box-1&indices=349,351", nil)
+	w := httptest.NewRecorder()
+	handler.GetAdminValues(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var response valuesResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.ClientID != "box-1" || len(response.Values) != 1 || response.Values[0].K != 349 || response.Values[0].V != "22" {
+		t.Errorf("Expected only 349=22 for box-1, got %+v", response)
+	}
+
+	testCases := []struct {
+		query    string
+		expected int
+	}{
+		{"?client=box-1", http.StatusBadRequest},
+		{"?client=box-1&indices=349,abc", http.StatusBadRequest},
+		{"?client=box-1&indices=1000", http.StatusBadRequest},
+		{"?client=unknown&indices=349", http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/values"+tc.query, nil)
+		w := httptest.NewRecorder()
+		handler.GetAdminValues(w, req)
+		if w.Code != tc.expected {
+			t.Errorf("For %q expected status %d, got %d", tc.query, tc.expected, w.Code)
+		}
+	}
+
+	// Reading an unknown box must not register it
+	if len(store.ListClients()) != 1 {
+		t.Errorf("Expected only box-1 in the inventory, got %+v", store.ListClients())
+	}
+}
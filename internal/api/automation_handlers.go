@@ -0,0 +1,231 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/data"
+	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
+)
+
+// sceneJSON is a scene in admin requests and responses
+type sceneJSON struct {
+	Name   string                `json:"name"`
+	Params []protocol.ExchangeKV `json:"params"`
+}
+
+// scheduleJSON is a schedule in admin requests and responses
+// Enabled defaults to true when omitted
+type scheduleJSON struct {
+	ID       string `json:"id"`
+	Scene    string `json:"scene"`
+	ClientID string `json:"client_id"`
+	At       string `json:"at"`
+	Enabled  *bool  `json:"enabled"`
+}
+
+// webhookJSON is a webhook in admin requests and responses
+type webhookJSON struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+func newSceneJSON(scene data.Scene) sceneJSON {
+	return sceneJSON{Name: scene.Name, Params: scene.Params}
+}
+
+func newScheduleJSON(schedule data.Schedule) scheduleJSON {
+	enabled := schedule.Enabled
+	return scheduleJSON{ID: schedule.ID, Scene: schedule.Scene, ClientID: schedule.ClientID, At: schedule.At, Enabled: &enabled}
+}
+
+func newWebhookJSON(webhook data.Webhook) webhookJSON {
+	return webhookJSON{ID: webhook.ID, URL: webhook.URL, Events: webhook.Events}
+}
+
+// AdminScenes handles GET and POST /api/admin/scenes
+// POST saves the scene, replacing the one of the same name
+func (h *Handler) AdminScenes(w http.ResponseWriter, r *http.Request) {
+	automation := h.adminStore(r).Automation()
+
+	switch r.Method {
+	case http.MethodGet:
+		response := make([]sceneJSON, 0)
+		for _, scene := range automation.ListScenes() {
+			response = append(response, newSceneJSON(scene))
+		}
+		writeAdminJSON(w, http.StatusOK, response)
+	case http.MethodPost:
+		var request sceneJSON
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		scene := data.Scene{Name: request.Name, Params: request.Params}
+		if err := automation.SaveScene(scene); err != nil {
+			writeAutomationError(w, err)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, newSceneJSON(scene))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// AdminScene handles GET and DELETE /api/admin/scenes/{name}
+// A scene still sent by a schedule cannot be deleted
+func (h *Handler) AdminScene(w http.ResponseWriter, r *http.Request) {
+	name, ok := automationID(w, r, "/api/admin/scenes/")
+	if !ok {
+		return
+	}
+	automation := h.adminStore(r).Automation()
+
+	switch r.Method {
+	case http.MethodGet:
+		scene, found := automation.GetScene(name)
+		if !found {
+			http.Error(w, "Scene not found", http.StatusNotFound)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, newSceneJSON(scene))
+	case http.MethodDelete:
+		if err := automation.DeleteScene(name); err != nil {
+			writeAutomationError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// AdminSchedules handles GET and POST /api/admin/schedules
+// POST saves the schedule, replacing the one of the same ID; it must send one of the
+// tenant's scenes to one of the tenant's boxes
+func (h *Handler) AdminSchedules(w http.ResponseWriter, r *http.Request) {
+	automation := h.adminStore(r).Automation()
+
+	switch r.Method {
+	case http.MethodGet:
+		response := make([]scheduleJSON, 0)
+		for _, schedule := range automation.ListSchedules() {
+			response = append(response, newScheduleJSON(schedule))
+		}
+		writeAdminJSON(w, http.StatusOK, response)
+	case http.MethodPost:
+		var request scheduleJSON
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		schedule := data.Schedule{ID: request.ID, Scene: request.Scene, ClientID: request.ClientID, At: request.At, Enabled: true}
+		if request.Enabled != nil {
+			schedule.Enabled = *request.Enabled
+		}
+		if err := automation.SaveSchedule(schedule); err != nil {
+			writeAutomationError(w, err)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, newScheduleJSON(schedule))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// AdminSchedule handles DELETE /api/admin/schedules/{id}
+func (h *Handler) AdminSchedule(w http.ResponseWriter, r *http.Request) {
+	id, ok := automationID(w, r, "/api/admin/schedules/")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := h.adminStore(r).Automation().DeleteSchedule(id); err != nil {
+		writeAutomationError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminWebhooks handles GET and POST /api/admin/webhooks
+// POST saves the webhook, replacing the one of the same ID
+func (h *Handler) AdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	automation := h.adminStore(r).Automation()
+
+	switch r.Method {
+	case http.MethodGet:
+		response := make([]webhookJSON, 0)
+		for _, webhook := range automation.ListWebhooks() {
+			response = append(response, newWebhookJSON(webhook))
+		}
+		writeAdminJSON(w, http.StatusOK, response)
+	case http.MethodPost:
+		var request webhookJSON
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		webhook := data.Webhook{ID: request.ID, URL: request.URL, Events: request.Events}
+		if err := automation.SaveWebhook(webhook); err != nil {
+			writeAutomationError(w, err)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, newWebhookJSON(webhook))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// AdminWebhook handles DELETE /api/admin/webhooks/{id}
+func (h *Handler) AdminWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := automationID(w, r, "/api/admin/webhooks/")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := h.adminStore(r).Automation().DeleteWebhook(id); err != nil {
+		writeAutomationError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// automationID reads the name or ID following prefix in the path, answering 400 when there is none
+func automationID(w http.ResponseWriter, r *http.Request, prefix string) (string, bool) {
+	id := strings.TrimPrefix(r.URL.Path, prefix)
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "ID is required", http.StatusBadRequest)
+		return "", false
+	}
+	return id, true
+}
+
+// writeAutomationError maps an automation error to its status
+// Unknown scenes, schedules, webhooks and boxes of other tenants are 404, scenes in use 409,
+// and everything else a validation error
+func writeAutomationError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, data.ErrInUse):
+		status = http.StatusConflict
+	case errors.Is(err, data.ErrNotFound), errors.Is(err, data.ErrClientNotInTenant):
+		status = http.StatusNotFound
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// writeAdminJSON writes an admin response body
+func writeAdminJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json ;charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
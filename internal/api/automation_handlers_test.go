@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/core"
+	"github.com/essensys-hub/essensys-server-backend/internal/data"
+)
+
+// newAutomationRouter returns a router with tenant admin tokens for acme (box-a) and globex (box-g)
+func newAutomationRouter(t *testing.T) (http.Handler, *data.MemoryStore) {
+	t.Helper()
+	store := data.NewMemoryStore()
+	store.AddTenant(data.Tenant{ID: "acme", Clients: []string{"box-a"}})
+	store.AddTenant(data.Tenant{ID: "globex", Clients: []string{"box-g"}})
+	handler := NewHandler(core.NewActionService(store), core.NewStatusService(store), store)
+	router := NewRouterWithAdminTokens(handler, map[string]string{}, false, map[string]string{
+		"acme-token":   "acme",
+		"globex-token": "globex",
+	})
+	return router, store
+}
+
+// adminCall sends an admin request with a tenant token and returns the recorded response
+func adminCall(router http.Handler, token, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminAutomation_Lifecycle(t *testing.T) {
+	router, store := newAutomationRouter(t)
+
+	steps := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"save scene", http.MethodPost, "/api/admin/scenes", `{"name":"morning","params":[{"k":613,"v":"64"}]}`, http.StatusOK},
+		{"scene without params", http.MethodPost, "/api/admin/scenes", `{"name":"empty"}`, http.StatusBadRequest},
+		{"invalid JSON", http.MethodPost, "/api/admin/scenes", `{`, http.StatusBadRequest},
+		{"get scene", http.MethodGet, "/api/admin/scenes/morning", "", http.StatusOK},
+		{"unknown scene", http.MethodGet, "/api/admin/scenes/evening", "", http.StatusNotFound},
+		{"save schedule", http.MethodPost, "/api/admin/schedules", `{"id":"wake","scene":"morning","client_id":"box-a","at":"07:30"}`, http.StatusOK},
+		{"schedule of an unknown scene", http.MethodPost, "/api/admin/schedules", `{"id":"x","scene":"evening","client_id":"box-a","at":"07:30"}`, http.StatusNotFound},
+		{"schedule with a bad time", http.MethodPost, "/api/admin/schedules", `{"id":"x","scene":"morning","client_id":"box-a","at":"7h30"}`, http.StatusBadRequest},
+		{"delete scene in use", http.MethodDelete, "/api/admin/scenes/morning", "", http.StatusConflict},
+		{"save webhook", http.MethodPost, "/api/admin/webhooks", `{"id":"ops","url":"https://acme.example/hook","events":["offline"]}`, http.StatusOK},
+		{"webhook with unknown event", http.MethodPost, "/api/admin/webhooks", `{"id":"x","url":"https://acme.example/hook","events":["deleted"]}`, http.StatusBadRequest},
+		{"delete schedule", http.MethodDelete, "/api/admin/schedules/wake", "", http.StatusNoContent},
+		{"delete scene", http.MethodDelete, "/api/admin/scenes/morning", "", http.StatusNoContent},
+		{"delete unknown webhook", http.MethodDelete, "/api/admin/webhooks/none", "", http.StatusNotFound},
+		{"webhook without ID", http.MethodDelete, "/api/admin/webhooks/", "", http.StatusBadRequest},
+		{"method not allowed", http.MethodPut, "/api/admin/schedules", "", http.StatusMethodNotAllowed},
+	}
+	for _, step := range steps {
+		if w := adminCall(router, "acme-token", step.method, step.path, step.body); w.Code != step.status {
+			t.Errorf("%s: expected status %d, got %d (%s)", step.name, step.status, w.Code, strings.TrimSpace(w.Body.String()))
+		}
+	}
+
+	w := adminCall(router, "acme-token", http.MethodGet, "/api/admin/webhooks", "")
+	var webhooks []webhookJSON
+	if err := json.NewDecoder(w.Body).Decode(&webhooks); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(webhooks) != 1 || webhooks[0].ID != "ops" || webhooks[0].Events[0] != "offline" {
+		t.Errorf("Expected webhook ops, got %+v", webhooks)
+	}
+	if scenes := store.ForTenant("acme").Automation().ListScenes(); len(scenes) != 0 {
+		t.Errorf("Expected the scene deleted, got %+v", scenes)
+	}
+}
+
+func TestAdminAutomation_TenantIsolation(t *testing.T) {
+	router, store := newAutomationRouter(t)
+
+	adminCall(router, "acme-token", http.MethodPost, "/api/admin/scenes", `{"name":"morning","params":[{"k":613,"v":"64"}]}`)
+	adminCall(router, "acme-token", http.MethodPost, "/api/admin/webhooks", `{"id":"ops","url":"https://acme.example/hook","events":["online"]}`)
+
+	// A schedule cannot send a scene to another tenant's box
+	w := adminCall(router, "acme-token", http.MethodPost, "/api/admin/schedules", `{"id":"x","scene":"morning","client_id":"box-g","at":"07:30"}`)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 scheduling another tenant's box, got %d", w.Code)
+	}
+
+	// Globex sees nothing of acme's automation
+	for _, path := range []string{"/api/admin/scenes", "/api/admin/schedules", "/api/admin/webhooks"} {
+		w := adminCall(router, "globex-token", http.MethodGet, path, "")
+		if body := strings.TrimSpace(w.Body.String()); w.Code != http.StatusOK || body != "[]" {
+			t.Errorf("%s: expected an empty list for globex, got %d %s", path, w.Code, body)
+		}
+	}
+	if w := adminCall(router, "globex-token", http.MethodDelete, "/api/admin/webhooks/ops", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 deleting another tenant's webhook, got %d", w.Code)
+	}
+	if webhooks := store.WebhooksFor("box-a", data.EventClientOnline); len(webhooks) != 1 {
+		t.Errorf("Expected acme's webhook kept, got %+v", webhooks)
+	}
+}
+
+func TestAdminSchedules_EnabledDefaultsToTrue(t *testing.T) {
+	router, store := newAutomationRouter(t)
+	adminCall(router, "acme-token", http.MethodPost, "/api/admin/scenes", `{"name":"morning","params":[{"k":613,"v":"64"}]}`)
+	adminCall(router, "acme-token", http.MethodPost, "/api/admin/schedules", `{"id":"on","scene":"morning","client_id":"box-a","at":"07:30"}`)
+	adminCall(router, "acme-token", http.MethodPost, "/api/admin/schedules", `{"id":"off","scene":"morning","client_id":"box-a","at":"07:30","enabled":false}`)
+
+	schedules := store.ForTenant("acme").Automation().ListSchedules()
+	if len(schedules) != 2 || schedules[0].ID != "off" || schedules[0].Enabled || !schedules[1].Enabled {
+		t.Errorf("Expected off disabled and on enabled, got %+v", schedules)
+	}
+}
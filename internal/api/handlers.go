@@ -2,12 +2,15 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
-	"log"
+	"net"
 	"net/http"
 
 	"github.com/essensys-hub/essensys-server-backend/internal/core"
 	"github.com/essensys-hub/essensys-server-backend/internal/data"
+	"github.com/essensys-hub/essensys-server-backend/internal/logging"
+	"github.com/essensys-hub/essensys-server-backend/internal/metrics"
 	"github.com/essensys-hub/essensys-server-backend/internal/middleware"
 	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
 )
@@ -52,7 +55,7 @@ func (h *Handler) GetServerInfos(w http.ResponseWriter, r *http.Request) {
 	// Set Content-Type header with space before semicolon (as per requirement 5.5)
 	w.Header().Set("Content-Type", "application/json ;charset=UTF-8")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	writeBoxJSON(w, response)
 }
 
 // PostMyStatus handles POST /api/mystatus
@@ -74,6 +77,7 @@ func (h *Handler) PostMyStatus(w http.ResponseWriter, r *http.Request) {
 	// Normalize malformed JSON
 	normalizedBody, err := NormalizeJSON(body)
 	if err != nil {
+		logging.Warnf("[GO] Rejected status body from %s: %v", clientID, err)
 		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
 		return
 	}
@@ -86,7 +90,7 @@ func (h *Handler) PostMyStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Log status update (similar to server.sample.go)
-	log.Printf("[GO] Status Update (Version: %s, Items: %d)", statusReq.Version, len(statusReq.EK))
+	logging.Infof("[GO] Status Update (Version: %s, Items: %d)", statusReq.Version, len(statusReq.EK))
 
 	// Update status in the store
 	if err := h.statusService.UpdateStatus(clientID, statusReq); err != nil {
@@ -94,6 +98,16 @@ func (h *Handler) PostMyStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Record firmware version and origin in the client inventory
+	report := data.ClientReport{
+		FirmwareVersion: statusReq.Version,
+		RemoteIP:        remoteIP(r),
+	}
+	if ok {
+		report.CredentialID = clientID
+	}
+	h.store.RecordClientReport(clientID, report)
+
 	// Set Content-Type header with space before semicolon (as per requirement 5.5)
 	w.Header().Set("Content-Type", "application/json ;charset=UTF-8")
 	w.WriteHeader(http.StatusCreated)
@@ -120,15 +134,18 @@ func (h *Handler) GetMyActions(w http.ResponseWriter, r *http.Request) {
 	if response.Actions == nil {
 		response.Actions = []protocol.Action{}
 	}
+	metrics.ActionsDelivered.Add(float64(len(response.Actions)))
 
 	// Marshal to JSON for logging
-	jsonBytes, _ := json.Marshal(response)
-	log.Printf("[GO] Sending Actions: %s", string(jsonBytes))
+	if logging.Default().Enabled(logging.LevelDebug) {
+		jsonBytes, _ := json.Marshal(response)
+		logging.Debugf("[GO] Sending Actions: %s", string(jsonBytes))
+	}
 
 	// Set Content-Type header with space before semicolon (as per requirement 5.5)
 	w.Header().Set("Content-Type", "application/json ;charset=UTF-8")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	writeBoxJSON(w, response)
 }
 
 // PostDone handles POST /api/done/{guid}
@@ -155,7 +172,7 @@ func (h *Handler) PostDone(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Log acknowledgment (like server.sample.go)
-	log.Printf("[GO] Action acknowledged: %s", guid)
+	logging.Infof("[GO] Action acknowledged: %s", guid)
 
 	// Set Content-Type header with space before semicolon (as per requirement 5.5)
 	w.Header().Set("Content-Type", "application/json ;charset=UTF-8")
@@ -164,12 +181,20 @@ func (h *Handler) PostDone(w http.ResponseWriter, r *http.Request) {
 
 // PostAdminInject handles POST /api/admin/inject
 // This endpoint allows administrators to manually inject actions into the queue
+// The target box is given by ?client=, defaulting to the caller's own client ID;
+// tenant administrators must name a box of their tenant
 func (h *Handler) PostAdminInject(w http.ResponseWriter, r *http.Request) {
 	// Get client ID from context (set by auth middleware)
 	clientID, ok := middleware.GetClientID(r)
 	if !ok {
 		clientID = "default"
 	}
+	if target := r.URL.Query().Get("client"); target != "" {
+		clientID = target
+	} else if _, isTenantAdmin := middleware.GetTenantID(r); isTenantAdmin {
+		http.Error(w, "client parameter is required", http.StatusBadRequest)
+		return
+	}
 
 	// Read request body
 	body, err := io.ReadAll(r.Body)
@@ -193,11 +218,18 @@ func (h *Handler) PostAdminInject(w http.ResponseWriter, r *http.Request) {
 		params = []protocol.ExchangeKV{singleParam}
 	}
 
-	// Process the action using ActionService
+	// Process the action using ActionService bound to the caller's store
 	// This will handle complete block generation, bitwise fusion, etc.
-	guid, err := h.actionService.AddAction(clientID, params)
+	guid, err := h.actionService.WithStore(h.adminStore(r)).AddAction(clientID, params)
 	if err != nil {
-		http.Error(w, "Failed to add action", http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, data.ErrClientNotInTenant):
+			http.Error(w, "Client not found", http.StatusNotFound)
+		case errors.Is(err, data.ErrQuotaExceeded):
+			http.Error(w, "Pending action quota exceeded", http.StatusTooManyRequests)
+		default:
+			http.Error(w, "Failed to add action", http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -212,3 +244,23 @@ func (h *Handler) PostAdminInject(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// writeBoxJSON writes a box response body the way the reference server does:
+// json.Marshal output, without the trailing newline of json.Encoder
+func writeBoxJSON(w http.ResponseWriter, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		logging.Errorf("[GO] Failed to encode response: %v", err)
+		return
+	}
+	w.Write(body)
+}
+
+// remoteIP returns the IP part of the request's remote address
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -89,6 +89,34 @@ func TestPostMyStatus(t *testing.T) {
 	}
 }
 
+func TestPostMyStatus_RecordsInventory(t *testing.T) {
+	store := data.NewMemoryStore()
+	handler := NewHandler(core.NewActionService(store), core.NewStatusService(store), store)
+
+	body := []byte(`{version:"V125",ek:[{k:100,v:"1"}]}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/mystatus", bytes.NewReader(body))
+	req.RemoteAddr = "192.168.1.50:4321"
+	req = req.WithContext(context.WithValue(req.Context(), middleware.ClientIDKey, "test-client"))
+	w := httptest.NewRecorder()
+
+	handler.PostMyStatus(w, req)
+
+	clients := store.ListClients()
+	if len(clients) != 1 {
+		t.Fatalf("Expected 1 client, got %d", len(clients))
+	}
+	metadata := clients[0].Metadata
+	if metadata.FirmwareVersion != "V125" {
+		t.Errorf("Expected firmware 'V125', got '%s'", metadata.FirmwareVersion)
+	}
+	if metadata.RemoteIP != "192.168.1.50" {
+		t.Errorf("Expected remote IP '192.168.1.50', got '%s'", metadata.RemoteIP)
+	}
+	if metadata.CredentialID != "test-client" {
+		t.Errorf("Expected credential 'test-client', got '%s'", metadata.CredentialID)
+	}
+}
+
 func TestPostMyStatus_MalformedJSON(t *testing.T) {
 	// Setup
 	store := data.NewMemoryStore()
@@ -1,45 +1,417 @@
 package api
 
 import (
-	"encoding/json"
-	"regexp"
+	"fmt"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/metrics"
 )
 
 // NormalizeJSON converts malformed JSON to valid JSON
 // The legacy C client sends JSON with unquoted keys: {k:123,v:"val"}
 // We need to convert it to valid JSON: {"k":123,"v":"val"}
-// This matches the exact behavior of the ASP.NET server
+//
+// The input is read by a lenient JSON5-style parser in a single pass:
+// unquoted keys anywhere, single-quoted strings and trailing commas are accepted,
+// and an object missing its opening brace inside an array ([k:1,v:"0"}]) is repaired
+// like the ASP.NET server did. Whitespace is kept, so valid JSON comes out unchanged.
+// Errors are *NormalizeError values carrying the exact position of the problem
 func NormalizeJSON(input []byte) ([]byte, error) {
-	if len(input) == 0 {
-		return nil, json.Unmarshal(input, new(interface{}))
-	}
-
-	// Convert to string for processing
-	normalized := string(input)
-
-	// Fix unquoted keys - same approach as server.sample.go
-	// Pattern 1: {k: -> {"k":
-	normalized = regexp.MustCompile(`\{k:`).ReplaceAllString(normalized, `{"k":`)
-	
-	// Pattern 2: ,v: -> ,"v":
-	normalized = regexp.MustCompile(`,v:`).ReplaceAllString(normalized, `,"v":`)
-
-	// Also handle nested objects and arrays
-	// Pattern 3: [k: -> ["k": (for arrays)
-	normalized = regexp.MustCompile(`\[k:`).ReplaceAllString(normalized, `[{"k":`)
-	
-	// Pattern 4: Handle version field if unquoted
-	normalized = regexp.MustCompile(`\{version:`).ReplaceAllString(normalized, `{"version":`)
-	normalized = regexp.MustCompile(`,version:`).ReplaceAllString(normalized, `,"version":`)
-	
-	// Pattern 5: Handle ek field if unquoted
-	normalized = regexp.MustCompile(`,ek:`).ReplaceAllString(normalized, `,"ek":`)
-
-	// Validate that the normalized JSON is valid
-	var test interface{}
-	if err := json.Unmarshal([]byte(normalized), &test); err != nil {
+	n := normalizer{
+		in:  input,
+		out: make([]byte, 0, len(input)+len(input)/4),
+	}
+
+	if err := n.normalize(); err != nil {
+		metrics.NormalizerFailures.Inc()
 		return nil, err
 	}
 
-	return []byte(normalized), nil
+	if string(n.out) != string(input) {
+		metrics.NormalizerFixes.Inc()
+	}
+
+	return n.out, nil
+}
+
+// NormalizeError reports where and why the input could not be normalized
+type NormalizeError struct {
+	Offset int // byte offset in the input
+	Line   int // 1-based
+	Column int // 1-based, in bytes
+	Msg    string
+}
+
+func (e *NormalizeError) Error() string {
+	return fmt.Sprintf("invalid JSON at line %d, column %d (offset %d): %s", e.Line, e.Column, e.Offset, e.Msg)
+}
+
+// maxNormalizeDepth bounds nesting so hostile input cannot exhaust the stack
+const maxNormalizeDepth = 64
+
+// normalizer is a recursive-descent parser that writes valid JSON as it reads
+type normalizer struct {
+	in    []byte
+	pos   int
+	out   []byte
+	depth int
+}
+
+func (n *normalizer) normalize() error {
+	n.copySpace()
+	if n.pos >= len(n.in) {
+		return n.errorf("unexpected end of input")
+	}
+	if err := n.value(); err != nil {
+		return err
+	}
+	n.copySpace()
+	if n.pos < len(n.in) {
+		return n.errorf("unexpected %s after top-level value", n.describe())
+	}
+	return nil
+}
+
+// errorf builds a NormalizeError at the current position
+func (n *normalizer) errorf(format string, args ...interface{}) error {
+	line, column := 1, 1
+	for _, c := range n.in[:n.pos] {
+		if c == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return &NormalizeError{
+		Offset: n.pos,
+		Line:   line,
+		Column: column,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+// describe names the byte at the current position for error messages
+func (n *normalizer) describe() string {
+	if n.pos >= len(n.in) {
+		return "end of input"
+	}
+	return fmt.Sprintf("character %q", n.in[n.pos])
+}
+
+// skipSpace returns the end of the whitespace run starting at the current position
+func (n *normalizer) skipSpace() int {
+	end := n.pos
+	for end < len(n.in) {
+		switch n.in[end] {
+		case ' ', '\t', '\n', '\r':
+			end++
+		default:
+			return end
+		}
+	}
+	return end
+}
+
+// copySpace copies the whitespace at the current position to the output
+func (n *normalizer) copySpace() {
+	end := n.skipSpace()
+	n.out = append(n.out, n.in[n.pos:end]...)
+	n.pos = end
+}
+
+// value parses any JSON value
+func (n *normalizer) value() error {
+	if n.pos >= len(n.in) {
+		return n.errorf("unexpected end of input, expected a value")
+	}
+	switch c := n.in[n.pos]; {
+	case c == '{':
+		n.out = append(n.out, '{')
+		n.pos++
+		return n.object()
+	case c == '[':
+		return n.array()
+	case c == '"' || c == '\'':
+		return n.str()
+	case c == '-' || isDigit(c):
+		return n.number()
+	case isIdentStart(c):
+		start := n.pos
+		ident := n.ident()
+		switch ident {
+		case "true", "false", "null":
+			n.out = append(n.out, ident...)
+			return nil
+		}
+		n.pos = start
+		return n.errorf("unexpected identifier %q, expected a value", ident)
+	default:
+		return n.errorf("unexpected %s, expected a value", n.describe())
+	}
+}
+
+// object parses the members of an object whose opening brace was already written
+func (n *normalizer) object() error {
+	if n.depth++; n.depth > maxNormalizeDepth {
+		return n.errorf("nesting deeper than %d levels", maxNormalizeDepth)
+	}
+	defer func() { n.depth-- }()
+
+	n.copySpace()
+	if n.pos < len(n.in) && n.in[n.pos] == '}' {
+		n.out = append(n.out, '}')
+		n.pos++
+		return nil
+	}
+
+	for {
+		if err := n.key(); err != nil {
+			return err
+		}
+		n.copySpace()
+		if n.pos >= len(n.in) || n.in[n.pos] != ':' {
+			return n.errorf("unexpected %s, expected ':' after object key", n.describe())
+		}
+		n.out = append(n.out, ':')
+		n.pos++
+		n.copySpace()
+		if err := n.value(); err != nil {
+			return err
+		}
+
+		closed, err := n.separator('}')
+		if err != nil || closed {
+			return err
+		}
+	}
+}
+
+// array parses an array
+func (n *normalizer) array() error {
+	if n.depth++; n.depth > maxNormalizeDepth {
+		return n.errorf("nesting deeper than %d levels", maxNormalizeDepth)
+	}
+	defer func() { n.depth-- }()
+
+	n.out = append(n.out, '[')
+	n.pos++
+	n.copySpace()
+	if n.pos < len(n.in) && n.in[n.pos] == ']' {
+		n.out = append(n.out, ']')
+		n.pos++
+		return nil
+	}
+
+	for {
+		if n.missingBrace() {
+			// The firmware sometimes drops the brace of an array element: [k:1,v:"0"}]
+			n.out = append(n.out, '{')
+			if err := n.object(); err != nil {
+				return err
+			}
+		} else if err := n.value(); err != nil {
+			return err
+		}
+
+		closed, err := n.separator(']')
+		if err != nil || closed {
+			return err
+		}
+	}
+}
+
+// missingBrace reports whether the current position is an unquoted key directly followed by ':'
+func (n *normalizer) missingBrace() bool {
+	if n.pos >= len(n.in) || !isIdentStart(n.in[n.pos]) {
+		return false
+	}
+	end := n.pos
+	for end < len(n.in) && isIdentPart(n.in[end]) {
+		end++
+	}
+	return end < len(n.in) && n.in[end] == ':'
+}
+
+// separator consumes the ',' or closing character after a member or element
+// A comma directly followed by the closing character (a trailing comma) is dropped
+func (n *normalizer) separator(closing byte) (closed bool, err error) {
+	n.copySpace()
+	if n.pos >= len(n.in) {
+		return false, n.errorf("unexpected end of input, expected ',' or '%c'", closing)
+	}
+
+	switch n.in[n.pos] {
+	case closing:
+		n.out = append(n.out, closing)
+		n.pos++
+		return true, nil
+	case ',':
+		n.pos++
+		end := n.skipSpace()
+		if end < len(n.in) && n.in[end] == closing {
+			n.out = append(n.out, n.in[n.pos:end]...)
+			n.out = append(n.out, closing)
+			n.pos = end + 1
+			return true, nil
+		}
+		n.out = append(n.out, ',')
+		n.copySpace()
+		return false, nil
+	default:
+		return false, n.errorf("unexpected %s, expected ',' or '%c'", n.describe(), closing)
+	}
+}
+
+// key parses an object key: a quoted string or an unquoted identifier
+func (n *normalizer) key() error {
+	if n.pos >= len(n.in) {
+		return n.errorf("unexpected end of input, expected an object key")
+	}
+	c := n.in[n.pos]
+	if c == '"' || c == '\'' {
+		return n.str()
+	}
+	if !isIdentStart(c) {
+		return n.errorf("unexpected %s, expected an object key", n.describe())
+	}
+	ident := n.ident()
+	n.out = append(n.out, '"')
+	n.out = append(n.out, ident...)
+	n.out = append(n.out, '"')
+	return nil
+}
+
+// ident consumes an identifier and returns it
+func (n *normalizer) ident() string {
+	start := n.pos
+	for n.pos < len(n.in) && isIdentPart(n.in[n.pos]) {
+		n.pos++
+	}
+	return string(n.in[start:n.pos])
+}
+
+// str parses a double- or single-quoted string and writes it double-quoted
+func (n *normalizer) str() error {
+	quote := n.in[n.pos]
+	n.out = append(n.out, '"')
+	n.pos++
+
+	for n.pos < len(n.in) {
+		c := n.in[n.pos]
+		switch {
+		case c == quote:
+			n.out = append(n.out, '"')
+			n.pos++
+			return nil
+		case c == '\\':
+			if err := n.escape(quote); err != nil {
+				return err
+			}
+		case c < 0x20:
+			return n.errorf("control character %q in string", c)
+		case c == '"':
+			// Only reachable inside single-quoted strings
+			n.out = append(n.out, '\\', '"')
+			n.pos++
+		default:
+			// Bytes are copied as-is: the firmware may send ISO-8859-1 text
+			n.out = append(n.out, c)
+			n.pos++
+		}
+	}
+	return n.errorf("unterminated string")
+}
+
+// escape copies a backslash escape sequence, unescaping \' which JSON does not allow
+func (n *normalizer) escape(quote byte) error {
+	if n.pos+1 >= len(n.in) {
+		n.pos++
+		return n.errorf("unterminated string")
+	}
+	switch c := n.in[n.pos+1]; c {
+	case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+		n.out = append(n.out, '\\', c)
+		n.pos += 2
+	case '\'':
+		n.out = append(n.out, '\'')
+		n.pos += 2
+	case 'u':
+		if n.pos+6 > len(n.in) {
+			n.pos = len(n.in)
+			return n.errorf("unterminated string")
+		}
+		for _, h := range n.in[n.pos+2 : n.pos+6] {
+			if !isHexDigit(h) {
+				n.pos++
+				return n.errorf("invalid \\u escape in string")
+			}
+		}
+		n.out = append(n.out, n.in[n.pos:n.pos+6]...)
+		n.pos += 6
+	default:
+		n.pos++
+		return n.errorf("invalid escape character %q in string", c)
+	}
+	return nil
+}
+
+// number copies a number, checking it follows the JSON grammar
+func (n *normalizer) number() error {
+	start := n.pos
+	if n.in[n.pos] == '-' {
+		n.pos++
+	}
+
+	switch {
+	case n.pos < len(n.in) && n.in[n.pos] == '0':
+		n.pos++
+	case n.pos < len(n.in) && isDigit(n.in[n.pos]):
+		n.digits()
+	default:
+		return n.errorf("invalid number, expected a digit")
+	}
+
+	if n.pos < len(n.in) && n.in[n.pos] == '.' {
+		n.pos++
+		if n.pos >= len(n.in) || !isDigit(n.in[n.pos]) {
+			return n.errorf("invalid number, expected a digit after '.'")
+		}
+		n.digits()
+	}
+
+	if n.pos < len(n.in) && (n.in[n.pos] == 'e' || n.in[n.pos] == 'E') {
+		n.pos++
+		if n.pos < len(n.in) && (n.in[n.pos] == '+' || n.in[n.pos] == '-') {
+			n.pos++
+		}
+		if n.pos >= len(n.in) || !isDigit(n.in[n.pos]) {
+			return n.errorf("invalid number, expected a digit in exponent")
+		}
+		n.digits()
+	}
+
+	n.out = append(n.out, n.in[start:n.pos]...)
+	return nil
+}
+
+func (n *normalizer) digits() {
+	for n.pos < len(n.in) && isDigit(n.in[n.pos]) {
+		n.pos++
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isIdentStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c == '$'
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
 }
@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 )
 
@@ -130,3 +131,138 @@ func TestNormalizeJSON_HandlesAlreadyValidJSON(t *testing.T) {
 		t.Errorf("Result is not valid JSON: %v", err)
 	}
 }
+
+func TestNormalizeJSON_LenientSyntax(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"any key order", `{v:"0",k:1}`, `{"v":"0","k":1}`},
+		{"whitespace after brace", `{ k: 1, v: "0" }`, `{ "k": 1, "v": "0" }`},
+		{"unknown field", `{version:"V125",uptime:42,ek:[]}`, `{"version":"V125","uptime":42,"ek":[]}`},
+		{"single quotes", `{k:1,v:'a "quoted" \'word\''}`, `{"k":1,"v":"a \"quoted\" 'word'"}`},
+		{"trailing commas", `{ek:[{k:1,v:"0"},],}`, `{"ek":[{"k":1,"v":"0"}]}`},
+		{"trailing comma with whitespace", "[1,\n]", "[1\n]"},
+		{"missing element brace", `[k:1,v:"0"},{k:2,v:"1"}]`, `[{"k":1,"v":"0"},{"k":2,"v":"1"}]`},
+		{"literals", `{a:true,b:false,c:null,d:-1.5e3}`, `{"a":true,"b":false,"c":null,"d":-1.5e3}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NormalizeJSON([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("NormalizeJSON failed: %v", err)
+			}
+			if string(result) != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, string(result))
+			}
+			if !json.Valid(result) {
+				t.Errorf("Result is not valid JSON: %s", string(result))
+			}
+		})
+	}
+}
+
+func TestNormalizeJSON_ErrorPosition(t *testing.T) {
+	tests := []struct {
+		input  string
+		line   int
+		column int
+	}{
+		{`{invalid json that cannot be fixed}`, 1, 10},
+		{"{k:1,\n v:01}", 2, 5},
+		{`{k:1,v:"0"`, 1, 11},
+		{`{k:1,v:"abc`, 1, 12},
+		{`[1 2]`, 1, 4},
+	}
+
+	for _, tt := range tests {
+		_, err := NormalizeJSON([]byte(tt.input))
+		normErr, ok := err.(*NormalizeError)
+		if !ok {
+			t.Errorf("%q: expected *NormalizeError, got %v", tt.input, err)
+			continue
+		}
+		if normErr.Line != tt.line || normErr.Column != tt.column {
+			t.Errorf("%q: expected error at %d:%d, got %d:%d (%v)", tt.input, tt.line, tt.column, normErr.Line, normErr.Column, err)
+		}
+	}
+}
+
+func TestNormalizeJSON_RejectsDeepNesting(t *testing.T) {
+	input := strings.Repeat("[", 100) + strings.Repeat("]", 100)
+
+	if _, err := NormalizeJSON([]byte(input)); err == nil {
+		t.Error("Expected error for deeply nested input, got nil")
+	}
+}
+
+func TestNormalizeJSON_ValidJSONUnchanged(t *testing.T) {
+	inputs := []string{
+		`{"version":"V125","ek":[{"k":613,"v":"64"}]}`,
+		"{\n  \"a\": [1, 2.5, -0.1e-2],\n  \"b\": \"\\u00e9\\n\"\n}",
+		`"just a string"`,
+		`[]`,
+	}
+
+	for _, input := range inputs {
+		result, err := NormalizeJSON([]byte(input))
+		if err != nil {
+			t.Errorf("%q: NormalizeJSON failed: %v", input, err)
+			continue
+		}
+		if string(result) != input {
+			t.Errorf("Expected valid JSON unchanged, got %s from %s", string(result), input)
+		}
+	}
+}
+
+func FuzzNormalizeJSON(f *testing.F) {
+	// Seed corpus: bodies sent by BP_MQX_ETH firmware and the quirks seen in the field
+	seeds := []string{
+		`{version:"V125",ek:[{k:363,v:"01001100"},{k:349,v:"25"},{k:350,v:"0"}]}`,
+		`{"version":"V125","ek":[{"k":613,"v":"64"}]}`,
+		`{k:1,v:"0"}`,
+		`[{k:1,v:"0"},{k:2,v:"1"}]`,
+		`[k:1,v:"0"}]`,
+		`{ek:[{k:1,v:'x'},],}`,
+		"{\n  version: \"V37\",\n  ek: []\n}",
+		`{"v":"caf\u00e9 \"quoted\""}`,
+		"{v:\"caf\xe9\"}",
+		`{invalid json that cannot be fixed}`,
+		``,
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		result, err := NormalizeJSON(input)
+		if err != nil {
+			if _, ok := err.(*NormalizeError); !ok {
+				t.Fatalf("Expected *NormalizeError, got %T: %v", err, err)
+			}
+			if json.Valid(input) && !strings.Contains(err.Error(), "nesting deeper") {
+				t.Fatalf("Valid JSON rejected: %v", err)
+			}
+			return
+		}
+
+		if !json.Valid(result) {
+			t.Fatalf("Output is not valid JSON: %q from %q", result, input)
+		}
+		if json.Valid(input) && string(result) != string(input) {
+			t.Fatalf("Valid JSON was rewritten: %q became %q", input, result)
+		}
+
+		// Valid output round-trips unchanged
+		again, err := NormalizeJSON(result)
+		if err != nil {
+			t.Fatalf("Normalized output rejected on second pass: %v", err)
+		}
+		if string(again) != string(result) {
+			t.Fatalf("Normalization is not stable: %q became %q", result, again)
+		}
+	})
+}
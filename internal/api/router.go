@@ -10,34 +10,92 @@ import (
 // NewRouter creates and configures the HTTP router with all middleware and routes
 // If authEnabled is false, authentication middleware is skipped
 func NewRouter(handler *Handler, validCredentials map[string]string, authEnabled bool) http.Handler {
-	// Create separate mux for API routes
+	return NewRouterWithAdminTokens(handler, validCredentials, authEnabled, nil)
+}
+
+// NewRouterWithAdminTokens creates the router with tenant admin tokens (token -> tenant ID)
+// When adminTokens is non-empty, /api/admin/* requires a Bearer admin token and is scoped to its tenant;
+// otherwise admin routes use the same authentication as the box routes
+func NewRouterWithAdminTokens(handler *Handler, validCredentials map[string]string, authEnabled bool, adminTokens map[string]string) http.Handler {
+	return newRouter(handler, validCredentials, authEnabled, adminTokens, true, true)
+}
+
+// NewLegacyRouter creates the router of the legacy port when the API has its own listener:
+// the firmware routes and /health, with the admin routes blocked
+func NewLegacyRouter(handler *Handler, validCredentials map[string]string, authEnabled bool) http.Handler {
+	return newRouter(handler, validCredentials, authEnabled, nil, true, false)
+}
+
+// NewAPIRouter creates the router of the standard API listener:
+// the admin routes and /health, with the firmware routes blocked
+func NewAPIRouter(handler *Handler, validCredentials map[string]string, authEnabled bool, adminTokens map[string]string) http.Handler {
+	return newRouter(handler, validCredentials, authEnabled, adminTokens, false, true)
+}
+
+// newRouter builds the router with the box routes, the admin routes or both
+// A group that is not served answers 404 so it is clear the route lives on the other port
+func newRouter(handler *Handler, validCredentials map[string]string, authEnabled bool, adminTokens map[string]string, boxRoutes, adminRoutes bool) http.Handler {
+	// Create separate mux for box API routes
 	apiMux := http.NewServeMux()
 	apiMux.HandleFunc("/api/serverinfos", handler.GetServerInfos)
 	apiMux.HandleFunc("/api/mystatus", handler.PostMyStatus)
 	apiMux.HandleFunc("/api/myactions", handler.GetMyActions)
 	apiMux.HandleFunc("/api/done/", handler.PostDone)           // Trailing slash to match /api/done/{guid}
-	apiMux.HandleFunc("/api/admin/inject", handler.PostAdminInject) // Admin endpoint to inject actions
+
+	// Create separate mux for admin routes
+	adminMux := http.NewServeMux()
+	adminMux.HandleFunc("/api/admin/inject", handler.PostAdminInject) // Admin endpoint to inject actions
+	adminMux.HandleFunc("/api/admin/presence", handler.GetAdminPresence) // Admin endpoint for box uptime/availability
+	adminMux.HandleFunc("/api/admin/clients", handler.GetAdminClients)   // Admin endpoint to query the client inventory
+	adminMux.HandleFunc("/api/admin/clients/", handler.AdminClient)      // Admin endpoint for a single box: /api/admin/clients/{id}
+	adminMux.HandleFunc("/api/admin/values", handler.GetAdminValues)     // Admin endpoint to read a box's stored exchange table
+	adminMux.HandleFunc("/api/admin/scenes", handler.AdminScenes)        // Admin endpoint for the tenant's scenes
+	adminMux.HandleFunc("/api/admin/scenes/", handler.AdminScene)        // Admin endpoint for a single scene: /api/admin/scenes/{name}
+	adminMux.HandleFunc("/api/admin/schedules", handler.AdminSchedules)  // Admin endpoint for the tenant's schedules
+	adminMux.HandleFunc("/api/admin/schedules/", handler.AdminSchedule)  // Admin endpoint to delete a schedule: /api/admin/schedules/{id}
+	adminMux.HandleFunc("/api/admin/webhooks", handler.AdminWebhooks)    // Admin endpoint for the tenant's webhooks
+	adminMux.HandleFunc("/api/admin/webhooks/", handler.AdminWebhook)    // Admin endpoint to delete a webhook: /api/admin/webhooks/{id}
 
 	// Conditionally apply authentication middleware to API routes
 	var apiHandler http.Handler = apiMux
+	var adminHandler http.Handler = adminMux
 	if authEnabled {
 		apiHandler = middleware.BasicAuth(validCredentials)(apiMux)
+		adminHandler = middleware.BasicAuth(validCredentials)(adminMux)
+	}
+	if len(adminTokens) > 0 {
+		adminHandler = middleware.AdminTokenAuth(adminTokens)(adminMux)
 	}
 
 	// Create main mux that includes both authenticated and public routes
 	mainMux := http.NewServeMux()
-	mainMux.Handle("/api/", apiHandler)
+	if boxRoutes {
+		mainMux.Handle("/api/", apiHandler)
+	} else {
+		mainMux.HandleFunc("/api/", blockedRouteHandler)
+	}
+	if adminRoutes {
+		mainMux.Handle("/api/admin/", adminHandler)
+	} else {
+		mainMux.HandleFunc("/api/admin/", blockedRouteHandler)
+	}
 	mainMux.HandleFunc("/health", healthCheckHandler)
 
-	// Wire up middleware chain: Recovery → Logging → Routes
+	// Wire up middleware chain: Recovery → Logging → Metrics → Routes
 	// The chain is applied in reverse order (innermost to outermost)
 	var finalHandler http.Handler = mainMux
+	finalHandler = middleware.RequestMetrics(finalHandler)
 	finalHandler = middleware.RequestLogger(finalHandler)
 	finalHandler = middleware.Recovery(finalHandler)
 
 	return finalHandler
 }
 
+// blockedRouteHandler answers routes served on the other listener
+func blockedRouteHandler(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "Not available on this port", http.StatusNotFound)
+}
+
 // healthCheckHandler handles GET /health
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	// Only allow GET method
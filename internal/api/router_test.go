@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/essensys-hub/essensys-server-backend/internal/core"
@@ -167,3 +168,109 @@ func TestRouter_AuthenticationDisabled(t *testing.T) {
 		})
 	}
 }
+
+func TestRouter_TenantAdminIsolation(t *testing.T) {
+	store := data.NewMemoryStore()
+	store.AddTenant(data.Tenant{ID: "acme", Clients: []string{"box-a"}})
+	store.AddTenant(data.Tenant{ID: "globex", Clients: []string{"box-g"}})
+	store.SetClientConnected("box-a", true)
+	store.SetClientConnected("box-g", true)
+
+	actionService := core.NewActionService(store)
+	statusService := core.NewStatusService(store)
+	handler := NewHandler(actionService, statusService, store)
+	router := NewRouterWithAdminTokens(handler, map[string]string{}, false, map[string]string{
+		"acme-token":   "acme",
+		"globex-token": "globex",
+	})
+
+	// Without a token admin routes are refused
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/clients", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without token, got %d", w.Code)
+	}
+
+	// The acme admin only sees acme boxes
+	req = httptest.NewRequest(http.MethodGet, "/api/admin/clients", nil)
+	req.Header.Set("Authorization", "Bearer acme-token")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "box-a") || strings.Contains(body, "box-g") {
+		t.Errorf("Expected only box-a in acme inventory, got %s", body)
+	}
+
+	// The acme admin cannot read or inject into a globex box
+	req = httptest.NewRequest(http.MethodGet, "/api/admin/clients/box-g", nil)
+	req.Header.Set("Authorization", "Bearer acme-token")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another tenant's box, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/admin/inject?client=box-g", strings.NewReader(`{"k":613,"v":"1"}`))
+	req.Header.Set("Authorization", "Bearer acme-token")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 injecting into another tenant's box, got %d", w.Code)
+	}
+	if actions := store.DequeueActions("box-g"); len(actions) != 0 {
+		t.Errorf("Expected no actions queued for globex, got %d", len(actions))
+	}
+
+	// Injecting into an own box succeeds
+	req = httptest.NewRequest(http.MethodPost, "/api/admin/inject?client=box-a", strings.NewReader(`{"k":613,"v":"1"}`))
+	req.Header.Set("Authorization", "Bearer acme-token")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 injecting into own box, got %d", w.Code)
+	}
+	if actions := store.DequeueActions("box-a"); len(actions) != 1 {
+		t.Errorf("Expected 1 action queued for acme, got %d", len(actions))
+	}
+}
+
+func TestRouter_SeparateListeners(t *testing.T) {
+	store := data.NewMemoryStore()
+	actionService := core.NewActionService(store)
+	statusService := core.NewStatusService(store)
+	handler := NewHandler(actionService, statusService, store)
+
+	legacyRouter := NewLegacyRouter(handler, map[string]string{}, false)
+	apiRouter := NewAPIRouter(handler, map[string]string{}, false, nil)
+
+	tests := []struct {
+		name   string
+		router http.Handler
+		method string
+		path   string
+		status int
+	}{
+		{"firmware route on legacy port", legacyRouter, http.MethodGet, "/api/serverinfos", http.StatusOK},
+		{"admin route blocked on legacy port", legacyRouter, http.MethodGet, "/api/admin/clients", http.StatusNotFound},
+		{"health on legacy port", legacyRouter, http.MethodGet, "/health", http.StatusOK},
+		{"admin route on API port", apiRouter, http.MethodGet, "/api/admin/clients", http.StatusOK},
+		{"firmware route blocked on API port", apiRouter, http.MethodGet, "/api/serverinfos", http.StatusNotFound},
+		{"done blocked on API port", apiRouter, http.MethodPost, "/api/done/test-guid", http.StatusNotFound},
+		{"health on API port", apiRouter, http.MethodGet, "/health", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			w := httptest.NewRecorder()
+			tt.router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d for %s %s, got %d", tt.status, tt.method, tt.path, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/server"
+)
+
+// startServer runs a LegacyHTTPServer behind a capturing listener
+func startServer(t *testing.T, recorder *Recorder, filter Filter) string {
+	t.Helper()
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { inner.Close() })
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json ;charset=UTF-8")
+		w.Write([]byte(`{"isconnected":false}`))
+	})
+	go server.NewLegacyHTTPServer(handler).Serve(NewListener(inner, recorder, filter))
+	return inner.Addr().String()
+}
+
+// send writes a raw request and returns the raw response
+func send(t *testing.T, addr, request string) []byte {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	conn.Write([]byte(request))
+	var buf bytes.Buffer
+	buf.ReadFrom(conn)
+	return buf.Bytes()
+}
+
+// waitForConnections polls the capture file until it holds n connections
+func waitForConnections(t *testing.T, path string, n int) []Connection {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		connections, err := ReadFile(path)
+		if err == nil && len(connections) >= n {
+			return connections
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d captured connections, got %d (err: %v)", n, len(connections), err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestListener_CapturesMatchingClientAndReplays(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	recorder, err := NewRecorder(path, 0, 0)
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+	defer recorder.Close()
+
+	addr := startServer(t, recorder, NewFilter([]string{"box-1"}, nil))
+
+	// box-1:secret and box-2:secret; the trailing space mimics the firmware's request line
+	captured := "GET /api/serverinfos HTTP/1.1 \r\nHost: x\r\nAuthorization: Basic Ym94LTE6c2VjcmV0\r\n\r\n"
+	ignored := "GET /api/serverinfos HTTP/1.1\r\nHost: x\r\nAuthorization: Basic Ym94LTI6c2VjcmV0\r\n\r\n"
+	send(t, addr, ignored)
+	original := send(t, addr, captured)
+
+	connections := waitForConnections(t, path, 1)
+	if len(connections) != 1 {
+		t.Fatalf("Expected only box-1 to be captured, got %d connections", len(connections))
+	}
+	conn := connections[0]
+	if conn.ClientID != "box-1" {
+		t.Errorf("Expected client box-1, got %q", conn.ClientID)
+	}
+	if string(conn.Inbound()) != captured {
+		t.Errorf("Expected inbound bytes %q, got %q", captured, conn.Inbound())
+	}
+	if !bytes.Equal(conn.Outbound(), original) {
+		t.Errorf("Expected outbound bytes %q, got %q", original, conn.Outbound())
+	}
+
+	replayed, err := Replay(addr, conn, ReplayOptions{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if !bytes.Equal(replayed, original) {
+		t.Errorf("Expected replay to reproduce %q, got %q", original, replayed)
+	}
+}
+
+func TestListener_CapturesMatchingIP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	recorder, err := NewRecorder(path, 0, 0)
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+	defer recorder.Close()
+
+	addr := startServer(t, recorder, NewFilter(nil, []string{"127.0.0.1"}))
+	send(t, addr, "GET /api/serverinfos HTTP/1.1\r\nHost: x\r\n\r\n")
+
+	connections := waitForConnections(t, path, 1)
+	if connections[0].ClientID != "" {
+		t.Errorf("Expected no client ID without credentials, got %q", connections[0].ClientID)
+	}
+}
+
+func TestListener_AppendedRunsReplaySeparately(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	requests := []string{
+		"GET /api/serverinfos HTTP/1.1\r\nHost: x\r\n\r\n",
+		"GET /health HTTP/1.1\r\nHost: x\r\n\r\n",
+	}
+
+	// Each run opens the file again and starts a new listener, like a server restart
+	var addr string
+	for run, request := range requests {
+		recorder, err := NewRecorder(path, 0, 0)
+		if err != nil {
+			t.Fatalf("NewRecorder failed: %v", err)
+		}
+		addr = startServer(t, recorder, NewFilter(nil, []string{"127.0.0.1"}))
+		send(t, addr, request)
+		waitForConnections(t, path, run+1)
+		recorder.Close()
+	}
+
+	connections, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(connections) != 2 {
+		t.Fatalf("Expected one connection per run, got %d", len(connections))
+	}
+	if connections[0].ID == connections[1].ID {
+		t.Errorf("Expected the runs to use different connection IDs, both are %d", connections[0].ID)
+	}
+	for i, conn := range connections {
+		if string(conn.Inbound()) != requests[i] {
+			t.Errorf("Connection %d: expected inbound %q, got %q", i, requests[i], conn.Inbound())
+		}
+		replayed, err := Replay(addr, conn, ReplayOptions{Timeout: 5 * time.Second})
+		if err != nil {
+			t.Fatalf("Connection %d: replay failed: %v", i, err)
+		}
+		if !bytes.Equal(replayed, conn.Outbound()) {
+			t.Errorf("Connection %d: expected replay to reproduce %q, got %q", i, conn.Outbound(), replayed)
+		}
+	}
+}
+
+func TestRead_SameIDInSeparateBlocks(t *testing.T) {
+	// Captures written before IDs were unique across runs number every run from 1
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, record := range []Record{
+		{Conn: 1, Direction: Inbound, Data: []byte("run 1, conn 1")},
+		{Conn: 1, Direction: Outbound, Data: []byte("answer")},
+		{Conn: 2, Direction: Inbound, Data: []byte("run 1, conn 2")},
+		{Conn: 1, Direction: Inbound, Data: []byte("run 2, conn 1")},
+	} {
+		enc.Encode(record)
+	}
+
+	connections, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	var got []string
+	for _, conn := range connections {
+		got = append(got, string(conn.Inbound()))
+	}
+	want := []string{"run 1, conn 1", "run 1, conn 2", "run 2, conn 1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected connections %q, got %q", want, got)
+	}
+}
+
+func TestRecorder_Rotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	recorder, err := NewRecorder(path, 200, 3)
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+	defer recorder.Close()
+
+	for i := uint64(1); i <= 5; i++ {
+		record := Record{Conn: i, Direction: Inbound, Data: bytes.Repeat([]byte("x"), 100)}
+		if err := recorder.WriteConnection([]Record{record}); err != nil {
+			t.Fatalf("WriteConnection failed: %v", err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("Expected %s to exist: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected at most 3 files, found %s.3", path)
+	}
+
+	connections, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(connections) != 1 || connections[0].ID != 5 {
+		t.Errorf("Expected the current file to hold only connection 5, got %+v", connections)
+	}
+}
+
+func TestClientIDFromRequest(t *testing.T) {
+	tests := []struct {
+		request  string
+		expected string
+	}{
+		{"GET / HTTP/1.1\r\nauthorization: basic Ym94LTE6c2VjcmV0\r\n\r\n", "box-1"},
+		{"GET / HTTP/1.1\r\nHost: x\r\n\r\nAuthorization: Basic Ym94LTE6c2VjcmV0\r\n", ""},
+		{"GET / HTTP/1.1\r\nAuthorization: Bearer token\r\n\r\n", ""},
+		{"GET / HTTP/1.1\r\nAuthorization: Basic !!!\r\n\r\n", ""},
+	}
+
+	for _, tt := range tests {
+		if got := clientIDFromRequest([]byte(tt.request)); got != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.request, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/logging"
+)
+
+// maxConnBytes bounds the data buffered per connection; legacy exchanges are a few KB
+const maxConnBytes = 256 * 1024
+
+// Filter selects the connections to capture by client ID (matricule) or remote IP
+type Filter struct {
+	clientIDs map[string]bool
+	ips       map[string]bool
+}
+
+// NewFilter creates a filter matching any of the given client IDs or IPs
+func NewFilter(clientIDs, ips []string) Filter {
+	f := Filter{
+		clientIDs: make(map[string]bool),
+		ips:       make(map[string]bool),
+	}
+	for _, id := range clientIDs {
+		f.clientIDs[id] = true
+	}
+	for _, ip := range ips {
+		f.ips[ip] = true
+	}
+	return f
+}
+
+// Listener wraps a net.Listener and records the raw traffic of matching connections
+// The client ID is only known once the request headers are read, so data is buffered
+// per connection and written to the recorder when the connection closes
+type Listener struct {
+	net.Listener
+	recorder *Recorder
+	filter   Filter
+	nextID   uint64 // ID of the last connection, numbered from the listener's start time
+}
+
+// NewListener creates a capturing listener
+// Connection IDs start from the start time in microseconds, so the runs appended to the
+// same capture file never reuse an ID
+func NewListener(inner net.Listener, recorder *Recorder, filter Filter) *Listener {
+	return &Listener{
+		Listener: inner,
+		recorder: recorder,
+		filter:   filter,
+		nextID:   uint64(time.Now().UnixMicro()),
+	}
+}
+
+// Accept waits for the next connection and wraps it when it may need to be captured
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	ipMatch := l.filter.ips[remoteIP(conn.RemoteAddr())]
+	if !ipMatch && len(l.filter.clientIDs) == 0 {
+		return conn, nil
+	}
+
+	return &captureConn{
+		Conn:     conn,
+		listener: l,
+		id:       atomic.AddUint64(&l.nextID, 1),
+		ipMatch:  ipMatch,
+	}, nil
+}
+
+// captureConn buffers everything read from and written to a connection
+type captureConn struct {
+	net.Conn
+	listener *Listener
+	id       uint64
+	ipMatch  bool
+
+	mu        sync.Mutex
+	records   []Record
+	size      int
+	truncated bool
+	closeOnce sync.Once
+}
+
+func (c *captureConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.record(Inbound, b[:n])
+	}
+	return n, err
+}
+
+func (c *captureConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.record(Outbound, b[:n])
+	}
+	return n, err
+}
+
+// record appends a chunk, dropping data past maxConnBytes
+func (c *captureConn) record(dir Direction, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size+len(data) > maxConnBytes {
+		c.truncated = true
+		return
+	}
+	c.size += len(data)
+	c.records = append(c.records, Record{
+		Time:      time.Now(),
+		Direction: dir,
+		Data:      append([]byte(nil), data...),
+	})
+}
+
+// Close closes the connection and writes the capture if the connection matches the filter
+func (c *captureConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(c.flush)
+	return err
+}
+
+func (c *captureConn) flush() {
+	c.mu.Lock()
+	records := c.records
+	truncated := c.truncated
+	c.records = nil
+	c.mu.Unlock()
+
+	if len(records) == 0 {
+		return
+	}
+
+	var inbound bytes.Buffer
+	for _, record := range records {
+		if record.Direction == Inbound {
+			inbound.Write(record.Data)
+		}
+	}
+	clientID := clientIDFromRequest(inbound.Bytes())
+	if !c.ipMatch && !c.listener.filter.clientIDs[clientID] {
+		return
+	}
+
+	remote := c.RemoteAddr().String()
+	for i := range records {
+		records[i].Conn = c.id
+		records[i].Remote = remote
+		records[i].ClientID = clientID
+	}
+	if truncated {
+		logging.Warnf("[CAPTURE] Connection %d from %s exceeded %d bytes, capture truncated", c.id, remote, maxConnBytes)
+	}
+	if err := c.listener.recorder.WriteConnection(records); err != nil {
+		logging.Errorf("[CAPTURE] Failed to write capture of connection %d from %s: %v", c.id, remote, err)
+		return
+	}
+	logging.Debugf("[CAPTURE] Recorded connection %d from %s (client %q, %d chunks)", c.id, remote, clientID, len(records))
+}
+
+// clientIDFromRequest extracts the Basic Auth username from raw request headers
+func clientIDFromRequest(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			return "" // End of headers
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "Authorization") {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) < 6 || !strings.EqualFold(value[:6], "Basic ") {
+			return ""
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[6:]))
+		if err != nil {
+			return ""
+		}
+		username, _, _ := strings.Cut(string(decoded), ":")
+		return username
+	}
+	return ""
+}
+
+// remoteIP returns the IP part of a connection address
+func remoteIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
@@ -0,0 +1,107 @@
+// Package capture records the raw bytes exchanged with legacy boxes and replays them
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Direction tells which side sent a chunk of data
+type Direction string
+
+const (
+	// Inbound is data sent by the box to the server
+	Inbound Direction = "in"
+	// Outbound is data sent by the server to the box
+	Outbound Direction = "out"
+)
+
+// Record is one chunk of data read from or written to a connection
+// Records are stored as JSON lines; Data is base64-encoded so any byte survives
+type Record struct {
+	Time      time.Time `json:"time"`
+	Conn      uint64    `json:"conn"` // Unique across the runs of the server, see NewListener
+	Remote    string    `json:"remote"`
+	ClientID  string    `json:"client,omitempty"`
+	Direction Direction `json:"dir"`
+	Data      []byte    `json:"data"`
+}
+
+// Connection groups the records of a single captured connection in order
+type Connection struct {
+	ID       uint64
+	Remote   string
+	ClientID string
+	Records  []Record
+}
+
+// Inbound returns everything the box sent on the connection
+func (c Connection) Inbound() []byte {
+	return c.join(Inbound)
+}
+
+// Outbound returns everything the server sent on the connection
+func (c Connection) Outbound() []byte {
+	return c.join(Outbound)
+}
+
+func (c Connection) join(dir Direction) []byte {
+	var buf bytes.Buffer
+	for _, record := range c.Records {
+		if record.Direction == dir {
+			buf.Write(record.Data)
+		}
+	}
+	return buf.Bytes()
+}
+
+// Read parses a capture stream into connections, in file order
+// The recorder writes the records of a connection in one block, so a connection is a run of
+// records with the same ID; files captured before IDs were unique across runs still replay
+// every connection on its own
+func Read(r io.Reader) ([]Connection, error) {
+	var connections []Connection
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*maxConnBytes)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if len(connections) == 0 || connections[len(connections)-1].ID != record.Conn {
+			connections = append(connections, Connection{
+				ID:       record.Conn,
+				Remote:   record.Remote,
+				ClientID: record.ClientID,
+			})
+		}
+		last := &connections[len(connections)-1]
+		last.Records = append(last.Records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return connections, nil
+}
+
+// ReadFile parses a capture file into connections
+func ReadFile(path string) ([]Connection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
@@ -0,0 +1,131 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Default rotation settings
+const (
+	DefaultMaxFileBytes = 10 * 1024 * 1024
+	DefaultMaxFiles     = 5
+)
+
+// Recorder appends captured connections to a file, rotating it when it grows too large
+// Rotated files are renamed path.1, path.2, ...; at most maxFiles files are kept, including the current one
+type Recorder struct {
+	mu           sync.Mutex
+	path         string
+	maxFileBytes int64
+	maxFiles     int
+	file         *os.File
+	size         int64
+}
+
+// NewRecorder opens (or creates) the capture file
+// Non-positive limits fall back to the defaults
+func NewRecorder(path string, maxFileBytes int64, maxFiles int) (*Recorder, error) {
+	if maxFileBytes <= 0 {
+		maxFileBytes = DefaultMaxFileBytes
+	}
+	if maxFiles <= 0 {
+		maxFiles = DefaultMaxFiles
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, err
+		}
+	}
+
+	r := &Recorder{
+		path:         path,
+		maxFileBytes: maxFileBytes,
+		maxFiles:     maxFiles,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the current capture file in append mode
+func (r *Recorder) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+// WriteConnection writes all records of a connection together
+// A connection is never split across files, so every file can be replayed on its own
+func (r *Recorder) WriteConnection(records []Record) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return fmt.Errorf("capture recorder is closed")
+	}
+	if r.size > 0 && r.size+int64(buf.Len()) > r.maxFileBytes {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := r.file.Write(buf.Bytes())
+	r.size += int64(n)
+	return err
+}
+
+// rotate shifts path.N to path.N+1, drops the oldest file, moves the current file to path.1 and starts a new one (caller holds mu)
+func (r *Recorder) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+
+	os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxFiles-1))
+	for i := r.maxFiles - 2; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if r.maxFiles > 1 {
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return err
+	}
+
+	return r.open()
+}
+
+// Close closes the capture file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
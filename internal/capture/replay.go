@@ -0,0 +1,56 @@
+package capture
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+// maxReplayGap caps the pause between chunks when timing is preserved
+const maxReplayGap = 5 * time.Second
+
+// ReplayOptions controls how a captured connection is sent back to a server
+type ReplayOptions struct {
+	// Timeout bounds the whole exchange (default 10s)
+	Timeout time.Duration
+	// PreserveTiming waits between inbound chunks as long as the box did (capped at 5s)
+	PreserveTiming bool
+}
+
+// Replay sends the inbound chunks of a captured connection to addr, one write per chunk,
+// and returns everything the server sent back until it closed the connection
+func Replay(addr string, c Connection, opts ReplayOptions) ([]byte, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	var last time.Time
+	for _, record := range c.Records {
+		if record.Direction != Inbound {
+			continue
+		}
+		if opts.PreserveTiming && !last.IsZero() {
+			gap := record.Time.Sub(last)
+			if gap > maxReplayGap {
+				gap = maxReplayGap
+			}
+			if gap > 0 {
+				time.Sleep(gap)
+			}
+		}
+		last = record.Time
+		if _, err := conn.Write(record.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	return io.ReadAll(conn)
+}
@@ -2,20 +2,46 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"net"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/logging"
+	"github.com/essensys-hub/essensys-server-backend/internal/server"
 )
 
 // Config holds all configuration for the server
 type Config struct {
-	Server ServerConfig `yaml:"server"`
-	Auth   AuthConfig   `yaml:"auth"`
-	Logging LoggingConfig `yaml:"logging"`
+	Server   ServerConfig   `yaml:"server"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Logging  LoggingConfig  `yaml:"logging"`
+	Metrics  MetricsConfig  `yaml:"metrics"`
+	API      APIConfig      `yaml:"api"`
+	Presence PresenceConfig `yaml:"presence"`
+	Tenants  []TenantConfig `yaml:"tenants"`
+	Capture  CaptureConfig  `yaml:"capture"`
+
+	// notices are the messages found while loading, kept until LogConfig because logging is
+	// configured from this very configuration
+	notices []notice
+}
+
+// notice is a message found while loading the configuration
+type notice struct {
+	level logging.Level
+	msg   string
+}
+
+func (c *Config) warnf(format string, args ...interface{}) {
+	c.notices = append(c.notices, notice{logging.LevelWarn, fmt.Sprintf(format, args...)})
+}
+
+func (c *Config) infof(format string, args ...interface{}) {
+	c.notices = append(c.notices, notice{logging.LevelInfo, fmt.Sprintf(format, args...)})
 }
 
 // ServerConfig holds server-specific configuration
@@ -24,6 +50,56 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration `yaml:"read_timeout"`
 	WriteTimeout time.Duration `yaml:"write_timeout"`
 	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	// ShutdownTimeout bounds the wait for active connections on shutdown (0 closes them at once)
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// Conformance is the protocol conformance mode of legacy responses: off, warn or strict
+	Conformance string `yaml:"conformance"`
+	// Limits bounds the resources legacy clients can use
+	Limits LimitsConfig `yaml:"limits"`
+}
+
+// LimitsConfig holds the resource limits of the legacy server, see server.Limits
+// Defaults come from server.DefaultLimits; a zero value disables that limit
+type LimitsConfig struct {
+	MaxConnections    int           `yaml:"max_connections"`
+	ConnRatePerIP     float64       `yaml:"conn_rate_per_ip"`
+	ConnBurstPerIP    int           `yaml:"conn_burst_per_ip"`
+	RequestRatePerIP  float64       `yaml:"request_rate_per_ip"`
+	RequestBurstPerIP int           `yaml:"request_burst_per_ip"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes"`
+	MaxHeaders        int           `yaml:"max_headers"`
+	MaxBodyBytes      int           `yaml:"max_body_bytes"`
+	HeaderTimeout     time.Duration `yaml:"header_timeout"`
+}
+
+// newLimitsConfig returns the configuration of the given server limits
+func newLimitsConfig(l server.Limits) LimitsConfig {
+	return LimitsConfig{
+		MaxConnections:    l.MaxConnections,
+		ConnRatePerIP:     l.ConnRatePerIP,
+		ConnBurstPerIP:    l.ConnBurstPerIP,
+		RequestRatePerIP:  l.RequestRatePerIP,
+		RequestBurstPerIP: l.RequestBurstPerIP,
+		MaxHeaderBytes:    l.MaxHeaderBytes,
+		MaxHeaders:        l.MaxHeaders,
+		MaxBodyBytes:      l.MaxBodyBytes,
+		HeaderTimeout:     l.HeaderTimeout,
+	}
+}
+
+// ServerLimits returns the limits to apply to the legacy server
+func (l LimitsConfig) ServerLimits() server.Limits {
+	return server.Limits{
+		MaxConnections:    l.MaxConnections,
+		ConnRatePerIP:     l.ConnRatePerIP,
+		ConnBurstPerIP:    l.ConnBurstPerIP,
+		RequestRatePerIP:  l.RequestRatePerIP,
+		RequestBurstPerIP: l.RequestBurstPerIP,
+		MaxHeaderBytes:    l.MaxHeaderBytes,
+		MaxHeaders:        l.MaxHeaders,
+		MaxBodyBytes:      l.MaxBodyBytes,
+		HeaderTimeout:     l.HeaderTimeout,
+	}
 }
 
 // AuthConfig holds authentication configuration
@@ -38,16 +114,87 @@ type LoggingConfig struct {
 	Format string `yaml:"format"`
 }
 
+// MetricsConfig holds the Prometheus metrics endpoint configuration
+// Metrics are served on their own listener so they are never exposed on port 80
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Address string `yaml:"address"`
+}
+
+// APIConfig holds the standard HTTP(S) listener serving the admin API
+// When enabled, the legacy port only serves the firmware routes and this listener only the admin routes
+type APIConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Address string `yaml:"address"`
+	// TLSCertFile and TLSKeyFile enable HTTPS when both are set
+	TLSCertFile string `yaml:"tls_cert_file"`
+	TLSKeyFile  string `yaml:"tls_key_file"`
+	// ServeMetrics also serves /metrics on this listener
+	ServeMetrics bool `yaml:"serve_metrics"`
+}
+
+// TLSEnabled reports whether the API listener serves HTTPS
+func (a APIConfig) TLSEnabled() bool {
+	return a.TLSCertFile != "" && a.TLSKeyFile != ""
+}
+
+// PresenceConfig holds the offline detection configuration
+type PresenceConfig struct {
+	OfflineTimeout time.Duration `yaml:"offline_timeout"` // silence after which a box is marked offline
+	CheckInterval  time.Duration `yaml:"check_interval"`  // how often the monitor checks for silent boxes
+}
+
+// CaptureConfig holds the raw traffic capture configuration
+// Only connections from the listed client IDs or IPs are recorded
+type CaptureConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	Path         string   `yaml:"path"`
+	Clients      []string `yaml:"clients"`        // matricules to capture
+	IPs          []string `yaml:"ips"`            // remote IPs to capture
+	MaxFileBytes int64    `yaml:"max_file_bytes"` // rotate the capture file past this size
+	MaxFiles     int      `yaml:"max_files"`      // capture files kept, including the current one
+}
+
+// TenantConfig describes a customer and the boxes it owns
+// Boxes not listed in any tenant belong to the default tenant
+type TenantConfig struct {
+	ID          string       `yaml:"id"`
+	Name        string       `yaml:"name"`
+	AdminTokens []string     `yaml:"admin_tokens"` // Bearer tokens granting admin access to this tenant only
+	Clients     []string     `yaml:"clients"`      // matricules of the tenant's boxes
+	Quotas      TenantQuotas `yaml:"quotas"`
+}
+
+// TenantQuotas limits a tenant's resources (0 means unlimited)
+type TenantQuotas struct {
+	MaxClients        int `yaml:"max_clients"`
+	MaxPendingActions int `yaml:"max_pending_actions"`
+}
+
+// AdminTokens returns the mapping of every tenant admin token to its tenant ID
+func (c *Config) AdminTokens() map[string]string {
+	tokens := make(map[string]string)
+	for _, tenant := range c.Tenants {
+		for _, token := range tenant.AdminTokens {
+			tokens[token] = tenant.ID
+		}
+	}
+	return tokens
+}
+
 // Load loads configuration from environment variables and optionally a YAML file
 // Environment variables take precedence over YAML file values
 func Load() (*Config, error) {
 	// Start with default configuration
 	cfg := &Config{
 		Server: ServerConfig{
-			Port:         80, // MANDATORY for BP_MQX_ETH client compatibility
-			ReadTimeout:  10 * time.Second,
-			WriteTimeout: 10 * time.Second,
-			IdleTimeout:  60 * time.Second,
+			Port:            80, // MANDATORY for BP_MQX_ETH client compatibility
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 10 * time.Second,
+			Conformance:     "warn",
+			Limits:          newLimitsConfig(server.DefaultLimits()),
 		},
 		Auth: AuthConfig{
 			Enabled: false, // Disabled by default
@@ -57,13 +204,31 @@ func Load() (*Config, error) {
 			Level:  "info",
 			Format: "text",
 		},
+		Metrics: MetricsConfig{
+			Enabled: false,
+			Address: "127.0.0.1:9100",
+		},
+		API: APIConfig{
+			Enabled: false,
+			Address: ":8443",
+		},
+		Presence: PresenceConfig{
+			OfflineTimeout: 6 * time.Second, // 3x the slowest (2s) polling interval
+			CheckInterval:  1 * time.Second,
+		},
+		Capture: CaptureConfig{
+			Enabled:      false,
+			Path:         "captures/legacy.jsonl",
+			MaxFileBytes: 10 * 1024 * 1024,
+			MaxFiles:     5,
+		},
 	}
 
 	// Try to load from config.yaml if it exists
 	if err := loadFromYAML(cfg, "config.yaml"); err != nil {
 		// Log but don't fail if config file doesn't exist
 		if !os.IsNotExist(err) {
-			log.Printf("Warning: error loading config.yaml: %v", err)
+			cfg.warnf("error loading config.yaml: %v", err)
 		}
 	}
 
@@ -100,7 +265,30 @@ func loadFromEnv(cfg *Config) {
 		if port, err := strconv.Atoi(portStr); err == nil {
 			cfg.Server.Port = port
 		} else {
-			log.Printf("Warning: invalid SERVER_PORT value '%s', using default", portStr)
+			cfg.warnf("invalid SERVER_PORT value '%s', using default", portStr)
+		}
+	}
+
+	// SHUTDOWN_TIMEOUT
+	if timeoutStr := os.Getenv("SHUTDOWN_TIMEOUT"); timeoutStr != "" {
+		if timeout, err := time.ParseDuration(timeoutStr); err == nil {
+			cfg.Server.ShutdownTimeout = timeout
+		} else {
+			cfg.warnf("invalid SHUTDOWN_TIMEOUT value '%s', using default", timeoutStr)
+		}
+	}
+
+	// CONFORMANCE_MODE
+	if conformance := os.Getenv("CONFORMANCE_MODE"); conformance != "" {
+		cfg.Server.Conformance = conformance
+	}
+
+	// MAX_CONNECTIONS
+	if maxConnsStr := os.Getenv("MAX_CONNECTIONS"); maxConnsStr != "" {
+		if maxConns, err := strconv.Atoi(maxConnsStr); err == nil {
+			cfg.Server.Limits.MaxConnections = maxConns
+		} else {
+			cfg.warnf("invalid MAX_CONNECTIONS value '%s', using default", maxConnsStr)
 		}
 	}
 
@@ -109,29 +297,114 @@ func loadFromEnv(cfg *Config) {
 		cfg.Logging.Level = logLevel
 	}
 
+	// LOG_FORMAT
+	if logFormat := os.Getenv("LOG_FORMAT"); logFormat != "" {
+		cfg.Logging.Format = logFormat
+	}
+
 	// AUTH_ENABLED
 	if authEnabledStr := os.Getenv("AUTH_ENABLED"); authEnabledStr != "" {
 		if authEnabled, err := strconv.ParseBool(authEnabledStr); err == nil {
 			cfg.Auth.Enabled = authEnabled
 		} else {
-			log.Printf("Warning: invalid AUTH_ENABLED value '%s', using default", authEnabledStr)
+			cfg.warnf("invalid AUTH_ENABLED value '%s', using default", authEnabledStr)
+		}
+	}
+
+	// PRESENCE_OFFLINE_TIMEOUT
+	if timeoutStr := os.Getenv("PRESENCE_OFFLINE_TIMEOUT"); timeoutStr != "" {
+		if timeout, err := time.ParseDuration(timeoutStr); err == nil {
+			cfg.Presence.OfflineTimeout = timeout
+		} else {
+			cfg.warnf("invalid PRESENCE_OFFLINE_TIMEOUT value '%s', using default", timeoutStr)
+		}
+	}
+
+	// METRICS_ENABLED
+	if metricsEnabledStr := os.Getenv("METRICS_ENABLED"); metricsEnabledStr != "" {
+		if metricsEnabled, err := strconv.ParseBool(metricsEnabledStr); err == nil {
+			cfg.Metrics.Enabled = metricsEnabled
+		} else {
+			cfg.warnf("invalid METRICS_ENABLED value '%s', using default", metricsEnabledStr)
+		}
+	}
+
+	// METRICS_ADDRESS
+	if metricsAddr := os.Getenv("METRICS_ADDRESS"); metricsAddr != "" {
+		cfg.Metrics.Address = metricsAddr
+	}
+
+	// API_ENABLED
+	if apiEnabledStr := os.Getenv("API_ENABLED"); apiEnabledStr != "" {
+		if apiEnabled, err := strconv.ParseBool(apiEnabledStr); err == nil {
+			cfg.API.Enabled = apiEnabled
+		} else {
+			cfg.warnf("invalid API_ENABLED value '%s', using default", apiEnabledStr)
 		}
 	}
 
+	// API_ADDRESS
+	if apiAddr := os.Getenv("API_ADDRESS"); apiAddr != "" {
+		cfg.API.Address = apiAddr
+	}
+
+	// API_TLS_CERT_FILE / API_TLS_KEY_FILE
+	if certFile := os.Getenv("API_TLS_CERT_FILE"); certFile != "" {
+		cfg.API.TLSCertFile = certFile
+	}
+	if keyFile := os.Getenv("API_TLS_KEY_FILE"); keyFile != "" {
+		cfg.API.TLSKeyFile = keyFile
+	}
+
+	// CAPTURE_ENABLED
+	if captureEnabledStr := os.Getenv("CAPTURE_ENABLED"); captureEnabledStr != "" {
+		if captureEnabled, err := strconv.ParseBool(captureEnabledStr); err == nil {
+			cfg.Capture.Enabled = captureEnabled
+		} else {
+			cfg.warnf("invalid CAPTURE_ENABLED value '%s', using default", captureEnabledStr)
+		}
+	}
+
+	// CAPTURE_PATH
+	if capturePath := os.Getenv("CAPTURE_PATH"); capturePath != "" {
+		cfg.Capture.Path = capturePath
+	}
+
+	// CAPTURE_CLIENTS (format: "client1,client2")
+	if captureClients := os.Getenv("CAPTURE_CLIENTS"); captureClients != "" {
+		cfg.Capture.Clients = parseList(captureClients)
+	}
+
+	// CAPTURE_IPS (format: "192.168.1.10,192.168.1.11")
+	if captureIPs := os.Getenv("CAPTURE_IPS"); captureIPs != "" {
+		cfg.Capture.IPs = parseList(captureIPs)
+	}
+
 	// CLIENT_CREDENTIALS (format: "client1:pass1,client2:pass2")
 	if clientCreds := os.Getenv("CLIENT_CREDENTIALS"); clientCreds != "" {
-		clients := parseClientCredentials(clientCreds)
+		clients := cfg.parseClientCredentials(clientCreds)
 		if len(clients) > 0 {
 			cfg.Auth.Clients = clients
 		}
 	}
 }
 
+// parseList parses a comma-separated list, skipping empty items
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // parseClientCredentials parses the CLIENT_CREDENTIALS environment variable
 // Format: "client1:pass1,client2:pass2"
-func parseClientCredentials(creds string) map[string]string {
+func (c *Config) parseClientCredentials(creds string) map[string]string {
 	clients := make(map[string]string)
-	
+
 	pairs := strings.Split(creds, ",")
 	for _, pair := range pairs {
 		parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
@@ -142,10 +415,10 @@ func parseClientCredentials(creds string) map[string]string {
 				clients[matricule] = key
 			}
 		} else {
-			log.Printf("Warning: invalid client credential format '%s', expected 'matricule:key'", pair)
+			c.warnf("invalid client credential format '%s', expected 'matricule:key'", pair)
 		}
 	}
-	
+
 	return clients
 }
 
@@ -158,9 +431,9 @@ func (c *Config) Validate() error {
 
 	// Warn if not using port 80
 	if c.Server.Port != 80 {
-		log.Printf("WARNING: Server configured to use port %d instead of 80", c.Server.Port)
-		log.Printf("WARNING: BP_MQX_ETH clients are hardcoded to connect to port 80")
-		log.Printf("WARNING: This configuration may not work with legacy clients")
+		c.warnf("Server configured to use port %d instead of 80", c.Server.Port)
+		c.warnf("BP_MQX_ETH clients are hardcoded to connect to port 80")
+		c.warnf("This configuration may not work with legacy clients")
 	}
 
 	// Validate timeouts
@@ -173,48 +446,241 @@ func (c *Config) Validate() error {
 	if c.Server.IdleTimeout <= 0 {
 		return fmt.Errorf("invalid idle timeout: %v (must be positive)", c.Server.IdleTimeout)
 	}
+	if c.Server.ShutdownTimeout < 0 {
+		return fmt.Errorf("invalid shutdown timeout: %v (must not be negative)", c.Server.ShutdownTimeout)
+	}
+
+	// Validate conformance mode (empty means warn)
+	validConformanceModes := map[string]bool{
+		"off":    true,
+		"warn":   true,
+		"strict": true,
+	}
+	if c.Server.Conformance != "" && !validConformanceModes[strings.ToLower(c.Server.Conformance)] {
+		return fmt.Errorf("invalid conformance mode: %s (must be off, warn, or strict)", c.Server.Conformance)
+	}
+
+	// Validate resource limits
+	if err := c.Server.Limits.validate(); err != nil {
+		return err
+	}
+
+	// Validate presence settings
+	if c.Presence.OfflineTimeout <= 0 {
+		return fmt.Errorf("invalid offline timeout: %v (must be positive)", c.Presence.OfflineTimeout)
+	}
+	if c.Presence.CheckInterval <= 0 {
+		return fmt.Errorf("invalid presence check interval: %v (must be positive)", c.Presence.CheckInterval)
+	}
 
 	// Validate log level
 	validLogLevels := map[string]bool{
-		"debug": true,
-		"info":  true,
-		"warn":  true,
-		"error": true,
+		"debug":   true,
+		"info":    true,
+		"warn":    true,
+		"warning": true,
+		"error":   true,
 	}
 	if !validLogLevels[strings.ToLower(c.Logging.Level)] {
-		return fmt.Errorf("invalid log level: %s (must be debug, info, warn, or error)", c.Logging.Level)
+		return fmt.Errorf("invalid log level: %s (must be debug, info, warn, warning, or error)", c.Logging.Level)
+	}
+
+	// Validate log format (empty means text)
+	validLogFormats := map[string]bool{
+		"text": true,
+		"json": true,
+	}
+	if c.Logging.Format != "" && !validLogFormats[strings.ToLower(c.Logging.Format)] {
+		return fmt.Errorf("invalid log format: %s (must be text or json)", c.Logging.Format)
+	}
+
+	// Validate metrics listener
+	if c.Metrics.Enabled {
+		_, portStr, err := net.SplitHostPort(c.Metrics.Address)
+		if err != nil {
+			return fmt.Errorf("invalid metrics address: %s (%v)", c.Metrics.Address, err)
+		}
+		if portStr == strconv.Itoa(c.Server.Port) {
+			return fmt.Errorf("invalid metrics address: %s (must not share the server port %d)", c.Metrics.Address, c.Server.Port)
+		}
+	}
+
+	// Validate API listener
+	if c.API.Enabled {
+		_, portStr, err := net.SplitHostPort(c.API.Address)
+		if err != nil {
+			return fmt.Errorf("invalid API address: %s (%v)", c.API.Address, err)
+		}
+		if portStr == strconv.Itoa(c.Server.Port) {
+			return fmt.Errorf("invalid API address: %s (must not share the server port %d)", c.API.Address, c.Server.Port)
+		}
+		if c.Metrics.Enabled && c.Metrics.Address == c.API.Address {
+			return fmt.Errorf("invalid API address: %s (already used by metrics, set api.serve_metrics instead)", c.API.Address)
+		}
+		if (c.API.TLSCertFile == "") != (c.API.TLSKeyFile == "") {
+			return fmt.Errorf("invalid API TLS configuration: tls_cert_file and tls_key_file must be set together")
+		}
+	}
+
+	// Validate traffic capture
+	if c.Capture.Enabled {
+		if c.Capture.Path == "" {
+			return fmt.Errorf("invalid capture configuration: path is required")
+		}
+		if len(c.Capture.Clients) == 0 && len(c.Capture.IPs) == 0 {
+			return fmt.Errorf("invalid capture configuration: at least one client or IP is required")
+		}
+		for _, ip := range c.Capture.IPs {
+			if net.ParseIP(ip) == nil {
+				return fmt.Errorf("invalid capture IP: %s", ip)
+			}
+		}
+		if c.Capture.MaxFileBytes <= 0 || c.Capture.MaxFiles <= 0 {
+			return fmt.Errorf("invalid capture rotation: max_file_bytes and max_files must be positive")
+		}
+	}
+
+	// Validate tenants
+	if err := c.validateTenants(); err != nil {
+		return err
 	}
 
 	// Validate authentication
 	if c.Auth.Enabled {
 		if len(c.Auth.Clients) == 0 {
-			log.Printf("WARNING: Authentication is enabled but no client credentials are configured")
-			log.Printf("WARNING: All requests will be rejected with 401 Unauthorized")
+			c.warnf("Authentication is enabled but no client credentials are configured")
+			c.warnf("All requests will be rejected with 401 Unauthorized")
 		}
 	} else {
-		log.Printf("INFO: Authentication is disabled - all requests will be accepted without credentials")
+		c.infof("Authentication is disabled - all requests will be accepted without credentials")
+	}
+
+	return nil
+}
+
+// validateTenants checks tenant IDs, token and client uniqueness, and quotas
+func (c *Config) validateTenants() error {
+	tenantIDs := make(map[string]bool)
+	tokenOwners := make(map[string]string)
+	clientOwners := make(map[string]string)
+
+	for _, tenant := range c.Tenants {
+		if tenant.ID == "" {
+			return fmt.Errorf("invalid tenant: id is required")
+		}
+		if tenantIDs[tenant.ID] {
+			return fmt.Errorf("invalid tenant %s: duplicate id", tenant.ID)
+		}
+		tenantIDs[tenant.ID] = true
+
+		if len(tenant.AdminTokens) == 0 {
+			c.warnf("Tenant %s has no admin tokens, no one can manage its boxes through the admin API", tenant.ID)
+		}
+		for _, token := range tenant.AdminTokens {
+			if token == "" {
+				return fmt.Errorf("invalid tenant %s: empty admin token", tenant.ID)
+			}
+			if owner, exists := tokenOwners[token]; exists {
+				return fmt.Errorf("invalid tenant %s: admin token already used by tenant %s", tenant.ID, owner)
+			}
+			tokenOwners[token] = tenant.ID
+		}
+
+		for _, clientID := range tenant.Clients {
+			if owner, exists := clientOwners[clientID]; exists {
+				return fmt.Errorf("invalid tenant %s: client %s already belongs to tenant %s", tenant.ID, clientID, owner)
+			}
+			clientOwners[clientID] = tenant.ID
+		}
+
+		if tenant.Quotas.MaxClients < 0 || tenant.Quotas.MaxPendingActions < 0 {
+			return fmt.Errorf("invalid tenant %s: quotas must not be negative", tenant.ID)
+		}
+		if tenant.Quotas.MaxClients > 0 && len(tenant.Clients) > tenant.Quotas.MaxClients {
+			return fmt.Errorf("invalid tenant %s: %d clients exceed max_clients quota of %d", tenant.ID, len(tenant.Clients), tenant.Quotas.MaxClients)
+		}
 	}
 
 	return nil
 }
 
-// LogConfig logs the current configuration (without sensitive data)
+// LogConfig logs the messages found while loading, then the current configuration (without
+// sensitive data)
+// Call it once logging is configured, so that they follow its level and format
 func (c *Config) LogConfig() {
-	log.Printf("===========================================")
-	log.Printf("Essensys Backend Server Configuration")
-	log.Printf("===========================================")
-	log.Printf("Server:")
-	log.Printf("  Port: %d %s", c.Server.Port, c.portWarning())
-	log.Printf("  Read Timeout: %v", c.Server.ReadTimeout)
-	log.Printf("  Write Timeout: %v", c.Server.WriteTimeout)
-	log.Printf("  Idle Timeout: %v", c.Server.IdleTimeout)
-	log.Printf("Authentication:")
-	log.Printf("  Enabled: %v", c.Auth.Enabled)
-	log.Printf("  Configured Clients: %d", len(c.Auth.Clients))
-	log.Printf("Logging:")
-	log.Printf("  Level: %s", c.Logging.Level)
-	log.Printf("  Format: %s", c.Logging.Format)
-	log.Printf("===========================================")
+	for _, n := range c.notices {
+		if n.level == logging.LevelWarn {
+			logging.Warnf("%s", n.msg)
+		} else {
+			logging.Infof("%s", n.msg)
+		}
+	}
+	c.notices = nil
+
+	logging.Infof("===========================================")
+	logging.Infof("Essensys Backend Server Configuration")
+	logging.Infof("===========================================")
+	logging.Infof("Server:")
+	logging.Infof("  Port: %d %s", c.Server.Port, c.portWarning())
+	logging.Infof("  Read Timeout: %v", c.Server.ReadTimeout)
+	logging.Infof("  Write Timeout: %v", c.Server.WriteTimeout)
+	logging.Infof("  Idle Timeout: %v", c.Server.IdleTimeout)
+	logging.Infof("  Shutdown Timeout: %v", c.Server.ShutdownTimeout)
+	logging.Infof("  Conformance: %s", c.Server.Conformance)
+	logging.Infof("  Max Connections: %d", c.Server.Limits.MaxConnections)
+	logging.Infof("  Rate per IP: %g conn/s, %g req/s", c.Server.Limits.ConnRatePerIP, c.Server.Limits.RequestRatePerIP)
+	logging.Infof("  Max Header/Body Bytes: %d/%d", c.Server.Limits.MaxHeaderBytes, c.Server.Limits.MaxBodyBytes)
+	logging.Infof("Authentication:")
+	logging.Infof("  Enabled: %v", c.Auth.Enabled)
+	logging.Infof("  Configured Clients: %d", len(c.Auth.Clients))
+	logging.Infof("Logging:")
+	logging.Infof("  Level: %s", c.Logging.Level)
+	logging.Infof("  Format: %s", c.Logging.Format)
+	logging.Infof("Presence:")
+	logging.Infof("  Offline Timeout: %v", c.Presence.OfflineTimeout)
+	logging.Infof("  Check Interval: %v", c.Presence.CheckInterval)
+	logging.Infof("Capture:")
+	logging.Infof("  Enabled: %v", c.Capture.Enabled)
+	if c.Capture.Enabled {
+		logging.Infof("  Path: %s", c.Capture.Path)
+		logging.Infof("  Clients: %v", c.Capture.Clients)
+		logging.Infof("  IPs: %v", c.Capture.IPs)
+	}
+	logging.Infof("Tenants: %d", len(c.Tenants))
+	for _, tenant := range c.Tenants {
+		logging.Infof("  %s: %d clients, %d admin tokens", tenant.ID, len(tenant.Clients), len(tenant.AdminTokens))
+	}
+	logging.Infof("API:")
+	logging.Infof("  Enabled: %v", c.API.Enabled)
+	if c.API.Enabled {
+		logging.Infof("  Address: %s", c.API.Address)
+		logging.Infof("  TLS: %v", c.API.TLSEnabled())
+		logging.Infof("  Serve Metrics: %v", c.API.ServeMetrics)
+	}
+	logging.Infof("Metrics:")
+	logging.Infof("  Enabled: %v", c.Metrics.Enabled)
+	logging.Infof("  Address: %s", c.Metrics.Address)
+	logging.Infof("===========================================")
+}
+
+// validate checks the resource limits
+func (l LimitsConfig) validate() error {
+	if l.MaxConnections < 0 {
+		return fmt.Errorf("invalid max connections: %d (must not be negative)", l.MaxConnections)
+	}
+	if l.ConnRatePerIP < 0 || l.RequestRatePerIP < 0 {
+		return fmt.Errorf("invalid rate limit: rates per IP must not be negative")
+	}
+	if (l.ConnRatePerIP > 0 && l.ConnBurstPerIP < 1) || (l.RequestRatePerIP > 0 && l.RequestBurstPerIP < 1) {
+		return fmt.Errorf("invalid rate limit: a burst of at least 1 is required when a rate is set")
+	}
+	if l.MaxHeaderBytes < 0 || l.MaxHeaders < 0 || l.MaxBodyBytes < 0 {
+		return fmt.Errorf("invalid request limits: max_header_bytes, max_headers and max_body_bytes must not be negative")
+	}
+	if l.HeaderTimeout < 0 {
+		return fmt.Errorf("invalid header timeout: %v (must not be negative)", l.HeaderTimeout)
+	}
+	return nil
 }
 
 // portWarning returns a warning message if not using port 80
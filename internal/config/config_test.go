@@ -1,9 +1,16 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
+	"log"
 	"os"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/logging"
+	"github.com/essensys-hub/essensys-server-backend/internal/server"
 )
 
 func TestLoad_Defaults(t *testing.T) {
@@ -34,6 +41,9 @@ func TestLoad_Defaults(t *testing.T) {
 	if cfg.Logging.Level != "info" {
 		t.Errorf("Expected default log level 'info', got '%s'", cfg.Logging.Level)
 	}
+	if limits := cfg.Server.Limits.ServerLimits(); limits != server.DefaultLimits() {
+		t.Errorf("Expected the server's default limits, got %+v", limits)
+	}
 }
 
 func TestLoad_EnvironmentVariables(t *testing.T) {
@@ -122,7 +132,7 @@ func TestParseClientCredentials(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := parseClientCredentials(tt.input)
+			result := (&Config{}).parseClientCredentials(tt.input)
 			if len(result) != len(tt.expected) {
 				t.Errorf("Expected %d clients, got %d", len(tt.expected), len(result))
 			}
@@ -181,6 +191,129 @@ func TestValidate_InvalidLogLevel(t *testing.T) {
 	}
 }
 
+func TestValidate_LogLevelsMatchLogging(t *testing.T) {
+	os.Clearenv()
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	cfg.Auth.Enabled = false
+
+	// Every name logging.ParseLevel accepts must pass validation
+	for _, level := range []string{"debug", "info", "warn", "warning", "error", "WARN"} {
+		cfg.Logging.Level = level
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Expected log level %q to be valid, got %v", level, err)
+		}
+	}
+}
+
+func TestValidate_InvalidLogFormat(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{
+			Port:         80,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  60 * time.Second,
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "xml",
+		},
+		Presence: PresenceConfig{
+			OfflineTimeout: 6 * time.Second,
+			CheckInterval:  1 * time.Second,
+		},
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Error("Expected validation error for invalid log format, got nil")
+	}
+}
+
+func TestLoad_LogFormatFromEnv(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("LOG_FORMAT", "json")
+	defer os.Clearenv()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.Logging.Format != "json" {
+		t.Errorf("Expected log format 'json' from env, got '%s'", cfg.Logging.Format)
+	}
+}
+
+func TestValidate_MetricsAddressSharesServerPort(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{
+			Port:         80,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  60 * time.Second,
+		},
+		Logging: LoggingConfig{
+			Level: "info",
+		},
+		Metrics: MetricsConfig{
+			Enabled: true,
+			Address: ":80",
+		},
+		Presence: PresenceConfig{
+			OfflineTimeout: 6 * time.Second,
+			CheckInterval:  1 * time.Second,
+		},
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Error("Expected validation error for metrics on the server port, got nil")
+	}
+}
+
+func TestLoad_MetricsFromEnv(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("METRICS_ENABLED", "true")
+	os.Setenv("METRICS_ADDRESS", ":9200")
+	defer os.Clearenv()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if !cfg.Metrics.Enabled {
+		t.Error("Expected metrics to be enabled from env")
+	}
+	if cfg.Metrics.Address != ":9200" {
+		t.Errorf("Expected metrics address ':9200' from env, got '%s'", cfg.Metrics.Address)
+	}
+}
+
+func TestValidate_InvalidOfflineTimeout(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{
+			Port:         80,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  60 * time.Second,
+		},
+		Logging: LoggingConfig{
+			Level: "info",
+		},
+		Presence: PresenceConfig{
+			OfflineTimeout: 0,
+			CheckInterval:  1 * time.Second,
+		},
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Error("Expected validation error for invalid offline timeout, got nil")
+	}
+}
+
 func TestValidate_InvalidTimeout(t *testing.T) {
 	cfg := &Config{
 		Server: ServerConfig{
@@ -261,3 +394,271 @@ logging:
 		t.Errorf("Expected 2 clients from YAML, got %d", len(cfg.Auth.Clients))
 	}
 }
+
+func TestValidate_Tenants(t *testing.T) {
+	base := func() *Config {
+		return &Config{
+			Server: ServerConfig{
+				Port:         80,
+				ReadTimeout:  10 * time.Second,
+				WriteTimeout: 10 * time.Second,
+				IdleTimeout:  60 * time.Second,
+			},
+			Logging: LoggingConfig{
+				Level: "info",
+			},
+			Presence: PresenceConfig{
+				OfflineTimeout: 6 * time.Second,
+				CheckInterval:  1 * time.Second,
+			},
+			Tenants: []TenantConfig{
+				{ID: "acme", AdminTokens: []string{"t1"}, Clients: []string{"box-a"}},
+				{ID: "globex", AdminTokens: []string{"t2"}, Clients: []string{"box-g"}},
+			},
+		}
+	}
+
+	if err := base().Validate(); err != nil {
+		t.Fatalf("Expected valid tenants, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+	}{
+		{"missing id", func(cfg *Config) { cfg.Tenants[0].ID = "" }},
+		{"duplicate id", func(cfg *Config) { cfg.Tenants[1].ID = "acme" }},
+		{"shared token", func(cfg *Config) { cfg.Tenants[1].AdminTokens = []string{"t1"} }},
+		{"shared client", func(cfg *Config) { cfg.Tenants[1].Clients = []string{"box-a"} }},
+		{"client quota", func(cfg *Config) {
+			cfg.Tenants[0].Clients = []string{"box-a", "box-b"}
+			cfg.Tenants[0].Quotas.MaxClients = 1
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base()
+			tt.modify(cfg)
+			if err := cfg.Validate(); err == nil {
+				t.Errorf("Expected validation error for %s, got nil", tt.name)
+			}
+		})
+	}
+
+	tokens := base().AdminTokens()
+	if tokens["t1"] != "acme" || tokens["t2"] != "globex" {
+		t.Errorf("Unexpected admin token mapping: %v", tokens)
+	}
+}
+
+func TestLoad_CaptureFromEnv(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("CAPTURE_ENABLED", "true")
+	os.Setenv("CAPTURE_CLIENTS", "box-1, box-2")
+	os.Setenv("CAPTURE_IPS", "192.168.0.151")
+	defer os.Clearenv()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	if !cfg.Capture.Enabled {
+		t.Error("Expected capture enabled from env")
+	}
+	if len(cfg.Capture.Clients) != 2 || cfg.Capture.Clients[1] != "box-2" {
+		t.Errorf("Expected clients [box-1 box-2], got %v", cfg.Capture.Clients)
+	}
+	if len(cfg.Capture.IPs) != 1 || cfg.Capture.IPs[0] != "192.168.0.151" {
+		t.Errorf("Expected IPs [192.168.0.151], got %v", cfg.Capture.IPs)
+	}
+}
+
+func TestValidate_CaptureRequiresFilter(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{
+			Port:         80,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  60 * time.Second,
+		},
+		Logging: LoggingConfig{
+			Level: "info",
+		},
+		Presence: PresenceConfig{
+			OfflineTimeout: 6 * time.Second,
+			CheckInterval:  1 * time.Second,
+		},
+		Capture: CaptureConfig{
+			Enabled:      true,
+			Path:         "capture.jsonl",
+			MaxFileBytes: 1024,
+			MaxFiles:     2,
+		},
+	}
+
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for capture without clients or IPs, got nil")
+	}
+
+	cfg.Capture.IPs = []string{"not-an-ip"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for invalid capture IP, got nil")
+	}
+
+	cfg.Capture.IPs = []string{"10.0.0.1"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected valid capture configuration, got %v", err)
+	}
+}
+
+func TestValidate_InvalidConformanceMode(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{
+			Port:         80,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  60 * time.Second,
+			Conformance:  "loud",
+		},
+		Logging: LoggingConfig{
+			Level: "info",
+		},
+		Presence: PresenceConfig{
+			OfflineTimeout: 6 * time.Second,
+			CheckInterval:  1 * time.Second,
+		},
+	}
+
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for invalid conformance mode, got nil")
+	}
+}
+
+func TestValidate_InvalidLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits LimitsConfig
+	}{
+		{"negative max connections", LimitsConfig{MaxConnections: -1}},
+		{"rate without burst", LimitsConfig{RequestRatePerIP: 5}},
+		{"negative body size", LimitsConfig{MaxBodyBytes: -1}},
+		{"negative header timeout", LimitsConfig{HeaderTimeout: -time.Second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server: ServerConfig{
+					Port:         80,
+					ReadTimeout:  10 * time.Second,
+					WriteTimeout: 10 * time.Second,
+					IdleTimeout:  60 * time.Second,
+					Limits:       tt.limits,
+				},
+				Logging: LoggingConfig{
+					Level: "info",
+				},
+				Presence: PresenceConfig{
+					OfflineTimeout: 6 * time.Second,
+					CheckInterval:  1 * time.Second,
+				},
+			}
+
+			if err := cfg.Validate(); err == nil {
+				t.Error("Expected validation error, got nil")
+			}
+		})
+	}
+}
+
+func TestValidate_APIListener(t *testing.T) {
+	tests := []struct {
+		name    string
+		api     APIConfig
+		wantErr bool
+	}{
+		{"plain HTTP", APIConfig{Enabled: true, Address: ":8443"}, false},
+		{"TLS", APIConfig{Enabled: true, Address: ":8443", TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"}, false},
+		{"shares the legacy port", APIConfig{Enabled: true, Address: ":80"}, true},
+		{"invalid address", APIConfig{Enabled: true, Address: "8443"}, true},
+		{"cert without key", APIConfig{Enabled: true, Address: ":8443", TLSCertFile: "cert.pem"}, true},
+		{"disabled is not checked", APIConfig{Address: "8443"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server: ServerConfig{
+					Port:         80,
+					ReadTimeout:  10 * time.Second,
+					WriteTimeout: 10 * time.Second,
+					IdleTimeout:  60 * time.Second,
+				},
+				Logging: LoggingConfig{
+					Level: "info",
+				},
+				Presence: PresenceConfig{
+					OfflineTimeout: 6 * time.Second,
+					CheckInterval:  1 * time.Second,
+				},
+				API: tt.api,
+			}
+
+			err := cfg.Validate()
+			if tt.wantErr && err == nil {
+				t.Error("Expected validation error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected valid configuration, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLogConfig_NoticesFollowLoggingFormat(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("SERVER_PORT", "8080")
+	os.Setenv("SHUTDOWN_TIMEOUT", "soon")
+	defer os.Clearenv()
+
+	var logBuffer bytes.Buffer
+	log.SetOutput(&logBuffer)
+	defer log.SetOutput(nil)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if logBuffer.Len() != 0 {
+		t.Errorf("Expected nothing logged before logging is configured, got %q", logBuffer.String())
+	}
+
+	if err := logging.Configure("info", "json"); err != nil {
+		t.Fatal(err)
+	}
+	defer logging.Configure("debug", "text")
+	cfg.LogConfig()
+
+	warnings := 0
+	for _, line := range strings.Split(strings.TrimSpace(logBuffer.String()), "\n") {
+		var entry struct {
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Expected only JSON lines, got %q", line)
+		}
+		if entry.Level == "warn" {
+			warnings++
+			if strings.HasPrefix(strings.ToUpper(entry.Msg), "WARNING") {
+				t.Errorf("Expected no hand-written prefix, got %q", entry.Msg)
+			}
+		}
+	}
+	// The invalid SHUTDOWN_TIMEOUT, and the three lines about port 8080
+	if warnings != 4 {
+		t.Errorf("Expected 4 warnings, got %d in\n%s", warnings, logBuffer.String())
+	}
+}
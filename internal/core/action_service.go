@@ -7,6 +7,7 @@ import (
 	"strconv"
 
 	"github.com/essensys-hub/essensys-server-backend/internal/data"
+	"github.com/essensys-hub/essensys-server-backend/internal/logging"
 	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
 )
 
@@ -30,6 +31,13 @@ func NewActionService(store data.Store) *ActionService {
 	}
 }
 
+// WithStore returns a copy of the service operating on another store, such as a tenant-scoped view
+func (s *ActionService) WithStore(store data.Store) *ActionService {
+	return &ActionService{
+		store: store,
+	}
+}
+
 // AddAction adds an action to the queue with proper processing
 // It applies complete block generation and bitwise fusion as needed
 func (s *ActionService) AddAction(clientID string, params []protocol.ExchangeKV) (string, error) {
@@ -43,7 +51,10 @@ func (s *ActionService) AddAction(clientID string, params []protocol.ExchangeKV)
 	}
 
 	// Enqueue the action
-	s.store.EnqueueAction(clientID, action)
+	if err := s.store.EnqueueAction(clientID, action); err != nil {
+		return "", err
+	}
+	logging.Debugf("[GO] Action queued: %s (%d params) for client %s", action.GUID, len(action.Params), clientID)
 
 	return action.GUID, nil
 }
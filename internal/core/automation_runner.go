@@ -0,0 +1,199 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/data"
+	"github.com/essensys-hub/essensys-server-backend/internal/logging"
+	"github.com/essensys-hub/essensys-server-backend/internal/metrics"
+)
+
+// Default automation settings
+// Schedules are set to the minute, so checking every 15s never skips a minute
+const (
+	DefaultScheduleInterval = 15 * time.Second
+	DefaultWebhookTimeout   = 5 * time.Second
+)
+
+// automationRuns counts scheduled scenes and webhook deliveries by kind and result
+var automationRuns = metrics.NewCounterVec(
+	"essensys_automation_runs_total",
+	"Total number of scheduled scenes sent and webhooks delivered, by kind and result.",
+	"kind", "result",
+)
+
+func init() {
+	metrics.Default.Register(automationRuns)
+}
+
+// WebhookPayload is the JSON body posted to a webhook
+type WebhookPayload struct {
+	WebhookID string `json:"webhook_id"`
+	TenantID  string `json:"tenant_id"`
+	PresenceEvent
+}
+
+// AutomationRunner sends scheduled scenes to the boxes and delivers webhooks on presence events
+// Each tenant's schedules go through the tenant's view of the store, so they can only
+// command the tenant's boxes
+type AutomationRunner struct {
+	store         *data.MemoryStore
+	actionService *ActionService
+	checkInterval time.Duration
+	client        *http.Client
+
+	mu       sync.Mutex
+	lastRun  map[string]string // tenantID/scheduleID -> day of the last run, so a schedule runs once a day
+	stop     chan struct{}
+	done     chan struct{}
+	inflight sync.WaitGroup // webhook deliveries in progress
+}
+
+// NewAutomationRunner creates a new AutomationRunner instance
+// A non-positive check interval falls back to the default
+func NewAutomationRunner(store *data.MemoryStore, actionService *ActionService, checkInterval time.Duration) *AutomationRunner {
+	if checkInterval <= 0 {
+		checkInterval = DefaultScheduleInterval
+	}
+	return &AutomationRunner{
+		store:         store,
+		actionService: actionService,
+		checkInterval: checkInterval,
+		client:        &http.Client{Timeout: DefaultWebhookTimeout},
+		lastRun:       make(map[string]string),
+	}
+}
+
+// Start runs the schedules in a background goroutine until Shutdown is called
+func (a *AutomationRunner) Start() {
+	a.mu.Lock()
+	if a.stop != nil {
+		a.mu.Unlock()
+		return
+	}
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+	stop, done := a.stop, a.done
+	a.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(a.checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				a.RunDue(time.Now())
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown stops the schedules and waits for the webhook deliveries in progress, until ctx is done
+func (a *AutomationRunner) Shutdown(ctx context.Context) error {
+	a.mu.Lock()
+	stop, done := a.stop, a.done
+	a.stop, a.done = nil, nil
+	a.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	delivered := make(chan struct{})
+	go func() {
+		a.inflight.Wait()
+		close(delivered)
+	}()
+	select {
+	case <-delivered:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("webhook deliveries still in progress: %w", ctx.Err())
+	}
+}
+
+// RunDue sends the scene of every enabled schedule set to the minute of now, once a day
+func (a *AutomationRunner) RunDue(now time.Time) {
+	minute, day := now.Format("15:04"), now.Format("2006-01-02")
+
+	for _, tenantID := range a.store.TenantIDs() {
+		tenant := a.store.ForTenant(tenantID)
+		automation := tenant.Automation()
+		for _, schedule := range automation.ListSchedules() {
+			if !schedule.Enabled || schedule.At != minute {
+				continue
+			}
+			key := tenantID + "/" + schedule.ID
+			a.mu.Lock()
+			ran := a.lastRun[key] == day
+			a.lastRun[key] = day
+			a.mu.Unlock()
+			if ran {
+				continue
+			}
+
+			scene, ok := automation.GetScene(schedule.Scene)
+			if !ok {
+				continue
+			}
+			guid, err := a.actionService.WithStore(tenant).AddAction(schedule.ClientID, scene.Params)
+			if err != nil {
+				logging.Warnf("[AUTOMATION] Schedule %s of tenant %s: scene %s not sent to %s: %v", schedule.ID, tenantID, scene.Name, schedule.ClientID, err)
+				automationRuns.Inc("schedule", "error")
+				continue
+			}
+			logging.Infof("[AUTOMATION] Schedule %s of tenant %s: scene %s queued for %s (%s)", schedule.ID, tenantID, scene.Name, schedule.ClientID, guid)
+			automationRuns.Inc("schedule", "ok")
+		}
+	}
+}
+
+// HandlePresence delivers a presence event to the webhooks of the box's tenant
+// It is meant to be subscribed to the PresenceMonitor: deliveries run in their own goroutines
+// so the monitor never waits for a webhook
+func (a *AutomationRunner) HandlePresence(event PresenceEvent) {
+	tenantID := a.store.TenantOf(event.ClientID)
+	for _, webhook := range a.store.WebhooksFor(event.ClientID, string(event.Type)) {
+		payload := WebhookPayload{WebhookID: webhook.ID, TenantID: tenantID, PresenceEvent: event}
+		a.inflight.Add(1)
+		go func(url string) {
+			defer a.inflight.Done()
+			a.deliver(url, payload)
+		}(webhook.URL)
+	}
+}
+
+// deliver posts the payload to the webhook URL, expecting a 2xx response
+func (a *AutomationRunner) deliver(url string, payload WebhookPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logging.Errorf("[AUTOMATION] Webhook %s: %v", payload.WebhookID, err)
+		automationRuns.Inc("webhook", "error")
+		return
+	}
+
+	resp, err := a.client.Post(url, "application/json", bytes.NewReader(body))
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+	}
+	if err != nil {
+		logging.Warnf("[AUTOMATION] Webhook %s of tenant %s: %s event of %s not delivered: %v", payload.WebhookID, payload.TenantID, payload.Type, payload.ClientID, err)
+		automationRuns.Inc("webhook", "error")
+		return
+	}
+	logging.Debugf("[AUTOMATION] Webhook %s of tenant %s: %s event of %s delivered", payload.WebhookID, payload.TenantID, payload.Type, payload.ClientID)
+	automationRuns.Inc("webhook", "ok")
+}
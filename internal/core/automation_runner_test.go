@@ -0,0 +1,138 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/data"
+	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
+)
+
+// newAutomationTestStore returns a store with tenant acme owning box-a and globex owning box-g
+func newAutomationTestStore(t *testing.T) *data.MemoryStore {
+	t.Helper()
+	store := data.NewMemoryStore()
+	for _, tenant := range []data.Tenant{{ID: "acme", Clients: []string{"box-a"}}, {ID: "globex", Clients: []string{"box-g"}}} {
+		if err := store.AddTenant(tenant); err != nil {
+			t.Fatalf("AddTenant(%s) failed: %v", tenant.ID, err)
+		}
+	}
+	return store
+}
+
+func TestAutomationRunner_RunsDueSchedulesOnceADay(t *testing.T) {
+	store := newAutomationTestStore(t)
+	acme := store.ForTenant("acme").Automation()
+	if err := acme.SaveScene(data.Scene{Name: "morning", Params: []protocol.ExchangeKV{{K: 613, V: "64"}}}); err != nil {
+		t.Fatalf("SaveScene failed: %v", err)
+	}
+	if err := acme.SaveSchedule(data.Schedule{ID: "wake", Scene: "morning", ClientID: "box-a", At: "07:30", Enabled: true}); err != nil {
+		t.Fatalf("SaveSchedule failed: %v", err)
+	}
+	if err := acme.SaveSchedule(data.Schedule{ID: "off", Scene: "morning", ClientID: "box-a", At: "07:30"}); err != nil {
+		t.Fatalf("SaveSchedule failed: %v", err)
+	}
+	runner := NewAutomationRunner(store, NewActionService(store), time.Second)
+
+	day := time.Date(2026, 10, 18, 7, 29, 59, 0, time.Local)
+	runner.RunDue(day)
+	if actions := store.DequeueActions("box-a"); len(actions) != 0 {
+		t.Fatalf("Expected nothing sent before 07:30, got %+v", actions)
+	}
+
+	runner.RunDue(day.Add(time.Second))
+	runner.RunDue(day.Add(20 * time.Second))
+	actions := store.DequeueActions("box-a")
+	if len(actions) != 1 {
+		t.Fatalf("Expected the enabled schedule to send its scene once, got %d actions", len(actions))
+	}
+	found := false
+	for _, kv := range actions[0].Params {
+		if kv.K == 613 && kv.V == "64" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected the scene's [613]=64 in the action, got %+v", actions[0].Params)
+	}
+	if actions := store.DequeueActions("box-g"); len(actions) != 0 {
+		t.Errorf("Expected nothing in the globex queue, got %+v", actions)
+	}
+
+	// The next day the schedule runs again
+	store.AcknowledgeAction("box-a", actions[0].GUID)
+	runner.RunDue(day.Add(24*time.Hour + time.Second))
+	if actions := store.DequeueActions("box-a"); len(actions) != 1 {
+		t.Errorf("Expected the schedule to run again the next day, got %d actions", len(actions))
+	}
+}
+
+func TestAutomationRunner_DeliversWebhooksOfTheBoxTenant(t *testing.T) {
+	received := make(chan WebhookPayload, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Invalid webhook body: %v", err)
+		}
+		received <- payload
+	}))
+	defer hook.Close()
+
+	store := newAutomationTestStore(t)
+	store.ForTenant("acme").Automation().SaveWebhook(data.Webhook{ID: "acme-online", URL: hook.URL + "/acme", Events: []string{data.EventClientOnline}})
+	store.ForTenant("globex").Automation().SaveWebhook(data.Webhook{ID: "globex-online", URL: hook.URL + "/globex", Events: []string{data.EventClientOnline}})
+
+	runner := NewAutomationRunner(store, NewActionService(store), time.Second)
+	monitor := NewPresenceMonitor(store, 6*time.Second, time.Second)
+	monitor.Subscribe(runner.HandlePresence)
+
+	store.SetClientConnected("box-a", true)
+	monitor.Check(time.Now())
+	// Offline events have no webhook here
+	monitor.Check(time.Now().Add(10 * time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := runner.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	close(received)
+
+	var payloads []WebhookPayload
+	for payload := range received {
+		payloads = append(payloads, payload)
+	}
+	if len(payloads) != 1 {
+		t.Fatalf("Expected one delivery, got %+v", payloads)
+	}
+	got := payloads[0]
+	if got.WebhookID != "acme-online" || got.TenantID != "acme" || got.ClientID != "box-a" || got.Type != PresenceOnline {
+		t.Errorf("Unexpected payload %+v", got)
+	}
+}
+
+func TestAutomationRunner_ShutdownGivesUpOnSlowWebhooks(t *testing.T) {
+	release := make(chan struct{})
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hook.Close()
+	defer close(release)
+
+	store := newAutomationTestStore(t)
+	store.ForTenant("acme").Automation().SaveWebhook(data.Webhook{ID: "slow", URL: hook.URL, Events: []string{data.EventClientOffline}})
+	runner := NewAutomationRunner(store, NewActionService(store), time.Second)
+	runner.Start()
+
+	runner.HandlePresence(PresenceEvent{ClientID: "box-a", Type: PresenceOffline, Time: time.Now()})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := runner.Shutdown(ctx); err == nil {
+		t.Error("Expected Shutdown to report the delivery still in progress")
+	}
+}
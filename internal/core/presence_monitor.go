@@ -0,0 +1,192 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/data"
+	"github.com/essensys-hub/essensys-server-backend/internal/logging"
+	"github.com/essensys-hub/essensys-server-backend/internal/metrics"
+)
+
+// Default presence settings
+// Boxes poll every 500ms-2s, so 3x the slowest poll interval means the box is gone
+const (
+	DefaultOfflineTimeout = 6 * time.Second
+	DefaultCheckInterval  = 1 * time.Second
+)
+
+// PresenceEventType is the kind of presence transition
+type PresenceEventType string
+
+const (
+	// PresenceOnline is emitted when a box starts a new session
+	PresenceOnline PresenceEventType = "online"
+	// PresenceOffline is emitted when a box has been silent longer than the offline timeout
+	PresenceOffline PresenceEventType = "offline"
+)
+
+// PresenceEvent describes a box going online or offline
+type PresenceEvent struct {
+	ClientID string            `json:"client_id"`
+	Type     PresenceEventType `json:"type"`
+	Time     time.Time         `json:"time"`
+	// SessionSeconds is the length of the session that just ended (offline events only)
+	SessionSeconds float64 `json:"session_duration_seconds"`
+}
+
+// presenceEvents counts emitted presence events by type
+var presenceEvents = metrics.NewCounterVec(
+	"essensys_presence_events_total",
+	"Total number of presence events emitted, by type.",
+	"type",
+)
+
+func init() {
+	metrics.Default.Register(presenceEvents)
+}
+
+// PresenceMonitor marks boxes offline after a period of silence and emits presence events
+type PresenceMonitor struct {
+	store          data.Store
+	offlineTimeout time.Duration
+	checkInterval  time.Duration
+
+	mu        sync.Mutex
+	online    map[string]bool // last state seen by the monitor, used to detect transitions
+	listeners []func(PresenceEvent)
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewPresenceMonitor creates a new PresenceMonitor instance
+// Non-positive durations fall back to the defaults
+func NewPresenceMonitor(store data.Store, offlineTimeout, checkInterval time.Duration) *PresenceMonitor {
+	if offlineTimeout <= 0 {
+		offlineTimeout = DefaultOfflineTimeout
+	}
+	if checkInterval <= 0 {
+		checkInterval = DefaultCheckInterval
+	}
+	return &PresenceMonitor{
+		store:          store,
+		offlineTimeout: offlineTimeout,
+		checkInterval:  checkInterval,
+		online:         make(map[string]bool),
+	}
+}
+
+// Subscribe registers a function called for every presence event
+// Listeners are called synchronously from the monitor goroutine and must not block
+func (m *PresenceMonitor) Subscribe(listener func(PresenceEvent)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, listener)
+}
+
+// Start runs the monitor in a background goroutine until Stop is called
+func (m *PresenceMonitor) Start() {
+	m.mu.Lock()
+	if m.stop != nil {
+		m.mu.Unlock()
+		return
+	}
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	stop, done := m.stop, m.done
+	m.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(m.checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.Check(time.Now())
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background goroutine and waits for it to exit
+func (m *PresenceMonitor) Stop() {
+	m.mu.Lock()
+	stop, done := m.stop, m.done
+	m.stop, m.done = nil, nil
+	m.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// Check marks silent boxes offline and emits events for every transition since the last check
+func (m *PresenceMonitor) Check(now time.Time) {
+	var events []PresenceEvent
+
+	for _, client := range m.store.ListClients() {
+		connected := client.IsConnected
+		// The snapshot may be stale: the store only marks the box offline if it has not polled since
+		if connected && now.Sub(client.LastSeen) > m.offlineTimeout &&
+			m.store.MarkOfflineIfSilent(client.ID, now.Add(-m.offlineTimeout)) {
+			connected = false
+		}
+
+		m.mu.Lock()
+		wasOnline := m.online[client.ID]
+		m.online[client.ID] = connected
+		m.mu.Unlock()
+
+		switch {
+		case connected && !wasOnline:
+			events = append(events, PresenceEvent{
+				ClientID: client.ID,
+				Type:     PresenceOnline,
+				Time:     client.Presence.ConnectedSince,
+			})
+		case !connected && wasOnline:
+			event := PresenceEvent{
+				ClientID: client.ID,
+				Type:     PresenceOffline,
+				Time:     now,
+			}
+			if !client.Presence.ConnectedSince.IsZero() {
+				// Marked offline by this check: the session ran until the box was last seen
+				event.SessionSeconds = client.LastSeen.Sub(client.Presence.ConnectedSince).Seconds()
+			} else if sessions := client.Presence.Sessions; len(sessions) > 0 {
+				// Marked offline elsewhere: the store already closed the session
+				event.SessionSeconds = sessions[len(sessions)-1].Duration().Seconds()
+			}
+			events = append(events, event)
+		}
+	}
+
+	for _, event := range events {
+		m.emit(event)
+	}
+}
+
+// emit logs the event, records it and notifies listeners
+func (m *PresenceMonitor) emit(event PresenceEvent) {
+	switch event.Type {
+	case PresenceOnline:
+		logging.Infof("[PRESENCE] Client %s online", event.ClientID)
+	case PresenceOffline:
+		logging.Infof("[PRESENCE] Client %s offline (session lasted %.1fs)", event.ClientID, event.SessionSeconds)
+	}
+	presenceEvents.Inc(string(event.Type))
+
+	m.mu.Lock()
+	listeners := make([]func(PresenceEvent), len(m.listeners))
+	copy(listeners, m.listeners)
+	m.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
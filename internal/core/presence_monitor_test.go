@@ -0,0 +1,147 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/data"
+)
+
+func TestPresenceMonitor_MarksSilentClientOffline(t *testing.T) {
+	store := data.NewMemoryStore()
+	monitor := NewPresenceMonitor(store, 6*time.Second, time.Second)
+
+	var events []PresenceEvent
+	monitor.Subscribe(func(event PresenceEvent) {
+		events = append(events, event)
+	})
+
+	store.SetClientConnected("box-1", true)
+
+	// First check sees the box online
+	monitor.Check(time.Now())
+	if len(events) != 1 || events[0].Type != PresenceOnline || events[0].ClientID != "box-1" {
+		t.Fatalf("Expected one online event for box-1, got %+v", events)
+	}
+
+	// Still within the timeout: no transition
+	monitor.Check(time.Now().Add(3 * time.Second))
+	if len(events) != 1 {
+		t.Fatalf("Expected no new event within timeout, got %+v", events)
+	}
+	if !store.IsClientConnected("box-1") {
+		t.Error("Expected box-1 to still be connected within timeout")
+	}
+
+	// Past the timeout: box is marked offline
+	monitor.Check(time.Now().Add(7 * time.Second))
+	if len(events) != 2 || events[1].Type != PresenceOffline {
+		t.Fatalf("Expected an offline event, got %+v", events)
+	}
+	if store.IsClientConnected("box-1") {
+		t.Error("Expected box-1 to be marked offline")
+	}
+}
+
+func TestPresenceMonitor_RecordsSessions(t *testing.T) {
+	store := data.NewMemoryStore()
+	monitor := NewPresenceMonitor(store, 6*time.Second, time.Second)
+
+	store.SetClientConnected("box-1", true)
+	monitor.Check(time.Now())
+	monitor.Check(time.Now().Add(10 * time.Second))
+
+	// Reconnect starts a second session
+	store.SetClientConnected("box-1", true)
+	monitor.Check(time.Now())
+
+	clients := store.ListClients()
+	if len(clients) != 1 {
+		t.Fatalf("Expected 1 client, got %d", len(clients))
+	}
+	presence := clients[0].Presence
+	if len(presence.Sessions) != 1 {
+		t.Errorf("Expected 1 closed session, got %d", len(presence.Sessions))
+	}
+	if presence.ConnectedSince.IsZero() {
+		t.Error("Expected an open session after reconnect")
+	}
+	if presence.FirstSeen.IsZero() {
+		t.Error("Expected FirstSeen to be set")
+	}
+}
+
+func TestPresenceMonitor_StartStop(t *testing.T) {
+	store := data.NewMemoryStore()
+	monitor := NewPresenceMonitor(store, 20*time.Millisecond, 5*time.Millisecond)
+
+	offline := make(chan PresenceEvent, 1)
+	monitor.Subscribe(func(event PresenceEvent) {
+		if event.Type == PresenceOffline {
+			select {
+			case offline <- event:
+			default:
+			}
+		}
+	})
+
+	store.SetClientConnected("box-1", true)
+	monitor.Start()
+	defer monitor.Stop()
+
+	select {
+	case event := <-offline:
+		if event.ClientID != "box-1" {
+			t.Errorf("Expected offline event for box-1, got %s", event.ClientID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for offline event")
+	}
+}
+
+// staleStore returns ListClients snapshots taken before the box's last poll
+type staleStore struct {
+	data.Store
+}
+
+func (s staleStore) ListClients() []data.ClientInfo {
+	clients := s.Store.ListClients()
+	for i := range clients {
+		clients[i].LastSeen = clients[i].LastSeen.Add(-time.Minute)
+	}
+	return clients
+}
+
+func TestPresenceMonitor_KeepsClientThatPolledSinceSnapshot(t *testing.T) {
+	store := data.NewMemoryStore()
+	monitor := NewPresenceMonitor(staleStore{store}, 6*time.Second, time.Second)
+
+	var events []PresenceEvent
+	monitor.Subscribe(func(event PresenceEvent) {
+		events = append(events, event)
+	})
+
+	store.SetClientConnected("box-1", true)
+	monitor.Check(time.Now())
+
+	// The snapshot says silent, but the store saw the box poll: no false disconnect
+	if !store.IsClientConnected("box-1") {
+		t.Error("Expected box-1 to stay connected")
+	}
+	if len(events) != 1 || events[0].Type != PresenceOnline {
+		t.Errorf("Expected only the online event, got %+v", events)
+	}
+}
+
+func TestPresenceEvent_SessionDurationInSeconds(t *testing.T) {
+	body, err := json.Marshal(PresenceEvent{ClientID: "box-1", Type: PresenceOffline, SessionSeconds: 90})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var fields map[string]interface{}
+	json.Unmarshal(body, &fields)
+	if fields["session_duration_seconds"] != 90.0 {
+		t.Errorf("Expected session_duration_seconds 90, got %s", body)
+	}
+}
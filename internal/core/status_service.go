@@ -2,6 +2,7 @@ package core
 
 import (
 	"github.com/essensys-hub/essensys-server-backend/internal/data"
+	"github.com/essensys-hub/essensys-server-backend/internal/logging"
 	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
 )
 
@@ -26,7 +27,8 @@ func (s *StatusService) UpdateStatus(clientID string, status protocol.StatusRequ
 	
 	// Mark client as connected
 	s.store.SetClientConnected(clientID, true)
-	
+	logging.Debugf("[GO] Stored %d values for client %s", len(status.EK), clientID)
+
 	return nil
 }
 
@@ -0,0 +1,273 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
+)
+
+var (
+	// ErrNotFound is returned when a scene, schedule or webhook does not exist in the tenant
+	ErrNotFound = errors.New("not found")
+	// ErrInUse is returned when deleting a scene that a schedule still sends
+	ErrInUse = errors.New("in use")
+)
+
+// Event names a webhook can subscribe to
+const (
+	EventClientOnline  = "online"
+	EventClientOffline = "offline"
+)
+
+// Scene is a named set of exchange values sent to a box as a single action
+type Scene struct {
+	Name   string
+	Params []protocol.ExchangeKV
+}
+
+// Schedule sends a scene to a box every day at a time of day ("15:04", server local time)
+type Schedule struct {
+	ID       string
+	Scene    string
+	ClientID string
+	At       string
+	Enabled  bool
+}
+
+// Webhook is an URL notified of the events of the tenant's boxes
+type Webhook struct {
+	ID     string
+	URL    string
+	Events []string
+}
+
+// Automation holds the scenes, schedules and webhooks of one tenant
+// Schedules can only target the tenant's boxes and scenes, so a tenant can never command
+// another tenant's box through them
+type Automation interface {
+	SaveScene(scene Scene) error
+	GetScene(name string) (Scene, bool)
+	ListScenes() []Scene
+	DeleteScene(name string) error
+
+	SaveSchedule(schedule Schedule) error
+	ListSchedules() []Schedule
+	DeleteSchedule(id string) error
+
+	SaveWebhook(webhook Webhook) error
+	ListWebhooks() []Webhook
+	DeleteWebhook(id string) error
+}
+
+// automation is the Automation data of a tenant, kept in its tenantState
+type automation struct {
+	mu        sync.RWMutex
+	scenes    map[string]Scene
+	schedules map[string]Schedule
+	webhooks  map[string]Webhook
+}
+
+func newAutomation() *automation {
+	return &automation{
+		scenes:    make(map[string]Scene),
+		schedules: make(map[string]Schedule),
+		webhooks:  make(map[string]Webhook),
+	}
+}
+
+// tenantAutomation is the Automation of a tenant, checking boxes against the tenant registry
+type tenantAutomation struct {
+	root     *MemoryStore
+	tenantID string
+	data     *automation
+}
+
+// automationFor returns the Automation of a tenant, nil if the tenant does not exist
+func (ms *MemoryStore) automationFor(tenantID string) Automation {
+	tr := ms.tenants
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	state, ok := tr.tenants[tenantID]
+	if !ok {
+		return nil
+	}
+	return &tenantAutomation{root: ms, tenantID: tenantID, data: state.automation}
+}
+
+func (ta *tenantAutomation) SaveScene(scene Scene) error {
+	if scene.Name == "" {
+		return fmt.Errorf("scene name is required")
+	}
+	if len(scene.Params) == 0 {
+		return fmt.Errorf("scene %s has no params", scene.Name)
+	}
+	for _, kv := range scene.Params {
+		if kv.K < 0 || kv.K > protocol.MaxExchangeIndex {
+			return fmt.Errorf("scene %s: index %d out of range 0-%d", scene.Name, kv.K, protocol.MaxExchangeIndex)
+		}
+	}
+
+	ta.data.mu.Lock()
+	defer ta.data.mu.Unlock()
+	scene.Params = append([]protocol.ExchangeKV(nil), scene.Params...)
+	ta.data.scenes[scene.Name] = scene
+	return nil
+}
+
+func (ta *tenantAutomation) GetScene(name string) (Scene, bool) {
+	ta.data.mu.RLock()
+	defer ta.data.mu.RUnlock()
+	scene, ok := ta.data.scenes[name]
+	scene.Params = append([]protocol.ExchangeKV(nil), scene.Params...)
+	return scene, ok
+}
+
+func (ta *tenantAutomation) ListScenes() []Scene {
+	ta.data.mu.RLock()
+	defer ta.data.mu.RUnlock()
+	scenes := make([]Scene, 0, len(ta.data.scenes))
+	for _, scene := range ta.data.scenes {
+		scene.Params = append([]protocol.ExchangeKV(nil), scene.Params...)
+		scenes = append(scenes, scene)
+	}
+	sort.Slice(scenes, func(i, j int) bool { return scenes[i].Name < scenes[j].Name })
+	return scenes
+}
+
+// DeleteScene removes a scene; it is refused while a schedule still uses it
+func (ta *tenantAutomation) DeleteScene(name string) error {
+	ta.data.mu.Lock()
+	defer ta.data.mu.Unlock()
+	if _, ok := ta.data.scenes[name]; !ok {
+		return fmt.Errorf("scene %s: %w", name, ErrNotFound)
+	}
+	for _, schedule := range ta.data.schedules {
+		if schedule.Scene == name {
+			return fmt.Errorf("scene %s is used by schedule %s: %w", name, schedule.ID, ErrInUse)
+		}
+	}
+	delete(ta.data.scenes, name)
+	return nil
+}
+
+// SaveSchedule stores a schedule of one of the tenant's scenes on one of the tenant's boxes
+func (ta *tenantAutomation) SaveSchedule(schedule Schedule) error {
+	if schedule.ID == "" {
+		return fmt.Errorf("schedule ID is required")
+	}
+	if _, err := time.Parse("15:04", schedule.At); err != nil {
+		return fmt.Errorf("schedule %s: at must be a time of day such as 07:30, got %q", schedule.ID, schedule.At)
+	}
+	if ta.root.TenantOf(schedule.ClientID) != ta.tenantID {
+		return fmt.Errorf("schedule %s: client %s: %w", schedule.ID, schedule.ClientID, ErrClientNotInTenant)
+	}
+
+	ta.data.mu.Lock()
+	defer ta.data.mu.Unlock()
+	if _, ok := ta.data.scenes[schedule.Scene]; !ok {
+		return fmt.Errorf("schedule %s: scene %s: %w", schedule.ID, schedule.Scene, ErrNotFound)
+	}
+	ta.data.schedules[schedule.ID] = schedule
+	return nil
+}
+
+func (ta *tenantAutomation) ListSchedules() []Schedule {
+	ta.data.mu.RLock()
+	defer ta.data.mu.RUnlock()
+	schedules := make([]Schedule, 0, len(ta.data.schedules))
+	for _, schedule := range ta.data.schedules {
+		schedules = append(schedules, schedule)
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ID < schedules[j].ID })
+	return schedules
+}
+
+func (ta *tenantAutomation) DeleteSchedule(id string) error {
+	ta.data.mu.Lock()
+	defer ta.data.mu.Unlock()
+	if _, ok := ta.data.schedules[id]; !ok {
+		return fmt.Errorf("schedule %s: %w", id, ErrNotFound)
+	}
+	delete(ta.data.schedules, id)
+	return nil
+}
+
+func (ta *tenantAutomation) SaveWebhook(webhook Webhook) error {
+	if webhook.ID == "" {
+		return fmt.Errorf("webhook ID is required")
+	}
+	u, err := url.Parse(webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook %s: URL must be an absolute http or https URL, got %q", webhook.ID, webhook.URL)
+	}
+	if len(webhook.Events) == 0 {
+		return fmt.Errorf("webhook %s subscribes to no event", webhook.ID)
+	}
+	for _, event := range webhook.Events {
+		if event != EventClientOnline && event != EventClientOffline {
+			return fmt.Errorf("webhook %s: unknown event %q", webhook.ID, event)
+		}
+	}
+
+	ta.data.mu.Lock()
+	defer ta.data.mu.Unlock()
+	webhook.Events = append([]string(nil), webhook.Events...)
+	ta.data.webhooks[webhook.ID] = webhook
+	return nil
+}
+
+func (ta *tenantAutomation) ListWebhooks() []Webhook {
+	ta.data.mu.RLock()
+	defer ta.data.mu.RUnlock()
+	webhooks := make([]Webhook, 0, len(ta.data.webhooks))
+	for _, webhook := range ta.data.webhooks {
+		webhook.Events = append([]string(nil), webhook.Events...)
+		webhooks = append(webhooks, webhook)
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks
+}
+
+func (ta *tenantAutomation) DeleteWebhook(id string) error {
+	ta.data.mu.Lock()
+	defer ta.data.mu.Unlock()
+	if _, ok := ta.data.webhooks[id]; !ok {
+		return fmt.Errorf("webhook %s: %w", id, ErrNotFound)
+	}
+	delete(ta.data.webhooks, id)
+	return nil
+}
+
+// WebhooksFor returns the webhooks subscribed to an event of a box: only those of the box's
+// tenant, so that events never reach another tenant
+func (ms *MemoryStore) WebhooksFor(clientID, event string) []Webhook {
+	var subscribed []Webhook
+	for _, webhook := range ms.automationFor(ms.TenantOf(clientID)).ListWebhooks() {
+		for _, e := range webhook.Events {
+			if e == event {
+				subscribed = append(subscribed, webhook)
+				break
+			}
+		}
+	}
+	return subscribed
+}
+
+// deniedAutomation is the Automation of a view that owns nothing
+type deniedAutomation struct{}
+
+func (deniedAutomation) SaveScene(Scene) error         { return ErrClientNotInTenant }
+func (deniedAutomation) GetScene(string) (Scene, bool) { return Scene{}, false }
+func (deniedAutomation) ListScenes() []Scene           { return []Scene{} }
+func (deniedAutomation) DeleteScene(string) error      { return ErrNotFound }
+func (deniedAutomation) SaveSchedule(Schedule) error   { return ErrClientNotInTenant }
+func (deniedAutomation) ListSchedules() []Schedule     { return []Schedule{} }
+func (deniedAutomation) DeleteSchedule(string) error   { return ErrNotFound }
+func (deniedAutomation) SaveWebhook(Webhook) error     { return ErrClientNotInTenant }
+func (deniedAutomation) ListWebhooks() []Webhook       { return []Webhook{} }
+func (deniedAutomation) DeleteWebhook(string) error    { return ErrNotFound }
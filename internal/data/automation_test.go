@@ -0,0 +1,110 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
+)
+
+func TestAutomation_IsolatedPerTenant(t *testing.T) {
+	store := newTenantTestStore(t)
+	acme := store.ForTenant("acme").Automation()
+	globex := store.ForTenant("globex").Automation()
+
+	if err := acme.SaveScene(Scene{Name: "night", Params: []protocol.ExchangeKV{{K: 605, V: "255"}}}); err != nil {
+		t.Fatalf("SaveScene failed: %v", err)
+	}
+	if _, ok := globex.GetScene("night"); ok {
+		t.Error("Expected globex not to see acme's scene")
+	}
+	if len(globex.ListScenes()) != 0 || len(store.Automation().ListScenes()) != 0 {
+		t.Error("Expected the scene only in acme")
+	}
+	if scenes := acme.ListScenes(); len(scenes) != 1 || scenes[0].Name != "night" {
+		t.Errorf("Expected acme's scene, got %+v", scenes)
+	}
+
+	// A schedule can only send the tenant's scenes to the tenant's boxes
+	err := acme.SaveSchedule(Schedule{ID: "s1", Scene: "night", ClientID: "box-g", At: "22:00"})
+	if !errors.Is(err, ErrClientNotInTenant) {
+		t.Errorf("Expected ErrClientNotInTenant for globex's box, got %v", err)
+	}
+	err = globex.SaveSchedule(Schedule{ID: "s1", Scene: "night", ClientID: "box-g", At: "22:00"})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for acme's scene, got %v", err)
+	}
+	if err := acme.SaveSchedule(Schedule{ID: "s1", Scene: "night", ClientID: "box-a", At: "22:00", Enabled: true}); err != nil {
+		t.Fatalf("SaveSchedule failed: %v", err)
+	}
+	if len(globex.ListSchedules()) != 0 || len(acme.ListSchedules()) != 1 {
+		t.Error("Expected the schedule only in acme")
+	}
+	if err := globex.DeleteSchedule("s1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected globex unable to delete acme's schedule, got %v", err)
+	}
+	if err := acme.DeleteScene("night"); !errors.Is(err, ErrInUse) {
+		t.Error("Expected a scene in use by a schedule not to be deleted")
+	}
+}
+
+func TestAutomation_DeniedView(t *testing.T) {
+	store := newTenantTestStore(t)
+	escalated := store.ForTenant("acme").ForTenant("globex").Automation()
+
+	if err := escalated.SaveScene(Scene{Name: "x", Params: []protocol.ExchangeKV{{K: 1, V: "1"}}}); err == nil {
+		t.Error("Expected a denied view to refuse writes")
+	}
+	if len(escalated.ListWebhooks()) != 0 {
+		t.Error("Expected a denied view to list nothing")
+	}
+	if err := store.ForTenant("unknown").Automation().SaveScene(Scene{Name: "x", Params: []protocol.ExchangeKV{{K: 1, V: "1"}}}); err == nil {
+		t.Error("Expected an unknown tenant to own nothing")
+	}
+}
+
+func TestAutomation_Validation(t *testing.T) {
+	automation := NewMemoryStore().Automation()
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"scene without name", automation.SaveScene(Scene{Params: []protocol.ExchangeKV{{K: 1, V: "1"}}})},
+		{"scene without params", automation.SaveScene(Scene{Name: "empty"})},
+		{"scene index out of range", automation.SaveScene(Scene{Name: "big", Params: []protocol.ExchangeKV{{K: 1000, V: "1"}}})},
+		{"schedule with bad time", automation.SaveSchedule(Schedule{ID: "s", Scene: "big", ClientID: "box", At: "25:00"})},
+		{"webhook with relative URL", automation.SaveWebhook(Webhook{ID: "w", URL: "/hook", Events: []string{EventClientOnline}})},
+		{"webhook without events", automation.SaveWebhook(Webhook{ID: "w", URL: "https://example.com/hook"})},
+		{"webhook with unknown event", automation.SaveWebhook(Webhook{ID: "w", URL: "https://example.com/hook", Events: []string{"deleted"}})},
+	}
+	for _, tt := range tests {
+		if tt.err == nil {
+			t.Errorf("%s: expected an error, got nil", tt.name)
+		}
+	}
+}
+
+func TestWebhooksFor_OnlyTheClientsTenant(t *testing.T) {
+	store := newTenantTestStore(t)
+	acme := store.ForTenant("acme").Automation()
+	globex := store.ForTenant("globex").Automation()
+
+	acme.SaveWebhook(Webhook{ID: "acme-all", URL: "https://acme.example/hook", Events: []string{EventClientOnline, EventClientOffline}})
+	acme.SaveWebhook(Webhook{ID: "acme-offline", URL: "https://acme.example/offline", Events: []string{EventClientOffline}})
+	globex.SaveWebhook(Webhook{ID: "globex-all", URL: "https://globex.example/hook", Events: []string{EventClientOnline, EventClientOffline}})
+
+	webhooks := store.WebhooksFor("box-a", EventClientOnline)
+	if len(webhooks) != 1 || webhooks[0].ID != "acme-all" {
+		t.Errorf("Expected only acme-all for box-a online, got %+v", webhooks)
+	}
+	if webhooks := store.WebhooksFor("box-a", EventClientOffline); len(webhooks) != 2 {
+		t.Errorf("Expected both acme webhooks for box-a offline, got %+v", webhooks)
+	}
+	if webhooks := store.WebhooksFor("box-g", EventClientOffline); len(webhooks) != 1 || webhooks[0].ID != "globex-all" {
+		t.Errorf("Expected only globex-all for box-g, got %+v", webhooks)
+	}
+	if webhooks := store.WebhooksFor("unassigned", EventClientOnline); len(webhooks) != 0 {
+		t.Errorf("Expected no webhook for the default tenant, got %+v", webhooks)
+	}
+}
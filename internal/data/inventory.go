@@ -0,0 +1,85 @@
+package data
+
+import "sort"
+
+// ClientMetadata is the inventory record of a box
+// Firmware version and remote IP come from the box itself; name, site and tags are set by operators
+type ClientMetadata struct {
+	FirmwareVersion string
+	RemoteIP        string
+	CredentialID    string
+	Name            string
+	Site            string
+	Tags            []string
+}
+
+// ClientReport is what a box tells us about itself on each /api/mystatus
+type ClientReport struct {
+	FirmwareVersion string
+	RemoteIP        string
+	CredentialID    string
+}
+
+// MetadataUpdate changes operator-managed fields; nil fields are left unchanged
+type MetadataUpdate struct {
+	Name *string
+	Site *string
+	Tags *[]string
+}
+
+// apply records a box report, ignoring empty values so a partial report never erases data
+func (m *ClientMetadata) apply(report ClientReport) {
+	if report.FirmwareVersion != "" {
+		m.FirmwareVersion = report.FirmwareVersion
+	}
+	if report.RemoteIP != "" {
+		m.RemoteIP = report.RemoteIP
+	}
+	if report.CredentialID != "" {
+		m.CredentialID = report.CredentialID
+	}
+}
+
+// update applies an operator update
+func (m *ClientMetadata) update(update MetadataUpdate) {
+	if update.Name != nil {
+		m.Name = *update.Name
+	}
+	if update.Site != nil {
+		m.Site = *update.Site
+	}
+	if update.Tags != nil {
+		m.Tags = normalizeTags(*update.Tags)
+	}
+}
+
+// snapshot returns a copy that is safe to hand out
+func (m ClientMetadata) snapshot() ClientMetadata {
+	m.Tags = append([]string(nil), m.Tags...)
+	return m
+}
+
+// HasTag reports whether the box carries the given tag
+func (m ClientMetadata) HasTag(tag string) bool {
+	for _, t := range m.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeTags drops empty and duplicate tags and sorts the rest
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	sort.Strings(result)
+	return result
+}
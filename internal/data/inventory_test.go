@@ -0,0 +1,57 @@
+package data
+
+import "testing"
+
+func TestMemoryStore_RecordClientReport(t *testing.T) {
+	store := NewMemoryStore()
+
+	store.RecordClientReport("box-1", ClientReport{
+		FirmwareVersion: "V125",
+		RemoteIP:        "192.168.1.10",
+		CredentialID:    "box-1",
+	})
+	// A partial report must not erase known values
+	store.RecordClientReport("box-1", ClientReport{RemoteIP: "192.168.1.11"})
+
+	clients := store.ListClients()
+	if len(clients) != 1 {
+		t.Fatalf("Expected 1 client, got %d", len(clients))
+	}
+	metadata := clients[0].Metadata
+	if metadata.FirmwareVersion != "V125" {
+		t.Errorf("Expected firmware V125, got %s", metadata.FirmwareVersion)
+	}
+	if metadata.RemoteIP != "192.168.1.11" {
+		t.Errorf("Expected remote IP 192.168.1.11, got %s", metadata.RemoteIP)
+	}
+	if metadata.CredentialID != "box-1" {
+		t.Errorf("Expected credential box-1, got %s", metadata.CredentialID)
+	}
+}
+
+func TestMemoryStore_UpdateClientMetadata(t *testing.T) {
+	store := NewMemoryStore()
+
+	name := "Maison Dupont"
+	site := "Lyon"
+	tags := []string{"pilot", "", "alarm", "pilot"}
+	store.UpdateClientMetadata("box-1", MetadataUpdate{Name: &name, Site: &site, Tags: &tags})
+
+	// Updating only the name leaves site and tags alone
+	newName := "Maison Martin"
+	store.UpdateClientMetadata("box-1", MetadataUpdate{Name: &newName})
+
+	metadata := store.ListClients()[0].Metadata
+	if metadata.Name != "Maison Martin" {
+		t.Errorf("Expected name 'Maison Martin', got '%s'", metadata.Name)
+	}
+	if metadata.Site != "Lyon" {
+		t.Errorf("Expected site 'Lyon', got '%s'", metadata.Site)
+	}
+	if len(metadata.Tags) != 2 || metadata.Tags[0] != "alarm" || metadata.Tags[1] != "pilot" {
+		t.Errorf("Expected tags [alarm pilot], got %v", metadata.Tags)
+	}
+	if !metadata.HasTag("alarm") || metadata.HasTag("missing") {
+		t.Error("HasTag returned an unexpected result")
+	}
+}
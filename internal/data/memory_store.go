@@ -1,9 +1,11 @@
 package data
 
 import (
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/essensys-hub/essensys-server-backend/internal/metrics"
 	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
 )
 
@@ -15,13 +17,34 @@ type Store interface {
 	GetAllValues(clientID string, indices []int) []protocol.ExchangeKV
 
 	// Action Queue operations
-	EnqueueAction(clientID string, action protocol.Action)
+	EnqueueAction(clientID string, action protocol.Action) error
 	DequeueActions(clientID string) []protocol.Action
 	AcknowledgeAction(clientID string, guid string) bool
 
 	// Client management
 	IsClientConnected(clientID string) bool
 	SetClientConnected(clientID string, connected bool)
+	MarkOfflineIfSilent(clientID string, cutoff time.Time) bool
+	ListClients() []ClientInfo
+
+	// Inventory
+	RecordClientReport(clientID string, report ClientReport)
+	UpdateClientMetadata(clientID string, update MetadataUpdate)
+
+	// Tenancy
+	TenantOf(clientID string) string
+	ForTenant(tenantID string) Store
+	Automation() Automation
+}
+
+// ClientInfo is a read-only snapshot of a client's state
+type ClientInfo struct {
+	ID             string
+	IsConnected    bool
+	LastSeen       time.Time
+	PendingActions int // Pending in the queue of the client's tenant, shared by all its clients
+	Presence       Presence
+	Metadata       ClientMetadata
 }
 
 // ExchangeTable is a thread-safe key-value store for exchange table data
@@ -71,14 +94,16 @@ func (et *ExchangeTable) GetAll(indices []int) []protocol.ExchangeKV {
 
 // ActionQueue is a thread-safe FIFO queue for actions
 type ActionQueue struct {
-	mu      sync.Mutex
-	actions []protocol.Action
+	mu       sync.Mutex
+	actions  []protocol.Action
+	queuedAt map[string]time.Time // GUID -> enqueue time, used for ack latency
 }
 
 // NewActionQueue creates a new ActionQueue instance
 func NewActionQueue() *ActionQueue {
 	return &ActionQueue{
-		actions: make([]protocol.Action, 0),
+		actions:  make([]protocol.Action, 0),
+		queuedAt: make(map[string]time.Time),
 	}
 }
 
@@ -87,6 +112,26 @@ func (aq *ActionQueue) Enqueue(action protocol.Action) {
 	aq.mu.Lock()
 	defer aq.mu.Unlock()
 	aq.actions = append(aq.actions, action)
+	aq.queuedAt[action.GUID] = time.Now()
+}
+
+// EnqueueWithLimit adds an action unless the queue already holds limit actions (0 means no limit)
+func (aq *ActionQueue) EnqueueWithLimit(action protocol.Action, limit int) bool {
+	aq.mu.Lock()
+	defer aq.mu.Unlock()
+	if limit > 0 && len(aq.actions) >= limit {
+		return false
+	}
+	aq.actions = append(aq.actions, action)
+	aq.queuedAt[action.GUID] = time.Now()
+	return true
+}
+
+// Len returns the number of pending actions
+func (aq *ActionQueue) Len() int {
+	aq.mu.Lock()
+	defer aq.mu.Unlock()
+	return len(aq.actions)
 }
 
 // GetAll returns all actions in FIFO order WITHOUT removing them
@@ -110,6 +155,11 @@ func (aq *ActionQueue) Acknowledge(guid string) bool {
 		if action.GUID == guid {
 			// Remove the action by slicing
 			aq.actions = append(aq.actions[:i], aq.actions[i+1:]...)
+			if queuedAt, ok := aq.queuedAt[guid]; ok {
+				metrics.ActionAckLatency.ObserveDuration(time.Since(queuedAt))
+				delete(aq.queuedAt, guid)
+			}
+			metrics.ActionsAcknowledged.Inc()
 			return true
 		}
 	}
@@ -122,6 +172,8 @@ type ClientData struct {
 	ActionQueue   *ActionQueue
 	IsConnected   bool
 	LastSeen      time.Time
+	Presence      Presence
+	Metadata      ClientMetadata
 }
 
 // NewClientData creates a new ClientData instance
@@ -136,16 +188,16 @@ func NewClientData() *ClientData {
 
 // MemoryStore implements Store interface with in-memory storage
 type MemoryStore struct {
-	mu            sync.RWMutex
-	clients       map[string]*ClientData
-	globalActions *ActionQueue // Global action queue shared by all clients
+	mu      sync.RWMutex
+	clients map[string]*ClientData
+	tenants *tenantRegistry // Client -> tenant mapping and one action queue per tenant
 }
 
 // NewMemoryStore creates a new MemoryStore instance
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		clients:       make(map[string]*ClientData),
-		globalActions: NewActionQueue(), // Single global queue for all clients
+		clients: make(map[string]*ClientData),
+		tenants: newTenantRegistry(), // Unassigned clients share the default tenant's queue
 	}
 }
 
@@ -181,23 +233,30 @@ func (ms *MemoryStore) GetAllValues(clientID string, indices []int) []protocol.E
 	return client.ExchangeTable.GetAll(indices)
 }
 
-// EnqueueAction adds an action to the GLOBAL queue (shared by all clients)
-func (ms *MemoryStore) EnqueueAction(clientID string, action protocol.Action) {
-	// Use global queue instead of per-client queue
-	ms.globalActions.Enqueue(action)
+// EnqueueAction adds an action to the queue of the client's tenant (shared by the tenant's clients)
+// It fails with ErrQuotaExceeded when the tenant's MaxPendingActions quota is reached
+func (ms *MemoryStore) EnqueueAction(clientID string, action protocol.Action) error {
+	// Use tenant queue instead of per-client queue
+	queue, quotas := ms.tenants.queueFor(clientID)
+	if !queue.EnqueueWithLimit(action, quotas.MaxPendingActions) {
+		return ErrQuotaExceeded
+	}
+	return nil
 }
 
-// DequeueActions returns all pending actions from the GLOBAL queue WITHOUT removing them
+// DequeueActions returns all pending actions from the tenant queue WITHOUT removing them
 // Actions are only removed when AcknowledgeAction is called with the GUID
 func (ms *MemoryStore) DequeueActions(clientID string) []protocol.Action {
-	// Use global queue instead of per-client queue
-	return ms.globalActions.GetAll()
+	// Use tenant queue instead of per-client queue
+	queue, _ := ms.tenants.queueFor(clientID)
+	return queue.GetAll()
 }
 
-// AcknowledgeAction removes an action with the specified GUID from the GLOBAL queue
+// AcknowledgeAction removes an action with the specified GUID from the tenant queue
 func (ms *MemoryStore) AcknowledgeAction(clientID string, guid string) bool {
-	// Use global queue instead of per-client queue
-	return ms.globalActions.Acknowledge(guid)
+	// Use tenant queue instead of per-client queue
+	queue, _ := ms.tenants.queueFor(clientID)
+	return queue.Acknowledge(guid)
 }
 
 // IsClientConnected returns the connection status of a client
@@ -212,12 +271,86 @@ func (ms *MemoryStore) IsClientConnected(clientID string) bool {
 }
 
 // SetClientConnected sets the connection status of a client
+// Marking a client connected refreshes LastSeen; transitions open and close presence sessions
 func (ms *MemoryStore) SetClientConnected(clientID string, connected bool) {
 	client := ms.getOrCreateClient(clientID)
 	
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 	
+	now := time.Now()
+	switch {
+	case connected && !client.IsConnected:
+		client.Presence.open(now)
+	case !connected && client.IsConnected:
+		// The session ended when the client was last heard from, not when we noticed
+		client.Presence.close(client.LastSeen)
+	}
+
 	client.IsConnected = connected
-	client.LastSeen = time.Now()
+	if connected {
+		client.LastSeen = now
+	}
+}
+
+// MarkOfflineIfSilent marks a connected client offline if it was last seen before cutoff, and
+// reports whether it did
+// The check and the change happen under the store lock, so a poll that refreshed LastSeen since a
+// ListClients snapshot keeps the client online
+func (ms *MemoryStore) MarkOfflineIfSilent(clientID string, cutoff time.Time) bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	client, exists := ms.clients[clientID]
+	if !exists || !client.IsConnected || !client.LastSeen.Before(cutoff) {
+		return false
+	}
+	// The session ended when the client was last heard from, not when we noticed
+	client.Presence.close(client.LastSeen)
+	client.IsConnected = false
+	return true
+}
+
+// ListClients returns a snapshot of every known client, sorted by ID
+func (ms *MemoryStore) ListClients() []ClientInfo {
+	ms.mu.RLock()
+	result := make([]ClientInfo, 0, len(ms.clients))
+	for id, client := range ms.clients {
+		result = append(result, ClientInfo{
+			ID:          id,
+			IsConnected: client.IsConnected,
+			LastSeen:    client.LastSeen,
+			Presence:    client.Presence.snapshot(),
+			Metadata:    client.Metadata.snapshot(),
+		})
+	}
+	ms.mu.RUnlock()
+
+	for i := range result {
+		result[i].PendingActions = len(ms.DequeueActions(result[i].ID))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// RecordClientReport stores the firmware version, remote IP and credential reported by a box
+func (ms *MemoryStore) RecordClientReport(clientID string, report ClientReport) {
+	client := ms.getOrCreateClient(clientID)
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	client.Metadata.apply(report)
+}
+
+// UpdateClientMetadata changes the operator-managed inventory fields of a box
+// Unknown boxes are created so they can be registered before they first connect
+func (ms *MemoryStore) UpdateClientMetadata(clientID string, update MetadataUpdate) {
+	client := ms.getOrCreateClient(clientID)
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	client.Metadata.update(update)
 }
@@ -3,6 +3,7 @@ package data
 import (
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
 )
@@ -235,3 +236,56 @@ func TestMemoryStore_MultipleClients(t *testing.T) {
 		t.Errorf("Expected 'client2-value', got '%s'", value2)
 	}
 }
+
+func TestMemoryStore_ListClients(t *testing.T) {
+	store := NewMemoryStore()
+
+	store.SetClientConnected("client-b", true)
+	store.SetValue("client-a", 100, "value")
+	store.EnqueueAction("client-a", protocol.Action{GUID: "guid-1"})
+
+	clients := store.ListClients()
+	if len(clients) != 2 {
+		t.Fatalf("Expected 2 clients, got %d", len(clients))
+	}
+	if clients[0].ID != "client-a" || clients[1].ID != "client-b" {
+		t.Errorf("Expected clients sorted by ID, got %s, %s", clients[0].ID, clients[1].ID)
+	}
+	if clients[0].IsConnected {
+		t.Error("Expected client-a to not be connected")
+	}
+	if !clients[1].IsConnected {
+		t.Error("Expected client-b to be connected")
+	}
+	if clients[0].PendingActions != 1 {
+		t.Errorf("Expected 1 pending action, got %d", clients[0].PendingActions)
+	}
+}
+
+func TestMarkOfflineIfSilent(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetClientConnected("box-1", true)
+
+	// Seen after the cutoff: the box stays online
+	if store.MarkOfflineIfSilent("box-1", time.Now().Add(-time.Minute)) {
+		t.Error("Expected a box seen after the cutoff to stay online")
+	}
+	if !store.IsClientConnected("box-1") {
+		t.Error("Expected box-1 to be connected")
+	}
+
+	if !store.MarkOfflineIfSilent("box-1", time.Now().Add(time.Minute)) {
+		t.Error("Expected a box silent since the cutoff to be marked offline")
+	}
+	if store.IsClientConnected("box-1") {
+		t.Error("Expected box-1 to be offline")
+	}
+	if sessions := store.ListClients()[0].Presence.Sessions; len(sessions) != 1 {
+		t.Errorf("Expected the session to be closed, got %+v", sessions)
+	}
+
+	// Already offline, or unknown: nothing to do
+	if store.MarkOfflineIfSilent("box-1", time.Now().Add(time.Minute)) || store.MarkOfflineIfSilent("box-2", time.Now()) {
+		t.Error("Expected no change for offline or unknown boxes")
+	}
+}
@@ -0,0 +1,106 @@
+package data
+
+import (
+	"sort"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/metrics"
+)
+
+// RegisterMetrics registers the per-client gauges computed from the store at scrape time
+func RegisterMetrics(registry *metrics.Registry, store Store) {
+	registry.Register(metrics.NewGaugeFunc(
+		"essensys_connected_clients",
+		"Number of boxes currently marked as connected.",
+		func() []metrics.Sample {
+			connected := 0
+			for _, client := range store.ListClients() {
+				if client.IsConnected {
+					connected++
+				}
+			}
+			return []metrics.Sample{{Value: float64(connected)}}
+		},
+	))
+
+	registry.Register(metrics.NewGaugeFunc(
+		"essensys_client_last_seen_age_seconds",
+		"Seconds since each box was last seen.",
+		func() []metrics.Sample {
+			clients := store.ListClients()
+			samples := make([]metrics.Sample, 0, len(clients))
+			for _, client := range clients {
+				samples = append(samples, metrics.Sample{
+					LabelValues: []string{client.ID},
+					Value:       time.Since(client.LastSeen).Seconds(),
+				})
+			}
+			return samples
+		},
+		"client",
+	))
+
+	// The boxes of a tenant share one queue, so the depth is reported once per tenant:
+	// a per-box series would count each action once for every box of the tenant
+	registry.Register(metrics.NewGaugeFunc(
+		"essensys_action_queue_depth",
+		"Number of actions pending acknowledgment in the queue of each tenant.",
+		func() []metrics.Sample {
+			depths := make(map[string]int)
+			for _, client := range store.ListClients() {
+				depths[store.TenantOf(client.ID)] = client.PendingActions
+			}
+			tenants := make([]string, 0, len(depths))
+			for tenant := range depths {
+				tenants = append(tenants, tenant)
+			}
+			sort.Strings(tenants)
+
+			samples := make([]metrics.Sample, 0, len(tenants))
+			for _, tenant := range tenants {
+				samples = append(samples, metrics.Sample{
+					LabelValues: []string{tenant},
+					Value:       float64(depths[tenant]),
+				})
+			}
+			return samples
+		},
+		"tenant",
+	))
+
+	registry.Register(metrics.NewGaugeFunc(
+		"essensys_client_uptime_seconds",
+		"Duration of the current session of each box, zero when offline.",
+		func() []metrics.Sample {
+			now := time.Now()
+			clients := store.ListClients()
+			samples := make([]metrics.Sample, 0, len(clients))
+			for _, client := range clients {
+				samples = append(samples, metrics.Sample{
+					LabelValues: []string{client.ID},
+					Value:       client.Presence.Uptime(now).Seconds(),
+				})
+			}
+			return samples
+		},
+		"client",
+	))
+
+	registry.Register(metrics.NewGaugeFunc(
+		"essensys_client_availability_ratio",
+		"Fraction of time each box was online since it was first seen.",
+		func() []metrics.Sample {
+			now := time.Now()
+			clients := store.ListClients()
+			samples := make([]metrics.Sample, 0, len(clients))
+			for _, client := range clients {
+				samples = append(samples, metrics.Sample{
+					LabelValues: []string{client.ID},
+					Value:       client.Presence.Availability(now),
+				})
+			}
+			return samples
+		},
+		"client",
+	))
+}
@@ -0,0 +1,42 @@
+package data
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/metrics"
+	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
+)
+
+func TestRegisterMetrics_QueueDepthPerTenant(t *testing.T) {
+	store := newTenantTestStore(t)
+	store.AddTenant(Tenant{ID: "initech", Clients: []string{"box-i1", "box-i2", "box-i3"}})
+	for _, id := range []string{"box-a", "box-g", "box-i1", "box-i2", "box-i3"} {
+		store.SetClientConnected(id, true)
+	}
+	for _, guid := range []string{"a1", "a2"} {
+		store.EnqueueAction("box-a", protocol.Action{GUID: guid})
+	}
+	store.EnqueueAction("box-i1", protocol.Action{GUID: "i1"})
+
+	registry := metrics.NewRegistry()
+	RegisterMetrics(registry, store)
+	var buf bytes.Buffer
+	registry.WriteText(&buf)
+	output := buf.String()
+
+	// initech's three boxes share one queue holding one action
+	for _, line := range []string{
+		`essensys_action_queue_depth{tenant="acme"} 2`,
+		`essensys_action_queue_depth{tenant="globex"} 0`,
+		`essensys_action_queue_depth{tenant="initech"} 1`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected line %q in output:\n%s", line, output)
+		}
+	}
+	if strings.Count(output, "essensys_action_queue_depth{") != 3 {
+		t.Errorf("Expected one queue depth per tenant, got:\n%s", output)
+	}
+}
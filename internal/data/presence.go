@@ -0,0 +1,81 @@
+package data
+
+import "time"
+
+// MaxSessionHistory is the number of closed sessions kept per client
+const MaxSessionHistory = 100
+
+// Session is a period during which a client was continuously online
+type Session struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Duration returns the length of the session
+func (s Session) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// Presence holds the connect/disconnect history of a client
+type Presence struct {
+	FirstSeen      time.Time     // First time the client was marked connected (zero if never)
+	ConnectedSince time.Time     // Start of the current session (zero if offline)
+	OnlineTotal    time.Duration // Total duration of closed sessions
+	Sessions       []Session     // Most recent closed sessions, oldest first
+}
+
+// open starts a new session at the given time
+func (p *Presence) open(at time.Time) {
+	if p.FirstSeen.IsZero() {
+		p.FirstSeen = at
+	}
+	p.ConnectedSince = at
+}
+
+// close ends the current session at the given time and records it
+func (p *Presence) close(at time.Time) {
+	if p.ConnectedSince.IsZero() {
+		return
+	}
+	if at.Before(p.ConnectedSince) {
+		at = p.ConnectedSince
+	}
+	session := Session{Start: p.ConnectedSince, End: at}
+	p.OnlineTotal += session.Duration()
+	p.Sessions = append(p.Sessions, session)
+	if len(p.Sessions) > MaxSessionHistory {
+		p.Sessions = p.Sessions[len(p.Sessions)-MaxSessionHistory:]
+	}
+	p.ConnectedSince = time.Time{}
+}
+
+// snapshot returns a copy that is safe to hand out
+func (p Presence) snapshot() Presence {
+	p.Sessions = append([]Session(nil), p.Sessions...)
+	return p
+}
+
+// Uptime returns the duration of the current session, or zero if offline
+func (p Presence) Uptime(now time.Time) time.Duration {
+	if p.ConnectedSince.IsZero() {
+		return 0
+	}
+	return now.Sub(p.ConnectedSince)
+}
+
+// Availability returns the fraction of time the client was online since it was first seen
+func (p Presence) Availability(now time.Time) float64 {
+	if p.FirstSeen.IsZero() {
+		return 0
+	}
+	observed := now.Sub(p.FirstSeen)
+	if observed <= 0 {
+		return 1
+	}
+	online := p.OnlineTotal + p.Uptime(now)
+	availability := float64(online) / float64(observed)
+	if availability > 1 {
+		return 1
+	}
+	return availability
+}
@@ -0,0 +1,48 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPresence_Availability(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var presence Presence
+	presence.open(start)
+	presence.close(start.Add(30 * time.Minute))
+	presence.open(start.Add(50 * time.Minute))
+
+	now := start.Add(60 * time.Minute)
+
+	if uptime := presence.Uptime(now); uptime != 10*time.Minute {
+		t.Errorf("Expected uptime 10m, got %v", uptime)
+	}
+	// 30m + 10m online out of 60m observed
+	expected := 40.0 / 60.0
+	if availability := presence.Availability(now); availability < expected-0.0001 || availability > expected+0.0001 {
+		t.Errorf("Expected availability %.4f, got %.4f", expected, availability)
+	}
+}
+
+func TestPresence_NeverSeen(t *testing.T) {
+	var presence Presence
+	if presence.Availability(time.Now()) != 0 {
+		t.Error("Expected zero availability for a client never seen")
+	}
+	if presence.Uptime(time.Now()) != 0 {
+		t.Error("Expected zero uptime for a client never seen")
+	}
+}
+
+func TestPresence_SessionHistoryIsBounded(t *testing.T) {
+	start := time.Now()
+	var presence Presence
+	for i := 0; i < MaxSessionHistory+10; i++ {
+		presence.open(start.Add(time.Duration(i) * time.Minute))
+		presence.close(start.Add(time.Duration(i)*time.Minute + time.Second))
+	}
+	if len(presence.Sessions) != MaxSessionHistory {
+		t.Errorf("Expected %d sessions, got %d", MaxSessionHistory, len(presence.Sessions))
+	}
+}
@@ -0,0 +1,269 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/logging"
+	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
+)
+
+// DefaultTenant owns every client that is not explicitly assigned to a tenant
+const DefaultTenant = "default"
+
+var (
+	// ErrClientNotInTenant is returned when a tenant-scoped store touches another tenant's client
+	ErrClientNotInTenant = errors.New("client does not belong to tenant")
+	// ErrQuotaExceeded is returned when a tenant quota would be exceeded
+	ErrQuotaExceeded = errors.New("tenant quota exceeded")
+)
+
+// TenantQuotas limits the resources a tenant can use (zero means unlimited)
+type TenantQuotas struct {
+	MaxClients        int
+	MaxPendingActions int
+}
+
+// Tenant is a customer owning a group of boxes
+type Tenant struct {
+	ID      string
+	Name    string
+	Clients []string
+	Quotas  TenantQuotas
+}
+
+// tenantState is the runtime state of a tenant inside the store
+type tenantState struct {
+	tenant     Tenant
+	actions    *ActionQueue // Action queue shared by the tenant's boxes
+	automation *automation  // Scenes, schedules and webhooks of the tenant
+}
+
+// tenantRegistry maps clients to tenants
+// Unassigned clients belong to DefaultTenant, whose queue is the historical global queue
+type tenantRegistry struct {
+	mu       sync.RWMutex
+	tenants  map[string]*tenantState
+	tenantOf map[string]string // clientID -> tenantID
+}
+
+func newTenantRegistry() *tenantRegistry {
+	return &tenantRegistry{
+		tenants: map[string]*tenantState{
+			DefaultTenant: {
+				tenant:     Tenant{ID: DefaultTenant, Name: "Default"},
+				actions:    NewActionQueue(),
+				automation: newAutomation(),
+			},
+		},
+		tenantOf: make(map[string]string),
+	}
+}
+
+// lookup returns the tenant ID of a client
+func (tr *tenantRegistry) lookup(clientID string) string {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	if tenantID, ok := tr.tenantOf[clientID]; ok {
+		return tenantID
+	}
+	return DefaultTenant
+}
+
+// queueFor returns the action queue of the client's tenant
+func (tr *tenantRegistry) queueFor(clientID string) (*ActionQueue, TenantQuotas) {
+	tenantID := tr.lookup(clientID)
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	state := tr.tenants[tenantID]
+	return state.actions, state.tenant.Quotas
+}
+
+// AddTenant registers a tenant and assigns its clients
+// A client can belong to a single tenant, and the client list must fit the MaxClients quota
+func (ms *MemoryStore) AddTenant(tenant Tenant) error {
+	if tenant.ID == "" {
+		return fmt.Errorf("tenant ID is required")
+	}
+	if tenant.Quotas.MaxClients > 0 && len(tenant.Clients) > tenant.Quotas.MaxClients {
+		return fmt.Errorf("tenant %s: %d clients exceed quota of %d: %w", tenant.ID, len(tenant.Clients), tenant.Quotas.MaxClients, ErrQuotaExceeded)
+	}
+
+	tr := ms.tenants
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if _, exists := tr.tenants[tenant.ID]; exists && tenant.ID != DefaultTenant {
+		return fmt.Errorf("tenant %s already exists", tenant.ID)
+	}
+	for _, clientID := range tenant.Clients {
+		if owner, assigned := tr.tenantOf[clientID]; assigned && owner != tenant.ID {
+			return fmt.Errorf("client %s already belongs to tenant %s", clientID, owner)
+		}
+	}
+
+	state, exists := tr.tenants[tenant.ID]
+	if !exists {
+		state = &tenantState{actions: NewActionQueue(), automation: newAutomation()}
+		tr.tenants[tenant.ID] = state
+	}
+	state.tenant = tenant
+	state.tenant.Clients = append([]string(nil), tenant.Clients...)
+	for _, clientID := range tenant.Clients {
+		tr.tenantOf[clientID] = tenant.ID
+	}
+	return nil
+}
+
+// TenantOf returns the ID of the tenant owning the client
+func (ms *MemoryStore) TenantOf(clientID string) string {
+	return ms.tenants.lookup(clientID)
+}
+
+// TenantIDs returns the IDs of every tenant, the default one included, sorted
+func (ms *MemoryStore) TenantIDs() []string {
+	tr := ms.tenants
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	ids := make([]string, 0, len(tr.tenants))
+	for id := range tr.tenants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Automation returns the scenes, schedules and webhooks of the default tenant
+func (ms *MemoryStore) Automation() Automation {
+	return ms.automationFor(DefaultTenant)
+}
+
+// ForTenant returns a view of the store restricted to the tenant's clients
+func (ms *MemoryStore) ForTenant(tenantID string) Store {
+	return &tenantStore{root: ms, tenantID: tenantID}
+}
+
+// tenantStore is a Store restricted to the clients of a single tenant
+// Every operation on a client of another tenant is refused, so isolation does not depend on handlers
+type tenantStore struct {
+	root     *MemoryStore
+	tenantID string
+	denied   bool // set on views derived from another tenant's view; they own nothing
+}
+
+// owns reports whether the client belongs to this view's tenant, logging refusals
+func (ts *tenantStore) owns(clientID string) bool {
+	if !ts.denied && ts.root.TenantOf(clientID) == ts.tenantID {
+		return true
+	}
+	logging.Warnf("[TENANT] Tenant %s denied access to client %s", ts.tenantID, clientID)
+	return false
+}
+
+func (ts *tenantStore) GetValue(clientID string, index int) (string, bool) {
+	if !ts.owns(clientID) {
+		return "", false
+	}
+	return ts.root.GetValue(clientID, index)
+}
+
+func (ts *tenantStore) SetValue(clientID string, index int, value string) {
+	if ts.owns(clientID) {
+		ts.root.SetValue(clientID, index, value)
+	}
+}
+
+func (ts *tenantStore) GetAllValues(clientID string, indices []int) []protocol.ExchangeKV {
+	if !ts.owns(clientID) {
+		return []protocol.ExchangeKV{}
+	}
+	return ts.root.GetAllValues(clientID, indices)
+}
+
+func (ts *tenantStore) EnqueueAction(clientID string, action protocol.Action) error {
+	if !ts.owns(clientID) {
+		return ErrClientNotInTenant
+	}
+	return ts.root.EnqueueAction(clientID, action)
+}
+
+func (ts *tenantStore) DequeueActions(clientID string) []protocol.Action {
+	if !ts.owns(clientID) {
+		return nil
+	}
+	return ts.root.DequeueActions(clientID)
+}
+
+func (ts *tenantStore) AcknowledgeAction(clientID string, guid string) bool {
+	if !ts.owns(clientID) {
+		return false
+	}
+	return ts.root.AcknowledgeAction(clientID, guid)
+}
+
+func (ts *tenantStore) IsClientConnected(clientID string) bool {
+	if !ts.owns(clientID) {
+		return false
+	}
+	return ts.root.IsClientConnected(clientID)
+}
+
+func (ts *tenantStore) SetClientConnected(clientID string, connected bool) {
+	if ts.owns(clientID) {
+		ts.root.SetClientConnected(clientID, connected)
+	}
+}
+
+func (ts *tenantStore) MarkOfflineIfSilent(clientID string, cutoff time.Time) bool {
+	return ts.owns(clientID) && ts.root.MarkOfflineIfSilent(clientID, cutoff)
+}
+
+// ListClients returns only the tenant's clients
+func (ts *tenantStore) ListClients() []ClientInfo {
+	all := ts.root.ListClients()
+	result := make([]ClientInfo, 0, len(all))
+	for _, client := range all {
+		if !ts.denied && ts.root.TenantOf(client.ID) == ts.tenantID {
+			result = append(result, client)
+		}
+	}
+	return result
+}
+
+func (ts *tenantStore) RecordClientReport(clientID string, report ClientReport) {
+	if ts.owns(clientID) {
+		ts.root.RecordClientReport(clientID, report)
+	}
+}
+
+func (ts *tenantStore) UpdateClientMetadata(clientID string, update MetadataUpdate) {
+	if ts.owns(clientID) {
+		ts.root.UpdateClientMetadata(clientID, update)
+	}
+}
+
+// Automation returns the tenant's scenes, schedules and webhooks, and nothing for a denied view
+func (ts *tenantStore) Automation() Automation {
+	if ts.denied {
+		return deniedAutomation{}
+	}
+	if automation := ts.root.automationFor(ts.tenantID); automation != nil {
+		return automation
+	}
+	return deniedAutomation{}
+}
+
+func (ts *tenantStore) TenantOf(clientID string) string {
+	return ts.root.TenantOf(clientID)
+}
+
+// ForTenant never widens a scoped view: any other tenant gets a view that owns nothing
+func (ts *tenantStore) ForTenant(tenantID string) Store {
+	if tenantID == ts.tenantID {
+		return ts
+	}
+	return &tenantStore{root: ts.root, tenantID: ts.tenantID, denied: true}
+}
@@ -0,0 +1,121 @@
+package data
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
+)
+
+func newTenantTestStore(t *testing.T) *MemoryStore {
+	t.Helper()
+	store := NewMemoryStore()
+	if err := store.AddTenant(Tenant{ID: "acme", Clients: []string{"box-a"}}); err != nil {
+		t.Fatalf("AddTenant(acme) failed: %v", err)
+	}
+	if err := store.AddTenant(Tenant{ID: "globex", Clients: []string{"box-g"}, Quotas: TenantQuotas{MaxPendingActions: 1}}); err != nil {
+		t.Fatalf("AddTenant(globex) failed: %v", err)
+	}
+	return store
+}
+
+func TestAddTenant_Validation(t *testing.T) {
+	store := newTenantTestStore(t)
+
+	if err := store.AddTenant(Tenant{ID: "acme"}); err == nil {
+		t.Error("Expected error for duplicate tenant, got nil")
+	}
+	if err := store.AddTenant(Tenant{ID: "initech", Clients: []string{"box-a"}}); err == nil {
+		t.Error("Expected error for client owned by another tenant, got nil")
+	}
+	err := store.AddTenant(Tenant{ID: "hooli", Clients: []string{"box-1", "box-2"}, Quotas: TenantQuotas{MaxClients: 1}})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+	}
+
+	if store.TenantOf("box-a") != "acme" {
+		t.Errorf("Expected box-a in tenant acme, got %s", store.TenantOf("box-a"))
+	}
+	if store.TenantOf("unknown") != DefaultTenant {
+		t.Errorf("Expected unassigned box in default tenant, got %s", store.TenantOf("unknown"))
+	}
+	if ids := strings.Join(store.TenantIDs(), ","); ids != "acme,default,globex" {
+		t.Errorf("Expected tenants acme,default,globex, got %s", ids)
+	}
+}
+
+func TestTenantQueues_AreSeparate(t *testing.T) {
+	store := newTenantTestStore(t)
+
+	if err := store.EnqueueAction("box-a", protocol.Action{GUID: "a-1"}); err != nil {
+		t.Fatalf("EnqueueAction failed: %v", err)
+	}
+	if err := store.EnqueueAction("legacy-box", protocol.Action{GUID: "d-1"}); err != nil {
+		t.Fatalf("EnqueueAction failed: %v", err)
+	}
+
+	if actions := store.DequeueActions("box-g"); len(actions) != 0 {
+		t.Errorf("Expected no actions for globex, got %d", len(actions))
+	}
+	if actions := store.DequeueActions("box-a"); len(actions) != 1 || actions[0].GUID != "a-1" {
+		t.Errorf("Expected acme action a-1, got %+v", actions)
+	}
+	if store.AcknowledgeAction("box-g", "a-1") {
+		t.Error("Expected globex box unable to acknowledge an acme action")
+	}
+	if actions := store.DequeueActions("other-legacy-box"); len(actions) != 1 || actions[0].GUID != "d-1" {
+		t.Errorf("Expected unassigned boxes to share the default queue, got %+v", actions)
+	}
+}
+
+func TestTenantQuota_MaxPendingActions(t *testing.T) {
+	store := newTenantTestStore(t)
+
+	if err := store.EnqueueAction("box-g", protocol.Action{GUID: "g-1"}); err != nil {
+		t.Fatalf("First EnqueueAction failed: %v", err)
+	}
+	if err := store.EnqueueAction("box-g", protocol.Action{GUID: "g-2"}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+	}
+
+	store.AcknowledgeAction("box-g", "g-1")
+	if err := store.EnqueueAction("box-g", protocol.Action{GUID: "g-3"}); err != nil {
+		t.Errorf("Expected enqueue to succeed after ack, got %v", err)
+	}
+}
+
+func TestForTenant_Isolation(t *testing.T) {
+	store := newTenantTestStore(t)
+	store.SetClientConnected("box-a", true)
+	store.SetClientConnected("box-g", true)
+	store.SetValue("box-g", 1, "secret")
+
+	acme := store.ForTenant("acme")
+
+	clients := acme.ListClients()
+	if len(clients) != 1 || clients[0].ID != "box-a" {
+		t.Errorf("Expected acme to list only box-a, got %+v", clients)
+	}
+	if _, ok := acme.GetValue("box-g", 1); ok {
+		t.Error("Expected acme unable to read globex values")
+	}
+	if err := acme.EnqueueAction("box-g", protocol.Action{GUID: "x"}); !errors.Is(err, ErrClientNotInTenant) {
+		t.Errorf("Expected ErrClientNotInTenant, got %v", err)
+	}
+	acme.UpdateClientMetadata("box-g", MetadataUpdate{Name: stringPtr("hijacked")})
+	for _, client := range store.ListClients() {
+		if client.ID == "box-g" && client.Metadata.Name != "" {
+			t.Errorf("Expected globex metadata untouched, got %q", client.Metadata.Name)
+		}
+	}
+
+	// A scoped view cannot be widened to another tenant
+	if clients := acme.ForTenant("globex").ListClients(); len(clients) != 0 {
+		t.Errorf("Expected no clients through a widened view, got %+v", clients)
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
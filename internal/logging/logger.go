@@ -0,0 +1,216 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log entry
+type Level int
+
+const (
+	// LevelDebug is used for verbose troubleshooting output
+	LevelDebug Level = iota
+	// LevelInfo is used for normal operational messages
+	LevelInfo
+	// LevelWarn is used for unexpected but recoverable situations
+	LevelWarn
+	// LevelError is used for failures
+	LevelError
+)
+
+// String returns the lowercase name of the level
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+}
+
+// ParseLevel converts a level name (debug, info, warn or warning, error) to a Level
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown log level: %s", name)
+	}
+}
+
+// Format is the output encoding of log entries
+type Format int
+
+const (
+	// FormatText writes entries through the standard log package as plain lines, level first
+	FormatText Format = iota
+	// FormatJSON writes one JSON object per entry
+	FormatJSON
+)
+
+// ParseFormat converts a format name (text, json) to a Format
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "", "text":
+		return FormatText, nil
+	case "json":
+		return FormatJSON, nil
+	default:
+		return FormatText, fmt.Errorf("unknown log format: %s", name)
+	}
+}
+
+// Logger is a leveled logger that writes to the standard log package output
+// Writing through log.Writer() keeps log.SetOutput working for tests and tools
+type Logger struct {
+	mu     sync.RWMutex
+	level  Level
+	format Format
+}
+
+// New creates a new Logger with the given level and format
+func New(level Level, format Format) *Logger {
+	return &Logger{
+		level:  level,
+		format: format,
+	}
+}
+
+// SetLevel changes the minimum level that is written
+func (l *Logger) SetLevel(level Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+}
+
+// SetFormat changes the output encoding
+func (l *Logger) SetFormat(format Format) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.format = format
+}
+
+// Enabled reports whether entries at the given level are written
+func (l *Logger) Enabled(level Level) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return level >= l.level
+}
+
+// jsonEntry is the shape of a single entry in JSON format
+type jsonEntry struct {
+	Time  string `json:"time"`
+	Level string `json:"level"`
+	Msg   string `json:"msg"`
+}
+
+// logf formats and writes a single entry if its level is enabled
+func (l *Logger) logf(level Level, format string, args ...interface{}) {
+	l.mu.RLock()
+	minLevel, outFormat := l.level, l.format
+	l.mu.RUnlock()
+
+	if level < minLevel {
+		return
+	}
+
+	msg := fmt.Sprintf(format, args...)
+
+	if outFormat == FormatJSON {
+		entry, err := json.Marshal(jsonEntry{
+			Time:  time.Now().Format(time.RFC3339Nano),
+			Level: level.String(),
+			Msg:   msg,
+		})
+		if err != nil {
+			return
+		}
+		log.Writer().Write(append(entry, '\n'))
+		return
+	}
+
+	// The level leads the message, so call sites do not prefix it by hand
+	// calldepth 3: logf -> Debugf/Infof/... -> caller
+	log.Output(3, strings.ToUpper(level.String())+" "+msg)
+}
+
+// Debugf logs a message at debug level
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.logf(LevelDebug, format, args...)
+}
+
+// Infof logs a message at info level
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.logf(LevelInfo, format, args...)
+}
+
+// Warnf logs a message at warn level
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.logf(LevelWarn, format, args...)
+}
+
+// Errorf logs a message at error level
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.logf(LevelError, format, args...)
+}
+
+// std is the process-wide logger used by the package-level helpers
+// It logs everything until Configure is called, like the standard log package
+var std = New(LevelDebug, FormatText)
+
+// Default returns the process-wide logger
+func Default() *Logger {
+	return std
+}
+
+// Configure sets the level and format of the process-wide logger from their names
+func Configure(level, format string) error {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	outFormat, err := ParseFormat(format)
+	if err != nil {
+		return err
+	}
+	std.SetLevel(lvl)
+	std.SetFormat(outFormat)
+	return nil
+}
+
+// Debugf logs a message at debug level on the process-wide logger
+func Debugf(format string, args ...interface{}) {
+	std.logf(LevelDebug, format, args...)
+}
+
+// Infof logs a message at info level on the process-wide logger
+func Infof(format string, args ...interface{}) {
+	std.logf(LevelInfo, format, args...)
+}
+
+// Warnf logs a message at warn level on the process-wide logger
+func Warnf(format string, args ...interface{}) {
+	std.logf(LevelWarn, format, args...)
+}
+
+// Errorf logs a message at error level on the process-wide logger
+func Errorf(format string, args ...interface{}) {
+	std.logf(LevelError, format, args...)
+}
@@ -0,0 +1,104 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestLogger_LevelFiltering(t *testing.T) {
+	var logBuffer bytes.Buffer
+	log.SetOutput(&logBuffer)
+	defer log.SetOutput(nil)
+
+	logger := New(LevelWarn, FormatText)
+	logger.Debugf("debug message")
+	logger.Infof("info message")
+	logger.Warnf("warn message")
+	logger.Errorf("error message")
+
+	logOutput := logBuffer.String()
+	if strings.Contains(logOutput, "debug message") || strings.Contains(logOutput, "info message") {
+		t.Errorf("Expected debug and info to be filtered, got: %s", logOutput)
+	}
+	if !strings.Contains(logOutput, "warn message") || !strings.Contains(logOutput, "error message") {
+		t.Errorf("Expected warn and error in log, got: %s", logOutput)
+	}
+}
+
+func TestLogger_TextFormatLevel(t *testing.T) {
+	var logBuffer bytes.Buffer
+	log.SetOutput(&logBuffer)
+	defer log.SetOutput(nil)
+
+	logger := New(LevelDebug, FormatText)
+	logger.Debugf("[GO] debug message")
+	logger.Warnf("[AUTH] warn message")
+
+	lines := strings.Split(strings.TrimSpace(logBuffer.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %q", logBuffer.String())
+	}
+	if !strings.HasSuffix(lines[0], "DEBUG [GO] debug message") {
+		t.Errorf("Expected the level before the message, got %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "WARN [AUTH] warn message") {
+		t.Errorf("Expected the level before the message, got %q", lines[1])
+	}
+}
+
+func TestLogger_JSONFormat(t *testing.T) {
+	var logBuffer bytes.Buffer
+	log.SetOutput(&logBuffer)
+	defer log.SetOutput(nil)
+
+	logger := New(LevelInfo, FormatJSON)
+	logger.Infof("hello %s", "world")
+
+	var entry map[string]string
+	if err := json.Unmarshal(logBuffer.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected a JSON log line, got %q: %v", logBuffer.String(), err)
+	}
+	if entry["level"] != "info" {
+		t.Errorf("Expected level 'info', got '%s'", entry["level"])
+	}
+	if entry["msg"] != "hello world" {
+		t.Errorf("Expected msg 'hello world', got '%s'", entry["msg"])
+	}
+	if entry["time"] == "" {
+		t.Error("Expected time in JSON entry")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Level
+		wantErr  bool
+	}{
+		{"debug", LevelDebug, false},
+		{"INFO", LevelInfo, false},
+		{"warn", LevelWarn, false},
+		{"warning", LevelWarn, false},
+		{"error", LevelError, false},
+		{"verbose", LevelInfo, true},
+	}
+
+	for _, tt := range tests {
+		level, err := ParseLevel(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLevel(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if !tt.wantErr && level != tt.expected {
+			t.Errorf("ParseLevel(%q) = %v, expected %v", tt.input, level, tt.expected)
+		}
+	}
+}
+
+func TestConfigure_InvalidFormat(t *testing.T) {
+	if err := Configure("info", "xml"); err == nil {
+		t.Error("Expected error for invalid format, got nil")
+	}
+}
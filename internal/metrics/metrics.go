@@ -0,0 +1,95 @@
+package metrics
+
+// Default is the process-wide registry served on the metrics listener
+var Default = NewRegistry()
+
+// Series recorded by the server
+// They are registered on Default at init so every package can record without wiring
+var (
+	// HTTPRequests counts handled requests per route, method and status code
+	HTTPRequests = NewCounterVec(
+		"essensys_http_requests_total",
+		"Total number of HTTP requests handled, by route, method and status code.",
+		"route", "method", "status",
+	)
+
+	// HTTPRequestDuration tracks handler latency per route and status code
+	HTTPRequestDuration = NewHistogramVec(
+		"essensys_http_request_duration_seconds",
+		"HTTP request handling latency in seconds, by route and status code.",
+		DefaultBuckets,
+		"route", "status",
+	)
+
+	// ActionsDelivered counts actions sent to boxes in /api/myactions responses
+	ActionsDelivered = NewCounterVec(
+		"essensys_actions_delivered_total",
+		"Total number of actions delivered to boxes in /api/myactions responses.",
+	)
+
+	// ActionsAcknowledged counts actions acknowledged through /api/done/{guid}
+	ActionsAcknowledged = NewCounterVec(
+		"essensys_actions_acknowledged_total",
+		"Total number of actions acknowledged by boxes through /api/done.",
+	)
+
+	// ActionAckLatency tracks the time between enqueueing an action and its acknowledgment
+	ActionAckLatency = NewHistogramVec(
+		"essensys_action_ack_latency_seconds",
+		"Time between an action being queued and its acknowledgment, in seconds.",
+		[]float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 30, 60, 300},
+	)
+
+	// NormalizerFixes counts request bodies that NormalizeJSON had to rewrite
+	NormalizerFixes = NewCounterVec(
+		"essensys_json_normalizer_fixes_total",
+		"Total number of request bodies rewritten by the JSON normalizer.",
+	)
+
+	// NormalizerFailures counts request bodies that could not be normalized to valid JSON
+	NormalizerFailures = NewCounterVec(
+		"essensys_json_normalizer_failures_total",
+		"Total number of request bodies the JSON normalizer could not turn into valid JSON.",
+	)
+
+	// LegacyParseErrors counts malformed requests rejected by LegacyHTTPServer, by reason
+	LegacyParseErrors = NewCounterVec(
+		"essensys_legacy_parse_errors_total",
+		"Total number of requests LegacyHTTPServer failed to parse, by reason.",
+		"reason",
+	)
+
+	// LegacyRejections counts connections and requests refused by the LegacyHTTPServer limits, by reason
+	LegacyRejections = NewCounterVec(
+		"essensys_legacy_rejections_total",
+		"Total number of connections and requests refused by LegacyHTTPServer resource limits, by reason.",
+		"reason",
+	)
+
+	// MatriculeMismatches counts authenticated requests whose matricule line names another box
+	MatriculeMismatches = NewCounterVec(
+		"essensys_auth_matricule_mismatches_total",
+		"Total number of authenticated requests whose matricule line does not match the Basic credential.",
+	)
+
+	// ConformanceViolations counts legacy responses breaking a firmware protocol rule, by rule
+	ConformanceViolations = NewCounterVec(
+		"essensys_conformance_violations_total",
+		"Total number of legacy responses breaking a firmware protocol rule, by rule.",
+		"rule",
+	)
+)
+
+func init() {
+	Default.Register(HTTPRequests)
+	Default.Register(HTTPRequestDuration)
+	Default.Register(ActionsDelivered)
+	Default.Register(ActionsAcknowledged)
+	Default.Register(ActionAckLatency)
+	Default.Register(NormalizerFixes)
+	Default.Register(NormalizerFailures)
+	Default.Register(LegacyParseErrors)
+	Default.Register(LegacyRejections)
+	Default.Register(MatriculeMismatches)
+	Default.Register(ConformanceViolations)
+}
@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Collector is a metric family that can write itself in Prometheus text format
+type Collector interface {
+	// Name returns the metric family name
+	Name() string
+	// WriteText writes the HELP, TYPE and sample lines of the family
+	WriteText(w io.Writer)
+}
+
+// Registry holds a set of collectors and renders them for scraping
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]Collector
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]Collector),
+	}
+}
+
+// Register adds a collector to the registry
+// Registering a second collector with the same name replaces the first one
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors[c.Name()] = c
+}
+
+// Unregister removes the collector with the given name
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.collectors, name)
+}
+
+// WriteText writes all registered families in Prometheus text format, sorted by name
+func (r *Registry) WriteText(w io.Writer) {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	collectors := make([]Collector, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.mu.RUnlock()
+
+	for _, c := range collectors {
+		c.WriteText(w)
+	}
+}
+
+// Handler returns an http.Handler serving the registry in Prometheus text format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var buf bytes.Buffer
+		r.WriteText(&buf)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.Bytes())
+	})
+}
+
+// writeHeader writes the HELP and TYPE lines of a family
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+// writeSample writes a single sample line
+func writeSample(w io.Writer, name string, labelNames, labelValues []string, value float64) {
+	fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(labelNames, labelValues), formatValue(value))
+}
+
+// formatLabels renders {a="x",b="y"}, or an empty string when there are no labels
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// formatValue renders a float the way Prometheus expects (+Inf, -Inf, NaN)
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueReplacer.Replace(v)
+}
+
+func escapeHelp(v string) string {
+	return helpReplacer.Replace(v)
+}
+
+// labelKey joins label values into a map key
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// checkLabels panics if the number of label values does not match the label names
+// A mismatch is a programming error, like in the Prometheus client library
+func checkLabels(name string, names, values []string) {
+	if len(names) != len(values) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", name, len(names), len(values)))
+	}
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_CounterText(t *testing.T) {
+	registry := NewRegistry()
+	counter := NewCounterVec("test_requests_total", "Test requests.", "route", "status")
+	registry.Register(counter)
+
+	counter.Inc("/api/mystatus", "201")
+	counter.Inc("/api/mystatus", "201")
+	counter.Inc("/api/myactions", "200")
+
+	var buf bytes.Buffer
+	registry.WriteText(&buf)
+
+	expected := `# HELP test_requests_total Test requests.
+# TYPE test_requests_total counter
+test_requests_total{route="/api/myactions",status="200"} 1
+test_requests_total{route="/api/mystatus",status="201"} 2
+`
+	if buf.String() != expected {
+		t.Errorf("Unexpected output:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
+
+func TestRegistry_UnlabelledCounterStartsAtZero(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(NewCounterVec("test_total", "Test."))
+
+	var buf bytes.Buffer
+	registry.WriteText(&buf)
+
+	if !strings.Contains(buf.String(), "test_total 0\n") {
+		t.Errorf("Expected zero sample, got:\n%s", buf.String())
+	}
+}
+
+func TestHistogram_Text(t *testing.T) {
+	registry := NewRegistry()
+	histogram := NewHistogramVec("test_latency_seconds", "Test latency.", []float64{0.1, 1}, "route")
+	registry.Register(histogram)
+
+	histogram.Observe(0.05, "/a")
+	histogram.Observe(0.5, "/a")
+	histogram.Observe(5, "/a")
+
+	var buf bytes.Buffer
+	registry.WriteText(&buf)
+	output := buf.String()
+
+	for _, line := range []string{
+		`test_latency_seconds_bucket{route="/a",le="0.1"} 1`,
+		`test_latency_seconds_bucket{route="/a",le="1"} 2`,
+		`test_latency_seconds_bucket{route="/a",le="+Inf"} 3`,
+		`test_latency_seconds_sum{route="/a"} 5.55`,
+		`test_latency_seconds_count{route="/a"} 3`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected line %q in output:\n%s", line, output)
+		}
+	}
+}
+
+func TestGaugeFunc_EscapesLabels(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(NewGaugeFunc("test_gauge", "Test gauge.", func() []Sample {
+		return []Sample{{LabelValues: []string{`a"b`}, Value: 3}}
+	}, "client"))
+
+	var buf bytes.Buffer
+	registry.WriteText(&buf)
+
+	if !strings.Contains(buf.String(), `test_gauge{client="a\"b"} 3`) {
+		t.Errorf("Expected escaped label value, got:\n%s", buf.String())
+	}
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(NewCounterVec("test_total", "Test."))
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected Content-Type: %s", w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), "test_total 0") {
+		t.Errorf("Expected metric in body, got:\n%s", w.Body.String())
+	}
+}
@@ -0,0 +1,316 @@
+package metrics
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// series is one labelled time series inside a vector
+type series struct {
+	labelValues []string
+	value       float64
+}
+
+// vec is the shared storage of counter and gauge vectors
+type vec struct {
+	mu         sync.Mutex
+	name       string
+	help       string
+	labelNames []string
+	series     map[string]*series
+}
+
+// init sets up an empty vector
+func (v *vec) init(name, help string, labelNames []string) {
+	v.name = name
+	v.help = help
+	v.labelNames = labelNames
+	v.series = make(map[string]*series)
+	// Unlabelled families expose a zero sample before the first update
+	if len(labelNames) == 0 {
+		v.series[""] = &series{}
+	}
+}
+
+// get returns the series for the label values, creating it if needed (caller holds mu)
+func (v *vec) get(labelValues []string) *series {
+	checkLabels(v.name, v.labelNames, labelValues)
+	key := labelKey(labelValues)
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		v.series[key] = s
+	}
+	return s
+}
+
+// value returns the current value for the label values without creating the series
+func (v *vec) value(labelValues []string) float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	checkLabels(v.name, v.labelNames, labelValues)
+	if s, ok := v.series[labelKey(labelValues)]; ok {
+		return s.value
+	}
+	return 0
+}
+
+// writeText writes every series sorted by label values
+func (v *vec) writeText(w io.Writer, kind string) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	samples := make([]series, 0, len(keys))
+	for _, key := range keys {
+		samples = append(samples, *v.series[key])
+	}
+	v.mu.Unlock()
+
+	writeHeader(w, v.name, v.help, kind)
+	for _, s := range samples {
+		writeSample(w, v.name, v.labelNames, s.labelValues, s.value)
+	}
+}
+
+// CounterVec is a set of monotonically increasing counters partitioned by labels
+type CounterVec struct {
+	vec
+}
+
+// NewCounterVec creates a CounterVec with the given label names
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{}
+	c.init(name, help, labelNames)
+	return c
+}
+
+// Name returns the metric family name
+func (c *CounterVec) Name() string { return c.name }
+
+// Inc increments the counter for the label values by one
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter for the label values by delta (negative deltas are ignored)
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.get(labelValues).value += delta
+}
+
+// Value returns the current value of the counter for the label values
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	return c.value(labelValues)
+}
+
+// WriteText writes the family in Prometheus text format
+func (c *CounterVec) WriteText(w io.Writer) { c.writeText(w, "counter") }
+
+// GaugeVec is a set of values that can go up and down, partitioned by labels
+type GaugeVec struct {
+	vec
+}
+
+// NewGaugeVec creates a GaugeVec with the given label names
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	g := &GaugeVec{}
+	g.init(name, help, labelNames)
+	return g
+}
+
+// Name returns the metric family name
+func (g *GaugeVec) Name() string { return g.name }
+
+// Set sets the gauge for the label values
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.get(labelValues).value = value
+}
+
+// Add adds delta (possibly negative) to the gauge for the label values
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.get(labelValues).value += delta
+}
+
+// Value returns the current value of the gauge for the label values
+func (g *GaugeVec) Value(labelValues ...string) float64 {
+	return g.value(labelValues)
+}
+
+// Delete removes the series for the label values
+func (g *GaugeVec) Delete(labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	checkLabels(g.name, g.labelNames, labelValues)
+	delete(g.series, labelKey(labelValues))
+}
+
+// WriteText writes the family in Prometheus text format
+func (g *GaugeVec) WriteText(w io.Writer) { g.writeText(w, "gauge") }
+
+// Sample is a single labelled value returned by a GaugeFunc
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// GaugeFunc is a gauge whose samples are computed by a callback at scrape time
+// It is used for values owned by another component, like the per-client state in the store
+type GaugeFunc struct {
+	name       string
+	help       string
+	labelNames []string
+	collect    func() []Sample
+}
+
+// NewGaugeFunc creates a GaugeFunc; collect must return label values matching labelNames
+func NewGaugeFunc(name, help string, collect func() []Sample, labelNames ...string) *GaugeFunc {
+	return &GaugeFunc{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		collect:    collect,
+	}
+}
+
+// Name returns the metric family name
+func (g *GaugeFunc) Name() string { return g.name }
+
+// WriteText writes the family in Prometheus text format
+func (g *GaugeFunc) WriteText(w io.Writer) {
+	samples := g.collect()
+	sort.Slice(samples, func(i, j int) bool {
+		return labelKey(samples[i].LabelValues) < labelKey(samples[j].LabelValues)
+	})
+
+	writeHeader(w, g.name, g.help, "gauge")
+	for _, s := range samples {
+		checkLabels(g.name, g.labelNames, s.LabelValues)
+		writeSample(w, g.name, g.labelNames, s.LabelValues, s.Value)
+	}
+}
+
+// DefaultBuckets are latency buckets in seconds suited to the 500ms-2s polling cycle
+var DefaultBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// histogramSeries is one labelled histogram
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64 // cumulative count per bucket is computed at write time
+	sum         float64
+	count       uint64
+}
+
+// HistogramVec is a set of histograms partitioned by labels
+type HistogramVec struct {
+	mu         sync.Mutex
+	name       string
+	help       string
+	buckets    []float64
+	labelNames []string
+	series     map[string]*histogramSeries
+}
+
+// NewHistogramVec creates a HistogramVec with the given upper bounds (sorted ascending)
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	h := &HistogramVec{
+		name:       name,
+		help:       help,
+		buckets:    sorted,
+		labelNames: labelNames,
+		series:     make(map[string]*histogramSeries),
+	}
+	// Unlabelled families expose empty buckets before the first observation
+	if len(labelNames) == 0 {
+		h.series[""] = &histogramSeries{counts: make([]uint64, len(sorted))}
+	}
+	return h
+}
+
+// Name returns the metric family name
+func (h *HistogramVec) Name() string { return h.name }
+
+// Observe records a single value for the label values
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	checkLabels(h.name, h.labelNames, labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := labelKey(labelValues)
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{
+			labelValues: append([]string(nil), labelValues...),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = s
+	}
+
+	for i, upper := range h.buckets {
+		if value <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// ObserveDuration records a duration in seconds for the label values
+func (h *HistogramVec) ObserveDuration(d time.Duration, labelValues ...string) {
+	h.Observe(d.Seconds(), labelValues...)
+}
+
+// Count returns the number of observations for the label values
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[labelKey(labelValues)]; ok {
+		return s.count
+	}
+	return 0
+}
+
+// WriteText writes the family in Prometheus text format
+func (h *HistogramVec) WriteText(w io.Writer) {
+	h.mu.Lock()
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	snapshot := make([]histogramSeries, 0, len(keys))
+	for _, key := range keys {
+		s := *h.series[key]
+		s.counts = append([]uint64(nil), s.counts...)
+		snapshot = append(snapshot, s)
+	}
+	h.mu.Unlock()
+
+	writeHeader(w, h.name, h.help, "histogram")
+	bucketLabels := append(append([]string(nil), h.labelNames...), "le")
+	for _, s := range snapshot {
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			writeSample(w, h.name+"_bucket", bucketLabels, append(append([]string(nil), s.labelValues...), formatValue(upper)), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", bucketLabels, append(append([]string(nil), s.labelValues...), "+Inf"), float64(s.count))
+		writeSample(w, h.name+"_sum", h.labelNames, s.labelValues, s.sum)
+		writeSample(w, h.name+"_count", h.labelNames, s.labelValues, float64(s.count))
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+const (
+	// TenantIDKey is the context key for storing the tenant of an admin request
+	TenantIDKey contextKey = "tenantID"
+)
+
+// GetTenantID extracts the tenant ID from the request context
+func GetTenantID(r *http.Request) (string, bool) {
+	tenantID, ok := r.Context().Value(TenantIDKey).(string)
+	return tenantID, ok
+}
+
+// AdminTokenAuth middleware validates Bearer admin tokens
+// adminTokens maps each token to the tenant it grants access to
+func AdminTokenAuth(adminTokens map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Extract Authorization header
+			authHeader := r.Header.Get("Authorization")
+			if !strings.HasPrefix(authHeader, "Bearer ") {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			// Validate token
+			token := strings.TrimPrefix(authHeader, "Bearer ")
+			tenantID, exists := adminTokens[token]
+			if token == "" || !exists {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			// Set tenantID in context so handlers use a tenant-scoped store
+			ctx := context.WithValue(r.Context(), TenantIDKey, tenantID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminTokenAuth(t *testing.T) {
+	adminTokens := map[string]string{
+		"token-acme":   "acme",
+		"token-globex": "globex",
+	}
+
+	var capturedTenant string
+	handler := AdminTokenAuth(adminTokens)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedTenant, _ = GetTenantID(r)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	testCases := []struct {
+		name           string
+		authHeader     string
+		expectedStatus int
+		expectedTenant string
+	}{
+		{"missing header", "", http.StatusUnauthorized, ""},
+		{"basic auth", "Basic dGVzdDp0ZXN0", http.StatusUnauthorized, ""},
+		{"unknown token", "Bearer nope", http.StatusUnauthorized, ""},
+		{"empty token", "Bearer ", http.StatusUnauthorized, ""},
+		{"acme token", "Bearer token-acme", http.StatusOK, "acme"},
+		{"globex token", "Bearer token-globex", http.StatusOK, "globex"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			capturedTenant = ""
+			req := httptest.NewRequest("GET", "/api/admin/clients", nil)
+			if tc.authHeader != "" {
+				req.Header.Set("Authorization", tc.authHeader)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+			if capturedTenant != tc.expectedTenant {
+				t.Errorf("Expected tenant '%s', got '%s'", tc.expectedTenant, capturedTenant)
+			}
+		})
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"encoding/base64"
 	"net/http"
 	"strings"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/logging"
+	"github.com/essensys-hub/essensys-server-backend/internal/metrics"
 )
 
 // contextKey is a custom type for context keys to avoid collisions
@@ -23,6 +26,7 @@ func GetClientID(r *http.Request) (string, bool) {
 
 // BasicAuth middleware validates Basic Authentication credentials
 // validCredentials is a map of username:password pairs
+// When the legacy server found a matricule line, it is cross-checked against the credential
 func BasicAuth(validCredentials map[string]string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -67,6 +71,19 @@ func BasicAuth(validCredentials map[string]string) func(http.Handler) http.Handl
 				return
 			}
 
+			// The firmware repeats its matricule on a line of its own: it must name the same box
+			// The request is still served, but a mismatch is worth an operator's attention
+			if matricule, ok := GetMatricule(r); ok && !matricule.matches(encodedCredentials, username, password) {
+				metrics.MatriculeMismatches.Inc()
+				if matricule.ClientID == "" {
+					logging.Warnf("[AUTH] Possible spoofing from %s: Basic credential for %q but an undecodable matricule line",
+						r.RemoteAddr, username)
+				} else {
+					logging.Warnf("[AUTH] Possible spoofing from %s: Basic credential for %q but matricule line for %q",
+						r.RemoteAddr, username, matricule.ClientID)
+				}
+			}
+
 			// Set clientID in context (using username as clientID)
 			ctx := context.WithValue(r.Context(), ClientIDKey, username)
 			r = r.WithContext(ctx)
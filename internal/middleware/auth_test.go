@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/metrics"
 )
 
 func TestBasicAuth_MissingAuthHeader(t *testing.T) {
@@ -185,3 +187,51 @@ func TestBasicAuth_MalformedCredentials(t *testing.T) {
 		t.Errorf("Expected status 401, got %d", w.Code)
 	}
 }
+
+func TestBasicAuth_MatriculeCrossCheck(t *testing.T) {
+	validCredentials := map[string]string{
+		"client1": "pass1",
+		"client2": "pass2",
+	}
+
+	handler := BasicAuth(validCredentials)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name     string
+		line     string
+		mismatch bool
+	}{
+		{"same box", encode("client1:pass1"), false},
+		{"another box", encode("client2:pass2"), true},
+		{"same box, other key", encode("client1:wrong"), true},
+		{"undecodable line", "not-base64!", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Basic "+encode("client1:pass1"))
+			req = req.WithContext(WithMatricule(req.Context(), ParseMatricule(tt.line)))
+			w := httptest.NewRecorder()
+
+			before := metrics.MatriculeMismatches.Value()
+			handler.ServeHTTP(w, req)
+
+			// A mismatch is reported, not refused: the credential itself is valid
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected status 200, got %d", w.Code)
+			}
+			counted := metrics.MatriculeMismatches.Value() - before
+			if tt.mismatch && counted != 1 {
+				t.Errorf("Expected the mismatch to be counted")
+			}
+			if !tt.mismatch && counted != 0 {
+				t.Errorf("Expected no mismatch, got %v", counted)
+			}
+		})
+	}
+}
@@ -1,9 +1,10 @@
 package middleware
 
 import (
-	"log"
 	"net/http"
 	"net/http/httputil"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/logging"
 )
 
 // DebugLogger logs all incoming requests with full details
@@ -13,19 +14,19 @@ func DebugLogger(next http.Handler) http.Handler {
 		// Log the raw request
 		dump, err := httputil.DumpRequest(r, true)
 		if err != nil {
-			log.Printf("[DEBUG] Error dumping request: %v", err)
+			logging.Debugf("Error dumping request: %v", err)
 		} else {
-			log.Printf("[DEBUG] Raw request:\n%s", string(dump))
+			logging.Debugf("Raw request:\n%s", string(dump))
 		}
 
 		// Log important headers
-		log.Printf("[DEBUG] Protocol: %s", r.Proto)
-		log.Printf("[DEBUG] Method: %s", r.Method)
-		log.Printf("[DEBUG] URL: %s", r.URL.String())
-		log.Printf("[DEBUG] Host: %s", r.Host)
-		log.Printf("[DEBUG] RemoteAddr: %s", r.RemoteAddr)
-		log.Printf("[DEBUG] Content-Length: %d", r.ContentLength)
-		log.Printf("[DEBUG] Transfer-Encoding: %v", r.TransferEncoding)
+		logging.Debugf("Protocol: %s", r.Proto)
+		logging.Debugf("Method: %s", r.Method)
+		logging.Debugf("URL: %s", r.URL.String())
+		logging.Debugf("Host: %s", r.Host)
+		logging.Debugf("RemoteAddr: %s", r.RemoteAddr)
+		logging.Debugf("Content-Length: %d", r.ContentLength)
+		logging.Debugf("Transfer-Encoding: %v", r.TransferEncoding)
 
 		// Call next handler
 		next.ServeHTTP(w, r)
@@ -1,10 +1,11 @@
 package middleware
 
 import (
-	"log"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/logging"
 )
 
 // contextKey for storing normalized JSON info
@@ -64,9 +65,10 @@ func RequestLogger(next http.Handler) http.Handler {
 		}
 
 		// Log incoming request with timestamp and client IP
-		// Format: [GO] DD/MM/YYYY HH:MM:SS METHOD PATH (IP)
-		timestamp := time.Now().Format("02/01/2006 15:04:05")
-		log.Printf("[GO] %s %s %s (%s)", timestamp, r.Method, r.URL.Path, clientIP)
+		// Format: [REQUEST] DD/MM/YYYY HH:MM:SS METHOD PATH (IP)
+		start := time.Now()
+		timestamp := start.Format("02/01/2006 15:04:05")
+		logging.Infof("[REQUEST] %s %s %s (%s)", timestamp, r.Method, r.URL.Path, clientIP)
 
 		// Wrap response writer to capture status code
 		wrappedWriter := newResponseWriter(w)
@@ -74,11 +76,14 @@ func RequestLogger(next http.Handler) http.Handler {
 		// Call next handler
 		next.ServeHTTP(wrappedWriter, r)
 
+		// Log response status and duration
+		logging.Infof("[RESPONSE] %s %s %d (%v)", r.Method, r.URL.Path, wrappedWriter.statusCode, time.Since(start))
+
 		// Log JSON normalization if it occurred (only in debug mode)
 		if normalizedInfo, ok := r.Context().Value(NormalizedJSONKey).(*NormalizedJSONInfo); ok {
-			log.Printf("[DEBUG] JSON normalized for %s", r.URL.Path)
-			log.Printf("[DEBUG] Original: %s", normalizedInfo.Original)
-			log.Printf("[DEBUG] Normalized: %s", normalizedInfo.Normalized)
+			logging.Debugf("[JSON_NORMALIZATION] JSON normalized for %s", r.URL.Path)
+			logging.Debugf("[JSON_NORMALIZATION] Original: %s", normalizedInfo.Original)
+			logging.Debugf("[JSON_NORMALIZATION] Normalized: %s", normalizedInfo.Normalized)
 		}
 	})
 }
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+const (
+	// MatriculeKey is the context key for storing the matricule line sent by the firmware
+	MatriculeKey contextKey = "matricule"
+)
+
+// Matricule is the bare line the BP_MQX_ETH firmware sends after its Authorization header
+// It is the base64 of "matricule:key", like the Basic credentials
+type Matricule struct {
+	// Raw is the line as sent, without its line ending
+	Raw string
+	// ClientID and Key are decoded from Raw; both are empty when it is not base64 of "id:key"
+	ClientID string
+	Key      string
+}
+
+// ParseMatricule decodes a matricule line
+func ParseMatricule(line string) Matricule {
+	m := Matricule{Raw: strings.TrimSpace(line)}
+	decoded, err := base64.StdEncoding.DecodeString(m.Raw)
+	if err != nil {
+		return m
+	}
+	if id, key, ok := strings.Cut(string(decoded), ":"); ok && id != "" {
+		m.ClientID, m.Key = id, key
+	}
+	return m
+}
+
+// matches reports whether the matricule line names the same box as the Basic credential
+// A line that is not base64 of "id:key" is compared with the encoded credential as sent
+func (m Matricule) matches(encodedCredentials, username, password string) bool {
+	if m.ClientID == "" {
+		return m.Raw == encodedCredentials
+	}
+	return m.ClientID == username && m.Key == password
+}
+
+// WithMatricule returns a copy of ctx carrying the matricule line of the request
+func WithMatricule(ctx context.Context, m Matricule) context.Context {
+	return context.WithValue(ctx, MatriculeKey, m)
+}
+
+// GetMatricule extracts the matricule line from the request context
+// It is only present on requests read by the legacy server from firmware that sent the line
+func GetMatricule(r *http.Request) (Matricule, bool) {
+	m, ok := r.Context().Value(MatriculeKey).(Matricule)
+	return m, ok
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseMatricule(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		clientID string
+		key      string
+	}{
+		{"base64 of id:key", "MTIzNDU2Nzg5MGFiY2RlZjpmZWRjYmEwOTg3NjU0MzIx", "1234567890abcdef", "fedcba0987654321"},
+		{"surrounding spaces", "  Ym94LTE6c2VjcmV0 ", "box-1", "secret"},
+		{"not base64", "box-1", "", ""},
+		{"base64 without separator", "Ym94LTE=", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := ParseMatricule(tt.line)
+			if m.ClientID != tt.clientID || m.Key != tt.key {
+				t.Errorf("Expected %q:%q, got %q:%q", tt.clientID, tt.key, m.ClientID, m.Key)
+			}
+		})
+	}
+}
+
+func TestGetMatricule(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/serverinfos", nil)
+	if _, ok := GetMatricule(req); ok {
+		t.Error("Expected no matricule on a plain request")
+	}
+
+	req = req.WithContext(WithMatricule(context.Background(), ParseMatricule("Ym94LTE6c2VjcmV0")))
+	m, ok := GetMatricule(req)
+	if !ok || m.ClientID != "box-1" {
+		t.Errorf("Expected matricule box-1 from context, got %+v", m)
+	}
+}
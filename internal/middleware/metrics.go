@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/metrics"
+)
+
+// knownRoutes are the route labels used for metrics, checked in order
+// Prefix routes collapse variable path segments (e.g. /api/done/{guid}) to keep label cardinality bounded
+var knownRoutes = []struct {
+	path   string
+	prefix bool
+	label  string
+}{
+	{"/api/serverinfos", false, "/api/serverinfos"},
+	{"/api/mystatus", false, "/api/mystatus"},
+	{"/api/myactions", false, "/api/myactions"},
+	{"/api/done/", true, "/api/done/{guid}"},
+	{"/api/admin/inject", false, "/api/admin/inject"},
+	{"/api/admin/values", false, "/api/admin/values"},
+	{"/api/admin/presence", false, "/api/admin/presence"},
+	{"/api/admin/clients", false, "/api/admin/clients"},
+	{"/api/admin/clients/", true, "/api/admin/clients/{id}"},
+	{"/api/admin/scenes", false, "/api/admin/scenes"},
+	{"/api/admin/scenes/", true, "/api/admin/scenes/{name}"},
+	{"/api/admin/schedules", false, "/api/admin/schedules"},
+	{"/api/admin/schedules/", true, "/api/admin/schedules/{id}"},
+	{"/api/admin/webhooks", false, "/api/admin/webhooks"},
+	{"/api/admin/webhooks/", true, "/api/admin/webhooks/{id}"},
+	{"/health", false, "/health"},
+}
+
+// routeLabel maps a request path to its route label
+func routeLabel(path string) string {
+	for _, route := range knownRoutes {
+		if path == route.path || (route.prefix && strings.HasPrefix(path, route.path)) {
+			return route.label
+		}
+	}
+	return "other"
+}
+
+// RequestMetrics middleware records request counts and latency per route and status
+func RequestMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		wrappedWriter := newResponseWriter(w)
+
+		next.ServeHTTP(wrappedWriter, r)
+
+		route := routeLabel(r.URL.Path)
+		status := strconv.Itoa(wrappedWriter.statusCode)
+		metrics.HTTPRequests.Inc(route, r.Method, status)
+		metrics.HTTPRequestDuration.ObserveDuration(time.Since(start), route, status)
+	})
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/metrics"
+)
+
+func TestRouteLabel(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/api/serverinfos", "/api/serverinfos"},
+		{"/api/mystatus", "/api/mystatus"},
+		{"/api/done/abc-123", "/api/done/{guid}"},
+		{"/api/admin/values", "/api/admin/values"},
+		{"/api/admin/scenes/morning", "/api/admin/scenes/{name}"},
+		{"/api/admin/webhooks", "/api/admin/webhooks"},
+		{"/health", "/health"},
+		{"/random/path", "other"},
+	}
+
+	for _, tt := range tests {
+		if got := routeLabel(tt.path); got != tt.expected {
+			t.Errorf("routeLabel(%q) = %q, expected %q", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestRequestMetrics_CountsByRouteAndStatus(t *testing.T) {
+	before := metrics.HTTPRequests.Value("/api/myactions", "GET", "404")
+	beforeObservations := metrics.HTTPRequestDuration.Count("/api/myactions", "404")
+
+	handler := RequestMetrics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	req := httptest.NewRequest("GET", "/api/myactions", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got := metrics.HTTPRequests.Value("/api/myactions", "GET", "404"); got != before+1 {
+		t.Errorf("Expected request counter %v, got %v", before+1, got)
+	}
+	if got := metrics.HTTPRequestDuration.Count("/api/myactions", "404"); got != beforeObservations+1 {
+		t.Errorf("Expected %d latency observations, got %d", beforeObservations+1, got)
+	}
+}
@@ -2,9 +2,10 @@ package middleware
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"runtime/debug"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/logging"
 )
 
 // Recovery middleware catches panics and returns HTTP 500
@@ -13,10 +14,10 @@ func Recovery(next http.Handler) http.Handler {
 		defer func() {
 			if err := recover(); err != nil {
 				// Log the error message
-				log.Printf("[PANIC] Error: %v", err)
+				logging.Errorf("[PANIC] Error: %v", err)
 				
 				// Log the stack trace
-				log.Printf("[PANIC] Stack trace:\n%s", debug.Stack())
+				logging.Errorf("[PANIC] Stack trace:\n%s", debug.Stack())
 				
 				// Return HTTP 500 Internal Server Error
 				http.Error(w, fmt.Sprintf("Internal Server Error: %v", err), http.StatusInternalServerError)
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
+)
+
+// ConformanceMode controls what happens when a response breaks the BP_MQX_ETH protocol rules
+type ConformanceMode string
+
+const (
+	// ConformanceOff skips the checks
+	ConformanceOff ConformanceMode = "off"
+	// ConformanceWarn logs violations and sends the response anyway (production)
+	ConformanceWarn ConformanceMode = "warn"
+	// ConformanceStrict replaces a violating response with a 500 (tests)
+	ConformanceStrict ConformanceMode = "strict"
+)
+
+// ParseConformanceMode converts a configuration string to a ConformanceMode (empty means warn)
+func ParseConformanceMode(s string) (ConformanceMode, error) {
+	switch ConformanceMode(strings.ToLower(s)) {
+	case "", ConformanceWarn:
+		return ConformanceWarn, nil
+	case ConformanceOff:
+		return ConformanceOff, nil
+	case ConformanceStrict:
+		return ConformanceStrict, nil
+	}
+	return "", fmt.Errorf("unknown conformance mode %q (must be off, warn or strict)", s)
+}
+
+// Conformance rules, used as the "rule" label of the violation metric
+const (
+	RuleSingleWrite   = "single_write"
+	RuleConnection    = "connection_close"
+	RuleContentLength = "content_length"
+	RuleContentType   = "content_type"
+	RuleCreated       = "post_created"
+	RuleDe67fFirst    = "de67f_first"
+	RuleCompleteBlock = "complete_block"
+)
+
+const (
+	// legacyContentType is the exact header the firmware expects, space before the semicolon included
+	legacyContentType = "application/json ;charset=UTF-8"
+	de67fPrefix       = `{"_de67f"`
+	// strictFailResponse replaces a violating response in strict mode
+	strictFailResponse = "HTTP/1.1 500 Internal Server Error\r\nConnection: close\r\nContent-Length: 0\r\n\r\n"
+)
+
+// Violation is a single broken protocol rule
+type Violation struct {
+	Rule   string
+	Detail string
+}
+
+func (v Violation) String() string {
+	return v.Rule + ": " + v.Detail
+}
+
+// ConformanceError is returned when strict mode refuses to send a response
+type ConformanceError struct {
+	Method     string
+	Path       string
+	Violations []Violation
+}
+
+func (e *ConformanceError) Error() string {
+	return fmt.Sprintf("response to %s %s breaks the legacy protocol: %s", e.Method, e.Path, formatViolations(e.Violations))
+}
+
+// formatViolations joins violations for log lines
+func formatViolations(violations []Violation) string {
+	parts := make([]string, len(violations))
+	for i, v := range violations {
+		parts[i] = v.String()
+	}
+	return strings.Join(parts, "; ")
+}
+
+// isBoxRoute reports whether the path is polled by the firmware, whose parser is the fragile one
+func isBoxRoute(path string) bool {
+	switch path {
+	case "/api/serverinfos", "/api/mystatus", "/api/myactions":
+		return true
+	}
+	return strings.HasPrefix(path, "/api/done/")
+}
+
+// CheckResponse checks a complete raw response against the firmware's protocol rules
+// Framing rules apply to every response; payload rules apply to successful box routes
+func CheckResponse(method, path string, raw []byte) []Violation {
+	var violations []Violation
+	add := func(rule, format string, args ...interface{}) {
+		violations = append(violations, Violation{Rule: rule, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	head, body, found := bytes.Cut(raw, []byte("\r\n\r\n"))
+	if !found {
+		add(RuleContentLength, "response has no end of headers")
+		return violations
+	}
+	lines := strings.Split(string(head), "\r\n")
+
+	var status int
+	if fields := strings.Fields(lines[0]); len(fields) >= 2 {
+		status, _ = strconv.Atoi(fields[1])
+	}
+
+	header := make(http.Header)
+	for _, line := range lines[1:] {
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	}
+
+	if !strings.EqualFold(header.Get("Connection"), "close") {
+		add(RuleConnection, "missing Connection: close")
+	}
+	if values := header.Values("Content-Length"); len(values) != 1 {
+		add(RuleContentLength, "expected one Content-Length header, got %d", len(values))
+	} else if n, err := strconv.Atoi(values[0]); err != nil || n != len(body) {
+		add(RuleContentLength, "Content-Length %q does not match body length %d", values[0], len(body))
+	}
+
+	if !isBoxRoute(path) || status < 200 || status >= 300 {
+		return violations
+	}
+
+	if method == http.MethodPost && status != http.StatusCreated {
+		add(RuleCreated, "POST answered %d instead of 201", status)
+	}
+	if len(body) > 0 {
+		if ct := header.Get("Content-Type"); ct != legacyContentType {
+			add(RuleContentType, "Content-Type %q instead of %q", ct, legacyContentType)
+		}
+	}
+	if path == "/api/myactions" {
+		violations = append(violations, checkActions(body)...)
+	}
+
+	return violations
+}
+
+// checkActions checks the myactions payload: _de67f first and complete light/shutter blocks
+func checkActions(body []byte) []Violation {
+	if !bytes.HasPrefix(bytes.TrimSpace(body), []byte(de67fPrefix)) {
+		return []Violation{{Rule: RuleDe67fFirst, Detail: "_de67f is not the first field"}}
+	}
+
+	var response protocol.ActionsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return []Violation{{Rule: RuleDe67fFirst, Detail: fmt.Sprintf("body is not an actions response: %v", err)}}
+	}
+
+	var violations []Violation
+	for _, action := range response.Actions {
+		values := make(map[int]string, len(action.Params))
+		touchesBlock := false
+		for _, param := range action.Params {
+			values[param.K] = param.V
+			if param.K >= protocol.IndexLightStart && param.K <= protocol.IndexLightEnd {
+				touchesBlock = true
+			}
+		}
+		if !touchesBlock {
+			continue
+		}
+
+		var missing []string
+		for k := protocol.IndexLightStart; k <= protocol.IndexLightEnd; k++ {
+			if _, ok := values[k]; !ok {
+				missing = append(missing, strconv.Itoa(k))
+			}
+		}
+		if len(missing) > 0 {
+			violations = append(violations, Violation{
+				Rule:   RuleCompleteBlock,
+				Detail: fmt.Sprintf("action %s is missing indices %s", action.GUID, strings.Join(missing, ",")),
+			})
+		}
+		if values[protocol.IndexScenario] != "1" {
+			violations = append(violations, Violation{
+				Rule:   RuleCompleteBlock,
+				Detail: fmt.Sprintf("action %s does not set %d to \"1\"", action.GUID, protocol.IndexScenario),
+			})
+		}
+	}
+	return violations
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
+)
+
+// rawResponse builds a response with the mandatory framing headers
+func rawResponse(status, contentType, body string) []byte {
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 " + status + "\r\nConnection: close\r\n")
+	b.WriteString("Content-Length: " + strconv.Itoa(len(body)) + "\r\n")
+	if contentType != "" {
+		b.WriteString("Content-Type: " + contentType + "\r\n")
+	}
+	b.WriteString("\r\n" + body)
+	return []byte(b.String())
+}
+
+// actionsBody encodes a myactions response with a single action
+func actionsBody(t *testing.T, params []protocol.ExchangeKV) string {
+	t.Helper()
+	body, err := json.Marshal(protocol.ActionsResponse{
+		Actions: []protocol.Action{{GUID: "g1", Params: params}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode actions: %v", err)
+	}
+	return string(body)
+}
+
+// hasRule reports whether the violations include the rule
+func hasRule(violations []Violation, rule string) bool {
+	for _, v := range violations {
+		if v.Rule == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCheckResponse_Conforming(t *testing.T) {
+	params := []protocol.ExchangeKV{{K: protocol.IndexScenario, V: "1"}}
+	for k := protocol.IndexLightStart; k <= protocol.IndexLightEnd; k++ {
+		params = append(params, protocol.ExchangeKV{K: k, V: "0"})
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		raw    []byte
+	}{
+		{http.MethodGet, "/api/serverinfos", rawResponse("200 OK", legacyContentType, `{"isconnected":false}`)},
+		{http.MethodPost, "/api/mystatus", rawResponse("201 Created", legacyContentType, "")},
+		{http.MethodPost, "/api/done/g1", rawResponse("201 Created", legacyContentType, "")},
+		{http.MethodGet, "/api/myactions", rawResponse("200 OK", legacyContentType, actionsBody(t, params))},
+		{http.MethodGet, "/api/serverinfos", rawResponse("401 Unauthorized", "", "")},
+		{http.MethodGet, "/health", rawResponse("200 OK", "application/json", `{"status":"ok"}`)},
+	}
+
+	for _, tt := range tests {
+		if violations := CheckResponse(tt.method, tt.path, tt.raw); len(violations) != 0 {
+			t.Errorf("%s %s: expected no violations, got %v", tt.method, tt.path, violations)
+		}
+	}
+}
+
+func TestCheckResponse_Violations(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		raw    []byte
+		rule   string
+	}{
+		{"missing connection close", http.MethodGet, "/health",
+			[]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"), RuleConnection},
+		{"wrong content length", http.MethodGet, "/health",
+			[]byte("HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: 5\r\n\r\nab"), RuleContentLength},
+		{"missing content length", http.MethodGet, "/health",
+			[]byte("HTTP/1.1 200 OK\r\nConnection: close\r\n\r\n"), RuleContentLength},
+		{"standard content type", http.MethodGet, "/api/serverinfos",
+			rawResponse("200 OK", "application/json; charset=utf-8", `{}`), RuleContentType},
+		{"post answered 200", http.MethodPost, "/api/mystatus",
+			rawResponse("200 OK", legacyContentType, ""), RuleCreated},
+		{"actions before _de67f", http.MethodGet, "/api/myactions",
+			rawResponse("200 OK", legacyContentType, `{"actions":[],"_de67f":null}`), RuleDe67fFirst},
+		{"incomplete light block", http.MethodGet, "/api/myactions",
+			rawResponse("200 OK", legacyContentType, actionsBody(t, []protocol.ExchangeKV{{K: 590, V: "1"}, {K: 613, V: "64"}})), RuleCompleteBlock},
+	}
+
+	for _, tt := range tests {
+		violations := CheckResponse(tt.method, tt.path, tt.raw)
+		if !hasRule(violations, tt.rule) {
+			t.Errorf("%s: expected a %s violation, got %v", tt.name, tt.rule, violations)
+		}
+	}
+}
+
+// serve runs a single request through a legacyResponseWriter and returns what reached the wire
+func serve(t *testing.T, mode ConformanceMode, method, path string, handler http.HandlerFunc) (string, error) {
+	t.Helper()
+	client, server := net.Pipe()
+	defer client.Close()
+
+	w := &legacyResponseWriter{
+		conn:        server,
+		header:      make(http.Header),
+		method:      method,
+		path:        path,
+		conformance: mode,
+	}
+	handler(w, nil)
+
+	received := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		client.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _ := client.Read(buf)
+		received <- string(buf[:n])
+	}()
+
+	err := w.flush()
+	server.Close()
+	return <-received, err
+}
+
+func TestLegacyResponseWriter_StrictModeRejects(t *testing.T) {
+	badHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{}`))
+	}
+
+	response, err := serve(t, ConformanceStrict, http.MethodPost, "/api/mystatus", badHandler)
+	if _, ok := err.(*ConformanceError); !ok {
+		t.Errorf("Expected *ConformanceError in strict mode, got %v", err)
+	}
+	if !strings.HasPrefix(response, "HTTP/1.1 500 Internal Server Error\r\n") {
+		t.Errorf("Expected the violating response to be replaced by a 500, got %q", response)
+	}
+
+	response, err = serve(t, ConformanceWarn, http.MethodPost, "/api/mystatus", badHandler)
+	if err != nil {
+		t.Errorf("Expected no error in warn mode, got %v", err)
+	}
+	if !strings.HasPrefix(response, "HTTP/1.1 200 OK\r\n") {
+		t.Errorf("Expected the response to be sent in warn mode, got %q", response)
+	}
+}
+
+func TestParseConformanceMode(t *testing.T) {
+	for input, expected := range map[string]ConformanceMode{"": ConformanceWarn, "STRICT": ConformanceStrict, "off": ConformanceOff} {
+		mode, err := ParseConformanceMode(input)
+		if err != nil || mode != expected {
+			t.Errorf("ParseConformanceMode(%q) = %v, %v; expected %v", input, mode, err, expected)
+		}
+	}
+	if _, err := ParseConformanceMode("loud"); err == nil {
+		t.Error("Expected error for unknown mode, got nil")
+	}
+}
@@ -0,0 +1,281 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/api"
+	"github.com/essensys-hub/essensys-server-backend/internal/core"
+	"github.com/essensys-hub/essensys-server-backend/internal/data"
+	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
+)
+
+// The files in testdata/golden are recorded from the reference server (docs/server.sample.go)
+// by testdata/golden/record.sh. Only testdata/golden/server holds responses the reference cannot
+// produce; run `go test ./internal/server -run TestGolden -update` to rewrite those after an intended change
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata/golden/server")
+
+// goldenMatch is how a response is compared with its golden file
+type goldenMatch int
+
+const (
+	// matchReference compares byte for byte with the reference recording
+	matchReference goldenMatch = iota
+	// matchReferenceStatus compares only the status line with the reference recording;
+	// the case documents why the rest differs
+	matchReferenceStatus
+	// matchServer compares byte for byte with testdata/golden/server, recorded from this server
+	// because the reference cannot produce the response
+	matchServer
+)
+
+// goldenAuthorization holds the Basic Auth credentials of the authenticated cases (box-1:secret)
+const goldenAuthorization = "Authorization: Basic Ym94LTE6c2VjcmV0\r\n"
+
+// startGoldenServer runs the full router behind a LegacyHTTPServer on a real TCP socket
+// Conformance is strict, so a response breaking the firmware rules also fails the golden comparison
+func startGoldenServer(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server := NewLegacyHTTPServer(handler)
+	server.Conformance = ConformanceStrict
+	go server.Serve(listener)
+	return listener.Addr().String()
+}
+
+// newGoldenRouter builds the production router on a fresh store
+func newGoldenRouter(authEnabled bool) (http.Handler, *data.MemoryStore) {
+	store := data.NewMemoryStore()
+	handler := api.NewHandler(core.NewActionService(store), core.NewStatusService(store), store)
+	return api.NewRouter(handler, map[string]string{"box-1": "secret"}, authEnabled), store
+}
+
+// roundTrip sends raw request bytes and returns everything received until the server closes
+func roundTrip(t *testing.T, addr, request string) []byte {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := conn.Write([]byte(request)); err != nil {
+		t.Fatalf("Failed to write request: %v", err)
+	}
+	var response bytes.Buffer
+	if _, err := response.ReadFrom(conn); err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	return response.Bytes()
+}
+
+// firmwarePost builds a POST the way the firmware sends it, trailing space included
+func firmwarePost(path, body string) string {
+	return "POST " + path + " HTTP/1.1 \r\nHost: 192.168.0.10\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body
+}
+
+// checkGolden compares a response with the golden file <name>.golden as selected by match
+func checkGolden(t *testing.T, name string, match goldenMatch, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name+".golden")
+	if match == matchServer {
+		path = filepath.Join("testdata", "golden", "server", name+".golden")
+	}
+
+	if *updateGolden && match == matchServer {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create golden directory: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("Failed to write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		if match == matchServer {
+			t.Fatalf("Failed to read golden file (run with -update to create it): %v", err)
+		}
+		t.Fatalf("Failed to read golden file (run testdata/golden/record.sh to record it): %v", err)
+	}
+	if match == matchReferenceStatus {
+		got, want = firstLine(got), firstLine(want)
+	}
+	if bytes.Equal(got, want) {
+		return
+	}
+
+	// Point at the first differing line: status line, a header or the body
+	gotLines := bytes.SplitAfter(got, []byte("\r\n"))
+	wantLines := bytes.SplitAfter(want, []byte("\r\n"))
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var g, w []byte
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if !bytes.Equal(g, w) {
+			t.Errorf("%s: response differs from golden at line %d\n got: %q\nwant: %q\nfull response: %q", name, i+1, g, w, got)
+			return
+		}
+	}
+}
+
+// firstLine returns the status line of a response
+func firstLine(response []byte) []byte {
+	if i := bytes.Index(response, []byte("\r\n")); i >= 0 {
+		return response[:i]
+	}
+	return response
+}
+
+func TestGolden_BoxEndpoints(t *testing.T) {
+	router, store := newGoldenRouter(false)
+	addr := startGoldenServer(t, router)
+
+	// Two queued actions with fixed GUIDs: a complete light block and a plain parameter
+	lightParams := []protocol.ExchangeKV{{K: protocol.IndexScenario, V: "1"}}
+	for k := protocol.IndexLightStart; k <= protocol.IndexLightEnd; k++ {
+		v := "0"
+		if k == 613 {
+			v = "64"
+		}
+		lightParams = append(lightParams, protocol.ExchangeKV{K: k, V: v})
+	}
+
+	tests := []struct {
+		name    string
+		match   goldenMatch
+		setup   func()
+		request string
+	}{
+		{
+			name: "serverinfos",
+			// The firmware sends a trailing space after the HTTP version
+			request: "GET /api/serverinfos HTTP/1.1 \r\nHost: 192.168.0.10\r\n\r\n",
+		},
+		{
+			name:    "serverinfos_http10",
+			request: "GET /api/serverinfos HTTP/1.0\r\n\r\n",
+		},
+		{
+			// The firmware quotes version and ek but not k and v
+			name:    "mystatus_unquoted_keys",
+			request: firmwarePost("/api/mystatus", `{"version":"V125","ek":[{k:613,v:"64"},{k:1,v:"0"}]}`),
+		},
+		{
+			// Error bodies: the reference answers an empty JSON-typed body, this server the
+			// http.Error text so that operators can tell failures apart; the firmware ignores both
+			name:    "mystatus_malformed_json",
+			match:   matchReferenceStatus,
+			request: firmwarePost("/api/mystatus", `{version:V125`),
+		},
+		{
+			name:    "myactions_empty",
+			request: "GET /api/myactions HTTP/1.1 \r\nHost: 192.168.0.10\r\n\r\n",
+		},
+		{
+			// The reference cannot queue actions with fixed GUIDs
+			name:  "myactions_several",
+			match: matchServer,
+			setup: func() {
+				store.EnqueueAction("default", protocol.Action{GUID: "11111111-1111-1111-1111-111111111111", Params: lightParams})
+				store.EnqueueAction("default", protocol.Action{GUID: "22222222-2222-2222-2222-222222222222", Params: []protocol.ExchangeKV{{K: 920, V: "1"}}})
+			},
+			request: "GET /api/myactions HTTP/1.1 \r\nHost: 192.168.0.10\r\n\r\n",
+		},
+		{
+			name:    "done_known",
+			request: "POST /api/done/11111111-1111-1111-1111-111111111111 HTTP/1.1 \r\nHost: 192.168.0.10\r\nContent-Length: 0\r\n\r\n",
+		},
+		{
+			// Error body differs as for mystatus_malformed_json
+			name:    "done_unknown",
+			match:   matchReferenceStatus,
+			request: "POST /api/done/11111111-1111-1111-1111-111111111111 HTTP/1.1 \r\nHost: 192.168.0.10\r\nContent-Length: 0\r\n\r\n",
+		},
+		{
+			// Error body differs as for mystatus_malformed_json
+			name:    "unknown_path",
+			match:   matchReferenceStatus,
+			request: "GET /api/unknown HTTP/1.1\r\nHost: x\r\n\r\n",
+		},
+		{
+			// The reference closes the connection without answering; this server answers 400
+			name:    "malformed_request_line",
+			match:   matchServer,
+			request: "\x00\x01garbage\r\n\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup()
+			}
+			checkGolden(t, tt.name, tt.match, roundTrip(t, addr, tt.request))
+		})
+	}
+}
+
+func TestGolden_Authentication(t *testing.T) {
+	router, _ := newGoldenRouter(true)
+	addr := startGoldenServer(t, router)
+
+	// The reference has no authentication: refusals are this server's own, and an
+	// authenticated request must be answered exactly as the reference answers serverinfos
+	tests := []struct {
+		name    string
+		golden  string
+		match   goldenMatch
+		request string
+	}{
+		{"auth_missing", "auth_missing", matchServer, "GET /api/serverinfos HTTP/1.1 \r\nHost: x\r\n\r\n"},
+		{"auth_wrong_password", "auth_wrong_password", matchServer, "GET /api/serverinfos HTTP/1.1 \r\nHost: x\r\nAuthorization: Basic Ym94LTE6d3Jvbmc=\r\n\r\n"},
+		{"auth_valid", "serverinfos", matchReference, "GET /api/serverinfos HTTP/1.1 \r\nHost: x\r\n" + goldenAuthorization + "\r\n"},
+		// The firmware repeats its credentials alone on the line after Authorization
+		{"auth_valid_matricule_line", "serverinfos", matchReference, "GET /api/serverinfos HTTP/1.1 \r\nhost: x\r\n" + goldenAuthorization + "Ym94LTE6c2VjcmV0\r\n\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkGolden(t, tt.golden, tt.match, roundTrip(t, addr, tt.request))
+		})
+	}
+}
+
+// The server does not emit alarm commands yet and the reference always sends a null _de67f;
+// this case pins the wire format of a myactions response carrying _de67f so the encoding
+// cannot drift before it does
+func TestGolden_AlarmCommand(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := protocol.ActionsResponse{
+			De67f:   &protocol.AlarmCommand{GUID: "33333333-3333-3333-3333-333333333333", OBL: "0123456789ABCDEF0123456789ABCDEF"},
+			Actions: []protocol.Action{},
+		}
+		body, _ := json.Marshal(response)
+		w.Header().Set("Content-Type", "application/json ;charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	})
+	addr := startGoldenServer(t, handler)
+
+	checkGolden(t, "myactions_alarm", matchServer, roundTrip(t, addr, "GET /api/myactions HTTP/1.1 \r\nHost: 192.168.0.10\r\n\r\n"))
+}
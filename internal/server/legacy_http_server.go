@@ -3,32 +3,129 @@ package server
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
-	"strings"
+	"sort"
+	"sync"
 	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/logging"
+	"github.com/essensys-hub/essensys-server-backend/internal/metrics"
+)
+
+// Default deadlines applied when the server is created without explicit timeouts
+const (
+	DefaultReadTimeout  = 10 * time.Second
+	DefaultWriteTimeout = 10 * time.Second
 )
 
 // LegacyHTTPServer handles HTTP requests from legacy clients that don't follow HTTP standards strictly
 type LegacyHTTPServer struct {
 	handler http.Handler
+
+	// ReadTimeout is the maximum duration for reading the entire request
+	ReadTimeout time.Duration
+	// WriteTimeout is the maximum duration for writing the response
+	WriteTimeout time.Duration
+	// Conformance selects how responses breaking the firmware protocol rules are handled
+	Conformance ConformanceMode
+	// Limits bounds connections, request rates and request sizes; read when Serve is first called
+	Limits Limits
+	// ShutdownHookTimeout is the time each shutdown hook gets, whatever is left of the drain deadline
+	ShutdownHookTimeout time.Duration
+
+	// Built once by the first Serve call and shared by every listener
+	limitsOnce     sync.Once
+	connSlots      chan struct{}
+	connLimiter    *ipRateLimiter
+	requestLimiter *ipRateLimiter
+
+	// Shutdown state, guarded by mu
+	mu         sync.Mutex
+	inShutdown bool
+	listeners  map[net.Listener]struct{}
+	conns      map[net.Conn]struct{}
+	drains     []shutdownHook
+	hooks      []shutdownHook
 }
 
 // NewLegacyHTTPServer creates a new legacy-compatible HTTP server
 func NewLegacyHTTPServer(handler http.Handler) *LegacyHTTPServer {
-	return &LegacyHTTPServer{handler: handler}
+	return &LegacyHTTPServer{
+		handler:      handler,
+		ReadTimeout:  DefaultReadTimeout,
+		WriteTimeout: DefaultWriteTimeout,
+		Conformance:  ConformanceWarn,
+		Limits:       DefaultLimits(),
+
+		ShutdownHookTimeout: DefaultShutdownHookTimeout,
+	}
 }
 
 // Serve accepts incoming connections and handles them
+// Connections over the concurrency limit or the per-IP connection rate are closed at once
+// After Shutdown, Serve returns ErrServerClosed
+// Serve may be called for several listeners: they share the connection slots and rate limiters
 func (s *LegacyHTTPServer) Serve(l net.Listener) error {
+	if !s.trackListener(l) {
+		return ErrServerClosed
+	}
+
+	s.limitsOnce.Do(func() {
+		if s.Limits.MaxConnections > 0 {
+			s.connSlots = make(chan struct{}, s.Limits.MaxConnections)
+		}
+		s.connLimiter = newIPRateLimiter(s.Limits.ConnRatePerIP, s.Limits.ConnBurstPerIP)
+		s.requestLimiter = newIPRateLimiter(s.Limits.RequestRatePerIP, s.Limits.RequestBurstPerIP)
+	})
+	slots := s.connSlots
+
 	for {
 		conn, err := l.Accept()
 		if err != nil {
+			if s.shuttingDown() {
+				return ErrServerClosed
+			}
 			return err
 		}
-		go s.handleConnection(conn)
+
+		if !s.connLimiter.allow(remoteIP(conn.RemoteAddr())) {
+			metrics.LegacyRejections.Inc(RejectConnRate)
+			logging.Debugf("Connection rate exceeded by %s, closing", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+			default:
+				metrics.LegacyRejections.Inc(RejectMaxConnections)
+				logging.Debugf("%d connections open, closing connection from %s", s.Limits.MaxConnections, conn.RemoteAddr())
+				conn.Close()
+				continue
+			}
+		}
+
+		if !s.trackConn(conn) {
+			// Accepted while Shutdown was closing the listener
+			conn.Close()
+			if slots != nil {
+				<-slots
+			}
+			return ErrServerClosed
+		}
+
+		go func() {
+			defer s.untrackConn(conn)
+			// Release the slot taken from slots, the channel this connection acquired from
+			if slots != nil {
+				defer func() { <-slots }()
+			}
+			s.handleConnection(conn)
+		}()
 	}
 }
 
@@ -36,79 +133,71 @@ func (s *LegacyHTTPServer) Serve(l net.Listener) error {
 func (s *LegacyHTTPServer) handleConnection(conn net.Conn) {
 	defer conn.Close()
 	
-	// Set read deadline
-	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
-	
-	// Read the entire request
-	reader := bufio.NewReader(conn)
-	
-	// Read request line
-	requestLine, err := reader.ReadString('\n')
-	if err != nil {
-		// Silently ignore connection errors (client disconnected, etc.)
-		return
-	}
-	
-	// FIX: Remove trailing spaces before \r\n
-	// The BP_MQX_ETH client sends "GET /path HTTP/1.1 \r\n" with an extra space
-	requestLine = strings.TrimRight(requestLine, " \r\n") + "\r\n"
-	
-	// Debug logging disabled by default
-	// log.Printf("[DEBUG] Cleaned request line: %q", requestLine)
-	
-	// Read headers
-	var headerLines []string
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			break
-		}
-		if line == "\r\n" || line == "\n" {
-			break // End of headers
-		}
-		headerLines = append(headerLines, line)
+	// Set read deadline for the whole request (request line, headers and body)
+	// A shorter deadline covers the headers so a client trickling them in cannot hold the connection
+	start := time.Now()
+	limits := s.Limits.requestLimits()
+	if s.ReadTimeout > 0 {
+		conn.SetReadDeadline(start.Add(s.ReadTimeout))
 	}
-	
-	// Read body if Content-Length is present
-	var body []byte
-	for _, line := range headerLines {
-		if strings.HasPrefix(strings.ToLower(line), "content-length:") {
-			var cl int
-			fmt.Sscanf(line, "Content-Length: %d", &cl)
-			if cl > 0 {
-				body = make([]byte, cl)
-				io.ReadFull(reader, body)
+	if s.Limits.HeaderTimeout > 0 && (s.ReadTimeout <= 0 || s.Limits.HeaderTimeout < s.ReadTimeout) {
+		conn.SetReadDeadline(start.Add(s.Limits.HeaderTimeout))
+		limits.afterHeaders = func() {
+			if s.ReadTimeout > 0 {
+				conn.SetReadDeadline(start.Add(s.ReadTimeout))
+			} else {
+				conn.SetReadDeadline(time.Time{})
 			}
-			break
 		}
 	}
 	
-	// Reconstruct the HTTP request with cleaned request line
-	var buf bytes.Buffer
-	buf.WriteString(requestLine)
-	for _, line := range headerLines {
-		buf.WriteString(line)
-	}
-	buf.WriteString("\r\n")
-	if len(body) > 0 {
-		buf.Write(body)
-	}
-	
-	// Parse the cleaned request
-	req, err := http.ReadRequest(bufio.NewReader(&buf))
+	// Read and parse the request
+	req, err := readLegacyRequest(bufio.NewReader(conn), limits)
 	if err != nil {
-		// Silently send error response
-		conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+		var reqErr *requestError
+		var limitErr *limitError
+		var netErr net.Error
+		switch {
+		case err == io.EOF:
+			// Client disconnected without sending anything
+			logging.Debugf("Connection from %s closed before a request was sent", conn.RemoteAddr())
+		case errors.As(err, &netErr) && netErr.Timeout():
+			metrics.LegacyRejections.Inc(RejectSlowRequest)
+			logging.Warnf("Request from %s not received in time: %v", conn.RemoteAddr(), err)
+		case errors.As(err, &limitErr):
+			metrics.LegacyRejections.Inc(limitErr.reason)
+			logging.Warnf("Rejected request from %s: %v", conn.RemoteAddr(), err)
+			s.reject(conn, limitErr.status)
+		case errors.As(err, &reqErr) && reqErr.reason == reasonRequestLine:
+			metrics.LegacyParseErrors.Inc(reasonRequestLine)
+			logging.Debugf("Error reading request line from %s: %v", conn.RemoteAddr(), err)
+		default:
+			metrics.LegacyParseErrors.Inc(reasonRequest)
+			logging.Warnf("Failed to parse request from %s: %v", conn.RemoteAddr(), err)
+			s.reject(conn, http.StatusBadRequest)
+		}
 		return
 	}
 	
+	logging.Debugf("Parsed request line: %s %s %s", req.Method, req.RequestURI, req.Proto)
+
+	if !s.requestLimiter.allow(remoteIP(conn.RemoteAddr())) {
+		metrics.LegacyRejections.Inc(RejectRequestRate)
+		logging.Warnf("Request rate exceeded by %s: %s %s", conn.RemoteAddr(), req.Method, req.RequestURI)
+		s.reject(conn, http.StatusTooManyRequests)
+		return
+	}
+
 	// Set RemoteAddr for logging
 	req.RemoteAddr = conn.RemoteAddr().String()
 	
 	// Create a response writer that writes to the connection
 	w := &legacyResponseWriter{
-		conn:   conn,
-		header: make(http.Header),
+		conn:        conn,
+		header:      make(http.Header),
+		method:      req.Method,
+		path:        req.URL.Path,
+		conformance: s.Conformance,
 	}
 	
 	// Call the handler
@@ -116,7 +205,23 @@ func (s *LegacyHTTPServer) handleConnection(conn net.Conn) {
 	
 	// CRITICAL: Flush the buffered response to the connection
 	// This writes headers (with Content-Length) and body
-	w.flush()
+	s.setWriteDeadline(conn)
+	if err := w.flush(); err != nil {
+		logging.Warnf("Failed to write response to %s: %v", conn.RemoteAddr(), err)
+	}
+}
+
+// reject sends an empty response with the given status before the connection is closed
+func (s *LegacyHTTPServer) reject(conn net.Conn, status int) {
+	conn.SetWriteDeadline(time.Now().Add(rejectWriteTimeout))
+	conn.Write(rejectResponse(status))
+}
+
+// setWriteDeadline applies the configured write timeout to the connection
+func (s *LegacyHTTPServer) setWriteDeadline(conn net.Conn) {
+	if s.WriteTimeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
+	}
 }
 
 // legacyResponseWriter implements http.ResponseWriter for raw connections
@@ -127,6 +232,11 @@ type legacyResponseWriter struct {
 	statusCode    int
 	headerWritten bool
 	bodyBuffer    bytes.Buffer
+
+	// Request line, used by the conformance checks
+	method      string
+	path        string
+	conformance ConformanceMode
 }
 
 func (w *legacyResponseWriter) Header() http.Header {
@@ -169,15 +279,28 @@ func (w *legacyResponseWriter) flush() error {
 	statusText := http.StatusText(w.statusCode)
 	fmt.Fprintf(&response, "HTTP/1.1 %d %s\r\n", w.statusCode, statusText)
 	
+	// Content-Type, Connection and Content-Length come first, in the order of the reference
+	// server (docs/server.sample.go) the firmware was validated against
+	for _, value := range w.header["Content-Type"] {
+		fmt.Fprintf(&response, "Content-Type: %s\r\n", value)
+	}
+
 	// CRITICAL: Add Connection: close header for legacy BP_MQX_ETH clients
 	fmt.Fprintf(&response, "Connection: close\r\n")
 	
 	// CRITICAL: Add Content-Length header (required by BP_MQX_ETH client)
 	fmt.Fprintf(&response, "Content-Length: %d\r\n", w.bodyBuffer.Len())
 	
-	// Write other headers
-	for key, values := range w.header {
-		for _, value := range values {
+	// Write other headers in a stable order so responses are byte-for-byte reproducible
+	keys := make([]string, 0, len(w.header))
+	for key := range w.header {
+		if key != "Content-Type" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range w.header[key] {
 			fmt.Fprintf(&response, "%s: %s\r\n", key, value)
 		}
 	}
@@ -187,9 +310,36 @@ func (w *legacyResponseWriter) flush() error {
 	
 	// Append body
 	response.Write(w.bodyBuffer.Bytes())
+
+	raw := response.Bytes()
+	var conformanceErr error
+	if w.conformance != ConformanceOff {
+		if violations := CheckResponse(w.method, w.path, raw); len(violations) > 0 {
+			w.reportViolations(violations)
+			if w.conformance == ConformanceStrict {
+				raw = []byte(strictFailResponse)
+				conformanceErr = &ConformanceError{Method: w.method, Path: w.path, Violations: violations}
+			}
+		}
+	}
 	
 	// CRITICAL: Send everything in a SINGLE write() call
 	// This ensures the BP_MQX_ETH client receives the entire response at once
-	_, err := w.conn.Write(response.Bytes())
-	return err
+	n, err := w.conn.Write(raw)
+	if err != nil && n > 0 && w.conformance != ConformanceOff {
+		// The firmware only saw part of the response
+		w.reportViolations([]Violation{{Rule: RuleSingleWrite, Detail: fmt.Sprintf("only %d of %d bytes written", n, len(raw))}})
+	}
+	if err != nil {
+		return err
+	}
+	return conformanceErr
+}
+
+// reportViolations records and logs protocol violations
+func (w *legacyResponseWriter) reportViolations(violations []Violation) {
+	for _, v := range violations {
+		metrics.ConformanceViolations.Inc(v.Rule)
+	}
+	logging.Warnf("[CONFORMANCE] %s %s to %s: %s", w.method, w.path, w.conn.RemoteAddr(), formatViolations(violations))
 }
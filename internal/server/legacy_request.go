@@ -0,0 +1,269 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/middleware"
+)
+
+// Parse failure reasons, used as the "reason" label of the parse error metric
+const (
+	reasonRequestLine = "request_line"
+	reasonRequest     = "request"
+)
+
+// requestError is a request that could not be read or parsed
+type requestError struct {
+	reason string
+	err    error
+}
+
+func (e *requestError) Error() string {
+	return e.reason + ": " + e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// requestLimits bounds what readLegacyRequest accepts
+type requestLimits struct {
+	maxHeaderBytes int
+	maxHeaders     int
+	maxBodyBytes   int
+	// afterHeaders, when set, is called once the headers are read (before the body)
+	afterHeaders func()
+}
+
+// requestLimits returns the parser limits of l
+func (l Limits) requestLimits() requestLimits {
+	return requestLimits{
+		maxHeaderBytes: l.MaxHeaderBytes,
+		maxHeaders:     l.MaxHeaders,
+		maxBodyBytes:   l.MaxBodyBytes,
+	}
+}
+
+// readLegacyRequest reads one request from a legacy client and parses it with net/http
+// The request line and headers are cleaned up (the firmware adds a trailing space and a bare
+// matricule line) and the body is read from Content-Length or chunked Transfer-Encoding before
+// the request is rebuilt with a single Content-Length and handed to http.ReadRequest
+// The matricule line, when present, is available to handlers through middleware.GetMatricule
+// It returns io.EOF unchanged when the client closed the connection without sending anything,
+// and a *limitError when the request exceeds limits
+func readLegacyRequest(reader *bufio.Reader, limits requestLimits) (*http.Request, error) {
+	headerBudget := limits.maxHeaderBytes
+	if headerBudget <= 0 {
+		headerBudget = math.MaxInt
+	}
+	headerTooLarge := func() error {
+		return &limitError{
+			reason: RejectHeaderTooLarge,
+			status: http.StatusRequestHeaderFieldsTooLarge,
+			err:    fmt.Errorf("request line and headers exceed %d bytes", limits.maxHeaderBytes),
+		}
+	}
+
+	// Read request line
+	requestLine, err := readLine(reader, &headerBudget)
+	if err != nil {
+		if err == io.EOF && requestLine == "" {
+			return nil, io.EOF
+		}
+		if err == errHeaderTooLarge {
+			return nil, headerTooLarge()
+		}
+		return nil, &requestError{reason: reasonRequestLine, err: err}
+	}
+
+	// FIX: Remove trailing spaces before \r\n
+	// The BP_MQX_ETH client sends "GET /path HTTP/1.1 \r\n" with an extra space
+	requestLine = strings.TrimRight(requestLine, " \r\n") + "\r\n"
+
+	// Read headers, unfolding continuation lines and picking out the firmware's matricule line
+	// Line endings are normalized, so bare LF is accepted like CRLF
+	var headers []string
+	var matricule *middleware.Matricule
+	lines := 0
+	for {
+		line, err := readLine(reader, &headerBudget)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if err == errHeaderTooLarge {
+				return nil, headerTooLarge()
+			}
+			return nil, &requestError{reason: reasonRequest, err: fmt.Errorf("reading headers: %w", err)}
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break // End of headers
+		}
+		if lines++; limits.maxHeaders > 0 && lines > limits.maxHeaders {
+			return nil, &limitError{
+				reason: RejectTooManyHeaders,
+				status: http.StatusRequestHeaderFieldsTooLarge,
+				err:    fmt.Errorf("more than %d header lines", limits.maxHeaders),
+			}
+		}
+
+		switch {
+		case (line[0] == ' ' || line[0] == '\t') && len(headers) > 0:
+			// Obsolete line folding: the line continues the previous header
+			headers[len(headers)-1] += " " + strings.TrimSpace(line)
+		case matricule == nil && !strings.Contains(line, ":") && len(headers) > 0 && headerName(headers[len(headers)-1]) == "authorization":
+			// The firmware sends its matricule alone on the line after Authorization
+			m := middleware.ParseMatricule(line)
+			matricule = &m
+		default:
+			headers = append(headers, line)
+		}
+	}
+
+	if limits.afterHeaders != nil {
+		limits.afterHeaders()
+	}
+
+	// Pull out the framing headers; the body is re-framed with a single Content-Length
+	var contentLengths []string
+	var transferEncoding []string
+	kept := make([]string, 0, len(headers))
+	for _, header := range headers {
+		switch headerName(header) {
+		case "content-length":
+			contentLengths = append(contentLengths, headerValue(header))
+		case "transfer-encoding":
+			transferEncoding = append(transferEncoding, headerValue(header))
+		default:
+			kept = append(kept, header)
+		}
+	}
+
+	body, err := readBody(reader, transferEncoding, contentLengths, limits.maxBodyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reconstruct the HTTP request with cleaned request line and headers
+	var buf bytes.Buffer
+	buf.WriteString(requestLine)
+	for _, header := range kept {
+		buf.WriteString(header)
+		buf.WriteString("\r\n")
+	}
+	if len(contentLengths) > 0 || len(transferEncoding) > 0 {
+		fmt.Fprintf(&buf, "Content-Length: %d\r\n", len(body))
+	}
+	buf.WriteString("\r\n")
+	buf.Write(body)
+
+	// Parse the cleaned request
+	req, err := http.ReadRequest(bufio.NewReader(&buf))
+	if err != nil {
+		return nil, &requestError{reason: reasonRequest, err: err}
+	}
+	if matricule != nil {
+		req = req.WithContext(middleware.WithMatricule(req.Context(), *matricule))
+	}
+	return req, nil
+}
+
+// readBody reads the body framed by Transfer-Encoding or Content-Length
+// Transfer-Encoding takes precedence, as in RFC 7230 section 3.3.3; only chunked is supported
+func readBody(reader *bufio.Reader, transferEncoding, contentLengths []string, maxBodyBytes int) ([]byte, error) {
+	bodyTooLarge := func(format string, args ...interface{}) error {
+		return &limitError{
+			reason: RejectBodyTooLarge,
+			status: http.StatusRequestEntityTooLarge,
+			err:    fmt.Errorf(format, args...),
+		}
+	}
+
+	if len(transferEncoding) > 0 {
+		codings := strings.Join(transferEncoding, ",")
+		if !strings.EqualFold(strings.TrimSpace(codings), "chunked") {
+			return nil, &requestError{reason: reasonRequest, err: fmt.Errorf("unsupported Transfer-Encoding %q", codings)}
+		}
+
+		var chunks io.Reader = httputil.NewChunkedReader(reader)
+		if maxBodyBytes > 0 {
+			chunks = io.LimitReader(chunks, int64(maxBodyBytes)+1)
+		}
+		body, err := io.ReadAll(chunks)
+		if err != nil {
+			return nil, &requestError{reason: reasonRequest, err: fmt.Errorf("reading chunked body: %w", err)}
+		}
+		if maxBodyBytes > 0 && len(body) > maxBodyBytes {
+			return nil, bodyTooLarge("chunked body exceeds %d bytes", maxBodyBytes)
+		}
+		return body, nil
+	}
+
+	if len(contentLengths) == 0 {
+		return nil, nil
+	}
+	cl, err := strconv.Atoi(contentLengths[0])
+	if err != nil || cl < 0 {
+		return nil, &requestError{reason: reasonRequest, err: fmt.Errorf("invalid Content-Length %q", contentLengths[0])}
+	}
+	for _, other := range contentLengths[1:] {
+		if other != contentLengths[0] {
+			return nil, &requestError{reason: reasonRequest, err: fmt.Errorf("conflicting Content-Length %q and %q", contentLengths[0], other)}
+		}
+	}
+	if maxBodyBytes > 0 && cl > maxBodyBytes {
+		return nil, bodyTooLarge("Content-Length %d exceeds %d bytes", cl, maxBodyBytes)
+	}
+	if cl == 0 {
+		return nil, nil
+	}
+
+	body := make([]byte, cl)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, &requestError{reason: reasonRequest, err: fmt.Errorf("reading %d-byte body: %w", cl, err)}
+	}
+	return body, nil
+}
+
+// headerName returns the lower-cased name of a header line
+func headerName(header string) string {
+	name, _, _ := strings.Cut(header, ":")
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// headerValue returns the trimmed value of a header line
+func headerValue(header string) string {
+	_, value, _ := strings.Cut(header, ":")
+	return strings.TrimSpace(value)
+}
+
+// errHeaderTooLarge is returned by readLine when a line overruns the header budget
+var errHeaderTooLarge = errors.New("request line and headers too large")
+
+// readLine reads a line including its '\n', charging its length to budget
+// A line that would overrun the budget is refused before it is buffered in full
+func readLine(reader *bufio.Reader, budget *int) (string, error) {
+	var line []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(line)+len(chunk) > *budget {
+			return "", errHeaderTooLarge
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		*budget -= len(line)
+		return string(line), err
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Default resource limits applied when the server is created without explicit limits
+// A box polls a few times per second with requests of a few hundred bytes, so these
+// leave plenty of room for real traffic while bounding what a hostile client can take
+const (
+	DefaultMaxConnections = 1024
+	DefaultRatePerIP      = 20
+	DefaultBurstPerIP     = 40
+	DefaultMaxHeaderBytes = 8 << 10
+	DefaultMaxHeaders     = 64
+	DefaultMaxBodyBytes   = 1 << 20
+	DefaultHeaderTimeout  = 5 * time.Second
+	rejectWriteTimeout    = time.Second
+)
+
+// Rejection reasons, used as the "reason" label of the rejection metric
+const (
+	RejectMaxConnections = "max_connections"
+	RejectConnRate       = "connection_rate"
+	RejectRequestRate    = "request_rate"
+	RejectHeaderTooLarge = "header_too_large"
+	RejectTooManyHeaders = "too_many_headers"
+	RejectBodyTooLarge   = "body_too_large"
+	RejectSlowRequest    = "slow_request"
+)
+
+// Limits bounds the resources a single client or the whole server can use
+// A zero value disables that limit
+type Limits struct {
+	// MaxConnections is the maximum number of connections handled at once
+	MaxConnections int
+	// ConnRatePerIP and ConnBurstPerIP bound new connections per second from one IP
+	ConnRatePerIP  float64
+	ConnBurstPerIP int
+	// RequestRatePerIP and RequestBurstPerIP bound parsed requests per second from one IP
+	RequestRatePerIP  float64
+	RequestBurstPerIP int
+	// MaxHeaderBytes bounds the request line and headers together
+	MaxHeaderBytes int
+	// MaxHeaders bounds the number of header lines
+	MaxHeaders int
+	// MaxBodyBytes bounds the body, whether announced by Content-Length or sent chunked;
+	// a chunked body is refused as soon as it grows past the limit
+	MaxBodyBytes int
+	// HeaderTimeout is the time allowed to send the request line and headers
+	HeaderTimeout time.Duration
+}
+
+// DefaultLimits returns the limits used by NewLegacyHTTPServer
+func DefaultLimits() Limits {
+	return Limits{
+		MaxConnections:    DefaultMaxConnections,
+		ConnRatePerIP:     DefaultRatePerIP,
+		ConnBurstPerIP:    DefaultBurstPerIP,
+		RequestRatePerIP:  DefaultRatePerIP,
+		RequestBurstPerIP: DefaultBurstPerIP,
+		MaxHeaderBytes:    DefaultMaxHeaderBytes,
+		MaxHeaders:        DefaultMaxHeaders,
+		MaxBodyBytes:      DefaultMaxBodyBytes,
+		HeaderTimeout:     DefaultHeaderTimeout,
+	}
+}
+
+// limitError is a request refused because it exceeds one of the Limits
+type limitError struct {
+	reason string
+	status int
+	err    error
+}
+
+func (e *limitError) Error() string {
+	return e.reason + ": " + e.err.Error()
+}
+
+// rejectResponse is the complete response sent when a request is refused
+func rejectResponse(status int) []byte {
+	return []byte(fmt.Sprintf("HTTP/1.1 %d %s\r\nConnection: close\r\nContent-Length: 0\r\n\r\n", status, http.StatusText(status)))
+}
+
+// remoteIP returns the IP part of a remote address
+func remoteIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
@@ -0,0 +1,279 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/metrics"
+)
+
+// startLimitedServer runs a LegacyHTTPServer answering 200 with the given limits
+func startLimitedServer(t *testing.T, limits Limits) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server := NewLegacyHTTPServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.Limits = limits
+	go server.Serve(listener)
+	return listener.Addr().String()
+}
+
+// closedWithoutResponse reports whether the server closed the connection without answering
+// A reset counts as closed: the server never read the request it refused
+func closedWithoutResponse(t *testing.T, addr, request string) bool {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	conn.Write([]byte(request))
+	n, _ := conn.Read(make([]byte, 64))
+	return n == 0
+}
+
+// statusOf returns the status line of a raw response, or "" when the server closed without answering
+func statusOf(response []byte) string {
+	line, _, _ := strings.Cut(string(response), "\r\n")
+	return line
+}
+
+func TestLimits_RequestSize(t *testing.T) {
+	limits := DefaultLimits()
+	limits.MaxHeaderBytes = 256
+	limits.MaxHeaders = 4
+	limits.MaxBodyBytes = 16
+	addr := startLimitedServer(t, limits)
+
+	tests := []struct {
+		name    string
+		request string
+		status  string
+		reason  string
+	}{
+		{
+			name:    "within limits",
+			request: "POST /api/mystatus HTTP/1.1 \r\nHost: x\r\nContent-Length: 2\r\n\r\n{}",
+			status:  "HTTP/1.1 200 OK",
+		},
+		{
+			name:    "header too large",
+			request: "GET /api/serverinfos HTTP/1.1\r\nX-Padding: " + strings.Repeat("a", 300) + "\r\n\r\n",
+			status:  "HTTP/1.1 431 Request Header Fields Too Large",
+			reason:  RejectHeaderTooLarge,
+		},
+		{
+			name:    "request line too large",
+			request: "GET /" + strings.Repeat("a", 300) + " HTTP/1.1\r\n\r\n",
+			status:  "HTTP/1.1 431 Request Header Fields Too Large",
+			reason:  RejectHeaderTooLarge,
+		},
+		{
+			name:    "too many headers",
+			request: "GET /api/serverinfos HTTP/1.1\r\nA: 1\r\nB: 2\r\nC: 3\r\nD: 4\r\nE: 5\r\n\r\n",
+			status:  "HTTP/1.1 431 Request Header Fields Too Large",
+			reason:  RejectTooManyHeaders,
+		},
+		{
+			name:    "body too large",
+			request: "POST /api/mystatus HTTP/1.1\r\nContent-Length: 17\r\n\r\n" + strings.Repeat("a", 17),
+			status:  "HTTP/1.1 413 Request Entity Too Large",
+			reason:  RejectBodyTooLarge,
+		},
+		{
+			name:    "chunked body too large",
+			request: "POST /api/mystatus HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n11\r\n" + strings.Repeat("a", 17) + "\r\n0\r\n\r\n",
+			status:  "HTTP/1.1 413 Request Entity Too Large",
+			reason:  RejectBodyTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before float64
+			if tt.reason != "" {
+				before = metrics.LegacyRejections.Value(tt.reason)
+			}
+			if got := statusOf(roundTrip(t, addr, tt.request)); got != tt.status {
+				t.Errorf("Expected %q, got %q", tt.status, got)
+			}
+			if tt.reason != "" && metrics.LegacyRejections.Value(tt.reason) != before+1 {
+				t.Errorf("Expected the %s rejection to be counted", tt.reason)
+			}
+		})
+	}
+}
+
+func TestLimits_RequestRatePerIP(t *testing.T) {
+	limits := DefaultLimits()
+	limits.RequestRatePerIP = 0.001
+	limits.RequestBurstPerIP = 2
+	addr := startLimitedServer(t, limits)
+
+	request := "GET /api/serverinfos HTTP/1.1\r\n\r\n"
+	for i := 0; i < 2; i++ {
+		if got := statusOf(roundTrip(t, addr, request)); got != "HTTP/1.1 200 OK" {
+			t.Fatalf("Request %d: expected 200 within the burst, got %q", i+1, got)
+		}
+	}
+	if got := statusOf(roundTrip(t, addr, request)); got != "HTTP/1.1 429 Too Many Requests" {
+		t.Errorf("Expected 429 once the burst is spent, got %q", got)
+	}
+}
+
+func TestLimits_ConnectionRatePerIP(t *testing.T) {
+	limits := DefaultLimits()
+	limits.ConnRatePerIP = 0.001
+	limits.ConnBurstPerIP = 1
+	addr := startLimitedServer(t, limits)
+
+	request := "GET /api/serverinfos HTTP/1.1\r\n\r\n"
+	if got := statusOf(roundTrip(t, addr, request)); got != "HTTP/1.1 200 OK" {
+		t.Fatalf("Expected the first connection to be served, got %q", got)
+	}
+	if !closedWithoutResponse(t, addr, request) {
+		t.Error("Expected the second connection to be closed without a response")
+	}
+}
+
+func TestLimits_MaxConnections(t *testing.T) {
+	limits := DefaultLimits()
+	limits.MaxConnections = 1
+	addr := startLimitedServer(t, limits)
+
+	// Hold the only slot with a connection that has not sent its request yet
+	held, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer held.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	if !closedWithoutResponse(t, addr, "GET /api/serverinfos HTTP/1.1\r\n\r\n") {
+		t.Error("Expected a connection over the limit to be closed without a response")
+	}
+
+	// Releasing the slot lets the next connection in
+	held.Write([]byte("GET /api/serverinfos HTTP/1.1\r\n\r\n"))
+	held.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 64)
+	held.Read(buf)
+	held.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	if got := statusOf(roundTrip(t, addr, "GET /api/serverinfos HTTP/1.1\r\n\r\n")); got != "HTTP/1.1 200 OK" {
+		t.Errorf("Expected 200 once the slot is free, got %q", got)
+	}
+}
+
+func TestLimits_SharedAcrossListeners(t *testing.T) {
+	server := NewLegacyHTTPServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.Limits.MaxConnections = 1
+
+	// Serve on two listeners: the second call must not replace the slots the first one handed out
+	var addrs []string
+	for i := 0; i < 2; i++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		t.Cleanup(func() { listener.Close() })
+		go server.Serve(listener)
+		addrs = append(addrs, listener.Addr().String())
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	held, err := net.Dial("tcp", addrs[0])
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer held.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	if !closedWithoutResponse(t, addrs[1], "GET /api/serverinfos HTTP/1.1\r\n\r\n") {
+		t.Error("Expected the slot held on the first listener to count on the second")
+	}
+
+	held.Close()
+	time.Sleep(50 * time.Millisecond)
+	for _, addr := range addrs {
+		if got := statusOf(roundTrip(t, addr, "GET /api/serverinfos HTTP/1.1\r\n\r\n")); got != "HTTP/1.1 200 OK" {
+			t.Errorf("Expected 200 on %s once the slot is free, got %q", addr, got)
+		}
+	}
+}
+
+func TestLimits_SlowHeaders(t *testing.T) {
+	limits := DefaultLimits()
+	limits.HeaderTimeout = 100 * time.Millisecond
+	addr := startLimitedServer(t, limits)
+
+	before := metrics.LegacyRejections.Value(RejectSlowRequest)
+
+	// Trickle the headers without ever finishing them
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET /api/serverinfos HTTP/1.1\r\nHost: x\r\n"))
+
+	start := time.Now()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _ := conn.Read(make([]byte, 64))
+	if n != 0 {
+		t.Errorf("Expected the connection to be closed without a response, got %d bytes", n)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the header timeout to close the connection, took %v", elapsed)
+	}
+	if metrics.LegacyRejections.Value(RejectSlowRequest) != before+1 {
+		t.Errorf("Expected the slow request to be counted")
+	}
+}
+
+func TestIPRateLimiter_Refill(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newIPRateLimiter(2, 2)
+	limiter.now = func() time.Time { return now }
+
+	if !limiter.allow("10.0.0.1") || !limiter.allow("10.0.0.1") {
+		t.Fatal("Expected the burst to be allowed")
+	}
+	if limiter.allow("10.0.0.1") {
+		t.Error("Expected the third event to be refused")
+	}
+	if !limiter.allow("10.0.0.2") {
+		t.Error("Expected another IP to have its own bucket")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if !limiter.allow("10.0.0.1") {
+		t.Error("Expected one token after 500ms at 2/s")
+	}
+	if limiter.allow("10.0.0.1") {
+		t.Error("Expected the refilled token to be spent")
+	}
+
+	now = now.Add(2 * ratePruneInterval)
+	limiter.allow("10.0.0.3")
+	if _, ok := limiter.buckets["10.0.0.1"]; ok {
+		t.Error("Expected idle buckets to be pruned")
+	}
+
+	if newIPRateLimiter(0, 10) != nil || !(*ipRateLimiter)(nil).allow("x") {
+		t.Error("Expected a zero rate to disable the limiter")
+	}
+}
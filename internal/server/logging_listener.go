@@ -2,8 +2,9 @@ package server
 
 import (
 	"bytes"
-	"log"
 	"net"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/logging"
 )
 
 // LoggingListener wraps a net.Listener to log all connection attempts
@@ -20,12 +21,11 @@ func NewLoggingListener(inner net.Listener) *LoggingListener {
 func (l *LoggingListener) Accept() (net.Conn, error) {
 	conn, err := l.Listener.Accept()
 	if err != nil {
-		log.Printf("[DEBUG] TCP error accepting connection: %v", err)
+		logging.Debugf("TCP error accepting connection: %v", err)
 		return nil, err
 	}
 
-	// Only log in debug mode
-	// log.Printf("[DEBUG] TCP connection from %s", conn.RemoteAddr())
+	logging.Debugf("TCP connection from %s", conn.RemoteAddr())
 	return newLoggingConn(conn), nil
 }
 
@@ -48,25 +48,24 @@ func newLoggingConn(conn net.Conn) *loggingConn {
 func (c *loggingConn) Read(b []byte) (n int, err error) {
 	n, err = c.Conn.Read(b)
 	
-	// Debug logging disabled by default - uncomment for troubleshooting
-	// if c.firstRead && n > 0 {
-	// 	c.firstRead = false
-	// 	c.readBuffer.Write(b[:n])
-	// 	log.Printf("[DEBUG] First %d bytes from %s:", n, c.RemoteAddr())
-	// 	log.Printf("[DEBUG] Raw data (hex): %x", b[:n])
-	// 	log.Printf("[DEBUG] Raw data (string): %q", string(b[:n]))
-	// 	lines := bytes.Split(b[:n], []byte("\n"))
-	// 	if len(lines) > 0 {
-	// 		log.Printf("[DEBUG] First line: %q", string(bytes.TrimSpace(lines[0])))
-	// 	}
-	// }
+	// Dump the first chunk only when debug logging is enabled
+	if c.firstRead && n > 0 && logging.Default().Enabled(logging.LevelDebug) {
+		c.firstRead = false
+		c.readBuffer.Write(b[:n])
+		logging.Debugf("First %d bytes from %s:", n, c.RemoteAddr())
+		logging.Debugf("Raw data (hex): %x", b[:n])
+		logging.Debugf("Raw data (string): %q", string(b[:n]))
+		lines := bytes.Split(b[:n], []byte("\n"))
+		if len(lines) > 0 {
+			logging.Debugf("First line: %q", string(bytes.TrimSpace(lines[0])))
+		}
+	}
 	
 	return n, err
 }
 
 // Close logs when the connection is closed (debug mode only)
 func (c *loggingConn) Close() error {
-	// Debug logging disabled by default
-	// log.Printf("[DEBUG] TCP closing connection from %s", c.RemoteAddr())
+	logging.Debugf("TCP closing connection from %s", c.RemoteAddr())
 	return c.Conn.Close()
 }
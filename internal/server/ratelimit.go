@@ -0,0 +1,86 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// ratePruneInterval is how often idle buckets are dropped from an ipRateLimiter
+const ratePruneInterval = time.Minute
+
+// ipRateLimiter is a token bucket per remote IP
+// A nil limiter allows everything
+type ipRateLimiter struct {
+	mu        sync.Mutex
+	rate      float64 // tokens per second
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newIPRateLimiter returns a limiter allowing rate events per second per IP with the given burst,
+// or nil when rate is not positive
+func newIPRateLimiter(rate float64, burst int) *ipRateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &ipRateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// allow takes a token from the bucket of ip and reports whether one was available
+func (l *ipRateLimiter) allow(ip string) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[ip] = b
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * l.rate
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// prune drops the buckets that have refilled completely, they hold no state worth keeping
+func (l *ipRateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < ratePruneInterval {
+		return
+	}
+	l.lastPrune = now
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for ip, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, ip)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/internal/logging"
+)
+
+// ErrServerClosed is returned by Serve after Shutdown has been called
+var ErrServerClosed = errors.New("legacy server closed")
+
+// shutdownPollInterval is how often Shutdown checks whether the active connections are done
+const shutdownPollInterval = 50 * time.Millisecond
+
+// DefaultShutdownHookTimeout is the time each shutdown hook gets by default
+const DefaultShutdownHookTimeout = 5 * time.Second
+
+// shutdownHook is a function run by Shutdown: a drain started with it, or a hook run after it
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// RegisterDrain registers another server to shut down together with this one, such as the
+// API or metrics http.Server
+// Drains start as Shutdown starts, so every listener stops accepting at once, and run alongside
+// the legacy drain with the context given to Shutdown: they share its deadline
+func (s *LegacyHTTPServer) RegisterDrain(name string, fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drains = append(s.drains, shutdownHook{name: name, fn: fn})
+}
+
+// RegisterOnShutdown registers a function to run during Shutdown, after the connections are drained
+// Hooks run in registration order, even when the drain deadline has passed; each gets its own
+// context of ShutdownHookTimeout, so a drain that used up its deadline never cuts a flush short
+func (s *LegacyHTTPServer) RegisterOnShutdown(name string, fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, shutdownHook{name: name, fn: fn})
+}
+
+// hookResult is the outcome of a drain or a hook
+type hookResult struct {
+	name    string
+	err     error
+	elapsed time.Duration
+}
+
+// Shutdown stops accepting connections on every listener, including the registered drains,
+// waits for the active connections to finish until ctx is done, closes those still open,
+// waits for the drains, then runs the shutdown hooks
+// It returns ctx.Err() when connections had to be abandoned, otherwise the first drain or hook error
+func (s *LegacyHTTPServer) Shutdown(ctx context.Context) error {
+	start := time.Now()
+
+	s.mu.Lock()
+	s.inShutdown = true
+	for l := range s.listeners {
+		if err := l.Close(); err != nil {
+			logging.Warnf("[SHUTDOWN] Error closing listener %s: %v", l.Addr(), err)
+		}
+	}
+	s.listeners = nil
+	active := len(s.conns)
+	drains := append([]shutdownHook(nil), s.drains...)
+	hooks := append([]shutdownHook(nil), s.hooks...)
+	s.mu.Unlock()
+
+	// The other servers stop accepting now and drain alongside
+	drained := make(chan hookResult, len(drains))
+	for _, drain := range drains {
+		go func(drain shutdownHook) {
+			drainStart := time.Now()
+			err := drain.fn(ctx)
+			drained <- hookResult{name: drain.name, err: err, elapsed: time.Since(drainStart)}
+		}(drain)
+	}
+
+	if active > 0 {
+		logging.Infof("[SHUTDOWN] Waiting for %d active connections", active)
+	}
+
+	// Wait for the active connections to finish
+	var drainErr error
+	ticker := time.NewTicker(shutdownPollInterval)
+	for s.activeConns() > 0 && drainErr == nil {
+		select {
+		case <-ctx.Done():
+			drainErr = ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	ticker.Stop()
+
+	// Connections still open past the deadline are abandoned
+	abandoned := s.closeConns()
+	for _, remote := range abandoned {
+		logging.Warnf("[SHUTDOWN] Abandoned connection from %s", remote)
+	}
+
+	var firstErr error
+	failed := 0
+	record := func(kind string, result hookResult) {
+		if result.err != nil {
+			failed++
+			logging.Errorf("[SHUTDOWN] %s %s failed after %v: %v", kind, result.name, result.elapsed, result.err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("shutdown %s %s: %w", strings.ToLower(kind), result.name, result.err)
+			}
+			return
+		}
+		logging.Infof("[SHUTDOWN] %s %s done in %v", kind, result.name, result.elapsed)
+	}
+
+	// The drains return by the deadline at the latest
+	for range drains {
+		record("Drain", <-drained)
+	}
+
+	// Run the hooks: flush state, stop background work
+	hookTimeout := s.ShutdownHookTimeout
+	if hookTimeout <= 0 {
+		hookTimeout = DefaultShutdownHookTimeout
+	}
+	for _, hook := range hooks {
+		hookCtx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+		hookStart := time.Now()
+		err := hook.fn(hookCtx)
+		cancel()
+		record("Hook", hookResult{name: hook.name, err: err, elapsed: time.Since(hookStart)})
+	}
+
+	logging.Infof("[SHUTDOWN] Drained %d connections, abandoned %d, ran %d drains and %d hooks (%d failed) in %v",
+		active-len(abandoned), len(abandoned), len(drains), len(hooks), failed, time.Since(start))
+
+	if drainErr != nil {
+		return drainErr
+	}
+	return firstErr
+}
+
+// trackListener records a listener being served, or refuses it once shutdown has started
+func (s *LegacyHTTPServer) trackListener(l net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inShutdown {
+		return false
+	}
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]struct{})
+	}
+	s.listeners[l] = struct{}{}
+	return true
+}
+
+// trackConn records an active connection, or refuses it once shutdown has started
+func (s *LegacyHTTPServer) trackConn(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inShutdown {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]struct{})
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+// untrackConn forgets a connection once it is handled
+func (s *LegacyHTTPServer) untrackConn(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+}
+
+// shuttingDown reports whether Shutdown has been called
+func (s *LegacyHTTPServer) shuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inShutdown
+}
+
+func (s *LegacyHTTPServer) activeConns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// closeConns closes the connections still open and returns their remote addresses
+func (s *LegacyHTTPServer) closeConns() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var remotes []string
+	for conn := range s.conns {
+		remotes = append(remotes, conn.RemoteAddr().String())
+		conn.Close()
+		delete(s.conns, conn)
+	}
+	return remotes
+}
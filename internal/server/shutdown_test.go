@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startBlockingServer serves requests with a handler that waits for release before answering
+// It returns the address, the server, a channel signalled when a request arrives and Serve's result
+func startBlockingServer(t *testing.T, release <-chan struct{}) (string, *LegacyHTTPServer, <-chan struct{}, <-chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	arrived := make(chan struct{}, 1)
+	server := NewLegacyHTTPServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+	return listener.Addr().String(), server, arrived, served
+}
+
+// sendRequest starts a request and returns a channel receiving the raw response
+func sendRequest(t *testing.T, addr string) <-chan []byte {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	conn.Write([]byte("GET /api/serverinfos HTTP/1.1 \r\n\r\n"))
+
+	response := make(chan []byte, 1)
+	go func() {
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var buf []byte
+		chunk := make([]byte, 256)
+		for {
+			n, err := conn.Read(chunk)
+			buf = append(buf, chunk[:n]...)
+			if err != nil {
+				break
+			}
+		}
+		response <- buf
+	}()
+	return response
+}
+
+func TestShutdown_DrainsActiveConnections(t *testing.T) {
+	release := make(chan struct{})
+	addr, server, arrived, served := startBlockingServer(t, release)
+
+	var ran []string
+	server.RegisterOnShutdown("first", func(ctx context.Context) error {
+		ran = append(ran, "first")
+		return nil
+	})
+	server.RegisterOnShutdown("second", func(ctx context.Context) error {
+		ran = append(ran, "second")
+		return nil
+	})
+
+	response := sendRequest(t, addr)
+	<-arrived
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- server.Shutdown(ctx) }()
+
+	// Serve stops and new connections are refused while the request is still in flight
+	if err := <-served; err != ErrServerClosed {
+		t.Errorf("Expected Serve to return ErrServerClosed, got %v", err)
+	}
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		conn.Close()
+		t.Error("Expected new connections to be refused during shutdown")
+	}
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown returned before the active request finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Expected a clean shutdown, got %v", err)
+	}
+	if got := statusOf(<-response); got != "HTTP/1.1 200 OK" {
+		t.Errorf("Expected the in-flight request to be answered, got %q", got)
+	}
+	if len(ran) != 2 || ran[0] != "first" || ran[1] != "second" {
+		t.Errorf("Expected hooks to run in registration order, got %v", ran)
+	}
+}
+
+func TestShutdown_AbandonsConnectionsAtDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	addr, server, arrived, _ := startBlockingServer(t, release)
+
+	hookRan := false
+	server.RegisterOnShutdown("flush", func(ctx context.Context) error {
+		hookRan = true
+		return nil
+	})
+
+	response := sendRequest(t, addr)
+	<-arrived
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if !hookRan {
+		t.Error("Expected hooks to run even after the deadline")
+	}
+	if got := <-response; len(got) != 0 {
+		t.Errorf("Expected the abandoned connection to be closed without a response, got %q", got)
+	}
+}
+
+func TestShutdown_ReturnsHookError(t *testing.T) {
+	_, server, _, _ := startBlockingServer(t, nil)
+
+	flushErr := errors.New("disk full")
+	server.RegisterOnShutdown("store", func(ctx context.Context) error { return flushErr })
+	nextRan := false
+	server.RegisterOnShutdown("next", func(ctx context.Context) error {
+		nextRan = true
+		return nil
+	})
+
+	err := server.Shutdown(context.Background())
+	if !errors.Is(err, flushErr) {
+		t.Errorf("Expected the hook error, got %v", err)
+	}
+	if !nextRan {
+		t.Error("Expected the hooks after a failing one to run")
+	}
+	if err := server.Serve(nil); err != ErrServerClosed {
+		t.Errorf("Expected Serve after Shutdown to return ErrServerClosed, got %v", err)
+	}
+}
+
+func TestShutdown_HooksGetTheirOwnBudget(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	addr, server, arrived, _ := startBlockingServer(t, release)
+	server.ShutdownHookTimeout = time.Second
+
+	var hookErr error
+	var hookLeft time.Duration
+	server.RegisterOnShutdown("flush", func(ctx context.Context) error {
+		hookErr = ctx.Err()
+		if deadline, ok := ctx.Deadline(); ok {
+			hookLeft = time.Until(deadline)
+		}
+		return nil
+	})
+
+	sendRequest(t, addr)
+	<-arrived
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected the drain to time out, got %v", err)
+	}
+	if hookErr != nil {
+		t.Errorf("Expected the hook to get a live context after the drain timed out, got %v", hookErr)
+	}
+	if hookLeft < 500*time.Millisecond || hookLeft > time.Second {
+		t.Errorf("Expected the hook's own budget of 1s, %v left", hookLeft)
+	}
+}
+
+func TestShutdown_DrainsStopAcceptingFirst(t *testing.T) {
+	release := make(chan struct{})
+	addr, server, arrived, _ := startBlockingServer(t, release)
+
+	// A second server, like the API listener, shut down together with the legacy one
+	apiListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	apiServer := &http.Server{Handler: http.NotFoundHandler()}
+	go apiServer.Serve(apiListener)
+	server.RegisterDrain("api server", apiServer.Shutdown)
+
+	response := sendRequest(t, addr)
+	<-arrived
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- server.Shutdown(ctx) }()
+
+	// The API listener is closed while the legacy request is still in flight
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", apiListener.Addr().String())
+		if err != nil {
+			break
+		}
+		conn.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the API listener to stop accepting while the legacy connections drain")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(release)
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Expected a clean shutdown, got %v", err)
+	}
+	if got := string(<-response); !strings.HasPrefix(got, "HTTP/1.1 200") {
+		t.Errorf("Expected the in-flight request to be answered, got %q", got)
+	}
+}
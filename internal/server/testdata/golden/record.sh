@@ -0,0 +1,48 @@
+#!/bin/bash
+# Records the golden responses of the reference server (docs/server.sample.go)
+# The requests must stay identical to the ones sent by internal/server/golden_test.go
+# Usage: internal/server/testdata/golden/record.sh [port]
+set -euo pipefail
+
+GOLDEN_DIR="$(cd "$(dirname "$0")" && pwd)"
+ROOT="$(cd "$GOLDEN_DIR/../../../.." && pwd)"
+PORT="${1:-18081}"
+SAMPLE="$(mktemp -d)/server.sample"
+
+(cd "$ROOT" && go build -o "$SAMPLE" ./docs/server.sample.go)
+"$SAMPLE" -port "$PORT" > /dev/null &
+SAMPLE_PID=$!
+trap 'kill $SAMPLE_PID 2>/dev/null; rm -rf "$(dirname "$SAMPLE")"' EXIT
+sleep 1
+
+# send writes the raw request to the reference and prints everything it answers until it closes
+send() {
+	exec 3<>"/dev/tcp/127.0.0.1/$PORT"
+	printf '%s' "$1" >&3
+	cat <&3
+	exec 3<&-
+}
+
+# record saves the answer to a raw request as <name>.golden
+record() {
+	send "$2" > "$GOLDEN_DIR/$1.golden"
+	echo "Recorded $1.golden"
+}
+
+# firmware_post builds a POST the way the firmware sends it, trailing space included
+firmware_post() {
+	printf 'POST %s HTTP/1.1 \r\nHost: 192.168.0.10\r\nContent-Length: %d\r\n\r\n%s' "$1" "${#2}" "$2"
+}
+
+record serverinfos $'GET /api/serverinfos HTTP/1.1 \r\nHost: 192.168.0.10\r\n\r\n'
+record serverinfos_http10 $'GET /api/serverinfos HTTP/1.0\r\n\r\n'
+record mystatus_unquoted_keys "$(firmware_post /api/mystatus '{"version":"V125","ek":[{k:613,v:"64"},{k:1,v:"0"}]}')"
+record mystatus_malformed_json "$(firmware_post /api/mystatus '{version:V125')"
+record myactions_empty $'GET /api/myactions HTTP/1.1 \r\nHost: 192.168.0.10\r\n\r\n'
+record done_unknown $'POST /api/done/11111111-1111-1111-1111-111111111111 HTTP/1.1 \r\nHost: 192.168.0.10\r\nContent-Length: 0\r\n\r\n'
+record unknown_path $'GET /api/unknown HTTP/1.1\r\nHost: x\r\n\r\n'
+
+# The reference generates the GUIDs: acknowledge the one it queued, the answer does not depend on it
+send "$(firmware_post /api/admin/inject '[{"k":920,"v":"1"}]')" > /dev/null
+GUID="$(send $'GET /api/myactions HTTP/1.1 \r\nHost: 192.168.0.10\r\n\r\n' | sed -n 's/.*"guid":"\([^"]*\)".*/\1/p')"
+record done_known "POST /api/done/$GUID HTTP/1.1 "$'\r\nHost: 192.168.0.10\r\nContent-Length: 0\r\n\r\n'
@@ -0,0 +1,314 @@
+// Command loadtest runs a fleet against a server without the UI, prints a report and exits
+// non-zero when a threshold is broken, for CI
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"simulation/internal/client"
+	"simulation/internal/fleet"
+	"simulation/internal/metrics"
+	"simulation/internal/scenarios"
+)
+
+// Exit codes
+const (
+	exitPass   = 0
+	exitFailed = 1 // A threshold or a scenario check is broken, no request succeeded, or the run was interrupted
+	exitUsage  = 2 // Invalid flags or files, as for the flag package's own errors
+)
+
+// progressInterval is how often the run logs its progress
+const progressInterval = 10 * time.Second
+
+// limitedShare is the share of closed connections and 429 answers above which the server's
+// per-IP limits are most likely what the run measured
+const limitedShare = 0.5
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout))
+}
+
+// run parses the flags in args, runs the load test and returns the exit code; the report goes to stdout
+func run(args []string, stdout io.Writer) int {
+	flags := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	var thresholds thresholdFlags
+	target := flags.String("target", "", "server URL, e.g. http://192.168.0.10:80 (required). All clients connect from this host's IP:\n"+
+		"raise the server's per-IP limits (conn_rate_per_ip, request_rate_per_ip and their bursts) or they reject the fleet")
+	count := flags.Int("count", 10, "number of clients, started 5 per second")
+	ramp := flags.String("ramp", "", "ramp profile such as 20:10s,100:1m, whose last stage replaces -count")
+	duration := flags.Duration("duration", time.Minute, "how long the fleet runs once ramped up")
+	scenarioFile := flags.String("scenario", "", "scenario file applied by each client at startup")
+	modeName := flags.String("mode", "http", "http or firmware")
+	profiles := flags.String("profiles", client.DefaultProfile.Name, "device profiles with their weights, such as flat:50,house:30,alarm:20")
+	adminURL := flags.String("admin-url", "", "URL of the server's admin API for inject steps and server checks, if not the -target")
+	adminToken := flags.String("admin-token", "", "admin token sent as Bearer to the admin API; without it the clients use their own credentials")
+	adminClient := flags.String("admin-client", "", "ID the server files the clients under, default their credential ID (\"default\" if the server does not authenticate boxes)")
+	jsonOut := flags.String("json", "", "write the report as JSON to this file")
+	csvOut := flags.String("csv", "", "write the report as CSV to this file")
+	flags.Var(&thresholds, "threshold", "pass/fail condition such as p99<500ms or error_rate<0.1% (repeatable)")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitPass
+		}
+		return exitUsage
+	}
+	usageError := func(err error) int {
+		fmt.Fprintf(flags.Output(), "loadtest: %v\n", err)
+		flags.Usage()
+		return exitUsage
+	}
+
+	serverURL, err := parseTarget(*target)
+	if err != nil {
+		return usageError(err)
+	}
+	mode, err := client.ParseMode(*modeName)
+	if err != nil {
+		return usageError(err)
+	}
+
+	var stages []fleet.RampStage
+	if *ramp != "" {
+		stages, err = fleet.ParseRampProfile(*ramp)
+	} else if *count < 1 || *count > fleet.MaxClients {
+		err = fmt.Errorf("count must be between 1 and %d", fleet.MaxClients)
+	} else {
+		stages = fleet.DefaultRampProfile(*count)
+	}
+	if err != nil {
+		return usageError(err)
+	}
+
+	mix, err := fleet.ParseProfileMix(*profiles)
+	if err != nil {
+		return usageError(err)
+	}
+
+	var startupScenario []client.ScenarioStep
+	if *scenarioFile != "" {
+		scenario, err := scenarios.ReadFile(*scenarioFile)
+		if err != nil {
+			return usageError(fmt.Errorf("scenario %s: %w", *scenarioFile, err))
+		}
+		startupScenario = scenario.Steps
+	}
+	if stages[len(stages)-1].Clients > 1 && client.ChecksInjectedOnClient(startupScenario) {
+		return usageError(fmt.Errorf("scenario %s checks injected values on the client, but the server queues actions per tenant "+
+			"and another client may take them: run it with -count 1, or check on the server", *scenarioFile))
+	}
+	if *adminURL != "" {
+		if _, err := parseTarget(*adminURL); err != nil {
+			return usageError(fmt.Errorf("-admin-url must be an http:// or https:// URL, got %q", *adminURL))
+		}
+	}
+
+	manager := fleet.NewManager()
+	manager.SetAdmin(client.AdminAccess{URL: *adminURL, Token: *adminToken, Client: *adminClient})
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupted)
+
+	log.Printf("[LoadTest] Running against %s (%s mode, profiles %v), then for %v", serverURL, mode, mix, *duration)
+	complete := runFleet(manager, stages, serverURL, mode, mix, startupScenario, *duration, interrupted)
+
+	// Scenario results are read before stopping, which would abort the scenarios still running
+	clients := manager.GetAllClients()
+	results := make([]client.ScenarioResult, len(clients))
+	for i, emu := range clients {
+		results[i], _ = emu.ScenarioResult()
+	}
+
+	// Stopping waits for the requests in progress, so that the report counts them
+	manager.StopAllClients()
+	report := manager.Report()
+
+	printReport(stdout, report)
+	warnServerLimits(stdout, report)
+	if err := exportReport(report, *jsonOut, *csvOut); err != nil {
+		log.Printf("[LoadTest] %v", err)
+		return exitUsage
+	}
+
+	passed := printThresholds(stdout, report, thresholds)
+	scenariosPassed := true
+	if len(startupScenario) > 0 {
+		scenariosPassed = printScenarioResults(stdout, clients, results)
+	}
+	switch {
+	case !complete:
+		fmt.Fprintln(stdout, "\nFAIL: the run did not complete")
+		return exitFailed
+	case report.Overall.Requests == report.Overall.Errors:
+		fmt.Fprintln(stdout, "\nFAIL: no request succeeded")
+		return exitFailed
+	case !passed:
+		fmt.Fprintln(stdout, "\nFAIL: thresholds broken")
+		return exitFailed
+	case !scenariosPassed:
+		fmt.Fprintln(stdout, "\nFAIL: scenario checks failed")
+		return exitFailed
+	}
+	fmt.Fprintln(stdout, "\nPASS")
+	return exitPass
+}
+
+// warnServerLimits warns when most requests were closed or answered 429: the whole fleet comes
+// from one IP, so the server's per-IP limits reject it long before the server is loaded
+func warnServerLimits(w io.Writer, report metrics.Report) bool {
+	limited := report.Overall.Outcomes["closed"] + report.Overall.Outcomes["429"]
+	if report.Overall.Requests == 0 || float64(limited) <= limitedShare*float64(report.Overall.Requests) {
+		return false
+	}
+	fmt.Fprintf(w, "\nWARNING: %d of %d requests were closed or answered 429. The fleet connects from a single IP:\n"+
+		"raise the server's conn_rate_per_ip, request_rate_per_ip and their bursts for load tests,\n"+
+		"or this run measured the per-IP limits rather than the server\n", limited, report.Overall.Requests)
+	return true
+}
+
+// runFleet ramps the fleet up then lets it run, and reports false when interrupted or when
+// the ramp failed
+func runFleet(manager *fleet.Manager, stages []fleet.RampStage, serverURL string, mode client.Mode,
+	mix fleet.ProfileMix, startupScenario []client.ScenarioStep, duration time.Duration, interrupted <-chan os.Signal) bool {
+	ramped := make(chan error, 1)
+	go func() {
+		ramped <- manager.Ramp(stages, serverURL, mode, mix, startupScenario)
+	}()
+
+	progress := time.NewTicker(progressInterval)
+	defer progress.Stop()
+	var finished <-chan time.Time
+
+	for {
+		select {
+		case err := <-ramped:
+			if err != nil {
+				log.Printf("[LoadTest] %v", err)
+				return false
+			}
+			log.Printf("[LoadTest] Ramp complete, running for %v", duration)
+			finished = time.After(duration)
+
+		case <-finished:
+			return true
+
+		case sig := <-interrupted:
+			log.Printf("[LoadTest] Received %v, stopping", sig)
+			return false
+
+		case <-progress.C:
+			report := manager.Report()
+			log.Printf("[LoadTest] %d clients, %d requests, %.1f req/s, %.2f%% errors, p99 %.1f ms",
+				len(manager.GetAllClients()), report.Overall.Requests, report.Throughput,
+				report.Overall.ErrorRate*100, report.Overall.P99)
+		}
+	}
+}
+
+// parseTarget checks the server URL and keeps its scheme and host, the emulators adding the paths
+func parseTarget(target string) (string, error) {
+	if target == "" {
+		return "", fmt.Errorf("-target is required")
+	}
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("-target must be an http:// or https:// URL, got %q", target)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+func printReport(w io.Writer, report metrics.Report) {
+	fmt.Fprintf(w, "\n%d requests in %.1fs, %.1f req/s\n\n", report.Overall.Requests, report.Duration, report.Throughput)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "endpoint\trequests\terrors\ttimeouts\tp50 ms\tp95 ms\tp99 ms\tmax ms\t")
+	for _, ep := range append(report.Endpoints, report.Overall) {
+		fmt.Fprintf(tw, "%s\t%d\t%.2f%%\t%.2f%%\t%.1f\t%.1f\t%.1f\t%.1f\t\n",
+			ep.Endpoint, ep.Requests, ep.ErrorRate*100, ep.TimeoutRate*100, ep.P50, ep.P95, ep.P99, ep.Max)
+	}
+	tw.Flush()
+
+	if len(report.Overall.Outcomes) > 0 {
+		fmt.Fprintf(w, "\noutcomes: %v\n", report.Overall.Outcomes)
+	}
+}
+
+// printThresholds checks every threshold and reports whether they all pass
+func printThresholds(w io.Writer, report metrics.Report, thresholds []threshold) bool {
+	if len(thresholds) == 0 {
+		return true
+	}
+	fmt.Fprintln(w)
+	passed := true
+	for _, t := range thresholds {
+		ok, value := t.check(report)
+		status := "ok"
+		if !ok {
+			status = "BROKEN"
+			passed = false
+		}
+		fmt.Fprintf(w, "%-6s %s (measured %g)\n", status, t.expr, value)
+	}
+	return passed
+}
+
+// printScenarioResults reports the startup scenario of each client and whether all of them
+// passed; a scenario still running when the run ended did not pass
+func printScenarioResults(w io.Writer, clients []*client.Emulator, results []client.ScenarioResult) bool {
+	fmt.Fprintln(w)
+	counts := make(map[client.ScenarioStatus]int)
+	for i, emu := range clients {
+		result := results[i]
+		counts[result.Status]++
+		if result.Status == client.ScenarioPassed {
+			continue
+		}
+		fmt.Fprintf(w, "%s: scenario %s", emu.ID, result.Status)
+		if result.Error != "" {
+			fmt.Fprintf(w, ": %s", result.Error)
+		}
+		fmt.Fprintln(w)
+		for _, check := range result.Checks {
+			if !check.Passed {
+				fmt.Fprintf(w, "  step %d %s %v: got %q\n", check.Step, check.Kind, check.Condition, check.Actual)
+			}
+		}
+	}
+	fmt.Fprintf(w, "scenarios: %d passed, %d failed, %d running, %d aborted\n",
+		counts[client.ScenarioPassed], counts[client.ScenarioFailed], counts[client.ScenarioRunning], counts[client.ScenarioAborted])
+	return counts[client.ScenarioPassed] == len(clients)
+}
+
+// exportReport writes the report files requested by -json and -csv
+func exportReport(report metrics.Report, jsonPath, csvPath string) error {
+	if jsonPath != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(jsonPath, data, 0644); err != nil {
+			return err
+		}
+	}
+	if csvPath != "" {
+		f, err := os.Create(csvPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := report.WriteCSV(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"simulation/internal/metrics"
+)
+
+// boxServer answers the firmware routes like the server with an empty queue, or always with status
+func boxServer(t *testing.T, status int) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json ;charset=UTF-8")
+		switch r.URL.Path {
+		case "/api/serverinfos":
+			w.Write([]byte(`{"isconnected":false,"infos":[613],"newversion":"no"}`))
+		case "/api/mystatus":
+			w.WriteHeader(http.StatusCreated)
+		case "/api/myactions":
+			w.Write([]byte(`{"_de67f":null,"actions":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestRun_ExitCodes(t *testing.T) {
+	healthy := boxServer(t, 0)
+	limited := boxServer(t, http.StatusTooManyRequests)
+	short := []string{"-count", "1", "-duration", "200ms"}
+	// Another client could take the injected action before the one checking it
+	injectedOnClient := filepath.Join(t.TempDir(), "injected.json")
+	os.WriteFile(injectedOnClient, []byte(`{"name":"injected","steps":[{"delay":0,"inject":[{"index":590,"value":"1"}],`+
+		`"wait":{"on":"client","index":590,"equals":"1","within":1000}}]}`), 0644)
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+		out  string
+	}{
+		{"help", []string{"-h"}, exitPass, ""},
+		{"missing target", nil, exitUsage, ""},
+		{"unknown flag", []string{"-target", healthy, "-bogus"}, exitUsage, ""},
+		{"invalid threshold", []string{"-target", healthy, "-threshold", "p99<fast"}, exitUsage, ""},
+		{"count out of range", []string{"-target", healthy, "-count", "0"}, exitUsage, ""},
+		{"shrinking ramp", []string{"-target", healthy, "-ramp", "5:1s,2:1s"}, exitUsage, ""},
+		{"unknown mode", []string{"-target", healthy, "-mode", "telnet"}, exitUsage, ""},
+		{"unknown profile", []string{"-target", healthy, "-profiles", "castle"}, exitUsage, ""},
+		{"missing scenario", []string{"-target", healthy, "-scenario", "/nonexistent.json"}, exitUsage, ""},
+		{"client check of injected values", []string{"-target", healthy, "-count", "2", "-scenario", injectedOnClient}, exitUsage, ""},
+		{"invalid admin url", []string{"-target", healthy, "-admin-url", "localhost:8081"}, exitUsage, ""},
+		{"pass", append([]string{"-target", healthy, "-threshold", "error_rate<1%"}, short...), exitPass, "PASS"},
+		{"broken threshold", append([]string{"-target", healthy, "-threshold", "throughput>=1000"}, short...), exitFailed, "FAIL: thresholds broken"},
+		{"no request succeeded", append([]string{"-target", limited}, short...), exitFailed, "WARNING"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if got := run(tt.args, &out); got != tt.want {
+				t.Errorf("run(%v) = %d, expected %d\n%s", tt.args, got, tt.want, out.String())
+			}
+			if !strings.Contains(out.String(), tt.out) {
+				t.Errorf("Expected %q in the output, got:\n%s", tt.out, out.String())
+			}
+		})
+	}
+}
+
+func TestWarnServerLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		outcomes map[string]int
+		warned   bool
+	}{
+		{"no request", map[string]int{}, false},
+		{"healthy", map[string]int{"200": 90, "closed": 10}, false},
+		{"half limited", map[string]int{"200": 50, "closed": 25, "429": 25}, false},
+		{"mostly closed", map[string]int{"200": 10, "closed": 90}, true},
+		{"mostly 429", map[string]int{"201": 10, "429": 30}, true},
+	}
+	for _, tt := range tests {
+		report := metrics.Report{Overall: metrics.EndpointReport{Outcomes: tt.outcomes}}
+		for _, count := range tt.outcomes {
+			report.Overall.Requests += count
+		}
+		var out bytes.Buffer
+		if got := warnServerLimits(&out, report); got != tt.warned {
+			t.Errorf("%s: warned = %v, expected %v", tt.name, got, tt.warned)
+		}
+		if tt.warned && !strings.Contains(out.String(), "request_rate_per_ip") {
+			t.Errorf("%s: expected the warning to name the server settings, got %q", tt.name, out.String())
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"simulation/internal/metrics"
+)
+
+// threshold is a pass/fail condition on the overall report, such as p99<500ms
+type threshold struct {
+	expr   string
+	metric string
+	op     string
+	limit  float64
+}
+
+var thresholdPattern = regexp.MustCompile(`^\s*([a-z0-9_]+)\s*(<=|>=|<|>)\s*(\S+)\s*$`)
+
+// latencyMetrics take a duration limit, compared in milliseconds
+var latencyMetrics = map[string]bool{"p50": true, "p95": true, "p99": true, "max": true}
+
+// rateMetrics take a fraction (0.001) or a percentage (0.1%)
+var rateMetrics = map[string]bool{"error_rate": true, "timeout_rate": true}
+
+func parseThreshold(expr string) (threshold, error) {
+	match := thresholdPattern.FindStringSubmatch(expr)
+	if match == nil {
+		return threshold{}, fmt.Errorf("threshold %q: expected metric, operator and limit, e.g. p99<500ms", expr)
+	}
+	t := threshold{expr: strings.TrimSpace(expr), metric: match[1], op: match[2]}
+	value := match[3]
+
+	switch {
+	case latencyMetrics[t.metric]:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return threshold{}, fmt.Errorf("threshold %q: %s needs a duration such as 500ms", expr, t.metric)
+		}
+		t.limit = float64(d.Microseconds()) / 1000
+	case rateMetrics[t.metric]:
+		percent := strings.HasSuffix(value, "%")
+		rate, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil {
+			return threshold{}, fmt.Errorf("threshold %q: %s needs a rate such as 0.1%% or 0.001", expr, t.metric)
+		}
+		if percent {
+			rate /= 100
+		}
+		t.limit = rate
+	case t.metric == "throughput":
+		rps, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return threshold{}, fmt.Errorf("threshold %q: throughput needs requests per second", expr)
+		}
+		t.limit = rps
+	default:
+		return threshold{}, fmt.Errorf("threshold %q: unknown metric %s (p50, p95, p99, max, error_rate, timeout_rate, throughput)", expr, t.metric)
+	}
+	return t, nil
+}
+
+// measure returns the value of the threshold's metric in the report
+func (t threshold) measure(report metrics.Report) float64 {
+	switch t.metric {
+	case "p50":
+		return report.Overall.P50
+	case "p95":
+		return report.Overall.P95
+	case "p99":
+		return report.Overall.P99
+	case "max":
+		return report.Overall.Max
+	case "error_rate":
+		return report.Overall.ErrorRate
+	case "timeout_rate":
+		return report.Overall.TimeoutRate
+	default:
+		return report.Throughput
+	}
+}
+
+// check reports whether the report meets the threshold, and the measured value
+func (t threshold) check(report metrics.Report) (bool, float64) {
+	value := t.measure(report)
+	switch t.op {
+	case "<":
+		return value < t.limit, value
+	case "<=":
+		return value <= t.limit, value
+	case ">":
+		return value > t.limit, value
+	default:
+		return value >= t.limit, value
+	}
+}
+
+// thresholdFlags collects the repeated -threshold flags
+type thresholdFlags []threshold
+
+func (f *thresholdFlags) String() string {
+	exprs := make([]string, 0, len(*f))
+	for _, t := range *f {
+		exprs = append(exprs, t.expr)
+	}
+	return strings.Join(exprs, ",")
+}
+
+func (f *thresholdFlags) Set(expr string) error {
+	t, err := parseThreshold(expr)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, t)
+	return nil
+}
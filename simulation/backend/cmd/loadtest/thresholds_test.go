@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"simulation/internal/metrics"
+)
+
+func TestParseThreshold(t *testing.T) {
+	tests := []struct {
+		expr     string
+		metric   string
+		op       string
+		limit    float64
+		errorHas string
+	}{
+		{expr: "p99<500ms", metric: "p99", op: "<", limit: 500},
+		{expr: " p50 <= 1.5s ", metric: "p50", op: "<=", limit: 1500},
+		{expr: "max<250us", metric: "max", op: "<", limit: 0.25},
+		{expr: "error_rate<0.1%", metric: "error_rate", op: "<", limit: 0.001},
+		{expr: "timeout_rate<=0.02", metric: "timeout_rate", op: "<=", limit: 0.02},
+		{expr: "throughput>=10", metric: "throughput", op: ">=", limit: 10},
+		{expr: "throughput>2.5", metric: "throughput", op: ">", limit: 2.5},
+		{expr: "latency<500ms", errorHas: "unknown metric latency"},
+		{expr: "p99<500", errorHas: "needs a duration"},
+		{expr: "p99<fast", errorHas: "needs a duration"},
+		{expr: "error_rate<low", errorHas: "needs a rate"},
+		{expr: "throughput>=10rps", errorHas: "requests per second"},
+		{expr: "p99=500ms", errorHas: "expected metric, operator and limit"},
+		{expr: "", errorHas: "expected metric, operator and limit"},
+	}
+
+	for _, tt := range tests {
+		got, err := parseThreshold(tt.expr)
+		if tt.errorHas != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errorHas) {
+				t.Errorf("parseThreshold(%q): expected an error containing %q, got %v", tt.expr, tt.errorHas, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseThreshold(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if got.metric != tt.metric || got.op != tt.op || got.limit != tt.limit {
+			t.Errorf("parseThreshold(%q) = %s %s %v, expected %s %s %v", tt.expr, got.metric, got.op, got.limit, tt.metric, tt.op, tt.limit)
+		}
+	}
+}
+
+func TestThresholdCheck(t *testing.T) {
+	report := metrics.Report{
+		Throughput: 10,
+		Overall:    metrics.EndpointReport{P50: 20, P95: 200, P99: 500, Max: 800, ErrorRate: 0.001, TimeoutRate: 0},
+	}
+
+	tests := []struct {
+		expr     string
+		ok       bool
+		measured float64
+	}{
+		{"p99<500ms", false, 500},
+		{"p99<=500ms", true, 500},
+		{"p95<1s", true, 200},
+		{"p50>10ms", true, 20},
+		{"max<500ms", false, 800},
+		{"error_rate<0.1%", false, 0.001},
+		{"error_rate<=0.1%", true, 0.001},
+		{"timeout_rate<0.01", true, 0},
+		{"throughput>=10", true, 10},
+		{"throughput>10", false, 10},
+	}
+	for _, tt := range tests {
+		threshold, err := parseThreshold(tt.expr)
+		if err != nil {
+			t.Fatalf("parseThreshold(%q) failed: %v", tt.expr, err)
+		}
+		ok, measured := threshold.check(report)
+		if ok != tt.ok || measured != tt.measured {
+			t.Errorf("%s: check = %v (measured %v), expected %v (measured %v)", tt.expr, ok, measured, tt.ok, tt.measured)
+		}
+	}
+}
+
+func TestThresholdFlags(t *testing.T) {
+	var flags thresholdFlags
+	if err := flags.Set("p99<500ms"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := flags.Set("error_rate<0.1%"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := flags.Set("bogus"); err == nil {
+		t.Error("Expected an invalid threshold to be refused")
+	}
+	if got := flags.String(); got != "p99<500ms,error_rate<0.1%" {
+		t.Errorf("Unexpected flags %q", got)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"simulation/internal/api"
+	"simulation/internal/fleet"
+	"simulation/internal/scenarios"
+)
+
+// shutdownTimeout bounds how long in-flight UI requests may take once a stop is requested
+const shutdownTimeout = 10 * time.Second
+
+func main() {
+	addr := flag.String("addr", ":5375", "address of the simulator API (the UI expects port 5375)")
+	scenarioDir := flag.String("scenarios", scenarios.DefaultScenarioDir, "directory of the saved scenarios")
+	flag.Parse()
+
+	fleetManager := fleet.NewManager()
+	scenarioManager := scenarios.NewManager(*scenarioDir)
+
+	server := &http.Server{
+		Addr:         *addr,
+		Handler:      api.NewServer(fleetManager, scenarioManager).Handler(),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	serverErrors := make(chan error, 1)
+	go func() {
+		log.Printf("[Simulator] API listening on %s (scenarios in %s)", *addr, *scenarioDir)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serverErrors <- err
+		}
+	}()
+
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err := <-serverErrors:
+		log.Fatalf("[Simulator] Server error: %v", err)
+
+	case sig := <-shutdown:
+		log.Printf("[Simulator] Received %v, stopping", sig)
+
+		// Stop taking UI requests first so that no new client starts while the fleet stops
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("[Simulator] API shutdown incomplete: %v", err)
+		}
+
+		fleetManager.StopAllClients()
+		log.Printf("[Simulator] Stopped")
+	}
+}
@@ -1,3 +1,8 @@
 module simulation
 
 go 1.25.3
+
+require github.com/essensys-hub/essensys-server-backend v0.0.0
+
+// The simulator speaks the server's wire types from the enclosing repository
+replace github.com/essensys-hub/essensys-server-backend => ../..
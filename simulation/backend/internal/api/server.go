@@ -0,0 +1,490 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"simulation/internal/client"
+	"simulation/internal/fleet"
+	"simulation/internal/metrics"
+	"simulation/internal/scenarios"
+)
+
+// Server exposes the fleet and the saved scenarios to the UI
+type Server struct {
+	fleet     *fleet.Manager
+	scenarios *scenarios.Manager
+}
+
+func NewServer(fleetManager *fleet.Manager, scenarioManager *scenarios.Manager) *Server {
+	return &Server{
+		fleet:     fleetManager,
+		scenarios: scenarioManager,
+	}
+}
+
+// Handler returns the routes called by the UI's api.ts, with CORS for the Vite dev server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/clients", s.listClients)
+	mux.HandleFunc("GET /api/clients/{id}", s.getClient)
+	mux.HandleFunc("DELETE /api/clients/{id}", s.stopClient)
+	mux.HandleFunc("POST /api/clients/{id}/scenario", s.runScenario)
+	mux.HandleFunc("GET /api/clients/{id}/scenario", s.getScenarioResult)
+	mux.HandleFunc("POST /api/clients/{id}/inject-scenario", s.injectScenario)
+	mux.HandleFunc("GET /api/clients/{id}/faults", s.getClientFaults)
+	mux.HandleFunc("PUT /api/clients/{id}/faults", s.setClientFaults)
+	mux.HandleFunc("GET /api/clients/{id}/report", s.getClientReport)
+
+	mux.HandleFunc("POST /api/simulation/start", s.startSimulation)
+	mux.HandleFunc("POST /api/simulation/stop", s.stopSimulation)
+	mux.HandleFunc("GET /api/profiles", s.listProfiles)
+
+	mux.HandleFunc("GET /api/faults", s.getFleetFaults)
+	mux.HandleFunc("PUT /api/faults", s.setFleetFaults)
+	mux.HandleFunc("DELETE /api/faults/stats", s.resetFaultStats)
+
+	mux.HandleFunc("GET /api/report", s.getReport)
+	mux.HandleFunc("GET /api/report/timeline", s.getReportTimeline)
+	mux.HandleFunc("DELETE /api/report", s.resetReport)
+
+	mux.HandleFunc("GET /api/scenarios", s.listScenarios)
+	mux.HandleFunc("POST /api/scenarios", s.saveScenario)
+	mux.HandleFunc("GET /api/scenarios/{name}", s.loadScenario)
+	mux.HandleFunc("GET /api/scenarios/{name}/revisions", s.listRevisions)
+	mux.HandleFunc("GET /api/scenarios/{name}/revisions/{revision}", s.loadRevision)
+	mux.HandleFunc("GET /api/scenario-bundle", s.exportScenarios)
+	mux.HandleFunc("POST /api/scenario-bundle", s.importScenarios)
+
+	return withCORS(mux)
+}
+
+func (s *Server) listClients(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.fleet.GetAllClients())
+}
+
+func (s *Server) getClient(w http.ResponseWriter, r *http.Request) {
+	emu, ok := s.fleet.GetClient(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "client not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, emu)
+}
+
+func (s *Server) stopClient(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !s.fleet.StopClient(id) {
+		writeError(w, http.StatusNotFound, "client not found")
+		return
+	}
+	log.Printf("[API] Stopped client %s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runScenario applies the steps locally, as if the values had changed on the box
+func (s *Server) runScenario(w http.ResponseWriter, r *http.Request) {
+	emu, steps, ok := s.scenarioRequest(w, r, false)
+	if !ok {
+		return
+	}
+	emu.ExecuteScenario(steps)
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"status": "started", "steps": len(steps)})
+}
+
+// injectScenario queues the steps on the server, which hands them to the client as actions
+func (s *Server) injectScenario(w http.ResponseWriter, r *http.Request) {
+	emu, steps, ok := s.scenarioRequest(w, r, true)
+	if !ok {
+		return
+	}
+	emu.InjectScenario(steps)
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"status": "started", "steps": len(steps)})
+}
+
+// getScenarioResult returns the checks and status of the client's last scenario
+func (s *Server) getScenarioResult(w http.ResponseWriter, r *http.Request) {
+	emu, ok := s.fleet.GetClient(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "client not found")
+		return
+	}
+	result, ok := emu.ScenarioResult()
+	if !ok {
+		writeError(w, http.StatusNotFound, "no scenario has run on this client")
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// scenarioRequest resolves the client of a scenario request and decodes its steps
+// inject tells that the jobs of the steps are sent through the server's queue too
+func (s *Server) scenarioRequest(w http.ResponseWriter, r *http.Request, inject bool) (*client.Emulator, []client.ScenarioStep, bool) {
+	emu, ok := s.fleet.GetClient(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "client not found")
+		return nil, nil, false
+	}
+
+	var steps []client.ScenarioStep
+	if err := json.NewDecoder(r.Body).Decode(&steps); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid scenario: %v", err))
+		return nil, nil, false
+	}
+	if err := scenarios.ValidateSteps(steps); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, nil, false
+	}
+	if len(s.fleet.GetAllClients()) > 1 && client.ChecksInjectedOnClient(injectedSteps(steps, inject)) {
+		writeError(w, http.StatusConflict, errSharedQueue)
+		return nil, nil, false
+	}
+	return emu, steps, true
+}
+
+// injectedSteps returns the steps as the server sees them: when injecting, the jobs are injected too
+func injectedSteps(steps []client.ScenarioStep, inject bool) []client.ScenarioStep {
+	if !inject {
+		return steps
+	}
+	injected := make([]client.ScenarioStep, len(steps))
+	for i, step := range steps {
+		step.Inject = append(append([]client.ScenarioJob(nil), step.Jobs...), step.Inject...)
+		injected[i] = step
+	}
+	return injected
+}
+
+// errSharedQueue refuses client checks of injected values while several clients share the server's queue
+const errSharedQueue = "the scenario checks injected values on the client, but the server queues actions per tenant " +
+	"and another client may take them: run it with a single client, or check on the server"
+
+// faultsResponse is the fault profile of a client or of the fleet with its counters
+type faultsResponse struct {
+	Profile client.FaultProfile `json:"profile"`
+	Stats   client.FaultStats   `json:"stats"`
+}
+
+func (s *Server) getClientFaults(w http.ResponseWriter, r *http.Request) {
+	emu, ok := s.fleet.GetClient(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "client not found")
+		return
+	}
+	profile, stats := emu.Faults()
+	writeJSON(w, http.StatusOK, faultsResponse{Profile: profile, Stats: stats})
+}
+
+func (s *Server) setClientFaults(w http.ResponseWriter, r *http.Request) {
+	emu, ok := s.fleet.GetClient(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "client not found")
+		return
+	}
+	profile, ok := decodeFaultProfile(w, r)
+	if !ok {
+		return
+	}
+	emu.SetFaults(profile)
+	log.Printf("[API] Client %s fault profile set to %+v", emu.ID, profile)
+	_, stats := emu.Faults()
+	writeJSON(w, http.StatusOK, faultsResponse{Profile: profile, Stats: stats})
+}
+
+func (s *Server) getFleetFaults(w http.ResponseWriter, r *http.Request) {
+	profile, stats := s.fleet.Faults()
+	writeJSON(w, http.StatusOK, faultsResponse{Profile: profile, Stats: stats})
+}
+
+// setFleetFaults sets the fault profile of every client, and of those started later
+func (s *Server) setFleetFaults(w http.ResponseWriter, r *http.Request) {
+	profile, ok := decodeFaultProfile(w, r)
+	if !ok {
+		return
+	}
+	s.fleet.SetFaults(profile)
+	_, stats := s.fleet.Faults()
+	writeJSON(w, http.StatusOK, faultsResponse{Profile: profile, Stats: stats})
+}
+
+func (s *Server) resetFaultStats(w http.ResponseWriter, r *http.Request) {
+	s.fleet.ResetFaultStats()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeFaultProfile reads and validates a fault profile; an empty object clears all faults
+func decodeFaultProfile(w http.ResponseWriter, r *http.Request) (client.FaultProfile, bool) {
+	var profile client.FaultProfile
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&profile); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid fault profile: %v", err))
+		return profile, false
+	}
+	if err := profile.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid fault profile: %v", err))
+		return profile, false
+	}
+	return profile, true
+}
+
+func (s *Server) getReport(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, r, s.fleet.Report(), "report", metrics.Report.WriteCSV)
+}
+
+func (s *Server) getReportTimeline(w http.ResponseWriter, r *http.Request) {
+	report := s.fleet.Report()
+	if r.URL.Query().Get("format") != "csv" {
+		writeJSON(w, http.StatusOK, report.Timeline)
+		return
+	}
+	writeReport(w, r, report, "timeline", metrics.Report.WriteTimelineCSV)
+}
+
+func (s *Server) getClientReport(w http.ResponseWriter, r *http.Request) {
+	emu, ok := s.fleet.GetClient(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "client not found")
+		return
+	}
+	writeReport(w, r, emu.Metrics().Report(), emu.ID+"-report", metrics.Report.WriteCSV)
+}
+
+// resetReport forgets the requests of the fleet, to measure a new run
+func (s *Server) resetReport(w http.ResponseWriter, r *http.Request) {
+	s.fleet.ResetMetrics()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeReport answers with the report as JSON, or as a CSV download with ?format=csv
+func writeReport(w http.ResponseWriter, r *http.Request, report metrics.Report, name string, writeCSV func(metrics.Report, io.Writer) error) {
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		writeJSON(w, http.StatusOK, report)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".csv"))
+		if err := writeCSV(report, w); err != nil {
+			log.Printf("[API] Error writing %s: %v", name, err)
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "format must be json or csv")
+	}
+}
+
+func (s *Server) startSimulation(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	count, err := strconv.Atoi(query.Get("count"))
+	if err != nil || count < 1 || count > fleet.MaxClients {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("count must be between 1 and %d", fleet.MaxClients))
+		return
+	}
+
+	serverIP := query.Get("serverIP")
+	if serverIP == "" {
+		serverIP = "localhost"
+	}
+	serverPort := query.Get("serverPort")
+	if serverPort == "" {
+		serverPort = "80"
+	}
+	if port, err := strconv.Atoi(serverPort); err != nil || port < 1 || port > 65535 {
+		writeError(w, http.StatusBadRequest, "serverPort must be a port number")
+		return
+	}
+
+	mode, err := client.ParseMode(query.Get("mode"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	mix := fleet.DefaultProfileMix()
+	if profiles := query.Get("profiles"); profiles != "" {
+		if mix, err = fleet.ParseProfileMix(profiles); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	var startupScenario []client.ScenarioStep
+	if name := query.Get("startupScenario"); name != "" {
+		scenario, err := s.scenarios.LoadScenario(name)
+		if err != nil {
+			writeError(w, scenarioErrorStatus(err), fmt.Sprintf("cannot load scenario %q: %v", name, err))
+			return
+		}
+		startupScenario = scenario.Steps
+	}
+	if count+len(s.fleet.GetAllClients()) > 1 && client.ChecksInjectedOnClient(startupScenario) {
+		writeError(w, http.StatusConflict, errSharedQueue)
+		return
+	}
+
+	admin := client.AdminAccess{URL: query.Get("adminURL"), Token: query.Get("adminToken"), Client: query.Get("adminClient")}
+	if admin.URL != "" {
+		if u, err := url.Parse(admin.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			writeError(w, http.StatusBadRequest, "adminURL must be an http:// or https:// URL")
+			return
+		}
+	}
+
+	serverURL := fmt.Sprintf("http://%s:%s", serverIP, serverPort)
+	s.fleet.SetAdmin(admin)
+	s.fleet.StartRampUp(count, serverURL, mode, mix, startupScenario)
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"status": "started", "count": count, "server": serverURL, "mode": mode, "profiles": mix.String()})
+}
+
+// listProfiles returns the built-in device profiles a simulation can mix
+func (s *Server) listProfiles(w http.ResponseWriter, r *http.Request) {
+	profiles := make([]client.Profile, 0, len(client.Profiles))
+	for _, name := range client.ProfileNames() {
+		profiles = append(profiles, client.Profiles[name])
+	}
+	writeJSON(w, http.StatusOK, profiles)
+}
+
+func (s *Server) stopSimulation(w http.ResponseWriter, r *http.Request) {
+	s.fleet.StopAllClients()
+	writeJSON(w, http.StatusOK, map[string]string{"status": "stopped"})
+}
+
+func (s *Server) listScenarios(w http.ResponseWriter, r *http.Request) {
+	names, err := s.scenarios.ListScenarios()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if names == nil {
+		names = []string{}
+	}
+	writeJSON(w, http.StatusOK, names)
+}
+
+func (s *Server) saveScenario(w http.ResponseWriter, r *http.Request) {
+	var scenario scenarios.ScenarioWrapper
+	if err := json.NewDecoder(r.Body).Decode(&scenario); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid scenario: %v", err))
+		return
+	}
+
+	saved, err := s.scenarios.SaveScenario(scenario.Name, scenario.Steps)
+	if err != nil {
+		writeError(w, scenarioErrorStatus(err), err.Error())
+		return
+	}
+	log.Printf("[API] Saved scenario %s revision %d (%d steps)", saved.Name, saved.Revision, len(saved.Steps))
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"status": "saved", "name": saved.Name, "revision": saved.Revision})
+}
+
+func (s *Server) loadScenario(w http.ResponseWriter, r *http.Request) {
+	scenario, err := s.scenarios.LoadScenario(r.PathValue("name"))
+	if err != nil {
+		writeError(w, scenarioErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, scenario)
+}
+
+func (s *Server) listRevisions(w http.ResponseWriter, r *http.Request) {
+	revisions, err := s.scenarios.Revisions(r.PathValue("name"))
+	if err != nil {
+		writeError(w, scenarioErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, revisions)
+}
+
+func (s *Server) loadRevision(w http.ResponseWriter, r *http.Request) {
+	revision, err := strconv.Atoi(r.PathValue("revision"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "revision must be a number")
+		return
+	}
+	scenario, err := s.scenarios.LoadRevision(r.PathValue("name"), revision)
+	if err != nil {
+		writeError(w, scenarioErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, scenario)
+}
+
+// exportScenarios bundles the scenarios given by ?name= (repeatable), or all of them
+func (s *Server) exportScenarios(w http.ResponseWriter, r *http.Request) {
+	bundle, err := s.scenarios.Export(r.URL.Query()["name"])
+	if err != nil {
+		writeError(w, scenarioErrorStatus(err), err.Error())
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="scenarios.json"`)
+	writeJSON(w, http.StatusOK, bundle)
+}
+
+// importScenarios saves every scenario of a bundle as its next revision, or none if one is invalid
+func (s *Server) importScenarios(w http.ResponseWriter, r *http.Request) {
+	var bundle scenarios.Bundle
+	if err := json.NewDecoder(r.Body).Decode(&bundle); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid bundle: %v", err))
+		return
+	}
+
+	saved, err := s.scenarios.Import(bundle)
+	if err != nil {
+		writeError(w, scenarioErrorStatus(err), err.Error())
+		return
+	}
+	imported := make([]map[string]interface{}, 0, len(saved))
+	for _, scenario := range saved {
+		imported = append(imported, map[string]interface{}{"name": scenario.Name, "revision": scenario.Revision})
+	}
+	log.Printf("[API] Imported %d scenarios", len(saved))
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"status": "imported", "scenarios": imported})
+}
+
+// scenarioErrorStatus maps a scenario error to an HTTP status
+func scenarioErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, scenarios.ErrInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[API] Error encoding response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// withCORS lets the UI call the API from another origin (the Vite dev server)
+func withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
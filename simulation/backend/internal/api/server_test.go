@@ -0,0 +1,263 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"simulation/internal/client"
+	"simulation/internal/fleet"
+	"simulation/internal/scenarios"
+)
+
+// newTestServer returns the API handler over an empty fleet and a scenario directory of its own
+func newTestServer(t *testing.T) (http.Handler, *fleet.Manager, *scenarios.Manager) {
+	t.Helper()
+	fleetManager := fleet.NewManager()
+	scenarioManager := scenarios.NewManager(t.TempDir())
+	t.Cleanup(fleetManager.StopAllClients)
+	return NewServer(fleetManager, scenarioManager).Handler(), fleetManager, scenarioManager
+}
+
+// call sends a request to the handler and returns the recorded response
+func call(handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+// errorMessage decodes the {"error": ...} body of a refused request
+func errorMessage(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode error body: %v", err)
+	}
+	return body["error"]
+}
+
+// addClients adds stopped clients to the fleet, so requests can target them without a server
+func addClients(t *testing.T, fleetManager *fleet.Manager, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		if _, err := fleetManager.AddClient(id, "SERIAL", "http://127.0.0.1:1", client.ModeHTTP, client.Profiles["default"]); err != nil {
+			t.Fatalf("AddClient(%s) failed: %v", id, err)
+		}
+	}
+}
+
+func TestScenarioErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("name: %w", scenarios.ErrInvalid), http.StatusBadRequest},
+		{fmt.Errorf("scenario lights: %w", fs.ErrNotExist), http.StatusNotFound},
+		{errors.New("disk full"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := scenarioErrorStatus(tt.err); got != tt.want {
+			t.Errorf("scenarioErrorStatus(%v) = %d, expected %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	handler, fleetManager, scenarioManager := newTestServer(t)
+	addClients(t, fleetManager, "client-a")
+	if _, err := scenarioManager.SaveScenario("lights", []client.ScenarioStep{{Jobs: []client.ScenarioJob{{Index: 613, Value: "64"}}}}); err != nil {
+		t.Fatalf("SaveScenario failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"list clients", http.MethodGet, "/api/clients", "", http.StatusOK},
+		{"get client", http.MethodGet, "/api/clients/client-a", "", http.StatusOK},
+		{"unknown client", http.MethodGet, "/api/clients/client-z", "", http.StatusNotFound},
+		{"scenario on an unknown client", http.MethodPost, "/api/clients/client-z/scenario", `[]`, http.StatusNotFound},
+		{"no scenario has run", http.MethodGet, "/api/clients/client-a/scenario", "", http.StatusNotFound},
+		{"invalid scenario JSON", http.MethodPost, "/api/clients/client-a/scenario", `{`, http.StatusBadRequest},
+		{"invalid scenario step", http.MethodPost, "/api/clients/client-a/scenario", `[{"jobs":[{"index":999,"value":"1"}]}]`, http.StatusBadRequest},
+		{"load scenario", http.MethodGet, "/api/scenarios/lights", "", http.StatusOK},
+		{"unknown scenario", http.MethodGet, "/api/scenarios/evening", "", http.StatusNotFound},
+		{"invalid scenario name", http.MethodPost, "/api/scenarios", `{"name":"../x","steps":[{"jobs":[{"index":613,"value":"64"}]}]}`, http.StatusBadRequest},
+		{"revision not a number", http.MethodGet, "/api/scenarios/lights/revisions/first", "", http.StatusBadRequest},
+		{"unknown revision", http.MethodGet, "/api/scenarios/lights/revisions/9", "", http.StatusNotFound},
+		{"export an unknown scenario", http.MethodGet, "/api/scenario-bundle?name=evening", "", http.StatusNotFound},
+		{"report in another format", http.MethodGet, "/api/report?format=xml", "", http.StatusBadRequest},
+		{"wrong method", http.MethodPut, "/api/clients", "", http.StatusMethodNotAllowed},
+		{"stop client", http.MethodDelete, "/api/clients/client-a", "", http.StatusNoContent},
+		{"stop a stopped client", http.MethodDelete, "/api/clients/client-a", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w := call(handler, tt.method, tt.path, tt.body); w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d (%s)", tt.name, tt.status, w.Code, strings.TrimSpace(w.Body.String()))
+		}
+	}
+}
+
+func TestCORS(t *testing.T) {
+	handler, _, _ := newTestServer(t)
+
+	w := call(handler, http.MethodOptions, "/api/clients/client-a/faults", "")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected a preflight to answer 204, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(got, "PUT") || !strings.Contains(got, "DELETE") {
+		t.Errorf("Expected PUT and DELETE to be allowed, got %q", got)
+	}
+
+	// Errors carry the CORS headers too, so the UI can read them
+	w = call(handler, http.MethodGet, "/api/clients/client-z", "")
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected a JSON error with CORS headers, got %v", w.Header())
+	}
+	if msg := errorMessage(t, w); msg != "client not found" {
+		t.Errorf("Expected the error message, got %q", msg)
+	}
+}
+
+func TestScenarioRequest_SharedQueue(t *testing.T) {
+	checkAfterInject := `[{"inject":[{"index":590,"value":"1"}]},{"expect":[{"on":"client","index":590,"equals":"1"}]}]`
+	checkAfterJobs := `[{"jobs":[{"index":613,"value":"64"}],"expect":[{"on":"client","index":613,"equals":"64"}]}]`
+	checkOnServer := `[{"inject":[{"index":590,"value":"1"}],"wait":{"on":"server","index":590,"equals":"1","within":100}}]`
+
+	tests := []struct {
+		name    string
+		clients []string
+		path    string
+		body    string
+		status  int
+	}{
+		{"client check after inject, one client", []string{"client-a"}, "/api/clients/client-a/inject-scenario", checkAfterInject, http.StatusAccepted},
+		{"client check after inject, two clients", []string{"client-a", "client-b"}, "/api/clients/client-a/inject-scenario", checkAfterInject, http.StatusConflict},
+		{"client check after injected jobs, two clients", []string{"client-a", "client-b"}, "/api/clients/client-a/inject-scenario", checkAfterJobs, http.StatusConflict},
+		{"client check after local jobs, two clients", []string{"client-a", "client-b"}, "/api/clients/client-a/scenario", checkAfterJobs, http.StatusAccepted},
+		{"server check after inject, two clients", []string{"client-a", "client-b"}, "/api/clients/client-a/scenario", checkOnServer, http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		handler, fleetManager, _ := newTestServer(t)
+		addClients(t, fleetManager, tt.clients...)
+
+		w := call(handler, http.MethodPost, tt.path, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d (%s)", tt.name, tt.status, w.Code, strings.TrimSpace(w.Body.String()))
+			continue
+		}
+		if tt.status == http.StatusConflict {
+			if msg := errorMessage(t, w); msg != errSharedQueue {
+				t.Errorf("%s: expected the shared queue error, got %q", tt.name, msg)
+			}
+		}
+	}
+}
+
+func TestStartSimulation_SharedQueue(t *testing.T) {
+	handler, fleetManager, scenarioManager := newTestServer(t)
+	steps := []client.ScenarioStep{
+		{Inject: []client.ScenarioJob{{Index: 590, Value: "1"}}},
+		{Expect: []client.Condition{{On: client.OnClient, Index: 590, Equals: "1"}}},
+	}
+	if _, err := scenarioManager.SaveScenario("injected", steps); err != nil {
+		t.Fatalf("SaveScenario failed: %v", err)
+	}
+
+	w := call(handler, http.MethodPost, "/api/simulation/start?count=2&startupScenario=injected", "")
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for two clients, got %d", w.Code)
+	}
+	if w := call(handler, http.MethodPost, "/api/simulation/start?count=1&startupScenario=unknown", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown startup scenario, got %d", w.Code)
+	}
+	if clients := fleetManager.GetAllClients(); len(clients) != 0 {
+		t.Errorf("Expected no client started by refused requests, got %d", len(clients))
+	}
+}
+
+func TestStartSimulation_QueryValidation(t *testing.T) {
+	handler, fleetManager, _ := newTestServer(t)
+
+	for _, query := range []string{
+		"count=0",
+		"count=many",
+		fmt.Sprintf("count=%d", fleet.MaxClients+1),
+		"count=1&serverPort=http",
+		"count=1&serverPort=70000",
+		"count=1&mode=carrier-pigeon",
+		"count=1&profiles=castle:1",
+		"count=1&adminURL=" + url.QueryEscape("ftp://server/admin"),
+		"count=1&adminURL=" + url.QueryEscape("/api/admin"),
+	} {
+		if w := call(handler, http.MethodPost, "/api/simulation/start?"+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+	if clients := fleetManager.GetAllClients(); len(clients) != 0 {
+		t.Errorf("Expected no client started by invalid requests, got %d", len(clients))
+	}
+}
+
+func TestStartSimulation_AdminAccess(t *testing.T) {
+	// The box server answers every poll; the admin API runs on a listener of its own
+	box := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer box.Close()
+	injected := make(chan *http.Request, 4)
+	admin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		injected <- r
+		w.Write([]byte(`{"status":"ok","guid":"g"}`))
+	}))
+	defer admin.Close()
+
+	handler, fleetManager, _ := newTestServer(t)
+	boxURL, _ := url.Parse(box.URL)
+	query := url.Values{
+		"count":       {"1"},
+		"serverIP":    {boxURL.Hostname()},
+		"serverPort":  {boxURL.Port()},
+		"adminURL":    {admin.URL},
+		"adminToken":  {"tenant-token"},
+		"adminClient": {"box-7"},
+	}
+	if w := call(handler, http.MethodPost, "/api/simulation/start?"+query.Encode(), ""); w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d (%s)", w.Code, w.Body.String())
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(fleetManager.GetAllClients()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the simulation to start a client")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	id := fleetManager.GetAllClients()[0].ID
+
+	if w := call(handler, http.MethodPost, "/api/clients/"+id+"/inject-scenario", `[{"jobs":[{"index":613,"value":"64"}]}]`); w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d (%s)", w.Code, w.Body.String())
+	}
+	select {
+	case r := <-injected:
+		if r.URL.Path != "/api/admin/inject" || r.URL.Query().Get("client") != "box-7" {
+			t.Errorf("Expected an inject for box-7, got %s", r.URL)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer tenant-token" {
+			t.Errorf("Expected the admin token, got %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the scenario to be injected through the admin URL")
+	}
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pendingResponse is a Response of the profile waiting for its delay
+type pendingResponse struct {
+	at  time.Time
+	set map[int]string
+}
+
+// respond schedules the responses of the profile to a command the server wrote to index
+// A command writing 0 is the rest of a complete light and shutter block: nothing moves for it
+// e.mu must be held
+func (e *Emulator) respond(index int) {
+	command := int(e.table.Get(index))
+	if command == 0 {
+		return
+	}
+	for _, response := range e.Profile.Responses {
+		if response.Index == index && (response.Bits == 0 || command&response.Bits != 0) {
+			e.responses[index] = pendingResponse{
+				at:  time.Now().Add(time.Duration(response.After) * time.Millisecond),
+				set: response.Set,
+			}
+		}
+	}
+}
+
+// simulateDevice applies what the device did on its own since the last cycle: the drifts due and
+// the responses whose delay is over
+// It runs at the start of each cycle, so values change at most every poll interval, before
+// mystatus reports them
+func (e *Emulator) simulateDevice(now time.Time) {
+	e.mu.Lock()
+	var changes []string
+	for i, drift := range e.Profile.Drifts {
+		if now.Before(e.nextDrift[i]) {
+			continue
+		}
+		value := int(e.table.Get(drift.Index)) + (e.rng.Intn(3)-1)*drift.Step
+		if value < drift.Min {
+			value = drift.Min
+		}
+		if value > drift.Max {
+			value = drift.Max
+		}
+		e.table.Set(drift.Index, strconv.Itoa(value))
+		e.nextDrift[i] = now.Add(time.Duration(drift.Every) * time.Millisecond)
+	}
+
+	var due []int
+	for index, response := range e.responses {
+		if !now.Before(response.at) {
+			due = append(due, index)
+		}
+	}
+	sort.Ints(due)
+	for _, index := range due {
+		for k, v := range e.responses[index].set {
+			e.table.Set(k, v)
+			changes = append(changes, fmt.Sprintf("[%d]=%s", k, v))
+		}
+		delete(e.responses, index)
+	}
+	e.mu.Unlock()
+
+	// Drifts are too frequent for the history; responses are the outcome of a command
+	if len(changes) > 0 {
+		e.logHistory(fmt.Sprintf("Device responded: Set %s", strings.Join(changes, " ")))
+	}
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
+)
+
+// newProfileEmulator returns an emulator of the profile with a fixed random source
+func newProfileEmulator(t *testing.T, profile Profile) *Emulator {
+	t.Helper()
+	e, err := NewEmulator("test", "SERIAL", "http://127.0.0.1:1", ModeHTTP, profile)
+	if err != nil {
+		t.Fatalf("NewEmulator failed: %v", err)
+	}
+	e.rng = rand.New(rand.NewSource(1))
+	return e
+}
+
+// block is a complete light and shutter block with the given commands, as the server sends it
+func block(commands map[int]string) []protocol.ExchangeKV {
+	var params []protocol.ExchangeKV
+	for index := protocol.IndexLightStart; index <= protocol.IndexLightEnd; index++ {
+		value := "0"
+		if v, ok := commands[index]; ok {
+			value = v
+		}
+		params = append(params, protocol.ExchangeKV{K: index, V: value})
+	}
+	return params
+}
+
+func TestSimulateDevice_FlatLights(t *testing.T) {
+	tests := []struct {
+		name    string
+		command map[int]string
+		cleared bool
+	}{
+		{"light 1 on", map[int]string{611: "1"}, true},
+		{"light 4 off", map[int]string{605: "8"}, true},
+		{"wired and unwired lights", map[int]string{611: "129"}, true},
+		{"unwired light", map[int]string{611: "64"}, false},
+		{"other block", map[int]string{613: "1"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newProfileEmulator(t, Profiles["flat"])
+			start := time.Now()
+			e.applyParams("guid", block(tt.command))
+
+			e.simulateDevice(start.Add(LightSwitch - time.Millisecond))
+			for index, value := range tt.command {
+				if got := e.table.Report(index); got != value {
+					t.Errorf("Expected [%d]=%s before the lights switched, got %s", index, value, got)
+				}
+			}
+
+			e.simulateDevice(start.Add(LightSwitch + time.Second))
+			for index, value := range tt.command {
+				want := value
+				if tt.cleared {
+					want = "0"
+				}
+				if got := e.table.Report(index); got != want {
+					t.Errorf("Expected [%d]=%s once the lights switched, got %s", index, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSimulateDevice_ShutterRestartsOnNewCommand(t *testing.T) {
+	e := newProfileEmulator(t, Profiles["house"])
+	e.applyParams("first", block(map[int]string{617: "1"}))
+	firstDone := e.responses[617].at
+
+	// A second command while the shutter moves starts the travel again
+	time.Sleep(10 * time.Millisecond)
+	e.applyParams("second", block(map[int]string{617: "2"}))
+	secondDone := e.responses[617].at
+	if !secondDone.After(firstDone) {
+		t.Fatalf("Expected the second command to restart the travel, done at %v then %v", firstDone, secondDone)
+	}
+
+	e.simulateDevice(firstDone)
+	if e.table.Get(617) != 2 {
+		t.Errorf("Expected the second command to be pending when the first travel ends, got %d", e.table.Get(617))
+	}
+	e.simulateDevice(secondDone)
+	if e.table.Get(617) != 0 {
+		t.Errorf("Expected the shutter command cleared after its travel, got %d", e.table.Get(617))
+	}
+}
+
+func TestSimulateDevice_DriftStaysWithinBounds(t *testing.T) {
+	profile := Profile{
+		Name:   "drifting",
+		Seed:   map[int]string{425: "11"},
+		Drifts: []Drift{{Index: 425, Min: 10, Max: 12, Step: 1, Every: 1000}},
+	}
+	e := newProfileEmulator(t, profile)
+	start := time.Now()
+
+	// Nothing moves before the first period is over
+	e.simulateDevice(start)
+	if got := e.table.Get(425); got != 11 {
+		t.Errorf("Expected no drift before %dms, got %d", profile.Drifts[0].Every, got)
+	}
+
+	seen := make(map[byte]bool)
+	for i := 1; i <= 100; i++ {
+		e.simulateDevice(start.Add(time.Duration(i) * 1100 * time.Millisecond))
+		value := e.table.Get(425)
+		if value < 10 || value > 12 {
+			t.Fatalf("Cycle %d: drifted to %d, out of 10-12", i, value)
+		}
+		seen[value] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected the drift to reach every value of 10-12, got %v", seen)
+	}
+}
+
+func TestSimulateDevice_DriftIsReproducible(t *testing.T) {
+	profile := Profile{Name: "drifting", Seed: map[int]string{425: "100"}, Drifts: []Drift{{Index: 425, Min: 0, Max: 255, Step: 5, Every: 1}}}
+	a, b := newProfileEmulator(t, profile), newProfileEmulator(t, profile)
+	start := time.Now()
+	for i := 1; i <= 20; i++ {
+		now := start.Add(time.Duration(i) * time.Second)
+		a.simulateDevice(now)
+		b.simulateDevice(now)
+		if a.table.Get(425) != b.table.Get(425) {
+			t.Fatalf("Cycle %d: the same random source gave %d and %d", i, a.table.Get(425), b.table.Get(425))
+		}
+	}
+}
+
+func TestProfiles_HeatingModes(t *testing.T) {
+	for _, name := range ProfileNames() {
+		profile := Profiles[name]
+		if err := profile.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if len(profile.Drifts) > 0 {
+			t.Errorf("%s: expected no drift, the exchange table holds no documented measurement", name)
+		}
+		for index := 349; index <= 352; index++ {
+			switch profile.Seed[index] {
+			case HeatingAuto, HeatingOff, HeatingComfort, HeatingEco, HeatingFrostFree:
+			default:
+				t.Errorf("%s: [%d]=%q is not a heating mode", name, index, profile.Seed[index])
+			}
+		}
+		if mode := profile.Seed[353]; mode != WaterHeaterAuto && mode != WaterHeaterOff {
+			t.Errorf("%s: [353]=%q is not a water heater mode", name, mode)
+		}
+	}
+}
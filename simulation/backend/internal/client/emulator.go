@@ -1,15 +1,50 @@
 package client
 
 import (
+	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
+
+	"simulation/internal/metrics"
+)
+
+const (
+	// PollIntervalConnected is the firmware's cycle period while a user is connected to the server
+	PollIntervalConnected = 500 * time.Millisecond
+	// PollIntervalIdle is the firmware's cycle period otherwise
+	PollIntervalIdle = 2 * time.Second
+
+	// MaxTargetIndices is how many serverinfos indices the firmware keeps
+	MaxTargetIndices = 30
+
+	// firmwareVersion is the us_BP_VERSION_SERVEUR reported in /api/mystatus
+	firmwareVersion = 125
+)
+
+// Phase is the step of the polling cycle an emulator is in
+type Phase string
+
+const (
+	PhaseIdle        Phase = "idle"
+	PhaseServerInfos Phase = "serverinfos"
+	PhaseStatus      Phase = "mystatus"
+	PhaseActions     Phase = "myactions"
+	// PhaseFailed means the last cycle stopped on an error, like the firmware's "Fin Cycle (Erreur)"
+	PhaseFailed Phase = "failed"
 )
 
 // Emulator simulates a single BP_MQX_ETH client.
@@ -18,153 +53,509 @@ type Emulator struct {
 	Serial        string // Serial Number (Source for Matricule)
 	Matricule     string // Generated Auth Token (Base64(MD5(Serial)))
 	ServerURL     string
-	TargetIndices []int // Indices to monitor (from serverinfos)
+	Mode          Mode    // How requests are sent (well-formed HTTP or firmware bytes)
+	Profile       Profile // Device the emulator stands for
+	TargetIndices []int   // Indices to monitor (from serverinfos)
 
 	// State
-	mu      sync.RWMutex
-	Values  map[int]string // Current values of the exchange table
-	History []string       // Log of last 20 events/values
-	Active  bool
-	Client  *http.Client
+	mu             sync.RWMutex
+	table          *ExchangeTable // Current values of the exchange table
+	IsConnected    bool           // A user is connected (from serverinfos): poll faster
+	OfferedVersion string         // Firmware version offered by serverinfos, "" for none
+	Phase          Phase
+	History        []string // Log of last 20 events/values
+	Active         bool
+	Client         *http.Client
+
+	// Fault injection
+	faults      FaultProfile
+	faultStats  FaultStats
+	rng         *rand.Rand
+	pendingAcks []string // done acks held back by FaultReorderAcks
+
+	// metrics records the latency and outcome of every request
+	metrics *metrics.Stats
+
+	// scenario is the result of the last scenario run, nil if none
+	scenario *ScenarioResult
+	// admin is how inject steps and server conditions reach the admin API
+	admin AdminAccess
+
+	// Device behaviour of the profile: when each drift moves next, and the responses waiting
+	// for their delay, by the index of their command
+	nextDrift []time.Time
+	responses map[int]pendingResponse
+
+	// stop is closed by Stop; done is closed once the polling loop has returned
+	stop chan struct{}
+	done chan struct{}
 }
 
-func NewEmulator(id, serial, serverURL string) *Emulator {
+func NewEmulator(id, serial, serverURL string, mode Mode, profile Profile) (*Emulator, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	table, _ := NewExchangeTable(profile.Seed)
+
 	e := &Emulator{
 		ID:        id,
 		Serial:    serial,
 		ServerURL: serverURL,
-		Values:    make(map[int]string),
+		Mode:      mode,
+		Profile:   profile,
+		table:     table,
+		Phase:     PhaseIdle,
 		History:   make([]string, 0),
 		Client: &http.Client{
 			Timeout: 2 * time.Second,
+			Transport: &http.Transport{
+				// One connection per request, like the firmware, so that each one can get its faults
+				DisableKeepAlives: true,
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					faults, _ := ctx.Value(connFaultsKey{}).(connFaults)
+					return dialWithFaults(ctx, addr, faults)
+				},
+			},
 		},
+		faultStats: make(FaultStats),
+		metrics:    metrics.NewStats(),
+		rng:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		responses:  make(map[int]pendingResponse),
+	}
+	now := time.Now()
+	for _, drift := range profile.Drifts {
+		e.nextDrift = append(e.nextDrift, now.Add(time.Duration(drift.Every)*time.Millisecond))
 	}
 	e.Matricule = e.GenerateAuth()
-	return e
+	return e, nil
 }
 
 func (e *Emulator) Start() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.Active {
+		return
+	}
 	e.Active = true
-	go e.loop()
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+	go e.loop(e.stop, e.done)
 }
 
+// Stop ends the polling loop and waits for the request in progress, if any, to finish
 func (e *Emulator) Stop() {
+	e.mu.Lock()
+	if !e.Active {
+		e.mu.Unlock()
+		return
+	}
 	e.Active = false
+	close(e.stop)
+	done := e.done
+	e.mu.Unlock()
+
+	<-done
 }
 
-func (e *Emulator) loop() {
-	// Initial connection: Get Server Infos
-	e.getServerInfos()
+func (e *Emulator) loop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
 
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
+	for {
+		e.cycle()
 
-	for e.Active {
+		// The period depends on the last isconnected the server sent
+		timer := time.NewTimer(e.pollInterval())
 		select {
-		case <-ticker.C:
-			e.poll()
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
 		}
 	}
 }
 
-func (e *Emulator) poll() {
-	// 1. Send Status (POST /api/mystatus)
-	e.postMyStatus()
-
-	// 2. Get Actions (GET /api/myactions)
-	e.getMyActions()
+// stopped returns a channel closed when the emulator is stopped, nil if it never started
+func (e *Emulator) stopped() <-chan struct{} {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.stop
 }
 
-func (e *Emulator) getServerInfos() {
-	msg := "GET /api/serverinfos"
-	req, _ := http.NewRequest("GET", e.ServerURL+"/api/serverinfos", nil)
-	req.Header.Set("Connection", "close")
+// cycle runs one dialogue with the server, like the firmware's sc_DialogueAvecServeur:
+// serverinfos, then mystatus, then myactions and the done acks; a failed step ends the cycle
+func (e *Emulator) cycle() {
+	e.simulateDevice(time.Now())
 
-	resp, err := e.Client.Do(req)
-	if err != nil {
-		e.logHistory(fmt.Sprintf("Error %s: %v", msg, err))
+	e.setPhase(PhaseServerInfos)
+	if !e.getServerInfos() {
+		e.setPhase(PhaseFailed)
 		return
 	}
-	defer resp.Body.Close()
-	// e.logHistory(fmt.Sprintf("Success %s: %d", msg, resp.StatusCode))
+
+	// If mystatus fails, myactions is never called
+	e.setPhase(PhaseStatus)
+	if !e.postMyStatus() {
+		e.setPhase(PhaseFailed)
+		return
+	}
+
+	e.setPhase(PhaseActions)
+	if !e.getMyActions() {
+		e.setPhase(PhaseFailed)
+		return
+	}
+	e.setPhase(PhaseIdle)
+}
+
+func (e *Emulator) setPhase(phase Phase) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.Phase = phase
+}
+
+// pollInterval returns the cycle period: faster while a user is connected
+func (e *Emulator) pollInterval() time.Duration {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	interval := PollIntervalIdle
+	if e.IsConnected {
+		interval = PollIntervalConnected
+	}
+	// A skewed clock shows as a drifting period: the protocol carries no timestamps
+	return time.Duration(float64(interval) * (1 + e.faults.ClockSkew))
+}
+
+// SetFaults replaces the fault profile; the counters are kept
+func (e *Emulator) SetFaults(profile FaultProfile) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.faults = profile
+}
+
+// Faults returns the fault profile and a copy of the fault counters
+func (e *Emulator) Faults() (FaultProfile, FaultStats) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	stats := make(FaultStats)
+	stats.Merge(e.faultStats)
+	return e.faults, stats
 }
 
-func (e *Emulator) postMyStatus() {
-	// Construct the bad JSON manually
-	var ekParts []string
+// ResetFaultStats sets the fault counters back to zero
+func (e *Emulator) ResetFaultStats() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.faultStats = make(FaultStats)
+}
 
+// Metrics returns a copy of the latency and outcome records
+func (e *Emulator) Metrics() *metrics.Stats {
 	e.mu.RLock()
-	for k, v := range e.Values {
-		ekParts = append(ekParts, fmt.Sprintf("{k:%d,v:\"%s\"}", k, v))
+	defer e.mu.RUnlock()
+	return e.metrics.Clone()
+}
+
+// ResetMetrics forgets the requests recorded so far
+func (e *Emulator) ResetMetrics() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.metrics = metrics.NewStats()
+}
+
+// drawFaults draws the faults of one request
+func (e *Emulator) drawFaults(method string) (connFaults, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	duplicate := method == http.MethodPost && hit(e.rng, e.faults.DuplicateRate)
+	return e.faults.drawConnFaults(e.rng), duplicate
+}
+
+// recordFaults counts the outcome of a request for each fault injected into it
+func (e *Emulator) recordFaults(faults []Fault, status int, err error) {
+	if len(faults) == 0 {
+		return
+	}
+	result := outcome(status, err)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, fault := range faults {
+		e.faultStats.record(fault, result)
 	}
-	e.mu.RUnlock()
+}
 
-	if len(ekParts) == 0 {
-		ekParts = append(ekParts, "{k:605,v:\"0\"}")
+// exchange sends one firmware request, with the faults drawn for it, and returns the status and
+// the body of the response
+func (e *Emulator) exchange(method, path string, body []byte) (int, []byte, error) {
+	faults, duplicate := e.drawFaults(method)
+	if len(faults.faults()) > 0 {
+		e.logHistory(fmt.Sprintf("Injecting %v into %s %s", faults.faults(), method, path))
 	}
 
-	bodyStr := fmt.Sprintf(`{version:"1.0",ek:[%s]}`, strings.Join(ekParts, ","))
+	status, respBody, err := e.timedSend(method, path, body, faults)
+	switch {
+	case faults.dns:
+		e.recordFaults([]Fault{FaultDNS}, 0, err)
+	case faults.drop:
+		// Nothing comes back from a dropped request: the server's reaction is only on its side
+		e.recordFaults(faults.faults(), 0, errDropped)
+	default:
+		e.recordFaults(faults.faults(), status, err)
+	}
 
-	req, _ := http.NewRequest("POST", e.ServerURL+"/api/mystatus", strings.NewReader(bodyStr))
-	req.Header.Set("Content-Type", "application/json")
+	if duplicate {
+		e.logHistory(fmt.Sprintf("Injecting duplicate %s %s", method, path))
+		dupStatus, _, dupErr := e.timedSend(method, path, body, connFaults{})
+		e.recordFaults([]Fault{FaultDuplicate}, dupStatus, dupErr)
+	}
+	return status, respBody, err
+}
+
+// timedSend sends one request and records its latency and outcome
+func (e *Emulator) timedSend(method, path string, body []byte, faults connFaults) (int, []byte, error) {
+	start := time.Now()
+	status, respBody, err := e.send(method, path, body, faults)
+	end := time.Now()
+
+	e.mu.Lock()
+	e.metrics.Record(endpointName(method, path), end, end.Sub(start), outcome(status, err))
+	e.mu.Unlock()
+	return status, respBody, err
+}
+
+// endpointName groups the requests of an endpoint, whatever the GUID of a done ack
+func endpointName(method, path string) string {
+	if strings.HasPrefix(path, "/api/done/") {
+		path = "/api/done/{guid}"
+	}
+	return method + " " + path
+}
+
+// send writes one request and reads its response
+func (e *Emulator) send(method, path string, body []byte, faults connFaults) (int, []byte, error) {
+	if e.Mode == ModeFirmware {
+		return firmwareExchange(e.ServerURL, firmwareRequest(method, path, e.Matricule, body), faults)
+	}
+
+	ctx := context.WithValue(context.Background(), connFaultsKey{}, faults)
+	req, _ := http.NewRequestWithContext(ctx, method, e.ServerURL+path, bytes.NewReader(body))
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/json")
+	}
 	req.Header.Set("Connection", "close")
 	req.Header.Set("Authorization", "Basic "+e.Matricule)
 
 	resp, err := e.Client.Do(req)
 	if err != nil {
-		e.logHistory(fmt.Sprintf("Error POST /api/mystatus: %v", err))
-		return
+		return 0, nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 201 {
-		e.logHistory(fmt.Sprintf("Warning POST /api/mystatus: Status %d", resp.StatusCode))
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
 	}
+	return resp.StatusCode, respBody, nil
 }
 
-func (e *Emulator) getMyActions() {
-	req, _ := http.NewRequest("GET", e.ServerURL+"/api/myactions", nil)
-	req.Header.Set("Connection", "close")
-	req.Header.Set("Authorization", "Basic "+e.Matricule)
+// getServerInfos reads which indices to report, whether a user is connected and whether a
+// firmware update is offered
+func (e *Emulator) getServerInfos() bool {
+	status, body, err := e.exchange("GET", "/api/serverinfos", nil)
+	if err != nil {
+		e.logHistory(fmt.Sprintf("Error GET /api/serverinfos: %v", err))
+		return false
+	}
+	if status != 200 {
+		e.logHistory(fmt.Sprintf("Warning GET /api/serverinfos: Status %d", status))
+		return false
+	}
 
-	resp, err := e.Client.Do(req)
+	var infos protocol.ServerInfoResponse
+	if err := json.Unmarshal(body, &infos); err != nil {
+		e.logHistory(fmt.Sprintf("Error decoding serverinfos: %v", err))
+		return false
+	}
+
+	// The firmware keeps at most 30 indices
+	targets := infos.Infos
+	if len(targets) > MaxTargetIndices {
+		targets = targets[:MaxTargetIndices]
+	}
+	offered := ""
+	if infos.NewVersion != "" && infos.NewVersion != "no" {
+		offered = infos.NewVersion
+	}
+
+	e.mu.Lock()
+	connectedChanged := e.IsConnected != infos.IsConnected
+	versionChanged := e.OfferedVersion != offered
+	e.TargetIndices = targets
+	e.IsConnected = infos.IsConnected
+	e.OfferedVersion = offered
+	e.mu.Unlock()
+
+	if connectedChanged {
+		if infos.IsConnected {
+			e.logHistory(fmt.Sprintf("User connected: polling every %v", PollIntervalConnected))
+		} else {
+			e.logHistory(fmt.Sprintf("No user connected: polling every %v", PollIntervalIdle))
+		}
+	}
+	if versionChanged && offered != "" {
+		// The emulator does not download firmware; the offer is only recorded
+		e.logHistory(fmt.Sprintf("Firmware update %s offered", offered))
+	}
+	return true
+}
+
+// statusBody builds the /api/mystatus body like sc_JsonPostServerInformation: the version is
+// quoted, k and v are not, and only the indices requested by serverinfos are reported
+func (e *Emulator) statusBody() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ekParts := make([]string, 0, len(e.TargetIndices))
+	for _, index := range e.TargetIndices {
+		ekParts = append(ekParts, fmt.Sprintf("{k:%d,v:\"%s\"}", index, e.table.Report(index)))
+	}
+	return fmt.Sprintf(`{"version":"V%d","ek":[%s]}`, firmwareVersion, strings.Join(ekParts, ","))
+}
+
+func (e *Emulator) postMyStatus() bool {
+	status, _, err := e.exchange("POST", "/api/mystatus", []byte(e.statusBody()))
+	if err != nil {
+		e.logHistory(fmt.Sprintf("Error POST /api/mystatus: %v", err))
+		return false
+	}
+
+	if status != 201 {
+		e.logHistory(fmt.Sprintf("Warning POST /api/mystatus: Status %d", status))
+		return false
+	}
+	return true
+}
+
+func (e *Emulator) getMyActions() bool {
+	status, body, err := e.exchange("GET", "/api/myactions", nil)
 	if err != nil {
 		e.logHistory(fmt.Sprintf("Error GET /api/myactions: %v", err))
-		return
+		return false
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		e.logHistory(fmt.Sprintf("Warning GET /api/myactions: Status %d", resp.StatusCode))
-		return
+	if status != 200 {
+		e.logHistory(fmt.Sprintf("Warning GET /api/myactions: Status %d", status))
+		return false
 	}
 
-	// Parse Actions
-	var response struct {
-		Actions []struct {
-			Index int    `json:"index"`
-			Value string `json:"value"`
-		} `json:"actions"`
+	// The firmware's strstr parser only finds the actions when _de67f comes first
+	if e.Mode == ModeFirmware && !bytes.HasPrefix(body, []byte(`{"_de67f"`)) {
+		e.logHistory("Error GET /api/myactions: _de67f is not the first field")
+		return false
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+	// The server sends {"_de67f":..., "actions":[{guid, params:[{k,v}]}]}
+	var response protocol.ActionsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
 		e.logHistory(fmt.Sprintf("Error decoding actions: %v", err))
-		return
+		return false
+	}
+
+	var acks []string
+
+	// The alarm command is AES encrypted with the box key, which the emulator does not have:
+	// it is only acknowledged, so that the server does not send it again
+	if response.De67f != nil && response.De67f.GUID != "" {
+		e.logHistory(fmt.Sprintf("ALARM COMMAND RECEIVED: %s (not decrypted)", response.De67f.GUID))
+		acks = append(acks, response.De67f.GUID)
+	}
+
+	for _, action := range response.Actions {
+		e.applyParams(action.GUID, action.Params)
+		acks = append(acks, action.GUID)
 	}
 
-	if len(response.Actions) > 0 {
-		e.mu.Lock()
-		for _, action := range response.Actions {
-			e.Values[action.Index] = action.Value
-			e.logHistory(fmt.Sprintf("ACTION RECEIVED: Set [%d]=%s", action.Index, action.Value))
+	e.sendAcks(acks)
+	return true
+}
+
+// sendAcks acknowledges the GUIDs of a response, after those held back from the previous one
+// FaultReorderAcks holds a response's acks back until the next cycle and sends them in reverse
+func (e *Emulator) sendAcks(acks []string) {
+	e.mu.Lock()
+	held := e.pendingAcks
+	e.pendingAcks = nil
+	reorder := len(acks) > 0 && hit(e.rng, e.faults.ReorderAcksRate)
+	if reorder {
+		for i := len(acks) - 1; i >= 0; i-- {
+			e.pendingAcks = append(e.pendingAcks, acks[i])
 		}
-		e.mu.Unlock()
+	}
+	e.mu.Unlock()
+
+	if reorder {
+		e.logHistory(fmt.Sprintf("Injecting reorder_acks: holding %d acks until the next cycle", len(acks)))
+	} else {
+		for _, guid := range acks {
+			e.acknowledge(guid)
+		}
+	}
 
-		// Acknowledge? The legacy protocol usually has a separate ACK mechanism or just assumes done.
-		// The current backend dequeue implementation assumes it is sent and done.
+	// Acks held back are sent late, once the server has moved on
+	for _, guid := range held {
+		status, err := e.acknowledge(guid)
+		e.recordFaults([]Fault{FaultReorderAcks}, status, err)
 	}
 }
 
+// applyParams writes the params of an action into the exchange table the way the firmware does:
+// in the order received, except the scenario index which is applied last so that it triggers on
+// the complete block
+func (e *Emulator) applyParams(guid string, params []protocol.ExchangeKV) {
+	ordered := make([]protocol.ExchangeKV, 0, len(params))
+	var scenarios []protocol.ExchangeKV
+	for _, kv := range params {
+		if kv.K == protocol.IndexScenario {
+			scenarios = append(scenarios, kv)
+			continue
+		}
+		ordered = append(ordered, kv)
+	}
+	ordered = append(ordered, scenarios...)
+
+	changes := make([]string, 0, len(ordered))
+	var rejected []string
+	e.mu.Lock()
+	for _, kv := range ordered {
+		if err := e.table.Set(kv.K, kv.V); err != nil {
+			rejected = append(rejected, err.Error())
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("[%d]=%s", kv.K, kv.V))
+		e.respond(kv.K)
+	}
+	e.mu.Unlock()
+
+	e.logHistory(fmt.Sprintf("ACTION RECEIVED %s: Set %s", guid, strings.Join(changes, " ")))
+	for _, reason := range rejected {
+		e.logHistory(fmt.Sprintf("Ignored param of %s: %s", guid, reason))
+	}
+}
+
+// acknowledge tells the server an action was executed (POST /api/done/{guid}), which removes it
+// from the queue; an action that is not acknowledged is sent again on the next poll
+func (e *Emulator) acknowledge(guid string) (int, error) {
+	status, _, err := e.exchange("POST", "/api/done/"+guid, nil)
+	if err != nil {
+		e.logHistory(fmt.Sprintf("Error POST /api/done/%s: %v", guid, err))
+		return 0, err
+	}
+
+	if status != 201 {
+		e.logHistory(fmt.Sprintf("Warning POST /api/done/%s: Status %d", guid, status))
+	}
+	return status, nil
+}
+
 func (e *Emulator) logHistory(msg string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
@@ -182,23 +573,39 @@ func (e *Emulator) MarshalJSON() ([]byte, error) {
 
 	// Explicitly list fields to marshal to avoid any reflection issues with http.Client
 	return json.Marshal(&struct {
-		ID            string         `json:"ID"`
-		Serial        string         `json:"Serial"`
-		Matricule     string         `json:"Matricule"`
-		ServerURL     string         `json:"ServerURL"`
-		TargetIndices []int          `json:"TargetIndices"`
-		Values        map[int]string `json:"Values"`
-		History       []string       `json:"History"`
-		Active        bool           `json:"Active"`
+		ID             string          `json:"ID"`
+		Serial         string          `json:"Serial"`
+		Matricule      string          `json:"Matricule"`
+		ServerURL      string          `json:"ServerURL"`
+		Mode           Mode            `json:"Mode"`
+		Profile        string          `json:"Profile"`
+		TargetIndices  []int           `json:"TargetIndices"`
+		Values         map[int]string  `json:"Values"`
+		IsConnected    bool            `json:"IsConnected"`
+		OfferedVersion string          `json:"OfferedVersion"`
+		Phase          Phase           `json:"Phase"`
+		Faults         FaultProfile    `json:"Faults"`
+		FaultStats     FaultStats      `json:"FaultStats"`
+		Scenario       *ScenarioResult `json:"Scenario"`
+		History        []string        `json:"History"`
+		Active         bool            `json:"Active"`
 	}{
-		ID:            e.ID,
-		Serial:        e.Serial,
-		Matricule:     e.Matricule,
-		ServerURL:     e.ServerURL,
-		TargetIndices: e.TargetIndices,
-		Values:        e.Values,
-		History:       e.History,
-		Active:        e.Active,
+		ID:             e.ID,
+		Serial:         e.Serial,
+		Matricule:      e.Matricule,
+		ServerURL:      e.ServerURL,
+		Mode:           e.Mode,
+		Profile:        e.Profile.Name,
+		TargetIndices:  e.TargetIndices,
+		Values:         e.table.Snapshot(),
+		IsConnected:    e.IsConnected,
+		OfferedVersion: e.OfferedVersion,
+		Phase:          e.Phase,
+		Faults:         e.faults,
+		FaultStats:     e.faultStats,
+		Scenario:       e.scenario,
+		History:        e.History,
+		Active:         e.Active,
 	})
 }
 
@@ -209,35 +616,163 @@ type ScenarioJob struct {
 }
 
 // ScenarioStep defines a group of actions with a delay
+// A step runs in this order: Faults replaces the client's fault profile, Jobs are applied,
+// Inject is queued on the server, Wait must hold before its timeout or the scenario fails,
+// Expect is checked, then the step waits Delay milliseconds
 type ScenarioStep struct {
-	Jobs  []ScenarioJob `json:"jobs"`
-	Delay int           `json:"delay"`
+	Jobs   []ScenarioJob `json:"jobs"`
+	Delay  int           `json:"delay"`
+	Faults *FaultProfile `json:"faults,omitempty"`
+	// Inject is queued through /api/admin/inject, whether the scenario runs locally or not
+	Inject []ScenarioJob `json:"inject,omitempty"`
+	Wait   *Condition    `json:"wait,omitempty"`
+	Expect []Condition   `json:"expect,omitempty"`
+}
+
+// ValidateSteps checks the parts of scenario steps that cannot be applied as they are
+func ValidateSteps(steps []ScenarioStep) error {
+	for i, step := range steps {
+		if step.Delay < 0 {
+			return fmt.Errorf("step %d: delay must not be negative", i+1)
+		}
+		if step.Faults != nil {
+			if err := step.Faults.Validate(); err != nil {
+				return fmt.Errorf("step %d: %w", i+1, err)
+			}
+		}
+		if step.Wait != nil {
+			if err := step.Wait.validate(); err != nil {
+				return fmt.Errorf("step %d: wait: %w", i+1, err)
+			}
+			if step.Wait.Within == 0 {
+				return fmt.Errorf("step %d: wait needs a within timeout", i+1)
+			}
+		}
+		for _, condition := range step.Expect {
+			if err := condition.validate(); err != nil {
+				return fmt.Errorf("step %d: expect: %w", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyStepFaults switches to the fault profile of a step, if it has one
+func (e *Emulator) applyStepFaults(i int, step ScenarioStep) {
+	if step.Faults == nil {
+		return
+	}
+	e.SetFaults(*step.Faults)
+	e.logHistory(fmt.Sprintf("Scenario Step %d: fault profile %+v", i+1, *step.Faults))
 }
 
 func (e *Emulator) ExecuteScenario(steps []ScenarioStep) {
 	go func() {
 		e.logHistory(fmt.Sprintf("Starting scenario with %d steps", len(steps)))
+		e.beginScenario(len(steps))
 
 		for i, step := range steps {
+			e.applyStepFaults(i, step)
 			for _, job := range step.Jobs {
 				e.mu.Lock()
-				e.Values[job.Index] = job.Value
+				err := e.table.Set(job.Index, job.Value)
 				e.mu.Unlock()
+				if err != nil {
+					e.logHistory(fmt.Sprintf("Scenario Step %d: %v", i+1, err))
+					continue
+				}
 				e.logHistory(fmt.Sprintf("Scenario Step %d: Set [%d]=%s", i+1, job.Index, job.Value))
 			}
 
 			// Force immediate update to server
 			e.postMyStatus()
 
+			if !e.continueScenario(e.runStepChecks(i, step)) {
+				return
+			}
+
 			if step.Delay > 0 {
 				e.logHistory(fmt.Sprintf("Waiting %dms...", step.Delay))
-				time.Sleep(time.Duration(step.Delay) * time.Millisecond)
+				if !e.wait(time.Duration(step.Delay) * time.Millisecond) {
+					e.continueScenario(errStopped)
+					return
+				}
 			}
 		}
+		e.endScenario(ScenarioPassed, nil)
 		e.logHistory("Scenario complete")
 	}()
 }
 
+// InjectScenario replays the steps on the server side: each step is queued as an action
+// for this client through POST /api/admin/inject, and reaches the emulator on a later poll
+func (e *Emulator) InjectScenario(steps []ScenarioStep) {
+	go func() {
+		e.logHistory(fmt.Sprintf("Injecting scenario with %d steps", len(steps)))
+		e.beginScenario(len(steps))
+
+		for i, step := range steps {
+			e.applyStepFaults(i, step)
+			if len(step.Jobs) > 0 {
+				if err := e.injectJobs(step.Jobs); err != nil {
+					e.continueScenario(fmt.Errorf("step %d: inject: %w", i+1, err))
+					return
+				}
+				e.logHistory(fmt.Sprintf("Injected step %d (%d actions)", i+1, len(step.Jobs)))
+			}
+
+			if !e.continueScenario(e.runStepChecks(i, step)) {
+				return
+			}
+
+			if step.Delay > 0 && !e.wait(time.Duration(step.Delay)*time.Millisecond) {
+				e.continueScenario(errStopped)
+				return
+			}
+		}
+		e.endScenario(ScenarioPassed, nil)
+		e.logHistory("Scenario injection complete")
+	}()
+}
+
+// injectJobs queues the jobs on the server as one action for this client
+func (e *Emulator) injectJobs(jobs []ScenarioJob) error {
+	params := make([]map[string]interface{}, 0, len(jobs))
+	for _, job := range jobs {
+		params = append(params, map[string]interface{}{"k": job.Index, "v": job.Value})
+	}
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	req := e.adminRequest("POST", "/api/admin/inject", url.Values{}, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// wait sleeps for d and reports false if the emulator was stopped meanwhile
+func (e *Emulator) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-e.stopped():
+		return false
+	}
+}
+
 func (e *Emulator) GenerateAuth() string {
 	keyBytes, err := hex.DecodeString(e.Serial)
 	if err != nil {
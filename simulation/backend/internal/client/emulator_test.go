@@ -0,0 +1,101 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
+)
+
+// serverInfosServer answers /api/serverinfos with the given response
+func serverInfosServer(t *testing.T, infos protocol.ServerInfoResponse) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/serverinfos" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(infos)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func newTestEmulator(t *testing.T, serverURL string) *Emulator {
+	t.Helper()
+	e, err := NewEmulator("test", "SERIAL", serverURL, ModeHTTP, DefaultProfile)
+	if err != nil {
+		t.Fatalf("NewEmulator failed: %v", err)
+	}
+	return e
+}
+
+func TestGetServerInfos_KeepsThirtyIndices(t *testing.T) {
+	infos := make([]int, 40)
+	for i := range infos {
+		infos[i] = 600 + i
+	}
+	e := newTestEmulator(t, serverInfosServer(t, protocol.ServerInfoResponse{IsConnected: false, Infos: infos, NewVersion: "no"}))
+
+	if !e.getServerInfos() {
+		t.Fatalf("getServerInfos failed: %v", e.History)
+	}
+	if len(e.TargetIndices) != MaxTargetIndices || e.TargetIndices[0] != 600 || e.TargetIndices[29] != 629 {
+		t.Errorf("Expected the first 30 indices, got %v", e.TargetIndices)
+	}
+	if e.OfferedVersion != "" {
+		t.Errorf("Expected \"no\" to mean no update, got %q", e.OfferedVersion)
+	}
+	if got := e.pollInterval(); got != PollIntervalIdle {
+		t.Errorf("Expected %v without a user connected, got %v", PollIntervalIdle, got)
+	}
+}
+
+func TestGetServerInfos_ConnectedAndVersion(t *testing.T) {
+	e := newTestEmulator(t, serverInfosServer(t, protocol.ServerInfoResponse{IsConnected: true, Infos: []int{613}, NewVersion: "V130"}))
+
+	if !e.getServerInfos() {
+		t.Fatalf("getServerInfos failed: %v", e.History)
+	}
+	if e.OfferedVersion != "V130" {
+		t.Errorf("Expected V130 offered, got %q", e.OfferedVersion)
+	}
+	if got := e.pollInterval(); got != PollIntervalConnected {
+		t.Errorf("Expected %v while a user is connected, got %v", PollIntervalConnected, got)
+	}
+
+	// A skewed clock stretches the period
+	e.SetFaults(FaultProfile{ClockSkew: 0.5})
+	if got := e.pollInterval(); got != PollIntervalConnected*3/2 {
+		t.Errorf("Expected a 50%% longer period with clock_skew 0.5, got %v", got)
+	}
+}
+
+func TestGetServerInfos_FailsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	if newTestEmulator(t, server.URL).getServerInfos() {
+		t.Error("Expected a 401 to fail the cycle")
+	}
+}
+
+func TestStatusBody_OnlyTargetIndices(t *testing.T) {
+	e := newTestEmulator(t, "http://127.0.0.1:1")
+
+	if got := e.statusBody(); got != `{"version":"V125","ek":[]}` {
+		t.Errorf("Expected no index before serverinfos, got %s", got)
+	}
+
+	e.TargetIndices = []int{613, IndexAlert, 920}
+	e.table.Set(613, "64")
+	e.table.Set(IndexAlert, "1")
+	want := `{"version":"V125","ek":[{k:613,v:"64"},{k:363,v:"10000000"},{k:920,v:"0"}]}`
+	if got := e.statusBody(); got != want {
+		t.Errorf("Unexpected status body\n got: %s\nwant: %s", got, want)
+	}
+}
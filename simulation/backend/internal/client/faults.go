@@ -0,0 +1,250 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Fault is a kind of network misbehaviour an emulator can inject
+type Fault string
+
+const (
+	// FaultDrop closes the connection after writing half of the request
+	FaultDrop Fault = "drop"
+	// FaultDelay waits before writing the request
+	FaultDelay Fault = "delay"
+	// FaultFragment writes the request in several TCP segments
+	FaultFragment Fault = "fragment"
+	// FaultDuplicate sends a POST a second time
+	FaultDuplicate Fault = "duplicate"
+	// FaultReorderAcks holds the done acks of a response back and sends them, reversed, after the next ones
+	FaultReorderAcks Fault = "reorder_acks"
+	// FaultDNS fails to resolve the server, without connecting
+	FaultDNS Fault = "dns"
+)
+
+const (
+	// fragmentSize is the size of the segments of a fragmented request
+	fragmentSize = 16
+	// fragmentPause separates the segments so that the server reads them one at a time
+	fragmentPause = 10 * time.Millisecond
+)
+
+// errDropped is returned for a request whose connection was dropped on purpose
+var errDropped = errors.New("connection dropped by fault injection")
+
+// FaultProfile sets the probability of each fault, per request, from 0 to 1
+// The zero value injects nothing
+type FaultProfile struct {
+	DropRate        float64 `json:"drop_rate,omitempty"`
+	DelayRate       float64 `json:"delay_rate,omitempty"`
+	DelayMs         int     `json:"delay_ms,omitempty"`
+	FragmentRate    float64 `json:"fragment_rate,omitempty"`
+	DuplicateRate   float64 `json:"duplicate_rate,omitempty"`
+	ReorderAcksRate float64 `json:"reorder_acks_rate,omitempty"`
+	DNSFailureRate  float64 `json:"dns_failure_rate,omitempty"`
+	// ClockSkew stretches (> 0) or shrinks (< 0) the poll period, e.g. 0.1 for a clock 10% slow
+	ClockSkew float64 `json:"clock_skew,omitempty"`
+}
+
+// Validate checks that rates are probabilities and durations are usable
+func (p FaultProfile) Validate() error {
+	rates := map[string]float64{
+		"drop_rate":         p.DropRate,
+		"delay_rate":        p.DelayRate,
+		"fragment_rate":     p.FragmentRate,
+		"duplicate_rate":    p.DuplicateRate,
+		"reorder_acks_rate": p.ReorderAcksRate,
+		"dns_failure_rate":  p.DNSFailureRate,
+	}
+	for name, rate := range rates {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s must be between 0 and 1, got %v", name, rate)
+		}
+	}
+	if p.DelayMs < 0 {
+		return fmt.Errorf("delay_ms must not be negative, got %d", p.DelayMs)
+	}
+	if p.DelayRate > 0 && p.DelayMs == 0 {
+		return fmt.Errorf("delay_rate needs a delay_ms")
+	}
+	if p.ClockSkew <= -1 {
+		return fmt.Errorf("clock_skew must be greater than -1, got %v", p.ClockSkew)
+	}
+	return nil
+}
+
+// FaultStats counts, for each fault, the outcomes of the requests it was injected into:
+// the status the server answered, or closed (no response, as when a server limit rejects the
+// connection), refused, timeout, dropped, dns_error or error
+type FaultStats map[Fault]map[string]int
+
+// record counts one outcome of a fault
+func (s FaultStats) record(fault Fault, outcome string) {
+	if s[fault] == nil {
+		s[fault] = make(map[string]int)
+	}
+	s[fault][outcome]++
+}
+
+// Merge adds the counts of other to s
+func (s FaultStats) Merge(other FaultStats) {
+	for fault, outcomes := range other {
+		for outcome, count := range outcomes {
+			if s[fault] == nil {
+				s[fault] = make(map[string]int)
+			}
+			s[fault][outcome] += count
+		}
+	}
+}
+
+// connFaults are the faults drawn for one connection
+type connFaults struct {
+	drop     bool
+	delay    time.Duration
+	fragment bool
+	dns      bool
+}
+
+// faults lists the connection faults drawn, for the counters
+func (f connFaults) faults() []Fault {
+	var faults []Fault
+	if f.dns {
+		faults = append(faults, FaultDNS)
+	}
+	if f.delay > 0 {
+		faults = append(faults, FaultDelay)
+	}
+	if f.fragment {
+		faults = append(faults, FaultFragment)
+	}
+	if f.drop {
+		faults = append(faults, FaultDrop)
+	}
+	return faults
+}
+
+// drawConnFaults draws the connection faults of one request
+func (p FaultProfile) drawConnFaults(rng *rand.Rand) connFaults {
+	var f connFaults
+	f.dns = hit(rng, p.DNSFailureRate)
+	f.drop = hit(rng, p.DropRate)
+	f.fragment = hit(rng, p.FragmentRate)
+	if hit(rng, p.DelayRate) {
+		f.delay = time.Duration(p.DelayMs) * time.Millisecond
+	}
+	return f
+}
+
+// hit reports whether an event of the given probability happens
+func hit(rng *rand.Rand, rate float64) bool {
+	return rate > 0 && rng.Float64() < rate
+}
+
+// connFaultsKey carries the connFaults of an http.Client request to the dialer
+type connFaultsKey struct{}
+
+// dialWithFaults dials addr and wraps the connection so that it misbehaves as drawn
+func dialWithFaults(ctx context.Context, addr string, f connFaults) (net.Conn, error) {
+	if f.dns {
+		host, _, _ := net.SplitHostPort(addr)
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	dialer := net.Dialer{Timeout: firmwareTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if !f.drop && f.delay == 0 && !f.fragment {
+		return conn, nil
+	}
+	return &faultyConn{Conn: conn, faults: f}, nil
+}
+
+// faultyConn applies connection faults to the writes of a request
+type faultyConn struct {
+	net.Conn
+	faults  connFaults
+	written bool
+}
+
+func (c *faultyConn) Write(p []byte) (int, error) {
+	// Faults apply to the request, which the emulators send in a single write
+	if c.written {
+		return c.Conn.Write(p)
+	}
+	c.written = true
+
+	if c.faults.delay > 0 {
+		time.Sleep(c.faults.delay)
+	}
+	if c.faults.drop {
+		c.writeSegments(p[:len(p)/2])
+		c.Conn.Close()
+		return 0, errDropped
+	}
+	return c.writeSegments(p)
+}
+
+// writeSegments writes p at once, or in small segments when fragmenting
+func (c *faultyConn) writeSegments(p []byte) (int, error) {
+	if !c.faults.fragment {
+		return c.Conn.Write(p)
+	}
+	if tcp, ok := c.Conn.(*net.TCPConn); ok {
+		// Do not let Nagle's algorithm coalesce the segments again
+		tcp.SetNoDelay(true)
+	}
+	written := 0
+	for written < len(p) {
+		end := written + fragmentSize
+		if end > len(p) {
+			end = len(p)
+		}
+		n, err := c.Conn.Write(p[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if written < len(p) {
+			time.Sleep(fragmentPause)
+		}
+	}
+	return written, nil
+}
+
+// outcome names the result of a request for the fault counters
+func outcome(status int, err error) string {
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		return strconv.Itoa(status)
+	case errors.Is(err, errDropped):
+		return "dropped"
+	case errors.As(err, &dnsErr):
+		return "dns_error"
+	case errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET),
+		strings.Contains(err.Error(), "server closed"):
+		return "closed"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+	return "error"
+}
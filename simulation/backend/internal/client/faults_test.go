@@ -0,0 +1,221 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFaultProfile_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile FaultProfile
+		ok      bool
+	}{
+		{"zero value", FaultProfile{}, true},
+		{"every rate at 1", FaultProfile{DropRate: 1, DelayRate: 1, DelayMs: 10, FragmentRate: 1, DuplicateRate: 1, ReorderAcksRate: 1, DNSFailureRate: 1}, true},
+		{"rate above 1", FaultProfile{DropRate: 1.5}, false},
+		{"negative rate", FaultProfile{DNSFailureRate: -0.1}, false},
+		{"negative delay", FaultProfile{DelayMs: -1}, false},
+		{"delay rate without delay", FaultProfile{DelayRate: 0.5}, false},
+		{"clock running fast", FaultProfile{ClockSkew: -0.5}, true},
+		{"clock skew of -1", FaultProfile{ClockSkew: -1}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.profile.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v, expected ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestDrawConnFaults(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	if f := (FaultProfile{}).drawConnFaults(rng); len(f.faults()) != 0 {
+		t.Errorf("Expected no fault from the zero profile, got %v", f.faults())
+	}
+	all := FaultProfile{DropRate: 1, DelayRate: 1, DelayMs: 5, FragmentRate: 1, DNSFailureRate: 1}.drawConnFaults(rng)
+	if got := fmt.Sprint(all.faults()); got != "[dns delay fragment drop]" {
+		t.Errorf("Expected every connection fault, got %s", got)
+	}
+	if all.delay != 5*time.Millisecond {
+		t.Errorf("Expected a 5ms delay, got %v", all.delay)
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	// A port nothing listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	_, refused := net.Dial("tcp", addr)
+
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		want   string
+	}{
+		{"success", 201, nil, "201"},
+		{"error status", 429, nil, "429"},
+		{"dropped", 0, fmt.Errorf("write: %w", errDropped), "dropped"},
+		{"dns", 0, &net.DNSError{Err: "no such host", Name: "box", IsNotFound: true}, "dns_error"},
+		{"deadline", 0, fmt.Errorf("read: %w", os.ErrDeadlineExceeded), "timeout"},
+		{"context deadline", 0, context.DeadlineExceeded, "timeout"},
+		{"eof", 0, io.EOF, "closed"},
+		{"unexpected eof", 0, io.ErrUnexpectedEOF, "closed"},
+		{"reset", 0, &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, "closed"},
+		{"http client closed", 0, errors.New("http: server closed idle connection"), "closed"},
+		{"refused", 0, refused, "refused"},
+		{"other", 0, errors.New("boom"), "error"},
+	}
+	for _, tt := range tests {
+		if got := outcome(tt.status, tt.err); got != tt.want {
+			t.Errorf("%s: outcome = %q, expected %q (err %v)", tt.name, got, tt.want, tt.err)
+		}
+	}
+}
+
+// recordReads accepts one connection and returns the size of each read until the client closes
+func recordReads(t *testing.T) (string, <-chan []int) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	reads := make(chan []int, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var sizes []int
+		buf := make([]byte, 4096)
+		for {
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			n, err := conn.Read(buf)
+			if n > 0 {
+				sizes = append(sizes, n)
+			}
+			if err != nil {
+				break
+			}
+		}
+		reads <- sizes
+	}()
+	return listener.Addr().String(), reads
+}
+
+func sum(sizes []int) int {
+	total := 0
+	for _, n := range sizes {
+		total += n
+	}
+	return total
+}
+
+func TestDialWithFaults_Drop(t *testing.T) {
+	addr, reads := recordReads(t)
+	request := []byte(strings.Repeat("x", 64))
+
+	conn, err := dialWithFaults(context.Background(), addr, connFaults{drop: true})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(request); !errors.Is(err, errDropped) {
+		t.Errorf("Expected errDropped, got %v", err)
+	}
+	if got := sum(<-reads); got != 32 {
+		t.Errorf("Expected the server to get half of the request, got %d bytes", got)
+	}
+}
+
+func TestDialWithFaults_Fragment(t *testing.T) {
+	addr, reads := recordReads(t)
+	request := []byte(strings.Repeat("x", 64))
+
+	conn, err := dialWithFaults(context.Background(), addr, connFaults{fragment: true})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	if n, err := conn.Write(request); err != nil || n != len(request) {
+		t.Fatalf("Expected the whole request written, got %d, %v", n, err)
+	}
+	conn.Close()
+
+	sizes := <-reads
+	if sum(sizes) != len(request) {
+		t.Errorf("Expected %d bytes, got %v", len(request), sizes)
+	}
+	if len(sizes) < 2 {
+		t.Errorf("Expected the request in several segments, got %v", sizes)
+	}
+	for _, n := range sizes {
+		if n > fragmentSize {
+			t.Errorf("Expected segments of at most %d bytes, got %v", fragmentSize, sizes)
+			break
+		}
+	}
+}
+
+func TestDialWithFaults_DNS(t *testing.T) {
+	_, err := dialWithFaults(context.Background(), "box.local:80", connFaults{dns: true})
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || dnsErr.Name != "box.local" {
+		t.Errorf("Expected a DNS error for box.local, got %v", err)
+	}
+}
+
+func TestSendAcks_ReorderReleasesHeldAcksOnNextCycle(t *testing.T) {
+	var mu sync.Mutex
+	var acked []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		acked = append(acked, strings.TrimPrefix(r.URL.Path, "/api/done/"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+	e := newTestEmulator(t, server.URL)
+
+	e.SetFaults(FaultProfile{ReorderAcksRate: 1})
+	e.sendAcks([]string{"a", "b"})
+	if len(acked) != 0 {
+		t.Fatalf("Expected the acks held back, got %v", acked)
+	}
+
+	// Next cycle: the new ack goes first, then the held ones in reverse
+	e.SetFaults(FaultProfile{})
+	e.sendAcks([]string{"c"})
+	if got := strings.Join(acked, ","); got != "c,b,a" {
+		t.Errorf("Expected c,b,a, got %s", got)
+	}
+	_, stats := e.Faults()
+	if stats[FaultReorderAcks]["201"] != 2 {
+		t.Errorf("Expected two late acks counted as 201, got %v", stats)
+	}
+
+	// Nothing is held anymore
+	e.sendAcks(nil)
+	if len(acked) != 3 {
+		t.Errorf("Expected no more acks, got %v", acked)
+	}
+}
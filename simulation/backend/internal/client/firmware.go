@@ -0,0 +1,162 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mode selects how an emulator talks to the server
+type Mode string
+
+const (
+	// ModeHTTP sends well-formed requests with Go's http.Client
+	ModeHTTP Mode = "http"
+	// ModeFirmware writes the exact bytes of the BP_MQX_ETH firmware on a raw socket
+	ModeFirmware Mode = "firmware"
+)
+
+// ParseMode parses a mode name, the empty string meaning ModeHTTP
+func ParseMode(name string) (Mode, error) {
+	switch Mode(name) {
+	case "", ModeHTTP:
+		return ModeHTTP, nil
+	case ModeFirmware:
+		return ModeFirmware, nil
+	default:
+		return "", fmt.Errorf("unknown mode %q (expected %q or %q)", name, ModeHTTP, ModeFirmware)
+	}
+}
+
+const (
+	// firmwareHost is the Host the firmware always sends, whatever server it reaches
+	firmwareHost = "mon.essensys.fr"
+
+	// firmwareRxBufferSize is the receive buffer of the emulated firmware: a longer response is truncated
+	firmwareRxBufferSize = 4096
+
+	// firmwareTimeout bounds the connection, the write and the single read
+	firmwareTimeout = 2 * time.Second
+)
+
+// firmwareRequest builds the request bytes as the firmware concatenates them with strcat:
+// trailing space in the request line, fixed header order and casing, Content-Length only on
+// POST, then the Authorization header followed by the bare matricule line
+func firmwareRequest(method, path, matricule string, body []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(method + " " + path + " HTTP/1.1 \r\n")
+	b.WriteString("Accept: application/json,application/xhtml1+xml,application/xml;q=0.9,*/*;q=0.8\r\n")
+	if method == http.MethodPost {
+		if path == "/api/mystatus" {
+			b.WriteString("Content-type: application/json ;charset=UTF-8\r\n")
+		} else {
+			b.WriteString("Content-type: application/json\r\n")
+		}
+	}
+	b.WriteString("host: " + firmwareHost + "\r\n")
+	b.WriteString("Cache-Control: max-age=0\r\n")
+	b.WriteString("Accept-Charset: ISO-8859-1,utf-8;q=0.7,*;q=0.3\r\n")
+	if method == http.MethodPost {
+		b.WriteString("Content-Length: " + strconv.Itoa(len(body)) + "\r\n")
+	}
+	b.WriteString("Authorization: Basic " + matricule + "\r\n")
+	b.WriteString(matricule)
+	b.WriteString("\r\n\r\n")
+	b.Write(body)
+	return b.Bytes()
+}
+
+// firmwareExchange sends a request the way the firmware does: one write on a new connection,
+// then a single read into a fixed buffer
+// Like the firmware, it fails when the response does not arrive in that one read (headers and
+// body written separately, or a body longer than the buffer)
+func firmwareExchange(serverURL string, request []byte, faults connFaults) (int, []byte, error) {
+	addr, err := firmwareAddr(serverURL)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	conn, err := dialWithFaults(context.Background(), addr, faults)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(firmwareTimeout))
+
+	if _, err := conn.Write(request); err != nil {
+		return 0, nil, err
+	}
+
+	buf := make([]byte, firmwareRxBufferSize)
+	n, err := conn.Read(buf)
+	if n == 0 {
+		return 0, nil, fmt.Errorf("no response: %w", err)
+	}
+	return parseFirmwareResponse(buf[:n])
+}
+
+// parseFirmwareResponse reads a response the way the firmware's strstr-based code does
+func parseFirmwareResponse(rx []byte) (int, []byte, error) {
+	headerEnd := bytes.Index(rx, []byte("\r\n\r\n"))
+	if headerEnd < 0 {
+		return 0, nil, fmt.Errorf("fragmented response: headers incomplete after one read (%d bytes)", len(rx))
+	}
+	lines := strings.Split(string(rx[:headerEnd]), "\r\n")
+
+	// The firmware looks for the exact status line, e.g. "HTTP/1.1 201 Created"
+	status, err := firmwareStatus(lines[0])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	body := rx[headerEnd+4:]
+	for _, line := range lines[1:] {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			continue
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid Content-Length %q", value)
+		}
+		if len(body) < length {
+			return 0, nil, fmt.Errorf("fragmented response: %d of %d body bytes after one read", len(body), length)
+		}
+		body = body[:length]
+	}
+	return status, body, nil
+}
+
+// firmwareStatus extracts the status code from a status line, which must carry the standard reason
+func firmwareStatus(line string) (int, error) {
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) != 3 || fields[0] != "HTTP/1.1" {
+		return 0, fmt.Errorf("unexpected status line %q", line)
+	}
+	status, err := strconv.Atoi(fields[1])
+	if err != nil || fields[2] != http.StatusText(status) {
+		return 0, fmt.Errorf("unexpected status line %q", line)
+	}
+	return status, nil
+}
+
+// firmwareAddr returns the host:port to dial for a server URL such as http://192.168.0.10
+func firmwareAddr(serverURL string) (string, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" {
+		return "", fmt.Errorf("firmware mode only speaks plain HTTP, got %q", serverURL)
+	}
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), "80"), nil
+	}
+	return u.Host, nil
+}
@@ -0,0 +1,229 @@
+package client
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFirmwareRequest(t *testing.T) {
+	const common = "Accept: application/json,application/xhtml1+xml,application/xml;q=0.9,*/*;q=0.8\r\n"
+	const trailer = "host: mon.essensys.fr\r\n" +
+		"Cache-Control: max-age=0\r\n" +
+		"Accept-Charset: ISO-8859-1,utf-8;q=0.7,*;q=0.3\r\n"
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{
+			name:   "GET without Content-Length",
+			method: "GET",
+			path:   "/api/serverinfos",
+			want: "GET /api/serverinfos HTTP/1.1 \r\n" + common + trailer +
+				"Authorization: Basic Ym94LTE6c2VjcmV0\r\nYm94LTE6c2VjcmV0\r\n\r\n",
+		},
+		{
+			name:   "POST mystatus with its charset",
+			method: "POST",
+			path:   "/api/mystatus",
+			body:   `{"version":"V125","ek":[{k:613,v:"64"}]}`,
+			want: "POST /api/mystatus HTTP/1.1 \r\n" + common +
+				"Content-type: application/json ;charset=UTF-8\r\n" + trailer +
+				"Content-Length: 40\r\n" +
+				"Authorization: Basic Ym94LTE6c2VjcmV0\r\nYm94LTE6c2VjcmV0\r\n\r\n" +
+				`{"version":"V125","ek":[{k:613,v:"64"}]}`,
+		},
+		{
+			name:   "POST done with an empty body",
+			method: "POST",
+			path:   "/api/done/1234",
+			want: "POST /api/done/1234 HTTP/1.1 \r\n" + common +
+				"Content-type: application/json\r\n" + trailer +
+				"Content-Length: 0\r\n" +
+				"Authorization: Basic Ym94LTE6c2VjcmV0\r\nYm94LTE6c2VjcmV0\r\n\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(firmwareRequest(tt.method, tt.path, "Ym94LTE6c2VjcmV0", []byte(tt.body)))
+			if got != tt.want {
+				t.Errorf("Unexpected request bytes\n got: %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFirmwareResponse(t *testing.T) {
+	const headers = "HTTP/1.1 200 OK\r\nContent-Type: application/json ;charset=UTF-8\r\nConnection: close\r\n"
+
+	tests := []struct {
+		name     string
+		rx       string
+		status   int
+		body     string
+		errorHas string
+	}{
+		{
+			name:   "complete response",
+			rx:     headers + "Content-Length: 2\r\n\r\n{}",
+			status: 200,
+			body:   "{}",
+		},
+		{
+			name:   "Content-Length in another casing",
+			rx:     "HTTP/1.1 201 Created\r\ncontent-length: 0\r\n\r\n",
+			status: 201,
+		},
+		{
+			name:   "bytes after the announced body are ignored",
+			rx:     headers + "Content-Length: 2\r\n\r\n{}garbage",
+			status: 200,
+			body:   "{}",
+		},
+		{
+			name:     "headers split across reads",
+			rx:       headers + "Content-Len",
+			errorHas: "headers incomplete",
+		},
+		{
+			name:     "body shorter than Content-Length",
+			rx:       headers + "Content-Length: 10\r\n\r\n{}",
+			errorHas: "2 of 10 body bytes",
+		},
+		{
+			name:     "invalid Content-Length",
+			rx:       headers + "Content-Length: ten\r\n\r\n",
+			errorHas: "invalid Content-Length",
+		},
+		{
+			name:     "non-standard reason",
+			rx:       "HTTP/1.1 201 OK\r\nContent-Length: 0\r\n\r\n",
+			errorHas: "unexpected status line",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body, err := parseFirmwareResponse([]byte(tt.rx))
+			if tt.errorHas != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorHas) {
+					t.Fatalf("Expected an error containing %q, got %v", tt.errorHas, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if status != tt.status || string(body) != tt.body {
+				t.Errorf("Expected %d %q, got %d %q", tt.status, tt.body, status, body)
+			}
+		})
+	}
+}
+
+func TestFirmwareStatus(t *testing.T) {
+	tests := []struct {
+		line   string
+		status int
+		ok     bool
+	}{
+		{"HTTP/1.1 200 OK", 200, true},
+		{"HTTP/1.1 201 Created", 201, true},
+		{"HTTP/1.1 404 Not Found", 404, true},
+		{"HTTP/1.1 201 OK", 0, false},
+		{"HTTP/1.1 200 ok", 0, false},
+		{"HTTP/1.0 200 OK", 0, false},
+		{"HTTP/1.1 200", 0, false},
+		{"HTTP/1.1 abc OK", 0, false},
+	}
+
+	for _, tt := range tests {
+		status, err := firmwareStatus(tt.line)
+		if (err == nil) != tt.ok || status != tt.status {
+			t.Errorf("firmwareStatus(%q) = %d, %v; expected %d, ok=%v", tt.line, status, err, tt.status, tt.ok)
+		}
+	}
+}
+
+// serveOnce answers the first connection of a local listener with the given writes, pausing between them
+func serveOnce(t *testing.T, writes ...string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Read(make([]byte, 4096))
+		for i, w := range writes {
+			if i > 0 {
+				time.Sleep(50 * time.Millisecond)
+			}
+			conn.Write([]byte(w))
+		}
+	}()
+	return "http://" + listener.Addr().String()
+}
+
+func TestFirmwareExchange(t *testing.T) {
+	request := firmwareRequest("GET", "/api/myactions", "Ym94LTE6c2VjcmV0", nil)
+	long := strings.Repeat("x", firmwareRxBufferSize+100)
+	headers := "HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: "
+
+	tests := []struct {
+		name     string
+		writes   []string
+		errorHas string
+	}{
+		{"single write", []string{headers + "2\r\n\r\n{}"}, ""},
+		{"headers and body written apart", []string{headers + "2\r\n\r\n", "{}"}, "0 of 2 body bytes"},
+		{"headers written apart", []string{"HTTP/1.1 200 OK\r\n", "Connection: close\r\nContent-Length: 0\r\n\r\n"}, "headers incomplete"},
+		{"body longer than the receive buffer", []string{headers + strconv.Itoa(len(long)) + "\r\n\r\n" + long}, "body bytes after one read"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _, err := firmwareExchange(serveOnce(t, tt.writes...), request, connFaults{})
+			if tt.errorHas == "" {
+				if err != nil || status != 200 {
+					t.Errorf("Expected 200, got %d, %v", status, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorHas) {
+				t.Errorf("Expected an error containing %q, got %v", tt.errorHas, err)
+			}
+		})
+	}
+}
+
+func TestFirmwareAddr(t *testing.T) {
+	tests := []struct {
+		url  string
+		addr string
+		ok   bool
+	}{
+		{"http://192.168.0.10", "192.168.0.10:80", true},
+		{"http://localhost:8080", "localhost:8080", true},
+		{"https://example.com", "", false},
+	}
+	for _, tt := range tests {
+		addr, err := firmwareAddr(tt.url)
+		if (err == nil) != tt.ok || addr != tt.addr {
+			t.Errorf("firmwareAddr(%q) = %q, %v; expected %q, ok=%v", tt.url, addr, err, tt.addr, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
+)
+
+// Profile describes the device an emulator stands for
+type Profile struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Seed is the exchange table at power-on, index to value as the server would send it
+	Seed map[int]string `json:"seed"`
+	// Drifts change values on their own, as a measurement would
+	// The built-in profiles have none: the exchange table holds no documented measurement
+	Drifts []Drift `json:"drifts,omitempty"`
+	// Responses are how the device reacts to the commands of the server
+	Responses []Response `json:"responses,omitempty"`
+}
+
+// Drift moves the value of Index by Step up or down, at random, every Every milliseconds,
+// without leaving Min-Max
+type Drift struct {
+	Index int `json:"index"`
+	Min   int `json:"min"`
+	Max   int `json:"max"`
+	Step  int `json:"step"`
+	Every int `json:"every_ms"`
+}
+
+// Response writes Set After milliseconds once the server has written a non-zero value to Index,
+// such as a shutter command that is done once the shutter has moved
+// Bits, when not 0, are the outputs wired to the index: a command for none of them is ignored
+// A new command on the same index starts the delay again
+type Response struct {
+	Index int            `json:"index"`
+	Bits  int            `json:"bits,omitempty"`
+	After int            `json:"after_ms"`
+	Set   map[int]string `json:"set"`
+}
+
+// Heating modes of the zones (349-352), as the UI's scenario editor sends them
+const (
+	HeatingAuto      = "1" // Follows the weekly planning
+	HeatingOff       = "16"
+	HeatingComfort   = "17"
+	HeatingEco       = "18"
+	HeatingFrostFree = "21"
+)
+
+// Modes of the water heater (353)
+const (
+	WaterHeaterAuto = "1" // Heats during off-peak hours
+	WaterHeaterOff  = "2"
+)
+
+// Validate checks that the profile can be applied to an exchange table
+func (p Profile) Validate() error {
+	if _, err := NewExchangeTable(p.Seed); err != nil {
+		return fmt.Errorf("profile %s: seed: %w", p.Name, err)
+	}
+	for _, drift := range p.Drifts {
+		if err := validIndex(drift.Index); err != nil {
+			return fmt.Errorf("profile %s: drift: %w", p.Name, err)
+		}
+		if drift.Min < 0 || drift.Max > 255 || drift.Min > drift.Max {
+			return fmt.Errorf("profile %s: drift of %d: min and max must be bytes, min first", p.Name, drift.Index)
+		}
+		if drift.Step < 1 || drift.Every < 1 {
+			return fmt.Errorf("profile %s: drift of %d: step and every_ms must be positive", p.Name, drift.Index)
+		}
+	}
+	for _, response := range p.Responses {
+		if err := validIndex(response.Index); err != nil {
+			return fmt.Errorf("profile %s: response: %w", p.Name, err)
+		}
+		if response.After < 0 {
+			return fmt.Errorf("profile %s: response to %d: after_ms must not be negative", p.Name, response.Index)
+		}
+		if response.Bits < 0 || response.Bits > 255 {
+			return fmt.Errorf("profile %s: response to %d: bits must be a byte", p.Name, response.Index)
+		}
+		if _, err := NewExchangeTable(response.Set); err != nil {
+			return fmt.Errorf("profile %s: response to %d: %w", p.Name, response.Index, err)
+		}
+	}
+	return nil
+}
+
+func validIndex(index int) error {
+	if index < 0 || index > protocol.MaxExchangeIndex {
+		return fmt.Errorf("index %d out of range 0-%d", index, protocol.MaxExchangeIndex)
+	}
+	return nil
+}
+
+// ShutterTravel is how long the shutters of the house profile take to move
+const ShutterTravel = 20 * time.Second
+
+// LightSwitch is how long the lights of the flat profile take to switch
+const LightSwitch = 500 * time.Millisecond
+
+// flatLights are the 4 lights of the flat profile, bits 0-3 of block 1
+const flatLights = 0x0F
+
+// lightsOff is the light and shutter block (605-622) with everything off
+func lightsOff() map[int]string {
+	seed := make(map[int]string)
+	for index := protocol.IndexLightStart; index <= protocol.IndexLightEnd; index++ {
+		seed[index] = "0"
+	}
+	return seed
+}
+
+// withSeed returns the values of the light and shutter block with the given ones on top
+func withSeed(values map[int]string) map[int]string {
+	seed := lightsOff()
+	for index, value := range values {
+		seed[index] = value
+	}
+	return seed
+}
+
+// DefaultProfile is a box with its lights and shutters off and the heating in comfort mode
+var DefaultProfile = Profile{
+	Name:        "default",
+	Description: "Lights and shutters off, heating in comfort mode",
+	Seed: withSeed(map[int]string{
+		349: HeatingComfort, // Day zone
+		350: HeatingComfort, // Night zone
+		351: HeatingComfort, // Bathroom 1
+		352: HeatingComfort, // Bathroom 2
+		353: WaterHeaterAuto,
+		363: "0", // Alert bitfield
+		590: "0", // Scenario trigger
+	}),
+}
+
+// Profiles are the built-in device profiles, by name
+var Profiles = map[string]Profile{
+	DefaultProfile.Name: DefaultProfile,
+	"flat": {
+		Name:        "flat",
+		Description: "Flat with 4 lights on block 1, switching in 0.5s, and one heating zone",
+		Seed: withSeed(map[int]string{
+			349: HeatingAuto,
+			350: HeatingOff,
+			351: HeatingOff,
+			352: HeatingOff,
+			353: WaterHeaterAuto,
+			363: "0",
+			590: "0",
+		}),
+		Responses: lightResponses(1, flatLights),
+	},
+	"house": {
+		Name:        "house",
+		Description: "House with shutters taking 20s to move and four heating zones",
+		Seed: withSeed(map[int]string{
+			349: HeatingAuto,
+			350: HeatingAuto,
+			351: HeatingComfort,
+			352: HeatingEco,
+			353: WaterHeaterAuto,
+			363: "0",
+			590: "0",
+		}),
+		Responses: shutterResponses(),
+	},
+	"alarm": {
+		Name:        "alarm",
+		Description: "Box with an alarm, no alert raised and the security socket on",
+		Seed: withSeed(map[int]string{
+			349: HeatingEco,
+			350: HeatingEco,
+			351: HeatingFrostFree,
+			352: HeatingFrostFree,
+			353: WaterHeaterOff,
+			363: "0", // Alert bitfield
+			440: "1", // Security socket
+			590: "0",
+		}),
+	},
+}
+
+// lightResponses clears the off (605-610) and on (611-616) commands of a block once its lights
+// have switched; commands for none of the wired lights are ignored
+func lightResponses(block, lights int) []Response {
+	var responses []Response
+	for _, index := range []int{protocol.IndexLightStart + block - 1, protocol.IndexLightStart + 5 + block} {
+		responses = append(responses, Response{
+			Index: index,
+			Bits:  lights,
+			After: int(LightSwitch / time.Millisecond),
+			Set:   map[int]string{index: "0"},
+		})
+	}
+	return responses
+}
+
+// shutterResponses clears each shutter command (617-622) once the shutter has moved
+func shutterResponses() []Response {
+	var responses []Response
+	for index := 617; index <= protocol.IndexLightEnd; index++ {
+		responses = append(responses, Response{
+			Index: index,
+			After: int(ShutterTravel / time.Millisecond),
+			Set:   map[int]string{index: "0"},
+		})
+	}
+	return responses
+}
+
+// ProfileNames lists the built-in profiles in alphabetical order
+func ProfileNames() []string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupProfile returns the built-in profile of that name
+func LookupProfile(name string) (Profile, error) {
+	profile, ok := Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown profile %q, expected one of %v", name, ProfileNames())
+	}
+	return profile, nil
+}
@@ -0,0 +1,343 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
+)
+
+// conditionPollInterval is how often a condition with a timeout is checked again
+const conditionPollInterval = 100 * time.Millisecond
+
+// Where a condition reads its value
+const (
+	// OnClient reads the emulator's exchange table, as the firmware would report it
+	OnClient = "client"
+	// OnServer reads the value the server stored for this client, through /api/admin/values
+	OnServer = "server"
+)
+
+// Condition expects an index to hold a value, on the client or on the server
+// Within, in milliseconds, gives the value time to arrive; 0 checks it once
+// The server queues injected actions per tenant, and any box of the tenant may take them:
+// a client condition on injected values only holds when a single client runs (see ChecksInjectedOnClient)
+type Condition struct {
+	On     string `json:"on"`
+	Index  int    `json:"index"`
+	Equals string `json:"equals"`
+	Within int    `json:"within,omitempty"`
+}
+
+func (c Condition) String() string {
+	s := fmt.Sprintf("%s [%d] = %q", c.On, c.Index, c.Equals)
+	if c.Within > 0 {
+		s += fmt.Sprintf(" within %dms", c.Within)
+	}
+	return s
+}
+
+func (c Condition) validate() error {
+	if c.On != OnClient && c.On != OnServer {
+		return fmt.Errorf("condition on must be %q or %q, got %q", OnClient, OnServer, c.On)
+	}
+	if c.Index < 0 || c.Index > protocol.MaxExchangeIndex {
+		return fmt.Errorf("condition index %d out of range 0-%d", c.Index, protocol.MaxExchangeIndex)
+	}
+	if c.Within < 0 {
+		return fmt.Errorf("condition within must not be negative")
+	}
+	return nil
+}
+
+// ScenarioStatus is the state of the last scenario an emulator ran
+type ScenarioStatus string
+
+const (
+	ScenarioRunning ScenarioStatus = "running"
+	// ScenarioPassed means every step ran and every check held
+	ScenarioPassed ScenarioStatus = "passed"
+	// ScenarioFailed means a check did not hold or a step could not run
+	ScenarioFailed ScenarioStatus = "failed"
+	// ScenarioAborted means the emulator was stopped before the end
+	ScenarioAborted ScenarioStatus = "aborted"
+)
+
+// CheckResult is the outcome of a wait or an expect
+type CheckResult struct {
+	Step      int       `json:"step"`
+	Kind      string    `json:"kind"` // wait or expect
+	Condition Condition `json:"condition"`
+	Passed    bool      `json:"passed"`
+	// Actual is the last value read, "" when the index holds none
+	Actual  string `json:"actual"`
+	Elapsed int64  `json:"elapsed_ms"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ScenarioResult records the checks of the last scenario an emulator ran
+type ScenarioResult struct {
+	Steps    int            `json:"steps"`
+	Status   ScenarioStatus `json:"status"`
+	Started  time.Time      `json:"started"`
+	Finished time.Time      `json:"finished"`
+	Checks   []CheckResult  `json:"checks"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// Failed counts the checks that did not hold
+func (r ScenarioResult) Failed() int {
+	failed := 0
+	for _, check := range r.Checks {
+		if !check.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// ScenarioResult returns a copy of the result of the last scenario, false if none ran
+func (e *Emulator) ScenarioResult() (ScenarioResult, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.scenario == nil {
+		return ScenarioResult{}, false
+	}
+	result := *e.scenario
+	result.Checks = append([]CheckResult(nil), e.scenario.Checks...)
+	return result, true
+}
+
+// beginScenario starts recording a new scenario, replacing the last result
+func (e *Emulator) beginScenario(steps int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.scenario = &ScenarioResult{Steps: steps, Status: ScenarioRunning, Started: time.Now(), Checks: []CheckResult{}}
+}
+
+// endScenario records how the scenario ended; it passes unless a check failed
+func (e *Emulator) endScenario(status ScenarioStatus, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if status == ScenarioPassed && e.scenario.Failed() > 0 {
+		status = ScenarioFailed
+	}
+	e.scenario.Status = status
+	e.scenario.Finished = time.Now()
+	if err != nil {
+		e.scenario.Error = err.Error()
+	}
+}
+
+// continueScenario reports whether the scenario may go on after err, and ends it otherwise
+func (e *Emulator) continueScenario(err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, errStopped):
+		e.endScenario(ScenarioAborted, err)
+		e.logHistory("Scenario aborted: client stopped")
+	default:
+		e.endScenario(ScenarioFailed, err)
+		e.logHistory(fmt.Sprintf("Scenario failed: %v", err))
+	}
+	return false
+}
+
+// runStepChecks queues the step's server commands, waits for its wait condition, then checks its
+// expectations
+// It returns an error when the scenario cannot go on: failed injection, wait timeout or stop
+func (e *Emulator) runStepChecks(i int, step ScenarioStep) error {
+	if len(step.Inject) > 0 {
+		if err := e.injectJobs(step.Inject); err != nil {
+			return fmt.Errorf("step %d: inject: %w", i+1, err)
+		}
+		e.logHistory(fmt.Sprintf("Scenario Step %d: Injected %d commands", i+1, len(step.Inject)))
+	}
+
+	if step.Wait != nil {
+		check, err := e.check(i, "wait", *step.Wait)
+		if err != nil {
+			return err
+		}
+		if !check.Passed {
+			return fmt.Errorf("step %d: wait for %v timed out (got %q)", i+1, *step.Wait, check.Actual)
+		}
+	}
+
+	// A failed expectation is recorded and the scenario goes on
+	for _, condition := range step.Expect {
+		if _, err := e.check(i, "expect", condition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errStopped ends a scenario whose emulator was stopped
+var errStopped = errors.New("client stopped")
+
+// check polls a condition until it holds or its time is up, and records the result
+// It returns errStopped, without recording anything, when the emulator is stopped meanwhile
+func (e *Emulator) check(i int, kind string, condition Condition) (CheckResult, error) {
+	start := time.Now()
+	deadline := start.Add(time.Duration(condition.Within) * time.Millisecond)
+	result := CheckResult{Step: i + 1, Kind: kind, Condition: condition}
+
+	for {
+		actual, err := e.conditionValue(condition)
+		result.Actual = actual
+		result.Error = ""
+		if err != nil {
+			result.Error = err.Error()
+		}
+		if err == nil && actual == condition.Equals {
+			result.Passed = true
+			break
+		}
+		if !time.Now().Before(deadline) {
+			break
+		}
+		if !e.wait(conditionPollInterval) {
+			return result, errStopped
+		}
+	}
+	result.Elapsed = time.Since(start).Milliseconds()
+
+	e.mu.Lock()
+	if e.scenario != nil {
+		e.scenario.Checks = append(e.scenario.Checks, result)
+	}
+	e.mu.Unlock()
+
+	verdict := "PASS"
+	if !result.Passed {
+		verdict = "FAIL"
+	}
+	detail := fmt.Sprintf("got %q", result.Actual)
+	if result.Error != "" {
+		detail = result.Error
+	}
+	e.logHistory(fmt.Sprintf("Scenario Step %d: %s %s %v: %s after %dms", i+1, verdict, kind, condition, detail, result.Elapsed))
+	return result, nil
+}
+
+// conditionValue reads the value a condition compares
+func (e *Emulator) conditionValue(condition Condition) (string, error) {
+	if condition.On == OnServer {
+		return e.serverValue(condition.Index)
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.table.Report(condition.Index), nil
+}
+
+// ChecksInjectedOnClient reports whether a wait or expect on the client follows an inject step
+// Such a scenario fails at random with more than one client, as another box may take the actions
+func ChecksInjectedOnClient(steps []ScenarioStep) bool {
+	injected := false
+	for _, step := range steps {
+		injected = injected || len(step.Inject) > 0
+		if !injected {
+			continue
+		}
+		conditions := step.Expect
+		if step.Wait != nil {
+			conditions = append([]Condition{*step.Wait}, conditions...)
+		}
+		for _, condition := range conditions {
+			if condition.On == OnClient {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AdminAccess is how an emulator reaches the server's admin API, for inject steps and server conditions
+type AdminAccess struct {
+	// URL of the admin API, "" for the box URL when the server serves both on one listener
+	URL string `json:"url"`
+	// Token is sent as a Bearer token when the server requires admin tokens; "" sends the box's
+	// own Basic credentials
+	Token string `json:"token,omitempty"`
+	// Client is the ID the server files the box under, passed as ?client=
+	// "" uses the box's credential ID; a server without box authentication files every box as "default"
+	Client string `json:"client,omitempty"`
+}
+
+// SetAdmin sets how the emulator reaches the admin API
+func (e *Emulator) SetAdmin(access AdminAccess) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.admin = access
+}
+
+// credentialID is the user of the Basic credentials, the ID an authenticating server gives the box
+func (e *Emulator) credentialID() string {
+	decoded, _ := base64.StdEncoding.DecodeString(e.Matricule)
+	id, _, _ := strings.Cut(string(decoded), ":")
+	return id
+}
+
+// adminRequest builds a request to an admin route for this client, with the admin credentials
+func (e *Emulator) adminRequest(method, path string, query url.Values, body io.Reader) *http.Request {
+	e.mu.RLock()
+	access := e.admin
+	e.mu.RUnlock()
+
+	base := access.URL
+	if base == "" {
+		base = e.ServerURL
+	}
+	if access.Client != "" {
+		query.Set("client", access.Client)
+	} else {
+		query.Set("client", e.credentialID())
+	}
+
+	req, _ := http.NewRequest(method, strings.TrimSuffix(base, "/")+path+"?"+query.Encode(), body)
+	req.Header.Set("Connection", "close")
+	if access.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+access.Token)
+	} else {
+		req.Header.Set("Authorization", "Basic "+e.Matricule)
+	}
+	return req
+}
+
+// serverValue reads the value the server stored for this client through /api/admin/values,
+// "" when it stored none
+func (e *Emulator) serverValue(index int) (string, error) {
+	req := e.adminRequest("GET", "/api/admin/values", url.Values{"indices": {strconv.Itoa(index)}}, nil)
+
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("GET /api/admin/values: status %d", resp.StatusCode)
+	}
+	var values struct {
+		Values []protocol.ExchangeKV `json:"values"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&values); err != nil {
+		return "", err
+	}
+	for _, kv := range values.Values {
+		if kv.K == index {
+			return kv.V, nil
+		}
+	}
+	return "", nil
+}
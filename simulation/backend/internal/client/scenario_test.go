@@ -0,0 +1,108 @@
+package client
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// adminRequestRecord is what the admin server received
+type adminRequestRecord struct {
+	method, path, query, auth, body string
+}
+
+// adminServer records the admin requests and answers /api/admin/values with index 613 set to 64
+func adminServer(t *testing.T) (string, <-chan adminRequestRecord) {
+	t.Helper()
+	received := make(chan adminRequestRecord, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- adminRequestRecord{r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("Authorization"), string(body)}
+		if r.URL.Path == "/api/admin/values" {
+			w.Write([]byte(`{"client_id":"x","values":[{"k":613,"v":"64"}]}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, received
+}
+
+func TestAdminRequests(t *testing.T) {
+	adminURL, received := adminServer(t)
+	e := newTestEmulator(t, "http://127.0.0.1:1")
+	decoded, _ := base64.StdEncoding.DecodeString(e.Matricule)
+	credentialID := strings.Split(string(decoded), ":")[0]
+
+	tests := []struct {
+		name   string
+		access AdminAccess
+		client string
+		auth   string
+	}{
+		{"box credentials", AdminAccess{URL: adminURL}, credentialID, "Basic " + e.Matricule},
+		{"admin token", AdminAccess{URL: adminURL + "/", Token: "secret"}, credentialID, "Bearer secret"},
+		{"server without box authentication", AdminAccess{URL: adminURL, Client: "default"}, "default", "Basic " + e.Matricule},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e.SetAdmin(tt.access)
+
+			if err := e.injectJobs([]ScenarioJob{{Index: 590, Value: "1"}}); err != nil {
+				t.Fatalf("injectJobs failed: %v", err)
+			}
+			got := <-received
+			want := adminRequestRecord{"POST", "/api/admin/inject", "client=" + tt.client, tt.auth, `[{"k":590,"v":"1"}]`}
+			if got != want {
+				t.Errorf("Unexpected inject request\n got: %+v\nwant: %+v", got, want)
+			}
+
+			value, err := e.serverValue(613)
+			if err != nil || value != "64" {
+				t.Fatalf("serverValue = %q, %v, expected \"64\"", value, err)
+			}
+			got = <-received
+			want = adminRequestRecord{"GET", "/api/admin/values", "client=" + tt.client + "&indices=613", tt.auth, ""}
+			if got != want {
+				t.Errorf("Unexpected values request\n got: %+v\nwant: %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestAdminRequests_DefaultToServerURL(t *testing.T) {
+	serverURL, received := adminServer(t)
+	e := newTestEmulator(t, serverURL)
+
+	if _, err := e.serverValue(613); err != nil {
+		t.Fatalf("serverValue failed: %v", err)
+	}
+	if got := <-received; got.path != "/api/admin/values" {
+		t.Errorf("Expected the box URL to serve the admin API, got %+v", got)
+	}
+}
+
+func TestChecksInjectedOnClient(t *testing.T) {
+	inject := []ScenarioJob{{Index: 590, Value: "1"}}
+	onClient := &Condition{On: OnClient, Index: 590, Equals: "1", Within: 1000}
+	onServer := &Condition{On: OnServer, Index: 590, Equals: "1", Within: 1000}
+
+	tests := []struct {
+		name  string
+		steps []ScenarioStep
+		want  bool
+	}{
+		{"no inject", []ScenarioStep{{Wait: onClient}}, false},
+		{"client check before the inject", []ScenarioStep{{Expect: []Condition{*onClient}}, {Inject: inject}}, false},
+		{"server check after the inject", []ScenarioStep{{Inject: inject, Wait: onServer}}, false},
+		{"client wait in the inject step", []ScenarioStep{{Inject: inject, Wait: onClient}}, true},
+		{"client expect in a later step", []ScenarioStep{{Inject: inject}, {Expect: []Condition{*onServer, *onClient}}}, true},
+	}
+	for _, tt := range tests {
+		if got := ChecksInjectedOnClient(tt.steps); got != tt.want {
+			t.Errorf("%s: ChecksInjectedOnClient = %v, expected %v", tt.name, got, tt.want)
+		}
+	}
+}
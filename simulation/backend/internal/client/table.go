@@ -0,0 +1,109 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/essensys-hub/essensys-server-backend/pkg/protocol"
+)
+
+// IndexAlert is the alert bitfield (bit 0: alarm triggered, bit 1: washing machine leak, ...)
+const IndexAlert = 363
+
+// bitfieldIndices are reported as a string of 8 bits instead of a decimal value
+// The firmware also formats EtatBP1 and EtatBP2 this way; their indices are not documented yet
+var bitfieldIndices = map[int]bool{
+	IndexAlert: true,
+}
+
+// ExchangeTable models the firmware's exchange table: one byte per index from 0 to
+// protocol.MaxExchangeIndex, all zero until written
+// It is not safe for concurrent use; the emulator guards it with its mutex
+type ExchangeTable struct {
+	values  [protocol.MaxExchangeIndex + 1]byte
+	written [protocol.MaxExchangeIndex + 1]bool
+}
+
+// NewExchangeTable returns a table holding the seed values
+func NewExchangeTable(seed map[int]string) (*ExchangeTable, error) {
+	t := &ExchangeTable{}
+	for index, value := range seed {
+		if err := t.Set(index, value); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Set writes a value received from the server or a scenario
+// The firmware converts it with atoi into an unsigned char, so "300" wraps to 44 and "abc" is 0
+func (t *ExchangeTable) Set(index int, value string) error {
+	if index < 0 || index > protocol.MaxExchangeIndex {
+		return fmt.Errorf("index %d out of range 0-%d", index, protocol.MaxExchangeIndex)
+	}
+	t.values[index] = byte(atoi(value))
+	t.written[index] = true
+	return nil
+}
+
+// Get returns the byte stored at index, 0 when out of range
+func (t *ExchangeTable) Get(index int) byte {
+	if index < 0 || index > protocol.MaxExchangeIndex {
+		return 0
+	}
+	return t.values[index]
+}
+
+// Report formats the value at index as the firmware sends it in /api/mystatus
+func (t *ExchangeTable) Report(index int) string {
+	value := t.Get(index)
+	if bitfieldIndices[index] {
+		return bitString(value)
+	}
+	return strconv.Itoa(int(value))
+}
+
+// Snapshot returns the reported form of every index written so far
+func (t *ExchangeTable) Snapshot() map[int]string {
+	snapshot := make(map[int]string)
+	for index, written := range t.written {
+		if written {
+			snapshot[index] = t.Report(index)
+		}
+	}
+	return snapshot
+}
+
+// bitString formats a byte the way vd_ConvertirOctetEnChaineBinaire does: bit 0 first
+func bitString(value byte) string {
+	bits := make([]byte, 8)
+	for i := range bits {
+		if value&(1<<i) == 0 {
+			bits[i] = '0'
+		} else {
+			bits[i] = '1'
+		}
+	}
+	return string(bits)
+}
+
+// atoi parses the leading decimal number of s like C's atoi: invalid input gives 0
+func atoi(s string) int {
+	i := 0
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	negative := false
+	if i < len(s) && (s[i] == '-' || s[i] == '+') {
+		negative = s[i] == '-'
+		i++
+	}
+	n := 0
+	for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		n = n*10 + int(s[i]-'0')
+	}
+	if negative {
+		return -n
+	}
+	return n
+}
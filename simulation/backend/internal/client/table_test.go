@@ -0,0 +1,100 @@
+package client
+
+import "testing"
+
+func TestAtoi(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"42", 42},
+		{"300", 300},
+		{"  7", 7},
+		{"-3", -3},
+		{"+5", 5},
+		{"12abc", 12},
+		{"abc", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := atoi(tt.in); got != tt.want {
+			t.Errorf("atoi(%q) = %d, expected %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExchangeTable_SetWrapsLikeUnsignedChar(t *testing.T) {
+	tests := []struct {
+		value string
+		want  byte
+	}{
+		{"255", 255},
+		{"256", 0},
+		{"300", 44},
+		{"-1", 255},
+		{"abc", 0},
+	}
+	for _, tt := range tests {
+		table := &ExchangeTable{}
+		if err := table.Set(613, tt.value); err != nil {
+			t.Fatalf("Set(%q) failed: %v", tt.value, err)
+		}
+		if got := table.Get(613); got != tt.want {
+			t.Errorf("Set(%q) stored %d, expected %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestExchangeTable_OutOfRange(t *testing.T) {
+	table := &ExchangeTable{}
+	if err := table.Set(-1, "1"); err == nil {
+		t.Error("Expected index -1 to be refused")
+	}
+	if err := table.Set(1000, "1"); err == nil {
+		t.Error("Expected index 1000 to be refused")
+	}
+	if got := table.Get(1000); got != 0 {
+		t.Errorf("Expected 0 out of range, got %d", got)
+	}
+}
+
+func TestBitString_BitZeroFirst(t *testing.T) {
+	tests := []struct {
+		value byte
+		want  string
+	}{
+		{0, "00000000"},
+		{1, "10000000"},
+		{2, "01000000"},
+		{3, "11000000"},
+		{128, "00000001"},
+		{255, "11111111"},
+	}
+	for _, tt := range tests {
+		if got := bitString(tt.value); got != tt.want {
+			t.Errorf("bitString(%d) = %q, expected %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestExchangeTable_ReportAndSnapshot(t *testing.T) {
+	table, err := NewExchangeTable(map[int]string{IndexAlert: "5", 613: "300"})
+	if err != nil {
+		t.Fatalf("NewExchangeTable failed: %v", err)
+	}
+
+	if got := table.Report(IndexAlert); got != "10100000" {
+		t.Errorf("Expected the alert bitfield as bits, got %q", got)
+	}
+	if got := table.Report(613); got != "44" {
+		t.Errorf("Expected 613 as a decimal byte, got %q", got)
+	}
+	// Unwritten indices read as 0 but are not in the snapshot
+	if got := table.Report(920); got != "0" {
+		t.Errorf("Expected an unwritten index to report 0, got %q", got)
+	}
+	snapshot := table.Snapshot()
+	if len(snapshot) != 2 || snapshot[IndexAlert] != "10100000" || snapshot[613] != "44" {
+		t.Errorf("Unexpected snapshot %v", snapshot)
+	}
+}
@@ -4,55 +4,133 @@ import (
 	"fmt"
 	"log"
 	"simulation/internal/client"
+	"simulation/internal/metrics"
+	"sort"
 	"sync"
 	"time"
 )
 
+// MaxClients is the largest fleet a single simulator runs
+const MaxClients = 100
+
+// RampUpBatch is how many clients are started before each pause of the ramp-up
+const RampUpBatch = 5
+
 type Manager struct {
 	mu      sync.RWMutex
 	Clients map[string]*client.Emulator
+
+	// rampStop is closed by StopAllClients to cancel the ramp-ups in progress
+	rampStop chan struct{}
+	// nextIndex numbers the clients of successive ramp-ups, which add to the fleet
+	nextIndex int
+	// faults is the fault profile of the fleet, given to every new client
+	faults client.FaultProfile
+	// admin is how the clients reach the server's admin API, given to every new client
+	admin client.AdminAccess
+	// retiredFaultStats keeps the fault counters of stopped clients until ResetFaultStats
+	retiredFaultStats client.FaultStats
+	// retiredMetrics keeps the request records of stopped clients until ResetMetrics
+	retiredMetrics *metrics.Stats
 }
 
 func NewManager() *Manager {
 	return &Manager{
-		Clients: make(map[string]*client.Emulator),
+		Clients:           make(map[string]*client.Emulator),
+		rampStop:          make(chan struct{}),
+		retiredFaultStats: make(client.FaultStats),
+		retiredMetrics:    metrics.NewStats(),
 	}
 }
 
-func (m *Manager) AddClient(id, serial, serverURL string) *client.Emulator {
+func (m *Manager) AddClient(id, serial, serverURL string, mode client.Mode, profile client.Profile) (*client.Emulator, error) {
+	emu, err := client.NewEmulator(id, serial, serverURL, mode, profile)
+	if err != nil {
+		return nil, err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	emu := client.NewEmulator(id, serial, serverURL)
+	// A client with the same ID from a previous run must not keep polling
+	if old, ok := m.Clients[id]; ok {
+		old.Stop()
+	}
 	m.Clients[id] = emu
-	return emu
+	return emu, nil
 }
 
-func (m *Manager) StartRampUp(count int, serverURL string, startupScenario []client.ScenarioStep) {
-	log.Printf("[Manager] StartRampUp requested for %d clients targeting %s", count, serverURL)
-	go func() {
-		for i := 0; i < count; i++ {
-			id := fmt.Sprintf("client-%d", i)
-			serial := fmt.Sprintf("%032x", i)
+// StartRampUp adds count clients to the fleet, RampUpBatch per second, up to MaxClients in all
+// Their profiles follow the proportions of mix
+func (m *Manager) StartRampUp(count int, serverURL string, mode client.Mode, mix ProfileMix, startupScenario []client.ScenarioStep) {
+	log.Printf("[Manager] StartRampUp requested for %d clients targeting %s (%s mode, profiles %v)", count, serverURL, mode, mix)
 
-			log.Printf("[Manager] Creating client %s", id)
-			emu := m.AddClient(id, serial, serverURL)
-
-			emu.Start()
+	m.mu.RLock()
+	stop := m.rampStop
+	m.mu.RUnlock()
 
-			if len(startupScenario) > 0 {
-				log.Printf("[Manager] Applying startup scenario to client %s", id)
-				emu.ExecuteScenario(startupScenario)
+	go func() {
+		profiles := mix.picker()
+		for i := 0; i < count; i++ {
+			if _, err := m.launchClient(stop, serverURL, mode, profiles.next(), startupScenario); err != nil {
+				log.Printf("[Manager] Ramp-up ended after %d clients: %v", i, err)
+				return
 			}
 
-			if (i+1)%5 == 0 {
-				time.Sleep(1 * time.Second)
+			if (i+1)%RampUpBatch == 0 {
+				wait(stop, 1*time.Second)
 			}
 		}
 		log.Printf("[Manager] Ramp-up complete")
 	}()
 }
 
+// launchClient starts the next client and applies the startup scenario, if any
+func (m *Manager) launchClient(stop <-chan struct{}, serverURL string, mode client.Mode, profile client.Profile, startupScenario []client.ScenarioStep) (*client.Emulator, error) {
+	emu, err := m.startClient(stop, serverURL, mode, profile)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[Manager] Started client %s (%s)", emu.ID, profile.Name)
+
+	if len(startupScenario) > 0 {
+		log.Printf("[Manager] Applying startup scenario to client %s", emu.ID)
+		emu.ExecuteScenario(startupScenario)
+	}
+	return emu, nil
+}
+
+// startClient adds and starts the next client unless the ramp-up was cancelled or the fleet is full
+// The check and the start happen under the lock so that StopAllClients cannot miss the client
+func (m *Manager) startClient(stop <-chan struct{}, serverURL string, mode client.Mode, profile client.Profile) (*client.Emulator, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	select {
+	case <-stop:
+		return nil, fmt.Errorf("cancelled")
+	default:
+	}
+	if len(m.Clients) >= MaxClients {
+		return nil, fmt.Errorf("fleet is full (%d clients)", MaxClients)
+	}
+
+	id := fmt.Sprintf("client-%d", m.nextIndex)
+	serial := fmt.Sprintf("%032x", m.nextIndex)
+
+	emu, err := client.NewEmulator(id, serial, serverURL, mode, profile)
+	if err != nil {
+		return nil, err
+	}
+	m.nextIndex++
+
+	emu.SetFaults(m.faults)
+	emu.SetAdmin(m.admin)
+	m.Clients[id] = emu
+	emu.Start()
+	return emu, nil
+}
+
 func (m *Manager) GetAllClients() []*client.Emulator {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -61,28 +139,132 @@ func (m *Manager) GetAllClients() []*client.Emulator {
 	for _, c := range m.Clients {
 		list = append(list, c)
 	}
+	// Keep the UI list stable between refreshes
+	sort.Slice(list, func(i, j int) bool {
+		if len(list[i].ID) != len(list[j].ID) {
+			return len(list[i].ID) < len(list[j].ID)
+		}
+		return list[i].ID < list[j].ID
+	})
 	return list
 }
 
-func (m *Manager) StopClient(id string) {
+// StopClient stops and removes a client, and reports whether it existed
+func (m *Manager) StopClient(id string) bool {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	emu, ok := m.Clients[id]
+	delete(m.Clients, id)
+	m.mu.Unlock()
 
-	if emu, ok := m.Clients[id]; ok {
+	if ok {
 		emu.Stop()
-		delete(m.Clients, id)
+		m.mu.Lock()
+		m.retire(emu)
+		m.mu.Unlock()
 	}
+	return ok
 }
 
-func (m *Manager) StopAllClients() {
+// retire keeps the counters of a stopped client; m.mu must be held
+func (m *Manager) retire(emu *client.Emulator) {
+	_, stats := emu.Faults()
+	m.retiredFaultStats.Merge(stats)
+	m.retiredMetrics.Merge(emu.Metrics())
+}
+
+// SetFaults sets the fault profile of every client, and of those started later
+func (m *Manager) SetFaults(profile client.FaultProfile) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.faults = profile
+	for _, emu := range m.Clients {
+		emu.SetFaults(profile)
+	}
+	log.Printf("[Manager] Fleet fault profile set to %+v", profile)
+}
 
+// SetAdmin sets how every client, and those started later, reaches the server's admin API
+func (m *Manager) SetAdmin(access client.AdminAccess) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.admin = access
 	for _, emu := range m.Clients {
-		emu.Stop()
+		emu.SetAdmin(access)
+	}
+}
+
+// Faults returns the fleet fault profile and the fault counters of all clients, including stopped ones
+func (m *Manager) Faults() (client.FaultProfile, client.FaultStats) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stats := make(client.FaultStats)
+	stats.Merge(m.retiredFaultStats)
+	for _, emu := range m.Clients {
+		_, emuStats := emu.Faults()
+		stats.Merge(emuStats)
+	}
+	return m.faults, stats
+}
+
+// ResetFaultStats sets the fault counters of the fleet back to zero
+func (m *Manager) ResetFaultStats() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retiredFaultStats = make(client.FaultStats)
+	for _, emu := range m.Clients {
+		emu.ResetFaultStats()
+	}
+}
+
+// Report summarises the requests of all clients, including stopped ones
+func (m *Manager) Report() metrics.Report {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stats := m.retiredMetrics.Clone()
+	for _, emu := range m.Clients {
+		stats.Merge(emu.Metrics())
+	}
+	return stats.Report()
+}
+
+// ResetMetrics forgets the requests of the fleet, to start a new measurement
+func (m *Manager) ResetMetrics() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retiredMetrics = metrics.NewStats()
+	for _, emu := range m.Clients {
+		emu.ResetMetrics()
 	}
+}
+
+// StopAllClients cancels the ramp-ups in progress and stops every client,
+// waiting for their requests in progress to finish
+func (m *Manager) StopAllClients() {
+	m.mu.Lock()
+	close(m.rampStop)
+	m.rampStop = make(chan struct{})
+	clients := m.Clients
 	// Clear the map
 	m.Clients = make(map[string]*client.Emulator)
+	m.nextIndex = 0
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, emu := range clients {
+		wg.Add(1)
+		go func(emu *client.Emulator) {
+			defer wg.Done()
+			emu.Stop()
+		}(emu)
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	for _, emu := range clients {
+		m.retire(emu)
+	}
+	m.mu.Unlock()
+	log.Printf("[Manager] Stopped %d clients", len(clients))
 }
 
 func (m *Manager) GetClient(id string) (*client.Emulator, bool) {
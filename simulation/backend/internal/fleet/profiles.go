@@ -0,0 +1,92 @@
+package fleet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"simulation/internal/client"
+)
+
+// ProfileShare is the part of the fleet standing for one device profile
+type ProfileShare struct {
+	Profile client.Profile
+	Weight  int
+}
+
+// ProfileMix gives each profile its share of the clients a ramp starts
+type ProfileMix []ProfileShare
+
+// DefaultProfileMix starts every client with client.DefaultProfile
+func DefaultProfileMix() ProfileMix {
+	return ProfileMix{{Profile: client.DefaultProfile, Weight: 1}}
+}
+
+// ParseProfileMix parses built-in profiles with their weights, such as "flat:50,house:30,alarm:20"
+// A profile without a weight counts for 1, so "house" alone starts only houses
+func ParseProfileMix(mix string) (ProfileMix, error) {
+	var shares ProfileMix
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(mix, ",") {
+		name, weightStr, hasWeight := strings.Cut(strings.TrimSpace(part), ":")
+		profile, err := client.LookupProfile(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("profile %s appears twice", name)
+		}
+		seen[name] = true
+
+		weight := 1
+		if hasWeight {
+			weight, err = strconv.Atoi(weightStr)
+			if err != nil || weight < 1 {
+				return nil, fmt.Errorf("profile %q: weight must be a positive integer", part)
+			}
+		}
+		shares = append(shares, ProfileShare{Profile: profile, Weight: weight})
+	}
+	return shares, nil
+}
+
+func (mix ProfileMix) String() string {
+	parts := make([]string, len(mix))
+	for i, share := range mix {
+		parts[i] = fmt.Sprintf("%s:%d", share.Profile.Name, share.Weight)
+	}
+	return strings.Join(parts, ",")
+}
+
+// profilePicker hands out the profiles of a mix in its proportions at any point of a ramp, not
+// only once it is over: with "flat:2,house:1" every third client is a house
+type profilePicker struct {
+	mix     ProfileMix
+	current []int
+	total   int
+}
+
+func (mix ProfileMix) picker() *profilePicker {
+	if len(mix) == 0 {
+		mix = DefaultProfileMix()
+	}
+	p := &profilePicker{mix: mix, current: make([]int, len(mix))}
+	for _, share := range mix {
+		p.total += share.Weight
+	}
+	return p
+}
+
+// next returns the profile of the next client, by smooth weighted round-robin: the profile
+// furthest behind its share goes first
+func (p *profilePicker) next() client.Profile {
+	best := 0
+	for i, share := range p.mix {
+		p.current[i] += share.Weight
+		if p.current[i] > p.current[best] {
+			best = i
+		}
+	}
+	p.current[best] -= p.total
+	return p.mix[best].Profile
+}
@@ -0,0 +1,92 @@
+package fleet
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProfileMix(t *testing.T) {
+	tests := []struct {
+		mix      string
+		want     string
+		errorHas string
+	}{
+		{mix: "flat:50,house:30,alarm:20", want: "flat:50,house:30,alarm:20"},
+		{mix: " house ", want: "house:1"},
+		{mix: "flat:2, house", want: "flat:2,house:1"},
+		{mix: "flat:1,flat:2", errorHas: "appears twice"},
+		{mix: "flat,house,flat", errorHas: "appears twice"},
+		{mix: "flat:0", errorHas: "positive integer"},
+		{mix: "flat:-1", errorHas: "positive integer"},
+		{mix: "flat:many", errorHas: "positive integer"},
+		{mix: "castle:1", errorHas: "unknown profile"},
+		{mix: "", errorHas: "unknown profile"},
+		{mix: "flat,", errorHas: "unknown profile"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseProfileMix(tt.mix)
+		if tt.errorHas != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errorHas) {
+				t.Errorf("ParseProfileMix(%q): expected an error containing %q, got %v", tt.mix, tt.errorHas, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseProfileMix(%q) failed: %v", tt.mix, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseProfileMix(%q) = %s, expected %s", tt.mix, got, tt.want)
+		}
+	}
+}
+
+func TestProfilePicker_Order(t *testing.T) {
+	mix, err := ParseProfileMix("flat:2,house:1")
+	if err != nil {
+		t.Fatalf("ParseProfileMix failed: %v", err)
+	}
+	picker := mix.picker()
+
+	var got []string
+	for i := 0; i < 9; i++ {
+		got = append(got, picker.next().Name)
+	}
+	want := "flat,house,flat,flat,house,flat,flat,house,flat"
+	if strings.Join(got, ",") != want {
+		t.Errorf("Expected %s, got %s", want, strings.Join(got, ","))
+	}
+}
+
+func TestProfilePicker_ProportionsAtEveryPrefix(t *testing.T) {
+	for _, spec := range []string{"flat:2,house:1", "flat:50,house:30,alarm:20", "flat:1,house:1,alarm:1,default:1", "alarm:7,house:3"} {
+		mix, err := ParseProfileMix(spec)
+		if err != nil {
+			t.Fatalf("ParseProfileMix(%q) failed: %v", spec, err)
+		}
+		total := 0
+		for _, share := range mix {
+			total += share.Weight
+		}
+
+		picker := mix.picker()
+		counts := make(map[string]int)
+		for n := 1; n <= 3*total; n++ {
+			counts[picker.next().Name]++
+			// Each profile is within one client of its share after every client
+			for _, share := range mix {
+				expected := float64(n*share.Weight) / float64(total)
+				if diff := float64(counts[share.Profile.Name]) - expected; diff <= -1 || diff >= 1 {
+					t.Fatalf("%s: after %d clients, %d are %s, expected about %.2f", spec, n, counts[share.Profile.Name], share.Profile.Name, expected)
+				}
+			}
+		}
+	}
+}
+
+func TestProfilePicker_EmptyMix(t *testing.T) {
+	if got := ProfileMix(nil).picker().next().Name; got != "default" {
+		t.Errorf("Expected an empty mix to start default clients, got %s", got)
+	}
+}
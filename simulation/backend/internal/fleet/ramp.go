@@ -0,0 +1,99 @@
+package fleet
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"simulation/internal/client"
+)
+
+// RampStage brings the fleet to Clients clients, started evenly over Over
+type RampStage struct {
+	Clients int
+	Over    time.Duration
+}
+
+// ParseRampProfile parses stages such as "20:10s,100:1m": 20 clients over the first 10 seconds,
+// then 80 more over a minute
+// A stage may keep the count to hold it; the fleet cannot shrink
+func ParseRampProfile(profile string) ([]RampStage, error) {
+	var stages []RampStage
+	previous := 0
+	for _, part := range strings.Split(profile, ",") {
+		countStr, overStr, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return nil, fmt.Errorf("ramp stage %q: expected clients:duration", part)
+		}
+		count, err := strconv.Atoi(countStr)
+		if err != nil || count < 1 || count > MaxClients {
+			return nil, fmt.Errorf("ramp stage %q: clients must be between 1 and %d", part, MaxClients)
+		}
+		if count < previous {
+			return nil, fmt.Errorf("ramp stage %q: the fleet cannot shrink from %d clients", part, previous)
+		}
+		over, err := time.ParseDuration(overStr)
+		if err != nil || over < 0 {
+			return nil, fmt.Errorf("ramp stage %q: invalid duration", part)
+		}
+		stages = append(stages, RampStage{Clients: count, Over: over})
+		previous = count
+	}
+	return stages, nil
+}
+
+// DefaultRampProfile is the ramp of StartRampUp: RampUpBatch clients per second
+func DefaultRampProfile(count int) []RampStage {
+	return []RampStage{{Clients: count, Over: time.Duration(count/RampUpBatch) * time.Second}}
+}
+
+// Ramp starts clients following the stages, with profiles in the proportions of mix, and returns
+// once the last stage is over
+// It fails when StopAllClients cancels it or the fleet is full
+func (m *Manager) Ramp(stages []RampStage, serverURL string, mode client.Mode, mix ProfileMix, startupScenario []client.ScenarioStep) error {
+	m.mu.RLock()
+	stop := m.rampStop
+	m.mu.RUnlock()
+
+	profiles := mix.picker()
+	started := 0
+	for _, stage := range stages {
+		toStart := stage.Clients - started
+		log.Printf("[Manager] Ramp stage: %d clients over %v", stage.Clients, stage.Over)
+
+		// A stage that starts nobody holds the fleet for its duration
+		if toStart <= 0 {
+			if !wait(stop, stage.Over) {
+				return fmt.Errorf("ramp cancelled after %d clients", started)
+			}
+			continue
+		}
+
+		interval := stage.Over / time.Duration(toStart)
+		for i := 0; i < toStart; i++ {
+			if _, err := m.launchClient(stop, serverURL, mode, profiles.next(), startupScenario); err != nil {
+				return fmt.Errorf("ramp ended after %d clients: %w", started, err)
+			}
+			started++
+			if !wait(stop, interval) {
+				return fmt.Errorf("ramp cancelled after %d clients", started)
+			}
+		}
+	}
+	log.Printf("[Manager] Ramp complete: %d clients", started)
+	return nil
+}
+
+// wait sleeps for d and reports false if the ramp was cancelled meanwhile
+func wait(stop <-chan struct{}, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
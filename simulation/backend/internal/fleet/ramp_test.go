@@ -0,0 +1,65 @@
+package fleet
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRampProfile(t *testing.T) {
+	tests := []struct {
+		profile  string
+		want     []RampStage
+		errorHas string
+	}{
+		{
+			profile: "20:10s,100:1m",
+			want:    []RampStage{{Clients: 20, Over: 10 * time.Second}, {Clients: 100, Over: time.Minute}},
+		},
+		{
+			// A stage keeping the count holds the fleet
+			profile: "10:5s, 10:30s ,20:0s",
+			want:    []RampStage{{Clients: 10, Over: 5 * time.Second}, {Clients: 10, Over: 30 * time.Second}, {Clients: 20}},
+		},
+		{
+			// A zero duration starts the clients at once
+			profile: "5:0s",
+			want:    []RampStage{{Clients: 5}},
+		},
+		{profile: "20:10s,10:10s", errorHas: "cannot shrink from 20"},
+		{profile: "0:10s", errorHas: "between 1 and 100"},
+		{profile: "101:10s", errorHas: "between 1 and 100"},
+		{profile: "ten:10s", errorHas: "between 1 and 100"},
+		{profile: "10", errorHas: "expected clients:duration"},
+		{profile: "10:soon", errorHas: "invalid duration"},
+		{profile: "10:-1s", errorHas: "invalid duration"},
+		{profile: "", errorHas: "expected clients:duration"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRampProfile(tt.profile)
+		if tt.errorHas != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errorHas) {
+				t.Errorf("ParseRampProfile(%q): expected an error containing %q, got %v", tt.profile, tt.errorHas, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRampProfile(%q) failed: %v", tt.profile, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRampProfile(%q) = %+v, expected %+v", tt.profile, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultRampProfile(t *testing.T) {
+	if got := DefaultRampProfile(50); !reflect.DeepEqual(got, []RampStage{{Clients: 50, Over: 10 * time.Second}}) {
+		t.Errorf("Expected 50 clients over 10s at 5 per second, got %+v", got)
+	}
+	if got := DefaultRampProfile(3); got[0].Over != 0 {
+		t.Errorf("Expected fewer than a batch to start at once, got %+v", got)
+	}
+}
@@ -0,0 +1,186 @@
+package metrics
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// AllEndpoints names the report line covering every endpoint
+const AllEndpoints = "all"
+
+// Report summarises Stats for the API and the exports; latencies are in milliseconds
+type Report struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration float64   `json:"duration_s"`
+	// Throughput is the mean number of requests completed per second
+	Throughput float64          `json:"throughput_rps"`
+	Overall    EndpointReport   `json:"overall"`
+	Endpoints  []EndpointReport `json:"endpoints"`
+	Timeline   []TimelinePoint  `json:"timeline"`
+}
+
+// EndpointReport summarises the requests of one endpoint, or of all of them
+// Errors are the requests without a 2xx response, timeouts included
+type EndpointReport struct {
+	Endpoint    string         `json:"endpoint"`
+	Requests    int            `json:"requests"`
+	Errors      int            `json:"errors"`
+	Timeouts    int            `json:"timeouts"`
+	ErrorRate   float64        `json:"error_rate"`
+	TimeoutRate float64        `json:"timeout_rate"`
+	P50         float64        `json:"p50_ms"`
+	P95         float64        `json:"p95_ms"`
+	P99         float64        `json:"p99_ms"`
+	Max         float64        `json:"max_ms"`
+	Outcomes    map[string]int `json:"outcomes"`
+}
+
+// TimelinePoint is the throughput of one TimelineResolution
+type TimelinePoint struct {
+	Time time.Time `json:"time"`
+	Bucket
+}
+
+// Report summarises the requests recorded so far
+func (s *Stats) Report() Report {
+	report := Report{
+		Start:     s.First,
+		End:       s.Last,
+		Endpoints: make([]EndpointReport, 0, len(s.Endpoints)),
+		Timeline:  make([]TimelinePoint, 0, len(s.Timeline)),
+	}
+
+	all := &EndpointStats{Latency: make(map[int]int), Outcomes: make(map[string]int)}
+	for name, ep := range s.Endpoints {
+		report.Endpoints = append(report.Endpoints, ep.report(name))
+		for bucket, count := range ep.Latency {
+			all.Latency[bucket] += count
+		}
+		for outcome, count := range ep.Outcomes {
+			all.Outcomes[outcome] += count
+		}
+	}
+	sort.Slice(report.Endpoints, func(i, j int) bool {
+		return report.Endpoints[i].Endpoint < report.Endpoints[j].Endpoint
+	})
+	report.Overall = all.report(AllEndpoints)
+
+	for slot, bucket := range s.Timeline {
+		report.Timeline = append(report.Timeline, TimelinePoint{Time: time.Unix(slot, 0), Bucket: *bucket})
+	}
+	sort.Slice(report.Timeline, func(i, j int) bool {
+		return report.Timeline[i].Time.Before(report.Timeline[j].Time)
+	})
+
+	// A single request still spans one resolution, so that the throughput is defined
+	duration := s.Last.Sub(s.First)
+	if duration < TimelineResolution {
+		duration = TimelineResolution
+	}
+	if report.Overall.Requests > 0 {
+		report.Duration = duration.Seconds()
+		report.Throughput = float64(report.Overall.Requests) / duration.Seconds()
+	}
+	return report
+}
+
+func (ep *EndpointStats) report(name string) EndpointReport {
+	r := EndpointReport{Endpoint: name, Outcomes: make(map[string]int)}
+	for outcome, count := range ep.Outcomes {
+		r.Outcomes[outcome] = count
+		r.Requests += count
+		if !IsSuccess(outcome) {
+			r.Errors += count
+		}
+		if outcome == OutcomeTimeout {
+			r.Timeouts += count
+		}
+	}
+	if r.Requests > 0 {
+		r.ErrorRate = float64(r.Errors) / float64(r.Requests)
+		r.TimeoutRate = float64(r.Timeouts) / float64(r.Requests)
+	}
+
+	buckets := make([]int, 0, len(ep.Latency))
+	responses := 0
+	for bucket, count := range ep.Latency {
+		buckets = append(buckets, bucket)
+		responses += count
+	}
+	if responses == 0 {
+		return r
+	}
+	sort.Ints(buckets)
+	r.P50 = percentile(ep.Latency, buckets, responses, 0.50)
+	r.P95 = percentile(ep.Latency, buckets, responses, 0.95)
+	r.P99 = percentile(ep.Latency, buckets, responses, 0.99)
+	r.Max = milliseconds(bucketBound(buckets[len(buckets)-1]))
+	return r
+}
+
+// percentile returns the upper bound, in milliseconds, of the bucket holding the quantile q
+func percentile(histogram map[int]int, buckets []int, total int, q float64) float64 {
+	rank := int(q*float64(total) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	seen := 0
+	for _, bucket := range buckets {
+		seen += histogram[bucket]
+		if seen >= rank {
+			return milliseconds(bucketBound(bucket))
+		}
+	}
+	return milliseconds(bucketBound(buckets[len(buckets)-1]))
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// WriteCSV writes one line per endpoint, then the line of all endpoints
+func (r Report) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"endpoint", "requests", "errors", "timeouts", "error_rate", "timeout_rate",
+		"p50_ms", "p95_ms", "p99_ms", "max_ms"})
+	for _, ep := range append(r.Endpoints, r.Overall) {
+		out.Write([]string{
+			ep.Endpoint,
+			strconv.Itoa(ep.Requests),
+			strconv.Itoa(ep.Errors),
+			strconv.Itoa(ep.Timeouts),
+			formatFloat(ep.ErrorRate),
+			formatFloat(ep.TimeoutRate),
+			formatFloat(ep.P50),
+			formatFloat(ep.P95),
+			formatFloat(ep.P99),
+			formatFloat(ep.Max),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteTimelineCSV writes the throughput, one line per TimelineResolution
+func (r Report) WriteTimelineCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"time", "requests", "errors", "timeouts"})
+	for _, point := range r.Timeline {
+		out.Write([]string{
+			point.Time.UTC().Format(time.RFC3339),
+			strconv.Itoa(point.Requests),
+			strconv.Itoa(point.Errors),
+			strconv.Itoa(point.Timeouts),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
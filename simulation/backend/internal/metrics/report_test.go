@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReport_WriteCSV(t *testing.T) {
+	stats := NewStats()
+	stats.Record("POST /api/mystatus", start, 10*time.Millisecond, "201")
+	stats.Record("GET /api/serverinfos", start, 10*time.Millisecond, "200")
+	stats.Record("GET /api/serverinfos", start, 10*time.Millisecond, OutcomeTimeout)
+
+	var buf bytes.Buffer
+	if err := stats.Report().WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+
+	header := "endpoint,requests,errors,timeouts,error_rate,timeout_rate,p50_ms,p95_ms,p99_ms,max_ms"
+	if got := strings.Join(rows[0], ","); got != header {
+		t.Errorf("Unexpected header %s", got)
+	}
+	// Endpoints in alphabetical order, then the line of all endpoints
+	var order []string
+	for _, row := range rows[1:] {
+		order = append(order, row[0])
+	}
+	if got := strings.Join(order, "|"); got != "GET /api/serverinfos|POST /api/mystatus|all" {
+		t.Errorf("Unexpected row order %s", got)
+	}
+	if got := strings.Join(rows[1][1:6], ","); got != "2,1,1,0.5,0.5" {
+		t.Errorf("Unexpected serverinfos counts %s", got)
+	}
+	if rows[3][1] != "3" {
+		t.Errorf("Expected 3 requests in all, got %s", rows[3][1])
+	}
+}
+
+func TestReport_WriteTimelineCSV(t *testing.T) {
+	stats := NewStats()
+	stats.Record("GET /api/myactions", start.Add(2*time.Second), time.Millisecond, "closed")
+	stats.Record("GET /api/myactions", start, time.Millisecond, "200")
+	stats.Record("GET /api/myactions", start.Add(100*time.Millisecond), time.Millisecond, "200")
+
+	var buf bytes.Buffer
+	if err := stats.Report().WriteTimelineCSV(&buf); err != nil {
+		t.Fatalf("WriteTimelineCSV failed: %v", err)
+	}
+	want := "time,requests,errors,timeouts\n" +
+		"2026-01-01T12:00:00Z,2,0,0\n" +
+		"2026-01-01T12:00:02Z,1,1,0\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Unexpected timeline\n got: %q\nwant: %q", got, want)
+	}
+}
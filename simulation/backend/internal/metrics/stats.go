@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	// bucketGrowth is the ratio between the bounds of two latency buckets: percentiles are
+	// accurate to 2%
+	bucketGrowth = 1.02
+
+	// TimelineResolution is the width of a throughput bucket
+	TimelineResolution = time.Second
+)
+
+// OutcomeTimeout is the outcome of a request that got no response in time
+const OutcomeTimeout = "timeout"
+
+// Stats records the latency and outcome of requests, per endpoint, and the throughput over time
+// It is not safe for concurrent use; the emulator guards it with its mutex
+type Stats struct {
+	Endpoints map[string]*EndpointStats `json:"endpoints"`
+	// Timeline counts the requests completed in each TimelineResolution, by Unix second
+	Timeline map[int64]*Bucket `json:"timeline"`
+	First    time.Time         `json:"first"`
+	Last     time.Time         `json:"last"`
+}
+
+// EndpointStats holds the requests of one endpoint
+type EndpointStats struct {
+	// Latency is a histogram of the requests that got a response: bucket to count
+	Latency map[int]int `json:"latency"`
+	// Outcomes counts the status codes and the failures (timeout, closed, refused...)
+	Outcomes map[string]int `json:"outcomes"`
+}
+
+// Bucket counts the requests completed during one TimelineResolution
+type Bucket struct {
+	Requests int `json:"requests"`
+	Errors   int `json:"errors"`
+	Timeouts int `json:"timeouts"`
+}
+
+func NewStats() *Stats {
+	return &Stats{
+		Endpoints: make(map[string]*EndpointStats),
+		Timeline:  make(map[int64]*Bucket),
+	}
+}
+
+// Record counts one request completed at the given time
+// The outcome is the status code, or a failure such as OutcomeTimeout; the latency of a request
+// without response is not recorded, since it only measures how long the client waited
+func (s *Stats) Record(endpoint string, at time.Time, latency time.Duration, outcome string) {
+	ep := s.endpoint(endpoint)
+	ep.Outcomes[outcome]++
+	if IsResponse(outcome) {
+		ep.Latency[bucketOf(latency)]++
+	}
+
+	slot := at.Truncate(TimelineResolution).Unix()
+	bucket := s.Timeline[slot]
+	if bucket == nil {
+		bucket = &Bucket{}
+		s.Timeline[slot] = bucket
+	}
+	bucket.Requests++
+	if !IsSuccess(outcome) {
+		bucket.Errors++
+	}
+	if outcome == OutcomeTimeout {
+		bucket.Timeouts++
+	}
+
+	if s.First.IsZero() || at.Before(s.First) {
+		s.First = at
+	}
+	if at.After(s.Last) {
+		s.Last = at
+	}
+}
+
+func (s *Stats) endpoint(name string) *EndpointStats {
+	ep := s.Endpoints[name]
+	if ep == nil {
+		ep = &EndpointStats{Latency: make(map[int]int), Outcomes: make(map[string]int)}
+		s.Endpoints[name] = ep
+	}
+	return ep
+}
+
+// Merge adds the requests of other to s
+func (s *Stats) Merge(other *Stats) {
+	for name, otherEp := range other.Endpoints {
+		ep := s.endpoint(name)
+		for bucket, count := range otherEp.Latency {
+			ep.Latency[bucket] += count
+		}
+		for outcome, count := range otherEp.Outcomes {
+			ep.Outcomes[outcome] += count
+		}
+	}
+	for slot, otherBucket := range other.Timeline {
+		bucket := s.Timeline[slot]
+		if bucket == nil {
+			bucket = &Bucket{}
+			s.Timeline[slot] = bucket
+		}
+		bucket.Requests += otherBucket.Requests
+		bucket.Errors += otherBucket.Errors
+		bucket.Timeouts += otherBucket.Timeouts
+	}
+	if !other.First.IsZero() && (s.First.IsZero() || other.First.Before(s.First)) {
+		s.First = other.First
+	}
+	if other.Last.After(s.Last) {
+		s.Last = other.Last
+	}
+}
+
+// Clone returns a copy of s
+func (s *Stats) Clone() *Stats {
+	clone := NewStats()
+	clone.Merge(s)
+	return clone
+}
+
+// IsResponse reports whether an outcome is a status code, as opposed to a failure
+func IsResponse(outcome string) bool {
+	return outcome != "" && outcome[0] >= '1' && outcome[0] <= '5'
+}
+
+// IsSuccess reports whether an outcome is a 2xx status
+func IsSuccess(outcome string) bool {
+	return len(outcome) == 3 && strings.HasPrefix(outcome, "2")
+}
+
+// bucketOf returns the histogram bucket of a latency: bucket i holds latencies up to
+// bucketGrowth^i microseconds
+func bucketOf(latency time.Duration) int {
+	us := float64(latency) / float64(time.Microsecond)
+	if us <= 1 {
+		return 0
+	}
+	return int(math.Ceil(math.Log(us) / math.Log(bucketGrowth)))
+}
+
+// bucketBound returns the upper bound of a histogram bucket
+func bucketBound(bucket int) time.Duration {
+	return time.Duration(math.Pow(bucketGrowth, float64(bucket)) * float64(time.Microsecond))
+}
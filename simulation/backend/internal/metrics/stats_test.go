@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// within2Percent reports whether got is at most 2% away from want
+func within2Percent(got, want float64) bool {
+	return math.Abs(got-want) <= want*0.02
+}
+
+func TestReport_Percentiles(t *testing.T) {
+	tests := []struct {
+		name          string
+		latencies     func() []time.Duration
+		p50, p95, p99 float64
+		max           float64
+	}{
+		{
+			name: "uniform 1-1000ms",
+			latencies: func() []time.Duration {
+				var l []time.Duration
+				for ms := 1; ms <= 1000; ms++ {
+					l = append(l, time.Duration(ms)*time.Millisecond)
+				}
+				return l
+			},
+			p50: 500, p95: 950, p99: 990, max: 1000,
+		},
+		{
+			name: "one slow request in a hundred",
+			latencies: func() []time.Duration {
+				var l []time.Duration
+				for i := 0; i < 99; i++ {
+					l = append(l, 10*time.Millisecond)
+				}
+				return append(l, time.Second)
+			},
+			p50: 10, p95: 10, p99: 10, max: 1000,
+		},
+		{
+			name: "sub-millisecond",
+			latencies: func() []time.Duration {
+				return []time.Duration{200 * time.Microsecond, 400 * time.Microsecond, 800 * time.Microsecond}
+			},
+			p50: 0.4, p95: 0.8, p99: 0.8, max: 0.8,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := NewStats()
+			for i, latency := range tt.latencies() {
+				stats.Record("GET /api/myactions", start.Add(time.Duration(i)*time.Millisecond), latency, "200")
+			}
+			r := stats.Report().Overall
+			for _, p := range []struct {
+				name      string
+				got, want float64
+			}{{"p50", r.P50, tt.p50}, {"p95", r.P95, tt.p95}, {"p99", r.P99, tt.p99}, {"max", r.Max, tt.max}} {
+				if !within2Percent(p.got, p.want) {
+					t.Errorf("%s = %vms, expected %vms within 2%%", p.name, p.got, p.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReport_ErrorAndTimeoutRates(t *testing.T) {
+	stats := NewStats()
+	outcomes := []string{"201", "201", "201", "201", "201", "201", "201", "500", OutcomeTimeout, OutcomeTimeout}
+	for i, outcome := range outcomes {
+		stats.Record("POST /api/mystatus", start.Add(time.Duration(i)*500*time.Millisecond), 10*time.Millisecond, outcome)
+	}
+
+	report := stats.Report()
+	r := report.Overall
+	if r.Requests != 10 || r.Errors != 3 || r.Timeouts != 2 {
+		t.Errorf("Expected 10 requests, 3 errors, 2 timeouts, got %d, %d, %d", r.Requests, r.Errors, r.Timeouts)
+	}
+	if r.ErrorRate != 0.3 || r.TimeoutRate != 0.2 {
+		t.Errorf("Expected rates 0.3 and 0.2, got %v and %v", r.ErrorRate, r.TimeoutRate)
+	}
+	// Timeouts have no latency: only the 8 responses are in the histogram
+	responses := 0
+	for _, count := range stats.Endpoints["POST /api/mystatus"].Latency {
+		responses += count
+	}
+	if responses != 8 {
+		t.Errorf("Expected 8 latencies, got %d", responses)
+	}
+	// 10 requests over 4.5s
+	if !within2Percent(report.Throughput, 10/4.5) {
+		t.Errorf("Expected %.2f requests/s, got %v", 10/4.5, report.Throughput)
+	}
+	if len(report.Timeline) != 5 || report.Timeline[4].Timeouts != 2 {
+		t.Errorf("Expected 5 timeline seconds with the timeouts in the last, got %+v", report.Timeline)
+	}
+}
+
+func TestReport_Empty(t *testing.T) {
+	report := NewStats().Report()
+	if report.Overall.Requests != 0 || report.Throughput != 0 || report.Overall.P99 != 0 {
+		t.Errorf("Expected an empty report, got %+v", report)
+	}
+}
+
+func TestStats_MergeAndClone(t *testing.T) {
+	a := NewStats()
+	a.Record("GET /api/serverinfos", start.Add(time.Second), 5*time.Millisecond, "200")
+	b := NewStats()
+	b.Record("GET /api/serverinfos", start, 5*time.Millisecond, "200")
+	b.Record("GET /api/myactions", start.Add(3*time.Second), 5*time.Millisecond, "closed")
+
+	a.Merge(b)
+	if got := a.Endpoints["GET /api/serverinfos"].Outcomes["200"]; got != 2 {
+		t.Errorf("Expected 2 merged serverinfos, got %d", got)
+	}
+	if got := a.Endpoints["GET /api/myactions"].Outcomes["closed"]; got != 1 {
+		t.Errorf("Expected the myactions endpoint merged in, got %d", got)
+	}
+	if !a.First.Equal(start) || !a.Last.Equal(start.Add(3*time.Second)) {
+		t.Errorf("Expected the merged span %v-%v, got %v-%v", start, start.Add(3*time.Second), a.First, a.Last)
+	}
+	if a.Timeline[start.Unix()].Requests != 1 || a.Timeline[start.Add(3*time.Second).Unix()].Errors != 1 {
+		t.Errorf("Unexpected merged timeline %+v", a.Timeline)
+	}
+
+	// Merging an empty Stats keeps the span
+	a.Merge(NewStats())
+	if !a.First.Equal(start) {
+		t.Errorf("Expected First kept, got %v", a.First)
+	}
+
+	clone := a.Clone()
+	clone.Record("GET /api/serverinfos", start, time.Millisecond, "200")
+	if a.Endpoints["GET /api/serverinfos"].Outcomes["200"] != 2 || a.Timeline[start.Unix()].Requests != 1 {
+		t.Error("Expected the clone not to share its maps with the original")
+	}
+	if clone.Report().Overall.Requests != 4 {
+		t.Errorf("Expected 4 requests in the clone, got %d", clone.Report().Overall.Requests)
+	}
+}
+
+func TestIsResponseAndIsSuccess(t *testing.T) {
+	tests := []struct {
+		outcome  string
+		response bool
+		success  bool
+	}{
+		{"200", true, true},
+		{"201", true, true},
+		{"429", true, false},
+		{"500", true, false},
+		{OutcomeTimeout, false, false},
+		{"closed", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		if IsResponse(tt.outcome) != tt.response || IsSuccess(tt.outcome) != tt.success {
+			t.Errorf("%q: IsResponse=%v IsSuccess=%v, expected %v %v", tt.outcome,
+				IsResponse(tt.outcome), IsSuccess(tt.outcome), tt.response, tt.success)
+		}
+	}
+}
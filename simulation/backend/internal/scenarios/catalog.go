@@ -0,0 +1,109 @@
+package scenarios
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"simulation/internal/client"
+)
+
+// IndexDef describes an exchange table index a scenario may use
+type IndexDef struct {
+	Name string `json:"name"`
+	// Bitfield indices are reported as 8 bits, bit 0 first, so conditions compare them that way
+	Bitfield bool `json:"bitfield,omitempty"`
+	// Values, when set, are the only codes the index takes, such as the heating modes
+	Values []string `json:"values,omitempty"`
+}
+
+// Codes of the heating zones and of the water heater, as the profiles seed them
+var (
+	heatingModes     = []string{client.HeatingAuto, client.HeatingOff, client.HeatingComfort, client.HeatingEco, client.HeatingFrostFree}
+	waterHeaterModes = []string{client.WaterHeaterAuto, client.WaterHeaterOff}
+)
+
+// Catalog lists the indices scenarios may set or check, as the UI's scenario editor offers them
+var Catalog = map[int]IndexDef{
+	349: {Name: "Heating mode, day zone", Values: heatingModes},
+	350: {Name: "Heating mode, night zone", Values: heatingModes},
+	351: {Name: "Heating mode, bathroom 1", Values: heatingModes},
+	352: {Name: "Heating mode, bathroom 2", Values: heatingModes},
+	353: {Name: "Water heater mode", Values: waterHeaterModes},
+	363: {Name: "Alerts and watering", Bitfield: true},
+	425: {Name: "Reported to the server (undocumented)"},
+	426: {Name: "Reported to the server (undocumented)"},
+	440: {Name: "Security socket"},
+	590: {Name: "Scenario trigger"},
+	605: {Name: "Lights off, block 1"},
+	606: {Name: "Lights off, block 2"},
+	607: {Name: "Lights off, block 3"},
+	608: {Name: "Lights off, block 4"},
+	609: {Name: "Lights off, block 5"},
+	610: {Name: "Lights off, block 6"},
+	611: {Name: "Lights on, block 1"},
+	612: {Name: "Lights on, block 2"},
+	613: {Name: "Lights on, block 3"},
+	614: {Name: "Lights on, block 4"},
+	615: {Name: "Lights on, block 5"},
+	616: {Name: "Lights on, block 6"},
+	617: {Name: "Shutters open, block 1"},
+	618: {Name: "Shutters open, block 2"},
+	619: {Name: "Shutters open, block 3"},
+	620: {Name: "Shutters close, block 1"},
+	621: {Name: "Shutters close, block 2"},
+	622: {Name: "Shutters close, block 3"},
+	920: {Name: "Reported to the server (undocumented)"},
+}
+
+var bitsPattern = regexp.MustCompile(`^[01]{8}$`)
+
+// ValidateSteps checks steps as client.ValidateSteps does, then checks every index against the
+// Catalog and every value against what the firmware stores: a byte, or 8 bits for a bitfield
+func ValidateSteps(steps []client.ScenarioStep) error {
+	if err := client.ValidateSteps(steps); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	for i, step := range steps {
+		for _, job := range append(append([]client.ScenarioJob(nil), step.Jobs...), step.Inject...) {
+			if err := validateValue(job.Index, job.Value, false); err != nil {
+				return fmt.Errorf("%w: step %d: %v", ErrInvalid, i+1, err)
+			}
+		}
+		conditions := step.Expect
+		if step.Wait != nil {
+			conditions = append([]client.Condition{*step.Wait}, conditions...)
+		}
+		for _, condition := range conditions {
+			if err := validateValue(condition.Index, condition.Equals, true); err != nil {
+				return fmt.Errorf("%w: step %d: condition: %v", ErrInvalid, i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateValue checks that an index is in the Catalog and that the value fits it
+// Values are sent as decimal bytes; conditions read bitfields back as 8 bits
+func validateValue(index int, value string, reported bool) error {
+	def, ok := Catalog[index]
+	if !ok {
+		return fmt.Errorf("index %d is not in the catalog", index)
+	}
+	if reported && def.Bitfield {
+		if !bitsPattern.MatchString(value) {
+			return fmt.Errorf("index %d (%s) is reported as 8 bits such as 00100000, got %q", index, def.Name, value)
+		}
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > 255 {
+		return fmt.Errorf("index %d (%s) holds a byte from 0 to 255, got %q", index, def.Name, value)
+	}
+	if len(def.Values) > 0 && !slices.Contains(def.Values, strconv.Itoa(n)) {
+		return fmt.Errorf("index %d (%s) takes one of %s, got %q", index, def.Name, strings.Join(def.Values, ", "), value)
+	}
+	return nil
+}
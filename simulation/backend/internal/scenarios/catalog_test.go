@@ -0,0 +1,71 @@
+package scenarios
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"simulation/internal/client"
+)
+
+func TestValidateSteps(t *testing.T) {
+	job := func(index int, value string) client.ScenarioStep {
+		return client.ScenarioStep{Jobs: []client.ScenarioJob{{Index: index, Value: value}}}
+	}
+	expect := func(index int, equals string) client.ScenarioStep {
+		return client.ScenarioStep{Expect: []client.Condition{{On: client.OnClient, Index: index, Equals: equals}}}
+	}
+
+	tests := []struct {
+		name     string
+		step     client.ScenarioStep
+		errorHas string
+	}{
+		{name: "byte", step: job(613, "64")},
+		{name: "byte bounds", step: job(605, "255")},
+		{name: "bitfield set as a byte", step: job(363, "4")},
+		{name: "bitfield checked as bits", step: expect(363, "00100000")},
+		{name: "byte checked", step: expect(613, "64")},
+		{name: "heating mode", step: job(349, client.HeatingComfort)},
+		{name: "heating mode checked", step: expect(350, client.HeatingFrostFree)},
+		{name: "water heater mode", step: job(353, client.WaterHeaterOff)},
+		{name: "temperature for a heating mode", step: job(349, "22"), errorHas: "takes one of 1, 16, 17, 18, 21"},
+		{name: "heating mode for the water heater", step: expect(353, client.HeatingComfort), errorHas: "takes one of 1, 2"},
+		{name: "injected", step: client.ScenarioStep{Inject: []client.ScenarioJob{{Index: 590, Value: "1"}}}},
+		{name: "index not in the catalog", step: job(999, "1"), errorHas: "not in the catalog"},
+		{name: "injected index not in the catalog", step: client.ScenarioStep{Inject: []client.ScenarioJob{{Index: 1, Value: "1"}}}, errorHas: "not in the catalog"},
+		{name: "value above a byte", step: job(613, "256"), errorHas: "byte from 0 to 255"},
+		{name: "negative value", step: job(613, "-1"), errorHas: "byte from 0 to 255"},
+		{name: "not a number", step: job(613, "on"), errorHas: "byte from 0 to 255"},
+		{name: "bitfield checked as a byte", step: expect(363, "4"), errorHas: "8 bits"},
+		{name: "wait without timeout", step: client.ScenarioStep{Wait: &client.Condition{On: client.OnServer, Index: 613, Equals: "64"}}, errorHas: "within"},
+		{name: "condition elsewhere", step: client.ScenarioStep{Expect: []client.Condition{{On: "box", Index: 613, Equals: "64"}}}, errorHas: "condition on"},
+		{name: "negative delay", step: client.ScenarioStep{Delay: -1}, errorHas: "delay"},
+	}
+
+	for _, tt := range tests {
+		err := ValidateSteps([]client.ScenarioStep{tt.step})
+		if tt.errorHas == "" {
+			if err != nil {
+				t.Errorf("%s: ValidateSteps failed: %v", tt.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), tt.errorHas) {
+			t.Errorf("%s: expected ErrInvalid containing %q, got %v", tt.name, tt.errorHas, err)
+		}
+	}
+}
+
+func TestCatalog_AgreesWithProfiles(t *testing.T) {
+	for _, name := range client.ProfileNames() {
+		for index, value := range client.Profiles[name].Seed {
+			if _, ok := Catalog[index]; !ok {
+				continue
+			}
+			if err := validateValue(index, value, false); err != nil {
+				t.Errorf("profile %s seeds a value the catalog refuses: %v", name, err)
+			}
+		}
+	}
+}
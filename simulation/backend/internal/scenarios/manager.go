@@ -2,79 +2,382 @@ package scenarios
 
 import (
 	"encoding/json"
-	"io/ioutil"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"simulation/internal/client"
 )
 
-const ScenarioDir = "scenarios_data"
+// DefaultScenarioDir is where the simulator keeps scenarios, relative to its working directory
+const DefaultScenarioDir = "scenarios_data"
+
+// historyDir holds every saved revision, one directory per scenario
+const historyDir = "history"
+
+// MaxRevisions is how many revisions of a scenario are kept, the oldest being removed first
+const MaxRevisions = 20
+
+// BundleFormat identifies the export files of scenario bundles
+const BundleFormat = "essensys-scenarios"
+
+// bundleVersion is the version of the bundle layout
+const bundleVersion = 1
+
+var (
+	// ErrInvalid is wrapped by the errors of invalid names, steps and bundles
+	ErrInvalid = errors.New("invalid scenario")
+
+	// namePattern allows letters, digits, spaces, - and _, so a name can never leave the scenario directory
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9 _-]{0,62}[A-Za-z0-9_-])?$`)
+)
 
 type Manager struct {
 	mu sync.RWMutex
+	// Dir holds a file per scenario and the history of their revisions
+	Dir string
 }
 
-func NewManager() *Manager {
+func NewManager(dir string) *Manager {
 	// Ensure directory exists
-	if _, err := os.Stat(ScenarioDir); os.IsNotExist(err) {
-		os.Mkdir(ScenarioDir, 0755)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		os.Mkdir(dir, 0755)
 	}
-	return &Manager{}
+	return &Manager{Dir: dir}
 }
 
+// ScenarioWrapper is a saved scenario, as stored in its file and served by the API
 type ScenarioWrapper struct {
-	Name  string                `json:"name"`
-	Steps []client.ScenarioStep `json:"steps"`
+	Name     string                `json:"name"`
+	Revision int                   `json:"revision"`
+	SavedAt  time.Time             `json:"saved_at"`
+	Steps    []client.ScenarioStep `json:"steps"`
+}
+
+// Revision describes one saved revision of a scenario
+type Revision struct {
+	Revision int       `json:"revision"`
+	SavedAt  time.Time `json:"saved_at"`
+	Steps    int       `json:"steps"`
+}
+
+// Bundle carries scenarios from one simulator to another
+type Bundle struct {
+	Format     string            `json:"format"`
+	Version    int               `json:"version"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Scenarios  []ScenarioWrapper `json:"scenarios"`
+}
+
+// ValidateName checks that a name is usable as a file name inside the scenario directory
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("%w: name %q must be 1 to 64 letters, digits, spaces, - or _, starting with a letter or digit", ErrInvalid, name)
+	}
+	return nil
 }
 
 func (m *Manager) ListScenarios() ([]string, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	files, err := ioutil.ReadDir(ScenarioDir)
+	files, err := os.ReadDir(m.Dir)
 	if err != nil {
 		return nil, err
 	}
 
 	var names []string
 	for _, f := range files {
-		if !f.IsDir() && strings.HasSuffix(f.Name(), ".json") {
-			names = append(names, strings.TrimSuffix(f.Name(), ".json"))
+		name := strings.TrimSuffix(f.Name(), ".json")
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".json") && ValidateName(name) == nil {
+			names = append(names, name)
 		}
 	}
 	return names, nil
 }
 
-func (m *Manager) SaveScenario(name string, steps []client.ScenarioStep) error {
+// SaveScenario validates the steps and saves them as the next revision of the scenario
+func (m *Manager) SaveScenario(name string, steps []client.ScenarioStep) (ScenarioWrapper, error) {
+	if err := ValidateName(name); err != nil {
+		return ScenarioWrapper{}, err
+	}
+	if err := ValidateSteps(steps); err != nil {
+		return ScenarioWrapper{}, err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return m.save(name, steps)
+}
+
+// save writes the next revision; m.mu must be held
+func (m *Manager) save(name string, steps []client.ScenarioStep) (ScenarioWrapper, error) {
+	scenario := ScenarioWrapper{Name: name, Revision: 1, SavedAt: time.Now().UTC(), Steps: steps}
+
+	current, err := m.load(name)
+	switch {
+	case err == nil:
+		scenario.Revision = current.Revision + 1
+		// A file saved before revisions were kept has none in the history yet
+		if _, err := os.Stat(m.revisionPath(name, current.Revision)); errors.Is(err, fs.ErrNotExist) {
+			if err := writeJSON(m.revisionPath(name, current.Revision), current); err != nil {
+				return ScenarioWrapper{}, err
+			}
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return ScenarioWrapper{}, err
+	}
 
-	path := filepath.Join(ScenarioDir, name+".json")
-	data, err := json.MarshalIndent(steps, "", "  ")
+	if err := writeJSON(m.revisionPath(name, scenario.Revision), scenario); err != nil {
+		return ScenarioWrapper{}, err
+	}
+	if err := writeJSON(m.scenarioPath(name), scenario); err != nil {
+		return ScenarioWrapper{}, err
+	}
+	return scenario, m.prune(name)
+}
+
+// prune removes the oldest revisions beyond MaxRevisions; m.mu must be held
+func (m *Manager) prune(name string) error {
+	revisions, err := m.revisionNumbers(name)
 	if err != nil {
 		return err
 	}
+	for len(revisions) > MaxRevisions {
+		if err := os.Remove(m.revisionPath(name, revisions[0])); err != nil {
+			return err
+		}
+		revisions = revisions[1:]
+	}
+	return nil
+}
+
+// LoadScenario returns the last revision of a scenario
+func (m *Manager) LoadScenario(name string) (ScenarioWrapper, error) {
+	if err := ValidateName(name); err != nil {
+		return ScenarioWrapper{}, err
+	}
 
-	return ioutil.WriteFile(path, data, 0644)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.load(name)
 }
 
-func (m *Manager) LoadScenario(name string) ([]client.ScenarioStep, error) {
+// load reads the current file of a scenario; m.mu must be held
+func (m *Manager) load(name string) (ScenarioWrapper, error) {
+	data, err := os.ReadFile(m.scenarioPath(name))
+	if err != nil {
+		return ScenarioWrapper{}, err
+	}
+	scenario, err := Decode(data)
+	if err != nil {
+		return ScenarioWrapper{}, err
+	}
+	scenario.Name = name
+	return scenario, nil
+}
+
+// Revisions lists the kept revisions of a scenario, oldest first
+func (m *Manager) Revisions(name string) ([]Revision, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	path := filepath.Join(ScenarioDir, name+".json")
-	data, err := ioutil.ReadFile(path)
+	current, err := m.load(name)
 	if err != nil {
 		return nil, err
 	}
+	numbers, err := m.revisionNumbers(name)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make([]Revision, 0, len(numbers)+1)
+	for _, number := range numbers {
+		scenario, err := m.loadRevision(name, number)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, Revision{Revision: number, SavedAt: scenario.SavedAt, Steps: len(scenario.Steps)})
+	}
+	// A file saved before revisions were kept is its only revision
+	if len(revisions) == 0 || revisions[len(revisions)-1].Revision != current.Revision {
+		revisions = append(revisions, Revision{Revision: current.Revision, SavedAt: current.SavedAt, Steps: len(current.Steps)})
+	}
+	return revisions, nil
+}
+
+// LoadRevision returns a kept revision of a scenario
+func (m *Manager) LoadRevision(name string, revision int) (ScenarioWrapper, error) {
+	if err := ValidateName(name); err != nil {
+		return ScenarioWrapper{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	var steps []client.ScenarioStep
-	if err := json.Unmarshal(data, &steps); err != nil {
+	scenario, err := m.loadRevision(name, revision)
+	if errors.Is(err, fs.ErrNotExist) {
+		// The current file of a scenario saved before revisions were kept
+		if current, currentErr := m.load(name); currentErr == nil && current.Revision == revision {
+			return current, nil
+		}
+	}
+	return scenario, err
+}
+
+func (m *Manager) loadRevision(name string, revision int) (ScenarioWrapper, error) {
+	data, err := os.ReadFile(m.revisionPath(name, revision))
+	if err != nil {
+		return ScenarioWrapper{}, err
+	}
+	scenario, err := Decode(data)
+	if err != nil {
+		return ScenarioWrapper{}, err
+	}
+	scenario.Name = name
+	return scenario, nil
+}
+
+// revisionNumbers lists the revisions in the history of a scenario, in ascending order
+func (m *Manager) revisionNumbers(name string) ([]int, error) {
+	files, err := os.ReadDir(filepath.Join(m.Dir, historyDir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
 		return nil, err
 	}
 
-	return steps, nil
+	var numbers []int
+	for _, f := range files {
+		number, err := strconv.Atoi(strings.TrimSuffix(f.Name(), ".json"))
+		if err == nil && !f.IsDir() {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// Export bundles the last revision of the named scenarios, or of all of them when names is empty
+func (m *Manager) Export(names []string) (Bundle, error) {
+	if len(names) == 0 {
+		var err error
+		if names, err = m.ListScenarios(); err != nil {
+			return Bundle{}, err
+		}
+	}
+
+	bundle := Bundle{Format: BundleFormat, Version: bundleVersion, ExportedAt: time.Now().UTC(), Scenarios: []ScenarioWrapper{}}
+	for _, name := range names {
+		scenario, err := m.LoadScenario(name)
+		if err != nil {
+			return Bundle{}, fmt.Errorf("scenario %s: %w", name, err)
+		}
+		bundle.Scenarios = append(bundle.Scenarios, scenario)
+	}
+	return bundle, nil
+}
+
+// Import validates every scenario of a bundle, then saves each one as its next revision
+// Nothing is saved when one of them is invalid
+func (m *Manager) Import(bundle Bundle) ([]ScenarioWrapper, error) {
+	if bundle.Format != BundleFormat || bundle.Version != bundleVersion {
+		return nil, fmt.Errorf("%w: expected a %s bundle of version %d", ErrInvalid, BundleFormat, bundleVersion)
+	}
+	seen := make(map[string]bool)
+	for _, scenario := range bundle.Scenarios {
+		if err := ValidateName(scenario.Name); err != nil {
+			return nil, err
+		}
+		if seen[scenario.Name] {
+			return nil, fmt.Errorf("%w: scenario %s appears twice", ErrInvalid, scenario.Name)
+		}
+		seen[scenario.Name] = true
+		if err := ValidateSteps(scenario.Steps); err != nil {
+			return nil, fmt.Errorf("scenario %s: %w", scenario.Name, err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	saved := make([]ScenarioWrapper, 0, len(bundle.Scenarios))
+	for _, scenario := range bundle.Scenarios {
+		result, err := m.save(scenario.Name, scenario.Steps)
+		if err != nil {
+			return saved, fmt.Errorf("scenario %s: %w", scenario.Name, err)
+		}
+		saved = append(saved, result)
+	}
+	return saved, nil
+}
+
+// Decode reads a scenario file: a ScenarioWrapper, or the bare array of steps that files held
+// before revisions were kept (revision 0)
+func Decode(data []byte) (ScenarioWrapper, error) {
+	var scenario ScenarioWrapper
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(data, &scenario.Steps); err != nil {
+			return ScenarioWrapper{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		return scenario, nil
+	}
+	if err := json.Unmarshal(data, &scenario); err != nil {
+		return ScenarioWrapper{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return scenario, nil
+}
+
+// ReadFile reads and validates a scenario file outside the scenario directory, such as a loadtest -scenario
+func ReadFile(path string) (ScenarioWrapper, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ScenarioWrapper{}, err
+	}
+	scenario, err := Decode(data)
+	if err != nil {
+		return ScenarioWrapper{}, err
+	}
+	if err := ValidateSteps(scenario.Steps); err != nil {
+		return ScenarioWrapper{}, err
+	}
+	return scenario, nil
+}
+
+func (m *Manager) scenarioPath(name string) string {
+	return filepath.Join(m.Dir, name+".json")
+}
+
+func (m *Manager) revisionPath(name string, revision int) string {
+	return filepath.Join(m.Dir, historyDir, name, strconv.Itoa(revision)+".json")
+}
+
+// writeJSON writes a file through a temporary file, so that a crash never leaves half of it
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
 }
@@ -0,0 +1,204 @@
+package scenarios
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"simulation/internal/client"
+)
+
+// lights is a valid scenario of one step, whose value tells the saves apart
+func lights(value string) []client.ScenarioStep {
+	return []client.ScenarioStep{{Jobs: []client.ScenarioJob{{Index: 613, Value: value}}}}
+}
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	return NewManager(filepath.Join(t.TempDir(), "scenarios"))
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"lights", "Morning scene_2", "a", "night-mode", strings.Repeat("a", 64)} {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("ValidateName(%q) failed: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "../x", "a/b", `a\b`, ".hidden", "trailing ", "x.json", strings.Repeat("a", 65)} {
+		if err := ValidateName(name); !errors.Is(err, ErrInvalid) {
+			t.Errorf("ValidateName(%q): expected ErrInvalid, got %v", name, err)
+		}
+	}
+}
+
+func TestManager_WritesOnlyUnderDir(t *testing.T) {
+	root := t.TempDir()
+	m := NewManager(filepath.Join(root, "scenarios"))
+
+	if _, err := m.SaveScenario("lights", lights("64")); err != nil {
+		t.Fatalf("SaveScenario failed: %v", err)
+	}
+	for _, name := range []string{"../x", "a/b", ""} {
+		if _, err := m.SaveScenario(name, lights("64")); !errors.Is(err, ErrInvalid) {
+			t.Errorf("SaveScenario(%q): expected ErrInvalid, got %v", name, err)
+		}
+		if _, err := m.LoadScenario(name); !errors.Is(err, ErrInvalid) {
+			t.Errorf("LoadScenario(%q): expected ErrInvalid, got %v", name, err)
+		}
+	}
+
+	var files []string
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(root, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	want := []string{"scenarios/history/lights/1.json", "scenarios/lights.json"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("Expected only %v to be written, got %v", want, files)
+	}
+}
+
+func TestManager_Revisions(t *testing.T) {
+	m := newTestManager(t)
+	for i, value := range []string{"1", "2", "3"} {
+		saved, err := m.SaveScenario("lights", lights(value))
+		if err != nil {
+			t.Fatalf("SaveScenario failed: %v", err)
+		}
+		if saved.Revision != i+1 {
+			t.Errorf("Expected revision %d, got %d", i+1, saved.Revision)
+		}
+	}
+
+	current, err := m.LoadScenario("lights")
+	if err != nil || current.Revision != 3 || current.Steps[0].Jobs[0].Value != "3" {
+		t.Errorf("Expected revision 3 as the current one, got %+v, %v", current, err)
+	}
+	second, err := m.LoadRevision("lights", 2)
+	if err != nil || second.Steps[0].Jobs[0].Value != "2" {
+		t.Errorf("Expected revision 2 kept, got %+v, %v", second, err)
+	}
+	revisions, err := m.Revisions("lights")
+	if err != nil || len(revisions) != 3 || revisions[0].Revision != 1 || revisions[2].Revision != 3 {
+		t.Errorf("Expected revisions 1 to 3, got %+v, %v", revisions, err)
+	}
+	if _, err := m.LoadRevision("lights", 4); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected an unknown revision not to exist, got %v", err)
+	}
+	if _, err := m.Revisions("unknown"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected an unknown scenario not to exist, got %v", err)
+	}
+}
+
+func TestManager_PrunesOldestRevisions(t *testing.T) {
+	m := newTestManager(t)
+	for i := 0; i < MaxRevisions+3; i++ {
+		if _, err := m.SaveScenario("lights", lights("64")); err != nil {
+			t.Fatalf("SaveScenario failed: %v", err)
+		}
+	}
+
+	revisions, err := m.Revisions("lights")
+	if err != nil {
+		t.Fatalf("Revisions failed: %v", err)
+	}
+	if len(revisions) != MaxRevisions || revisions[0].Revision != 4 || revisions[MaxRevisions-1].Revision != MaxRevisions+3 {
+		t.Errorf("Expected revisions 4 to %d, got %+v", MaxRevisions+3, revisions)
+	}
+	if _, err := m.LoadRevision("lights", 3); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected revision 3 pruned, got %v", err)
+	}
+}
+
+func TestManager_MigratesLegacyFile(t *testing.T) {
+	m := newTestManager(t)
+	// Files saved before revisions were kept hold a bare array of steps
+	legacy := `[{"jobs":[{"index":613,"value":"64"}],"delay":0}]`
+	if err := os.WriteFile(filepath.Join(m.Dir, "legacy.json"), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := m.LoadScenario("legacy")
+	if err != nil || current.Name != "legacy" || current.Revision != 0 || len(current.Steps) != 1 {
+		t.Fatalf("Expected the legacy file as revision 0, got %+v, %v", current, err)
+	}
+	if revisions, err := m.Revisions("legacy"); err != nil || len(revisions) != 1 || revisions[0].Revision != 0 {
+		t.Errorf("Expected revision 0 alone, got %+v, %v", revisions, err)
+	}
+
+	saved, err := m.SaveScenario("legacy", lights("1"))
+	if err != nil || saved.Revision != 1 {
+		t.Fatalf("Expected the next save to be revision 1, got %+v, %v", saved, err)
+	}
+	// The legacy content is moved to the history, so it can still be loaded
+	old, err := m.LoadRevision("legacy", 0)
+	if err != nil || old.Steps[0].Jobs[0].Value != "64" {
+		t.Errorf("Expected revision 0 kept, got %+v, %v", old, err)
+	}
+	if revisions, err := m.Revisions("legacy"); err != nil || len(revisions) != 2 {
+		t.Errorf("Expected revisions 0 and 1, got %+v, %v", revisions, err)
+	}
+}
+
+func TestManager_ImportIsAllOrNothing(t *testing.T) {
+	bundle := func(scenarios ...ScenarioWrapper) Bundle {
+		return Bundle{Format: BundleFormat, Version: bundleVersion, Scenarios: scenarios}
+	}
+	valid := ScenarioWrapper{Name: "lights", Steps: lights("64")}
+
+	tests := []struct {
+		name   string
+		bundle Bundle
+	}{
+		{"invalid steps", bundle(valid, ScenarioWrapper{Name: "unknown", Steps: []client.ScenarioStep{{Jobs: []client.ScenarioJob{{Index: 999, Value: "1"}}}}})},
+		{"invalid name", bundle(valid, ScenarioWrapper{Name: "../x", Steps: lights("1")})},
+		{"duplicate name", bundle(valid, valid)},
+		{"other format", Bundle{Format: "other", Version: bundleVersion, Scenarios: []ScenarioWrapper{valid}}},
+		{"other version", Bundle{Format: BundleFormat, Version: 2, Scenarios: []ScenarioWrapper{valid}}},
+	}
+	for _, tt := range tests {
+		m := newTestManager(t)
+		if _, err := m.Import(tt.bundle); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: expected ErrInvalid, got %v", tt.name, err)
+		}
+		if names, _ := m.ListScenarios(); len(names) != 0 {
+			t.Errorf("%s: expected nothing imported, got %v", tt.name, names)
+		}
+	}
+}
+
+func TestManager_ExportImport(t *testing.T) {
+	source := newTestManager(t)
+	source.SaveScenario("lights", lights("64"))
+	source.SaveScenario("shutters", []client.ScenarioStep{{Jobs: []client.ScenarioJob{{Index: 617, Value: "1"}}}})
+
+	exported, err := source.Export(nil)
+	if err != nil || len(exported.Scenarios) != 2 {
+		t.Fatalf("Expected both scenarios exported, got %+v, %v", exported, err)
+	}
+
+	target := newTestManager(t)
+	target.SaveScenario("lights", lights("1"))
+	saved, err := target.Import(exported)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	// Imported scenarios add a revision to those of the same name
+	if len(saved) != 2 || saved[0].Name != "lights" || saved[0].Revision != 2 || saved[1].Revision != 1 {
+		t.Errorf("Unexpected imported revisions %+v", saved)
+	}
+	if current, _ := target.LoadScenario("lights"); current.Steps[0].Jobs[0].Value != "64" {
+		t.Errorf("Expected the imported steps as the current ones, got %+v", current.Steps)
+	}
+
+	if _, err := source.Export([]string{"unknown"}); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected exporting an unknown scenario to fail, got %v", err)
+	}
+}
@@ -7,19 +7,61 @@ export interface Emulator {
     Serial: string;
     Matricule: string;
     ServerURL: string;
+    Mode: EmulatorMode;
+    Profile: string;
     Active: boolean;
+    TargetIndices: number[] | null;
     Values: Record<string, string>;
+    IsConnected: boolean;
+    OfferedVersion: string;
+    Phase: 'idle' | 'serverinfos' | 'mystatus' | 'myactions' | 'failed';
+    Scenario: ScenarioResult | null;
     History: string[];
 }
 
+// 'firmware' sends the exact bytes of the BP_MQX_ETH client on a raw socket
+export type EmulatorMode = 'http' | 'firmware';
+
 export interface ScenarioJob {
     index: number;
     value: string;
 }
 
+// Expects an index to hold a value on the client's table or in the server's store
+// within: milliseconds given to the value to arrive, 0 checks it once
+export interface Condition {
+    on: 'client' | 'server';
+    index: number;
+    equals: string;
+    within?: number;
+}
+
 export interface ScenarioStep {
     jobs: ScenarioJob[];
     delay: number;
+    faults?: FaultProfile;
+    inject?: ScenarioJob[];
+    wait?: Condition;
+    expect?: Condition[];
+}
+
+export interface CheckResult {
+    step: number;
+    kind: 'wait' | 'expect';
+    condition: Condition;
+    passed: boolean;
+    actual: string;
+    elapsed_ms: number;
+    error?: string;
+}
+
+export interface ScenarioResult {
+    steps: number;
+    status: 'running' | 'passed' | 'failed' | 'aborted';
+    started: string;
+    finished: string;
+    checks: CheckResult[];
+    error?: string;
 }
 
 export const getClients = async (): Promise<Emulator[]> => {
@@ -32,14 +74,65 @@ export const getClient = async (id: string): Promise<Emulator> => {
     return response.data;
 };
 
-export const startSimulation = async (count: number, serverIP: string = 'localhost', serverPort: string = '80', startupScenario: string = '') => {
-    let url = `${API_BASE_URL}/simulation/start?count=${count}&serverIP=${serverIP}&serverPort=${serverPort}`;
+// How the clients reach the server's admin API, for inject steps and server checks
+export interface AdminAccess {
+    url: string;    // '' for the server above, when it serves the admin API on the same port
+    token: string;  // Sent as a Bearer token; '' sends the clients' own credentials
+    client: string; // ID the server files the clients under; '' for their credential ID
+}
+
+// profiles mixes device profiles with their weights, such as 'flat:50,house:30,alarm:20'; '' starts default ones
+export const startSimulation = async (count: number, serverIP: string = 'localhost', serverPort: string = '80', startupScenario: string = '', mode: EmulatorMode = 'http', profiles: string = '', admin?: AdminAccess) => {
+    let url = `${API_BASE_URL}/simulation/start?count=${count}&serverIP=${serverIP}&serverPort=${serverPort}&mode=${mode}`;
     if (startupScenario) {
         url += `&startupScenario=${encodeURIComponent(startupScenario)}`;
     }
+    if (profiles) {
+        url += `&profiles=${encodeURIComponent(profiles)}`;
+    }
+    if (admin?.url) {
+        url += `&adminURL=${encodeURIComponent(admin.url)}`;
+    }
+    if (admin?.token) {
+        url += `&adminToken=${encodeURIComponent(admin.token)}`;
+    }
+    if (admin?.client) {
+        url += `&adminClient=${encodeURIComponent(admin.client)}`;
+    }
     await axios.post(url);
 };
 
+// Moves the value of index by step, at random, every every_ms, between min and max
+export interface Drift {
+    index: number;
+    min: number;
+    max: number;
+    step: number;
+    every_ms: number;
+}
+
+// Writes set after_ms once the server has written a non-zero value to index; with bits, only
+// a command for one of those outputs counts
+export interface DeviceResponse {
+    index: number;
+    bits?: number;
+    after_ms: number;
+    set: Record<string, string>;
+}
+
+export interface DeviceProfile {
+    name: string;
+    description: string;
+    seed: Record<string, string>;
+    drifts?: Drift[];
+    responses?: DeviceResponse[];
+}
+
+export const getProfiles = async (): Promise<DeviceProfile[]> => {
+    const response = await axios.get(`${API_BASE_URL}/profiles`);
+    return response.data;
+};
+
 export const stopSimulation = async () => {
     await axios.post(`${API_BASE_URL}/simulation/stop`);
 };
@@ -54,8 +147,35 @@ export const executeScenario = async (id: string, steps: ScenarioStep[], useMock
     await axios.post(`${API_BASE_URL}/clients/${id}/${endpoint}`, steps);
 };
 
-export const saveScenario = async (name: string, steps: ScenarioStep[]) => {
-    await axios.post(`${API_BASE_URL}/scenarios`, { name, steps });
+export const getScenarioResult = async (id: string): Promise<ScenarioResult> => {
+    const response = await axios.get(`${API_BASE_URL}/clients/${id}/scenario`);
+    return response.data;
+};
+
+export interface SavedScenario {
+    name: string;
+    revision: number;
+    saved_at: string;
+    steps: ScenarioStep[];
+}
+
+export interface ScenarioRevision {
+    revision: number;
+    saved_at: string;
+    steps: number;
+}
+
+export interface ScenarioBundle {
+    format: 'essensys-scenarios';
+    version: number;
+    exported_at: string;
+    scenarios: SavedScenario[];
+}
+
+// Each save keeps the previous revisions
+export const saveScenario = async (name: string, steps: ScenarioStep[]): Promise<{ name: string, revision: number }> => {
+    const response = await axios.post(`${API_BASE_URL}/scenarios`, { name, steps });
+    return response.data;
 };
 
 export const getScenarios = async (): Promise<string[]> => {
@@ -63,7 +183,132 @@ export const getScenarios = async (): Promise<string[]> => {
     return response.data;
 };
 
-export const loadScenario = async (name: string): Promise<{ name: string, steps: ScenarioStep[] }> => {
-    const response = await axios.get(`${API_BASE_URL}/scenarios/${name}`);
+export const loadScenario = async (name: string): Promise<SavedScenario> => {
+    const response = await axios.get(`${API_BASE_URL}/scenarios/${encodeURIComponent(name)}`);
+    return response.data;
+};
+
+export const getScenarioRevisions = async (name: string): Promise<ScenarioRevision[]> => {
+    const response = await axios.get(`${API_BASE_URL}/scenarios/${encodeURIComponent(name)}/revisions`);
+    return response.data;
+};
+
+export const loadScenarioRevision = async (name: string, revision: number): Promise<SavedScenario> => {
+    const response = await axios.get(`${API_BASE_URL}/scenarios/${encodeURIComponent(name)}/revisions/${revision}`);
+    return response.data;
+};
+
+// No names exports every scenario
+export const exportScenarios = async (names: string[] = []): Promise<ScenarioBundle> => {
+    const query = names.map(name => `name=${encodeURIComponent(name)}`).join('&');
+    const response = await axios.get(`${API_BASE_URL}/scenario-bundle${query ? `?${query}` : ''}`);
+    return response.data;
+};
+
+// Nothing is imported when one scenario of the bundle is invalid
+export const importScenarios = async (bundle: ScenarioBundle) => {
+    const response = await axios.post(`${API_BASE_URL}/scenario-bundle`, bundle);
+    return response.data;
+};
+
+// apiErrorMessage returns the {"error": ...} message of a failed call
+export const apiErrorMessage = (e: unknown): string => {
+    if (axios.isAxiosError(e) && e.response?.data?.error) {
+        return e.response.data.error;
+    }
+    return e instanceof Error ? e.message : String(e);
+};
+
+// Probability of each fault per request, from 0 to 1; {} clears all faults
+export interface FaultProfile {
+    drop_rate?: number;
+    delay_rate?: number;
+    delay_ms?: number;
+    fragment_rate?: number;
+    duplicate_rate?: number;
+    reorder_acks_rate?: number;
+    dns_failure_rate?: number;
+    clock_skew?: number;
+}
+
+// Outcome counts (status code, closed, timeout, dropped...) per fault
+export type FaultStats = Record<string, Record<string, number>>;
+
+export interface FaultsState {
+    profile: FaultProfile;
+    stats: FaultStats;
+}
+
+export const getFleetFaults = async (): Promise<FaultsState> => {
+    const response = await axios.get(`${API_BASE_URL}/faults`);
+    return response.data;
+};
+
+export const setFleetFaults = async (profile: FaultProfile): Promise<FaultsState> => {
+    const response = await axios.put(`${API_BASE_URL}/faults`, profile);
+    return response.data;
+};
+
+export const resetFaultStats = async () => {
+    await axios.delete(`${API_BASE_URL}/faults/stats`);
+};
+
+export const getClientFaults = async (id: string): Promise<FaultsState> => {
+    const response = await axios.get(`${API_BASE_URL}/clients/${id}/faults`);
+    return response.data;
+};
+
+export const setClientFaults = async (id: string, profile: FaultProfile): Promise<FaultsState> => {
+    const response = await axios.put(`${API_BASE_URL}/clients/${id}/faults`, profile);
     return response.data;
 };
+
+// Latencies in milliseconds; errors are requests without a 2xx response, timeouts included
+export interface EndpointReport {
+    endpoint: string;
+    requests: number;
+    errors: number;
+    timeouts: number;
+    error_rate: number;
+    timeout_rate: number;
+    p50_ms: number;
+    p95_ms: number;
+    p99_ms: number;
+    max_ms: number;
+    outcomes: Record<string, number>;
+}
+
+export interface TimelinePoint {
+    time: string;
+    requests: number;
+    errors: number;
+    timeouts: number;
+}
+
+export interface LoadReport {
+    start: string;
+    end: string;
+    duration_s: number;
+    throughput_rps: number;
+    overall: EndpointReport;
+    endpoints: EndpointReport[];
+    timeline: TimelinePoint[];
+}
+
+export const getReport = async (): Promise<LoadReport> => {
+    const response = await axios.get(`${API_BASE_URL}/report`);
+    return response.data;
+};
+
+export const getClientReport = async (id: string): Promise<LoadReport> => {
+    const response = await axios.get(`${API_BASE_URL}/clients/${id}/report`);
+    return response.data;
+};
+
+export const resetReport = async () => {
+    await axios.delete(`${API_BASE_URL}/report`);
+};
+
+// Links for the browser to download the exports
+export const reportCsvUrl = `${API_BASE_URL}/report?format=csv`;
+export const timelineCsvUrl = `${API_BASE_URL}/report/timeline?format=csv`;
@@ -1,6 +1,6 @@
 import React, { useEffect, useState } from 'react';
-import { getClients, startSimulation, getScenarios, stopSimulation, stopClient } from '../api';
-import type { Emulator } from '../api';
+import { getClients, startSimulation, getScenarios, stopSimulation, stopClient, getProfiles } from '../api';
+import type { Emulator, EmulatorMode, DeviceProfile, AdminAccess } from '../api';
 
 interface ClientListProps {
     onSelectClient: (id: string) => void;
@@ -11,6 +11,12 @@ const ClientList: React.FC<ClientListProps> = ({ onSelectClient }) => {
     const [count, setCount] = useState(1);
     const [serverIP, setServerIP] = useState('localhost');
     const [serverPort, setServerPort] = useState('8090');
+    const [mode, setMode] = useState<EmulatorMode>('http');
+    // Device profiles with their weights, such as flat:50,house:30,alarm:20
+    const [profiles, setProfiles] = useState('');
+    const [availableProfiles, setAvailableProfiles] = useState<DeviceProfile[]>([]);
+    // Admin API used by inject steps and server checks
+    const [admin, setAdmin] = useState<AdminAccess>({ url: '', token: '', client: '' });
 
     // Scenarios
     const [savedScenarios, setSavedScenarios] = useState<string[]>([]);
@@ -39,13 +45,14 @@ const ClientList: React.FC<ClientListProps> = ({ onSelectClient }) => {
     useEffect(() => {
         fetchClients();
         fetchScenarios();
+        getProfiles().then(setAvailableProfiles).catch(e => console.error("Failed to fetch profiles", e));
         const interval = setInterval(fetchClients, 2000);
         return () => clearInterval(interval);
     }, []);
 
     const handleStart = async () => {
         try {
-            await startSimulation(count, serverIP, serverPort, startupScenario);
+            await startSimulation(count, serverIP, serverPort, startupScenario, mode, profiles, admin);
             alert(`Started batch of ${count} clients connecting to ${serverIP}:${serverPort} with scenario: ${startupScenario || 'None'}`);
             fetchClients();
         } catch (e: any) {
@@ -55,7 +62,7 @@ const ClientList: React.FC<ClientListProps> = ({ onSelectClient }) => {
 
     const handleAddSingle = async () => {
         try {
-            await startSimulation(1, serverIP, serverPort, startupScenario);
+            await startSimulation(1, serverIP, serverPort, startupScenario, mode, profiles, admin);
             // No alert for single add to keep it quick, or maybe small toast?
             // alert("Added 1 client"); 
             fetchClients();
@@ -90,6 +97,64 @@ const ClientList: React.FC<ClientListProps> = ({ onSelectClient }) => {
 
                 <div className="h-8 w-px bg-gray-600 mx-2"></div>
 
+                <div className="flex items-center gap-2">
+                    <label className="text-sm text-gray-400">Mode:</label>
+                    <select
+                        value={mode}
+                        onChange={(e) => setMode(e.target.value as EmulatorMode)}
+                        className="p-2 rounded bg-gray-700 text-white border border-gray-600 w-28 focus:outline-none focus:border-blue-500 text-sm"
+                    >
+                        <option value="http">HTTP</option>
+                        <option value="firmware">Firmware</option>
+                    </select>
+                </div>
+
+                <div className="h-8 w-px bg-gray-600 mx-2"></div>
+
+                <div className="flex items-center gap-2">
+                    <label className="text-sm text-gray-400">Profiles:</label>
+                    <input
+                        type="text"
+                        value={profiles}
+                        onChange={(e) => setProfiles(e.target.value)}
+                        className="p-2 rounded bg-gray-700 text-white border border-gray-600 w-48 focus:outline-none focus:border-blue-500 text-sm"
+                        placeholder="flat:50,house:30,alarm:20"
+                        title={availableProfiles.map(p => `${p.name}: ${p.description}`).join('\n')}
+                    />
+                </div>
+
+                <div className="h-8 w-px bg-gray-600 mx-2"></div>
+
+                <div className="flex items-center gap-2">
+                    <label className="text-sm text-gray-400">Admin API:</label>
+                    <input
+                        type="text"
+                        value={admin.url}
+                        onChange={(e) => setAdmin({ ...admin, url: e.target.value })}
+                        className="p-2 rounded bg-gray-700 text-white border border-gray-600 w-48 focus:outline-none focus:border-blue-500 text-sm"
+                        placeholder="same as server"
+                        title="URL of the admin API, if the server runs it on its own port"
+                    />
+                    <input
+                        type="password"
+                        value={admin.token}
+                        onChange={(e) => setAdmin({ ...admin, token: e.target.value })}
+                        className="p-2 rounded bg-gray-700 text-white border border-gray-600 w-32 focus:outline-none focus:border-blue-500 text-sm"
+                        placeholder="admin token"
+                        title="Sent as a Bearer token when the server requires admin tokens"
+                    />
+                    <input
+                        type="text"
+                        value={admin.client}
+                        onChange={(e) => setAdmin({ ...admin, client: e.target.value })}
+                        className="p-2 rounded bg-gray-700 text-white border border-gray-600 w-24 focus:outline-none focus:border-blue-500 text-sm"
+                        placeholder="client ID"
+                        title="ID the server files the clients under: empty for their credentials, default if the server does not authenticate boxes"
+                    />
+                </div>
+
+                <div className="h-8 w-px bg-gray-600 mx-2"></div>
+
                 <div className="flex items-center gap-2">
                     <label className="text-sm text-gray-400">Startup Scen.:</label>
                     <select
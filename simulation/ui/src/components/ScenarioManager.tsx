@@ -1,5 +1,5 @@
 import React, { useState, useEffect } from 'react';
-import { saveScenario, loadScenario, getScenarios } from '../api';
+import { saveScenario, loadScenario, getScenarios, apiErrorMessage } from '../api';
 import type { ScenarioStep, ScenarioJob } from '../api';
 
 // Duplicate definition if not exported, better to Refactor later.
@@ -128,18 +128,19 @@ const ScenarioManager: React.FC = () => {
             setScenarioName(data.name);
             setScenarioSteps(data.steps || []);
         } catch (e) {
-            alert("Error loading scenario");
+            alert(`Error loading scenario: ${apiErrorMessage(e)}`);
         }
     };
 
     const save = async () => {
         if (!scenarioName) return alert("Enter a name");
         try {
-            await saveScenario(scenarioName, scenarioSteps);
-            alert("Saved!");
+            const saved = await saveScenario(scenarioName, scenarioSteps);
+            alert(`Saved revision ${saved.revision}`);
             refreshList();
         } catch (e) {
-            alert("Error saving");
+            // The server explains why: invalid name, index outside the catalog, value out of range...
+            alert(`Error saving: ${apiErrorMessage(e)}`);
         }
     };
 